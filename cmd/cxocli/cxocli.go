@@ -1,6 +1,8 @@
 package main
 
 import (
+	"compress/flate"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
@@ -8,8 +10,10 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/peterh/liner"
 
@@ -46,6 +50,12 @@ var (
 		"listening_address",
 		"roots",
 		"tree",
+		"diff",
+		"backup",
+		"restore",
+		"fsck",
+		"object",
+		"misc",
 		"terminate",
 		"quit",
 		"exit",
@@ -57,8 +67,13 @@ func main() {
 	var (
 		address string
 		execute string
+		dbPath  string
+		lsm     bool
+		keyFile string
+		blobDir string
+		compr   bool
 
-		rpc *node.RPCClient
+		cl  client
 		err error
 
 		line      *liner.State
@@ -79,6 +94,26 @@ func main() {
 		"e",
 		"",
 		"execute command and exit")
+	flag.StringVar(&dbPath,
+		"db",
+		"",
+		"inspect database of a stopped node (read-only, offline)")
+	flag.BoolVar(&lsm,
+		"lsm",
+		false,
+		"the -db is LSM-tree database (directory)")
+	flag.StringVar(&keyFile,
+		"db-key-file",
+		"",
+		"path to file with passphrase of encrypted -db")
+	flag.StringVar(&blobDir,
+		"blob-dir",
+		"",
+		"data directory with large values of the -db stored as files")
+	flag.BoolVar(&compr,
+		"compressed",
+		false,
+		"values of objects of the -db are compressed")
 
 	flag.BoolVar(&help,
 		"h",
@@ -93,21 +128,33 @@ func main() {
 		return
 	}
 
-	if address == "" {
-		fmt.Fprintln(os.Stderr, "empty address")
-		code = 1
-		return
+	if dbPath != "" {
+		conf := node.NewConfig()
+		conf.DBPath = dbPath
+		conf.DataDir = blobDir
+		conf.LSMDB = lsm
+		conf.DBKeyFile = keyFile
+		if blobDir != "" {
+			conf.BlobThreshold = 1 // any, values are not written
+		}
+		if compr {
+			conf.CompressLevel = flate.DefaultCompression // any
+		}
+		cl, err = openOffline(conf)
+	} else if address == "" {
+		err = errors.New("empty address")
+	} else {
+		cl, err = node.NewRPCClient(address)
 	}
-
-	if rpc, err = node.NewRPCClient(address); err != nil {
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		code = 1
 		return
 	}
-	defer rpc.Close()
+	defer cl.Close()
 
 	if execute != "" {
-		_, err = executeCommand(execute, rpc)
+		_, err = executeCommand(execute, cl)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			code = 1
@@ -145,7 +192,7 @@ func main() {
 			code = 1
 			return
 		}
-		terminate, err = executeCommand(cmd, rpc)
+		terminate, err = executeCommand(cmd, cl)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 		}
@@ -213,14 +260,23 @@ func args(ss []string) (string, error) {
 	return "", errTooManyArguments
 }
 
-func executeCommand(command string, rpc *node.RPCClient) (terminate bool,
+func executeCommand(command string, cl client) (terminate bool,
 	err error) {
 
 	ss := strings.Fields(command)
 	if len(ss) == 0 {
 		return
 	}
-	switch strings.ToLower(ss[0]) {
+	name := strings.ToLower(ss[0])
+
+	rpc, online := cl.(*node.RPCClient)
+	ofl, _ := cl.(*offline)
+
+	if !online && !offlineCommands[name] {
+		return false, errOnlineCommand
+	}
+
+	switch name {
 	case "subscribe":
 		err = subscribe(rpc, ss)
 	case "subscribe_to":
@@ -230,9 +286,9 @@ func executeCommand(command string, rpc *node.RPCClient) (terminate bool,
 	case "unsubscribe_from":
 		err = unsubscribeFrom(rpc, ss)
 	case "feeds":
-		err = feeds(rpc)
+		err = feeds(cl)
 	case "stat":
-		err = stat(rpc)
+		err = stat(cl)
 	case "connections":
 		err = connections(rpc)
 	case "incoming_connections":
@@ -246,9 +302,26 @@ func executeCommand(command string, rpc *node.RPCClient) (terminate bool,
 	case "listening_address":
 		err = listeningAddress(rpc)
 	case "roots":
-		err = roots(rpc, ss)
+		err = roots(cl, ss)
 	case "tree":
-		err = tree(rpc, ss)
+		err = tree(cl, ss)
+	case "diff":
+		err = diff(cl, ss)
+	case "backup":
+		err = backup(rpc, ss)
+	case "restore":
+		err = restore(rpc, ss)
+	case "fsck":
+		err = fsck(rpc, ss)
+	case "object", "misc":
+		if online {
+			return false, errOfflineCommand
+		}
+		if name == "object" {
+			err = object(ofl, ss)
+		} else {
+			err = misc(ofl, ss)
+		}
 	case "terminate":
 		err = term(rpc)
 	// help and exit
@@ -290,11 +363,37 @@ func showHelp() {
     disconnect from
   listening_address
     print listening address
-  roots <public key>
-    print brief information about all root objects of given feed
-  tree <pub key> [seq]
+  roots <public key> [--since <duration>]
+    print brief information about all root objects of given feed,
+    if the --since is given (e.g. 2h or 30m), then only roots
+    created during the duration are printed
+  tree <pub key> [seq] [path]
     print root by public key and seq number, if the seq omited then
-    last full root printed
+    last full root printed; if the path is given, then only values
+    found by the path are printed, e.g. Refs[0].Users[Age>30].Name;
+    the path starts with Refs and contains .Field, .*, [index], [-1],
+    [*], [#hash prefix] and [Field op literal] selectors
+  diff <pub key> <seq a> <seq b>
+    print changes between two roots of a feed: added (+), deleted (-)
+    and changed (~) elements, references and values with paths
+  backup <name> [since]
+    write database of the node to archive with given name, if the
+    since (seq number) is given, then only roots newer then it and
+    their new objects are written; the archive is file in backup
+    directory of the node, the node must allow remote backup
+  restore <name>
+    load database of the node from archive with given name in
+    backup directory of the node
+  fsck [repair]
+    check database of the node; in repair mode broken objects are
+    removed and broken full roots are marked as non-full to be
+    filled again, the node must allow remote repair
+  object <hash>
+    print object by hash and its references counter (offline only)
+  misc [prefix [namespace]]
+    list keys of misc bucket that starts with given prefix, and
+    lengths of values; the default namespace is used if namespace
+    is not given (offline only)
   terminate
     terminate server if allowed
   help
@@ -302,6 +401,10 @@ func showHelp() {
   quit or exit
     leave the cli
 
+  Use -db flag to inspect database of a stopped node. In this
+  offline mode the database is opened read-only, and only feeds,
+  stat, roots, tree, diff, object and misc commands are available.
+
 `)
 }
 
@@ -384,9 +487,9 @@ func unsubscribeFrom(rpc *node.RPCClient, ss []string) (err error) {
 	return
 }
 
-func feeds(rpc *node.RPCClient) (err error) {
+func feeds(cl client) (err error) {
 	var list []cipher.PubKey
-	if list, err = rpc.Feeds(); err != nil {
+	if list, err = cl.Feeds(); err != nil {
 		return
 	}
 	if len(list) == 0 {
@@ -399,24 +502,49 @@ func feeds(rpc *node.RPCClient) (err error) {
 	return
 }
 
-func stat(rpc *node.RPCClient) (err error) {
+func stat(cl client) (err error) {
 	var stat node.Stat
-	if stat, err = rpc.Stat(); err != nil {
+	if stat, err = cl.Stat(); err != nil {
 		return
 	}
 	fmt.Fprintln(out, "  ----")
 	fmt.Fprintln(out, "  Objects:", stat.Data.Objects)
 	fmt.Fprintln(out, "  Space:  ", stat.Data.Space.String())
+	fmt.Fprintln(out, "  Physical space:", stat.Data.PhysicalSpace.String())
+	if !stat.Data.Quota.IsZero() {
+		fmt.Fprintln(out, "  Quota:  ", stat.Data.Quota.String())
+	}
 	fmt.Fprintln(out, "  ----")
 	for pk, fs := range stat.Data.Feeds {
 		fmt.Fprintln(out, "  -", pk.Hex())
 		fmt.Fprintln(out, "    Root Objects: ", fs.Roots)
 		fmt.Fprintln(out, "    Space:        ", fs.Space.String())
+		if !fs.Quota.IsZero() {
+			if fs.Quota.MaxSpace > 0 {
+				fmt.Fprintln(out, "    Objects Space:", fs.ObjectsSpace.String())
+			}
+			fmt.Fprintln(out, "    Quota:        ", fs.Quota.String())
+		}
+	}
+	if len(stat.Data.Namespaces) > 0 {
+		fmt.Fprintln(out, "  ----")
+		namespaces := make([]string, 0, len(stat.Data.Namespaces))
+		for ns := range stat.Data.Namespaces {
+			namespaces = append(namespaces, ns)
+		}
+		sort.Strings(namespaces)
+		for _, ns := range namespaces {
+			nss := stat.Data.Namespaces[ns]
+			fmt.Fprintf(out, "  - misc %q: %d keys, %s\n", ns, nss.Keys,
+				nss.Space.String())
+		}
 	}
 	fmt.Fprintln(out, "  ----")
 	fmt.Fprintln(out, "  Registries:    ", stat.CXO.Registries)
 	fmt.Fprintln(out, "  Save    (avg): ", stat.CXO.Save)
 	fmt.Fprintln(out, "  Cleanup (avg): ", stat.CXO.CleanUp)
+	fmt.Fprintf(out, "  Cache:          %d hits, %d misses (%.2f)\n",
+		stat.CXO.CacheHits, stat.CXO.CacheMisses, stat.CXO.CacheRatio())
 	fmt.Fprintln(out, "  ----")
 	return
 }
@@ -499,13 +627,41 @@ func listeningAddress(rpc *node.RPCClient) (err error) {
 	return
 }
 
-func roots(rpc *node.RPCClient, ss []string) (err error) {
+func roots(cl client, ss []string) (err error) {
+
 	var pub cipher.PubKey
-	if pub, err = publicKeyArg(ss); err != nil {
+	var since time.Duration
+
+	switch len(ss) {
+	case 0, 1:
+		return errors.New(
+			"to few arguments: want <public key> [--since <duration>]")
+	case 2:
+	case 4:
+		if ss[2] != "--since" {
+			return fmt.Errorf("unknown flag %q", ss[2])
+		}
+		if since, err = time.ParseDuration(ss[3]); err != nil {
+			return
+		}
+		if since <= 0 {
+			return fmt.Errorf("invalid duration %s", since)
+		}
+	default:
+		return errors.New(
+			"wrong arguments: want <public key> [--since <duration>]")
+	}
+	if pub, err = cipher.PubKeyFromHex(ss[1]); err != nil {
 		return
 	}
+
 	var ris []node.RootInfo
-	if ris, err = rpc.Roots(pub); err != nil {
+	if since == 0 {
+		ris, err = cl.Roots(pub)
+	} else {
+		ris, err = cl.RootsByTime(pub, time.Now().Add(-since), time.Time{})
+	}
+	if err != nil {
 		return
 	}
 	if len(ris) == 0 {
@@ -521,34 +677,181 @@ func roots(rpc *node.RPCClient, ss []string) (err error) {
 	return
 }
 
-func tree(rpc *node.RPCClient, ss []string) (err error) {
+func tree(cl client, ss []string) (err error) {
 
 	var pk cipher.PubKey
 	var seq uint64
-	var lsatFull bool
+	var lsatFull = true
+	var path string
+
+	if len(ss) < 2 {
+		return errors.New("to few arguments: want <pub key> [seq] [path]")
+	}
+	if pk, err = cipher.PubKeyFromHex(ss[1]); err != nil {
+		return
+	}
+	rest := ss[2:]
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "Refs") {
+		if seq, err = strconv.ParseUint(rest[0], 10, 64); err != nil {
+			return
+		}
+		lsatFull, rest = false, rest[1:]
+	}
+	if len(rest) > 0 {
+		// the path can contain spaces inside quoted strings
+		path = strings.Join(rest, " ")
+	}
+	var tree string
+	if tree, err = cl.TreePath(pk, seq, lsatFull, path); err != nil {
+		return
+	}
+	fmt.Fprintln(out, tree)
+	return
+}
+
+func diff(cl client, ss []string) (err error) {
+
+	if len(ss) != 4 {
+		return errors.New("wrong arguments: want <pub key> <seq a> <seq b>")
+	}
+
+	var pk cipher.PubKey
+	if pk, err = cipher.PubKeyFromHex(ss[1]); err != nil {
+		return
+	}
+	var seqA, seqB uint64
+	if seqA, err = strconv.ParseUint(ss[2], 10, 64); err != nil {
+		return
+	}
+	if seqB, err = strconv.ParseUint(ss[3], 10, 64); err != nil {
+		return
+	}
+
+	var diff string
+	if diff, err = cl.Diff(pk, seqA, seqB); err != nil {
+		return
+	}
+	fmt.Fprintln(out, diff)
+	return
+}
+
+func backup(rpc *node.RPCClient, ss []string) (err error) {
+
+	var since uint64
+	var incremental bool
 
 	switch len(ss) {
 	case 0, 1:
-		return errors.New("to few arguments: want <pub key> [seq]")
+		return errors.New("to few arguments: want <name> [since]")
 	case 2:
-		lsatFull = true
 	case 3:
+		if since, err = strconv.ParseUint(ss[2], 10, 64); err != nil {
+			return
+		}
+		incremental = true
 	default:
-		return errors.New("to many arguments: want <pub key> [seq]")
+		return errors.New("to many arguments: want <name> [since]")
 	}
-	if pk, err = cipher.PubKeyFromHex(ss[1]); err != nil {
+	if err = rpc.Backup(ss[1], incremental, since); err != nil {
 		return
 	}
-	if lsatFull == false {
-		if seq, err = strconv.ParseUint(ss[2], 10, 64); err != nil {
-			return
+	fmt.Fprintln(out, "  saved")
+	return
+}
+
+func restore(rpc *node.RPCClient, ss []string) (err error) {
+	var path string
+	if path, err = args(ss); err != nil {
+		return
+	}
+	if err = rpc.Restore(path); err != nil {
+		return
+	}
+	fmt.Fprintln(out, "  restored")
+	return
+}
+
+func fsck(rpc *node.RPCClient, ss []string) (err error) {
+
+	var repair bool
+
+	switch len(ss) {
+	case 1:
+	case 2:
+		if ss[1] != "repair" {
+			return fmt.Errorf("unknown argument %q, want [repair]", ss[1])
 		}
+		repair = true
+	default:
+		return errors.New("to many arguments: want [repair]")
 	}
-	var tree string
-	if tree, err = rpc.Tree(pk, seq, lsatFull); err != nil {
+
+	var reply node.CheckReply
+	if reply, err = rpc.Check(repair); err != nil {
 		return
 	}
-	fmt.Fprintln(out, tree)
+	for _, p := range reply.Problems {
+		fmt.Fprintln(out, "  ", p)
+	}
+	for _, rr := range reply.Orphaned {
+		fmt.Fprintln(out, "   orphaned registry", rr)
+	}
+	if len(reply.Problems) == 0 && len(reply.Orphaned) == 0 {
+		fmt.Fprintln(out, "  ok")
+	}
+	if repair {
+		fmt.Fprintf(out, "  unmarked roots: %d, removed objects: %d\n",
+			reply.Unmarked, reply.Removed)
+	}
+	return
+}
+
+func object(ofl *offline, ss []string) (err error) {
+	var hs string
+	if hs, err = args(ss); err != nil {
+		return
+	}
+	var hash cipher.SHA256
+	if hash, err = cipher.SHA256FromHex(hs); err != nil {
+		return
+	}
+	var val []byte
+	var rc uint32
+	if val, rc, err = ofl.Object(hash); err != nil {
+		return
+	}
+	if val == nil {
+		fmt.Fprintln(out, "  not found")
+		return
+	}
+	fmt.Fprintln(out, "  refs:", rc)
+	fmt.Fprintln(out, "  size:", len(val))
+	fmt.Fprint(out, hex.Dump(val))
+	return
+}
+
+func misc(ofl *offline, ss []string) (err error) {
+	var prefix []byte
+	var ns string
+	switch len(ss) {
+	case 1:
+	case 3:
+		ns = ss[2]
+		fallthrough
+	case 2:
+		prefix = []byte(ss[1])
+	default:
+		return errors.New("to many arguments: want [prefix [namespace]]")
+	}
+	var n int
+	err = ofl.Misc(ns, prefix, func(key, value []byte) (_ error) {
+		fmt.Fprintf(out, "  - %q: %d bytes\n", key, len(value))
+		n++
+		return
+	})
+	if err == nil && n == 0 {
+		fmt.Fprintln(out, "  no keys")
+	}
 	return
 }
 
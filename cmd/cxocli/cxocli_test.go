@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -218,6 +220,44 @@ func Test_roots(t *testing.T) {
 			t.Error("wrong output")
 		}
 
+		// since
+
+		testOut.Reset()
+
+		err = roots(cl, []string{
+			"roots",
+			pk.Hex(),
+			"--since",
+			"1h",
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		out = strings.Replace(testOut.String(), "\r\n", "\n", -1)
+		if out != want {
+			t.Error("wrong output")
+		}
+
+	})
+
+	t.Run("wrong arguments", func(t *testing.T) {
+		defer testOut.Reset()
+
+		pk, _ := cipher.GenerateKeyPair()
+
+		for _, ss := range [][]string{
+			{"roots"},
+			{"roots", pk.Hex(), "--since"},
+			{"roots", pk.Hex(), "--until", "1h"},
+			{"roots", pk.Hex(), "--since", "1y"},
+			{"roots", pk.Hex(), "--since", "-1h"},
+		} {
+			if roots(nil, ss) == nil {
+				t.Error("missing error:", ss)
+			}
+		}
 	})
 }
 
@@ -299,6 +339,123 @@ func Test_tree(t *testing.T) {
 		t.Logf("%q", out)
 		t.Logf("%q", fmt.Sprintf(want, pack.Root().Short()))
 	}
+
+	// path (last full root)
+	testOut.Reset()
+
+	err = tree(cl, []string{
+		"tree",
+		pack.Root().Pub.Hex(),
+		`Refs[0].Users[Name="Jim`,
+		`Cobley"].Age`,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	const wantPath = `(root) %s Refs[0].Users[Name="Jim Cobley"].Age
+└── Refs[0].Users[1].Age {915fbaa}: 80
+
+`
+
+	out = strings.Replace(testOut.String(), "\r\n", "\n", -1)
+
+	if out != fmt.Sprintf(wantPath, pack.Root().Short()) {
+		t.Error("wrong output")
+		t.Logf("%q", out)
+		t.Logf("%q", fmt.Sprintf(wantPath, pack.Root().Short()))
+	}
+
+	if err = tree(cl, []string{"tree", pack.Root().Pub.Hex(), "0",
+		"Refs["}); err == nil {
+
+		t.Error("missing error")
+	}
+}
+
+func Test_backup(t *testing.T) {
+	// backup(rpc, ss)
+	// restore(rpc, ss)
+
+	t.Run("allowed", func(t *testing.T) {
+		defer testOut.Reset()
+
+		dir, err := ioutil.TempDir("", "cxocli-backup")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		conf := newNodeConfig()
+		conf.RemoteBackup = true
+		conf.BackupDir = dir
+
+		n, err := launchNode(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer n.Close()
+
+		cl, err := node.NewRPCClient(n.RPCAddress())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = backup(cl, []string{"backup", "archive"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = os.Stat(filepath.Join(dir, "archive")); err != nil {
+			t.Error(err)
+		}
+		if err = restore(cl, []string{"restore", "archive"}); err != nil {
+			t.Fatal(err)
+		}
+		if testOut.String() != "  saved\n  restored\n" {
+			t.Errorf("wrong output %q", testOut.String())
+		}
+
+		for _, name := range []string{
+			"../archive",
+			"sub/archive",
+			filepath.Join(dir, "archive"),
+			"..",
+		} {
+			if err = backup(cl, []string{"backup", name}); err == nil {
+				t.Errorf("missing error for %q", name)
+			}
+			if err = restore(cl, []string{"restore", name}); err == nil {
+				t.Errorf("missing error for %q", name)
+			}
+		}
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		defer testOut.Reset()
+
+		n, err := launchNode(newNodeConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer n.Close()
+
+		cl, err := node.NewRPCClient(n.RPCAddress())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := backup(cl, []string{"backup", "archive"}); err == nil {
+			t.Error("misisng error")
+		} else if err.Error() != "not allowed" {
+			t.Error("wrong error:", err)
+		}
+		if err := restore(cl, []string{"restore", "archive"}); err == nil {
+			t.Error("misisng error")
+		} else if err.Error() != "not allowed" {
+			t.Error("wrong error:", err)
+		}
+	})
+
 }
 
 func Test_term(t *testing.T) {
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+	"github.com/skycoin/cxo/node"
+	"github.com/skycoin/cxo/skyobject"
+)
+
+// A client represents RPC client of a running node
+// or database of a node opened offline
+type client interface {
+	Feeds() (list []cipher.PubKey, err error)
+	Stat() (stat node.Stat, err error)
+	Roots(feed cipher.PubKey) (ris []node.RootInfo, err error)
+	RootsByTime(feed cipher.PubKey, from, to time.Time) (ris []node.RootInfo,
+		err error)
+	TreePath(pk cipher.PubKey, seq uint64, lastFull bool,
+		path string) (tree string, err error)
+	Diff(pk cipher.PubKey, seqA, seqB uint64) (diff string, err error)
+	Close() error
+}
+
+var (
+	errOnlineCommand  = errors.New("the command is not available offline")
+	errOfflineCommand = errors.New("the command is available offline only")
+)
+
+// commands available offline
+var offlineCommands = map[string]bool{
+	"feeds":  true,
+	"stat":   true,
+	"roots":  true,
+	"tree":   true,
+	"diff":   true,
+	"object": true,
+	"misc":   true,
+	"help":   true,
+	"quit":   true,
+	"exit":   true,
+}
+
+// offline is database of a node opened in read-only
+// mode, the database can't be used by the node (and
+// by other writers) at the same time
+type offline struct {
+	db data.DB
+	c  *skyobject.Container
+}
+
+// openOffline opens database of a node in read-only mode.
+// The database is built by node.NewDB using given configurations
+// of the node (LSM-tree, encryption, blobs and compression). A drive
+// database of an old version can't be opened, it must be migrated
+// by opening it in read-write mode first. A LSM-tree database has
+// no read-only mode, it must not be used by a running node
+func openOffline(sc node.Config) (o *offline, err error) {
+	opts := data.SafeDriveOptions()
+	if sc.DriveOptions != nil {
+		*opts = *sc.DriveOptions
+	}
+	opts.ReadOnly = true
+	sc.DriveOptions = opts
+	sc.InMemoryDB = false
+
+	var db data.DB
+	if db, err = node.NewDB(sc); err != nil {
+		return
+	}
+
+	conf := skyobject.NewConfig()
+	conf.ReadOnly = true
+	conf.CleanUp = 0
+
+	o = &offline{db: db, c: skyobject.NewContainer(db, conf)}
+	return
+}
+
+func (o *offline) Feeds() (list []cipher.PubKey, err error) {
+	err = o.db.View(func(tx data.Tv) (_ error) {
+		list = tx.Feeds().List()
+		return
+	})
+	return
+}
+
+func (o *offline) Stat() (stat node.Stat, err error) {
+	stat.Data = o.c.DBStat()
+	stat.CXO = o.c.Stat()
+	return
+}
+
+func (o *offline) Roots(feed cipher.PubKey) (ris []node.RootInfo, err error) {
+	err = o.db.View(func(tx data.Tv) (_ error) {
+		roots := tx.Feeds().Roots(feed)
+		if roots == nil {
+			return
+		}
+		return roots.Ascend(o.appendRootInfo(roots, &ris))
+	})
+	return
+}
+
+func (o *offline) RootsByTime(feed cipher.PubKey,
+	from, to time.Time) (ris []node.RootInfo, err error) {
+
+	var tn int64
+	if !to.IsZero() {
+		tn = to.UnixNano()
+	}
+	err = o.db.View(func(tx data.Tv) (_ error) {
+		roots := tx.Feeds().Roots(feed)
+		if roots == nil {
+			return
+		}
+		return roots.RangeTime(from.UnixNano(), tn,
+			o.appendRootInfo(roots, &ris))
+	})
+	return
+}
+
+func (o *offline) appendRootInfo(roots data.ViewRoots,
+	ris *[]node.RootInfo) func(rp *data.RootPack) error {
+
+	return func(rp *data.RootPack) (err error) {
+		var ri node.RootInfo
+		if ri, err = node.NewRootInfo(o.c, roots, rp); err != nil {
+			return
+		}
+		*ris = append(*ris, ri)
+		return
+	}
+}
+
+func (o *offline) TreePath(pk cipher.PubKey, seq uint64, lastFull bool,
+	path string) (tree string, err error) {
+
+	var root *skyobject.Root
+	if lastFull {
+		root, err = o.c.LastFull(pk)
+	} else {
+		root, err = o.c.Root(pk, seq)
+	}
+	if err != nil {
+		return
+	}
+	if path != "" {
+		return o.c.InspectQuery(root, path)
+	}
+	tree = o.c.Inspect(root)
+	return
+}
+
+func (o *offline) Diff(pk cipher.PubKey, seqA, seqB uint64) (diff string,
+	err error) {
+
+	return node.Diff(o.c, pk, seqA, seqB)
+}
+
+// Object returns object by hash and its references
+// counter, the value is nil if object not found
+func (o *offline) Object(hash cipher.SHA256) (val []byte, rc uint32,
+	err error) {
+
+	err = o.db.View(func(tx data.Tv) (_ error) {
+		objs := tx.Objects()
+		val, rc = objs.GetCopy(hash), objs.Refs(hash)
+		return
+	})
+	return
+}
+
+// Misc calls given function for every key of misc
+// bucket of given namespace that has given prefix
+func (o *offline) Misc(namespace string, prefix []byte, fn func(key,
+	value []byte) error) error {
+
+	return o.db.View(func(tx data.Tv) error {
+		cur := tx.Misc(namespace).PrefixCursor(prefix)
+		for key, value := cur.First(); key != nil; key, value = cur.Next() {
+			if err := fn(key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (o *offline) Close() (err error) {
+	if err = o.c.Close(); err != nil {
+		o.db.Close()
+		return
+	}
+	return o.db.Close()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+	"github.com/skycoin/cxo/node"
+	"github.com/skycoin/cxo/skyobject"
+)
+
+func testOffline(t *testing.T, conf node.Config) {
+
+	// fill database of a node
+
+	conf.Skyobject = skyobject.NewConfig()
+	conf.Skyobject.KeepRoots = true // for diff
+
+	n, err := launchNode(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pk, sk := cipher.GenerateKeyPair()
+	n.Subscribe(nil, pk)
+
+	cnt := n.Container()
+	pack, err := cnt.NewRoot(pk, sk, 0, cnt.CoreRegistry().Types())
+	if err != nil {
+		n.Close()
+		t.Fatal(err)
+	}
+	pack.Append(&User{Name: "Alice", Age: 21})
+	if _, err = pack.Save(); err != nil {
+		n.Close()
+		t.Fatal(err)
+	}
+	alice := pack.Root().Refs[0].Object
+	rootHash := pack.Root().Hash
+
+	// seq 1
+	if err = pack.SetRefByIndex(0, &User{Name: "Alice", Age: 22}); err != nil {
+		n.Close()
+		t.Fatal(err)
+	}
+	if _, err = pack.Save(); err != nil {
+		n.Close()
+		t.Fatal(err)
+	}
+
+	err = n.DB().Update(func(tx data.Tu) error {
+		err := tx.Misc("").Set([]byte("app:key"), []byte("value"))
+		if err != nil {
+			return err
+		}
+		return tx.Misc("app").Set([]byte("ns:key"), []byte("ns value"))
+	})
+	if err != nil {
+		n.Close()
+		t.Fatal(err)
+	}
+	if err = n.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// inspect the database offline
+
+	ofl, err := openOffline(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ofl.Close()
+
+	for _, c := range []struct {
+		cmd  string
+		want []string
+	}{
+		{"feeds", []string{pk.Hex()}},
+		{"stat", []string{"Objects:", "Root Objects:  2",
+			`misc "app": 1 keys`}},
+		{"roots " + pk.Hex(), []string{rootHash.Hex(), "fill: true"}},
+		{"tree " + pk.Hex(), []string{"Alice"}},
+		{"tree " + pk.Hex() + " 0 Refs[0].Age", []string{"Refs[0].Age", "21"}},
+		{"diff " + pk.Hex() + " 0 1", []string{"~ Refs[0] ",
+			"~ Refs[0].Age 21 -> 22"}},
+		{"diff " + pk.Hex() + " 1 1", []string{"<no changes>"}},
+		{"object " + alice.Hex(), []string{"refs: 1", "Alice"}},
+		{"object " + cipher.SHA256{}.Hex(), []string{"not found"}},
+		{"misc app:", []string{`"app:key": 5 bytes`}},
+		{"misc unknown", []string{"no keys"}},
+		{"misc ns: app", []string{`"ns:key": 8 bytes`}},
+		{"misc app: app", []string{"no keys"}},
+	} {
+		testOut.Reset()
+		if _, err := executeCommand(c.cmd, ofl); err != nil {
+			t.Errorf("%s: %v", c.cmd, err)
+			continue
+		}
+		for _, want := range c.want {
+			if !strings.Contains(testOut.String(), want) {
+				t.Errorf("%s: missing %q in output %q", c.cmd, want,
+					testOut.String())
+			}
+		}
+	}
+	testOut.Reset()
+
+	if _, err = executeCommand("connections", ofl); err != errOnlineCommand {
+		t.Error("wrong error:", err)
+	}
+
+	// read-only
+	err = ofl.db.Update(func(tx data.Tu) error {
+		return tx.Misc("").Set([]byte("key"), []byte("value"))
+	})
+	if err == nil {
+		t.Error("missing error")
+	}
+
+}
+
+func Test_offline(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("drive", func(t *testing.T) {
+		conf := newNodeConfig()
+		conf.InMemoryDB = false
+		conf.DataDir = ""
+		conf.DBPath = filepath.Join(dir, "cxo.db")
+		testOffline(t, conf)
+	})
+
+	t.Run("encrypted blobs compressed", func(t *testing.T) {
+		keyFile := filepath.Join(dir, "key")
+		err := ioutil.WriteFile(keyFile, []byte("passphrase\n"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf := newNodeConfig()
+		conf.InMemoryDB = false
+		conf.DataDir = filepath.Join(dir, "data")
+		conf.DBPath = filepath.Join(dir, "wrapped.db")
+		conf.DBKeyFile = keyFile
+		conf.BlobThreshold = 16
+		conf.CompressLevel = 1
+		testOffline(t, conf)
+	})
+
+}
@@ -0,0 +1,487 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+)
+
+// ArchiveVersion is version of archive format
+// produced by Dump and DumpIncremental
+const ArchiveVersion uint32 = 1
+
+// archive errors
+var (
+	// ErrMalformedArchive occurs when Restore reads invalid,
+	// truncated or not an archive at all
+	ErrMalformedArchive = errors.New("malformed archive")
+	// ErrArchiveChecksum occurs when Restore reads a corrupted record
+	ErrArchiveChecksum = errors.New("archive checksum mismatch")
+	// ErrArchiveRecordTooLarge occurs when Dump writes or Restore
+	// reads a record longer than MaxArchiveRecordLen
+	ErrArchiveRecordTooLarge = errors.New("archive record too large")
+)
+
+// MaxArchiveRecordLen is max length of payload of an archive
+// record. It limits memory allocated by Restore reading
+// corrupted or forged archive
+const MaxArchiveRecordLen = 64 << 20
+
+// archive format
+//
+//     header: magic (8) | version (4) | incremental (1) | since (8) | crc (4)
+//     record: kind (1) | length (4) | payload (length) | crc (4)
+//
+// Records are objects, feeds followed by their roots, misc,
+// misc-objects of namespaces and end.
+// The crc of record is crc32 (IEEE) of kind, length and payload.
+// Payloads are:
+//
+//     object: hash (32) | references counter (4) | value
+//     feed:   public key (33)
+//     root:   public key (33) | RootMeta (17) | encoded RootPack
+//     misc:   key length (4) | key | value
+//     ns:     namespace length (4) | namespace | misc payload
+//     end:    amount of records before the end record (8)
+//
+// The RootMeta is flags (1) | space (8) | time (8), where flags
+// are full (1) and refill (2).
+//
+// All integers are big-endian
+
+var archiveMagic = []byte("CXODUMP\x00")
+
+const (
+	archiveHeaderLen = 8 + 4 + 1 + 8 + 4
+
+	archiveObject byte = 'o'
+	archiveFeed   byte = 'f'
+	archiveRoot   byte = 'r'
+	archiveMisc   byte = 'm'
+	archiveNS     byte = 'n'
+	archiveEnd    byte = 'e'
+)
+
+// Dump writes all objects, feeds, roots (with RootMeta)
+// and misc-objects of given DB to given writer. The Dump
+// performs one read-only transaction and streams data
+// without loading the whole database to memory. Use Restore
+// to load the archive
+func Dump(db DB, w io.Writer) error {
+	return dump(db, w, false, 0, nil)
+}
+
+// An ObjectsOfFunc calls given function for hash of every object
+// of given root, using given objects bucket to read them. If the
+// function returns deeper = false, then objects of the object
+// should be skipped. The DB knows nothing about relations between
+// roots and objects, thus the skyobject package provides the
+// function
+type ObjectsOfFunc func(objs ViewObjects, pk cipher.PubKey, rp *RootPack,
+	fn func(key cipher.SHA256) (deeper bool, err error)) (err error)
+
+// DumpIncremental is the same as Dump, but it writes only roots
+// with seq number greater than given and only objects of these
+// roots that are not objects of older roots. Given ObjectsOfFunc
+// is used to find objects of roots. Hashes of objects of older
+// roots are kept in memory during the dump. The Restore skips
+// roots that already exist
+func DumpIncremental(db DB, w io.Writer, since uint64,
+	objectsOf ObjectsOfFunc) error {
+
+	if objectsOf == nil {
+		return errors.New("missing ObjectsOfFunc")
+	}
+	return dump(db, w, true, since, objectsOf)
+}
+
+type archiveWriter struct {
+	w     *bufio.Writer
+	count uint64
+	head  [5]byte
+	sum   [4]byte
+}
+
+func (a *archiveWriter) header(incremental bool, since uint64) (err error) {
+	var head [archiveHeaderLen]byte
+	copy(head[:], archiveMagic)
+	binary.BigEndian.PutUint32(head[8:], ArchiveVersion)
+	if incremental {
+		head[12] = 1
+	}
+	binary.BigEndian.PutUint64(head[13:], since)
+	binary.BigEndian.PutUint32(head[21:], crc32.ChecksumIEEE(head[:21]))
+	_, err = a.w.Write(head[:])
+	return
+}
+
+// record writes record using given parts of payload
+func (a *archiveWriter) record(kind byte, parts ...[]byte) (err error) {
+	var ln int
+	for _, p := range parts {
+		ln += len(p)
+	}
+	if ln > MaxArchiveRecordLen {
+		return ErrArchiveRecordTooLarge
+	}
+	a.head[0] = kind
+	binary.BigEndian.PutUint32(a.head[1:], uint32(ln))
+
+	crc := crc32.NewIEEE()
+	crc.Write(a.head[:])
+	if _, err = a.w.Write(a.head[:]); err != nil {
+		return
+	}
+	for _, p := range parts {
+		crc.Write(p)
+		if _, err = a.w.Write(p); err != nil {
+			return
+		}
+	}
+	binary.BigEndian.PutUint32(a.sum[:], crc.Sum32())
+	if _, err = a.w.Write(a.sum[:]); err != nil {
+		return
+	}
+	if kind != archiveEnd {
+		a.count++
+	}
+	return
+}
+
+// objects writes objects of roots newer than given
+// seq number that are not objects of older roots
+func (a *archiveWriter) objects(tx Tv, since uint64,
+	objectsOf ObjectsOfFunc) (err error) {
+
+	objs := tx.Objects()
+	feeds := tx.Feeds()
+
+	// walk roots (older and newer, or newer only)
+	walk := func(newer bool,
+		fn func(key cipher.SHA256) (bool, error)) error {
+
+		return feeds.Ascend(func(pk cipher.PubKey) error {
+			return feeds.Roots(pk).Ascend(func(rp *RootPack) error {
+				if (rp.Seq > since) != newer {
+					return nil
+				}
+				return objectsOf(objs, pk, rp, fn)
+			})
+		})
+	}
+
+	old := make(map[cipher.SHA256]struct{})
+	err = walk(false, func(key cipher.SHA256) (bool, error) {
+		if _, ok := old[key]; ok {
+			return false, nil
+		}
+		old[key] = struct{}{}
+		return true, nil
+	})
+	if err != nil {
+		return
+	}
+
+	return walk(true, func(key cipher.SHA256) (deeper bool, err error) {
+		if _, ok := old[key]; ok {
+			return // already archived or written
+		}
+		old[key] = struct{}{}
+		value := objs.Get(key)
+		if value == nil {
+			return // missing object
+		}
+		err = a.record(archiveObject, key[:], rctob(objs.Refs(key)), value)
+		return err == nil, err
+	})
+}
+
+func dump(db DB, w io.Writer, incremental bool, since uint64,
+	objectsOf ObjectsOfFunc) (err error) {
+
+	aw := &archiveWriter{w: bufio.NewWriter(w)}
+
+	if err = aw.header(incremental, since); err != nil {
+		return
+	}
+
+	err = db.View(func(tx Tv) (err error) {
+
+		// objects
+
+		if incremental {
+			err = aw.objects(tx, since, objectsOf)
+		} else {
+			objs := tx.Objects()
+			err = objs.Ascend(func(key cipher.SHA256, value []byte) error {
+				return aw.record(archiveObject, key[:], rctob(objs.Refs(key)),
+					value)
+			})
+		}
+		if err != nil {
+			return
+		}
+
+		// feeds and roots
+
+		feeds := tx.Feeds()
+		err = feeds.Ascend(func(pk cipher.PubKey) (err error) {
+			if err = aw.record(archiveFeed, pk[:]); err != nil {
+				return
+			}
+			roots := feeds.Roots(pk)
+			return roots.Ascend(func(rp *RootPack) (err error) {
+				if incremental && rp.Seq <= since {
+					return // skip old root
+				}
+				var rm RootMeta
+				if rm, err = roots.Meta(rp.Seq); err != nil {
+					return
+				}
+				return aw.record(archiveRoot, pk[:], encodeRootMeta(rm),
+					encoder.Serialize(rp))
+			})
+		})
+		if err != nil {
+			return
+		}
+
+		// misc
+
+		err = tx.Misc("").Ascend(func(key, value []byte) error {
+			return aw.record(archiveMisc, rctob(uint32(len(key))), key, value)
+		})
+		if err != nil {
+			return
+		}
+
+		// namespaces
+
+		for _, ns := range tx.Namespaces() {
+			nsHead := append(rctob(uint32(len(ns))), ns...)
+			err = tx.Misc(ns).Ascend(func(key, value []byte) error {
+				return aw.record(archiveNS, nsHead, rctob(uint32(len(key))),
+					key, value)
+			})
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	if err = aw.record(archiveEnd, utob(aw.count)); err != nil {
+		return
+	}
+	return aw.w.Flush()
+}
+
+type archiveReader struct {
+	r     *bufio.Reader
+	count uint64
+	head  [5]byte
+	sum   [4]byte
+}
+
+func (a *archiveReader) readFull(p []byte) (err error) {
+	if _, err = io.ReadFull(a.r, p); err == io.EOF ||
+		err == io.ErrUnexpectedEOF {
+
+		err = ErrMalformedArchive // truncated
+	}
+	return
+}
+
+func (a *archiveReader) header() (err error) {
+	var head [archiveHeaderLen]byte
+	if err = a.readFull(head[:]); err != nil {
+		return
+	}
+	if !bytes.Equal(head[:8], archiveMagic) {
+		return ErrMalformedArchive
+	}
+	if crc32.ChecksumIEEE(head[:21]) != binary.BigEndian.Uint32(head[21:]) {
+		return ErrArchiveChecksum
+	}
+	if version := binary.BigEndian.Uint32(head[8:]); version != ArchiveVersion {
+		return fmt.Errorf("unsupported archive version %d", version)
+	}
+	return
+}
+
+// record reads next record
+func (a *archiveReader) record() (kind byte, payload []byte, err error) {
+	if err = a.readFull(a.head[:]); err != nil {
+		return
+	}
+	kind = a.head[0]
+	ln := binary.BigEndian.Uint32(a.head[1:])
+	if ln > MaxArchiveRecordLen {
+		err = ErrArchiveRecordTooLarge
+		return
+	}
+	payload = make([]byte, ln)
+	if err = a.readFull(payload); err != nil {
+		return
+	}
+	if err = a.readFull(a.sum[:]); err != nil {
+		return
+	}
+	crc := crc32.NewIEEE()
+	crc.Write(a.head[:])
+	crc.Write(payload)
+	if crc.Sum32() != binary.BigEndian.Uint32(a.sum[:]) {
+		err = ErrArchiveChecksum
+		return
+	}
+	if kind != archiveEnd {
+		a.count++
+	}
+	return
+}
+
+// Restore reads archive created by Dump or DumpIncremental
+// and writes its content to given DB. The Restore performs
+// one read-write transaction. Thus, if the archive is
+// corrupted or truncated, then the DB is not changed.
+// Existing roots are kept (but marked as full if the
+// archived one is full). Existing objects keep their
+// references counters and new objects get counters from
+// the archive. Thus, the counters are exact only if a full
+// archive restored to an empty DB. Otherwise, they should be
+// recounted (see skyobject.Container.Restore)
+func Restore(db DB, r io.Reader) (err error) {
+
+	ar := &archiveReader{r: bufio.NewReader(r)}
+
+	if err = ar.header(); err != nil {
+		return
+	}
+
+	return db.Update(func(tx Tu) (err error) {
+
+		var kind byte
+		var payload []byte
+
+		for {
+			if kind, payload, err = ar.record(); err != nil {
+				return
+			}
+			switch kind {
+			case archiveObject:
+				err = restoreObject(tx.Objects(), payload)
+			case archiveFeed:
+				err = restoreFeed(tx.Feeds(), payload)
+			case archiveRoot:
+				err = restoreRoot(tx.Feeds(), payload)
+			case archiveMisc:
+				err = restoreMisc(tx.Misc(""), payload)
+			case archiveNS:
+				err = restoreNamespace(tx, payload)
+			case archiveEnd:
+				if len(payload) != 8 || btou(payload) != ar.count {
+					return ErrMalformedArchive // lost records
+				}
+				return
+			default:
+				err = ErrMalformedArchive
+			}
+			if err != nil {
+				return
+			}
+		}
+
+	})
+}
+
+func restoreObject(objs UpdateObjects, payload []byte) (err error) {
+	if len(payload) < len(cipher.SHA256{})+4 {
+		return ErrMalformedArchive
+	}
+	var key cipher.SHA256
+	copy(key[:], payload)
+	value := payload[len(key)+4:]
+	if cipher.SumSHA256(value) != key {
+		return ErrMalformedArchive
+	}
+	if objs.IsExist(key) {
+		return // keep references counter
+	}
+	if err = objs.Set(key, value); err != nil {
+		return
+	}
+	return objs.SetRefs(key, btorc(payload[len(key):len(key)+4]))
+}
+
+func restoreFeed(feeds UpdateFeeds, payload []byte) (err error) {
+	if len(payload) != len(cipher.PubKey{}) {
+		return ErrMalformedArchive
+	}
+	var pk cipher.PubKey
+	copy(pk[:], payload)
+	return feeds.Add(pk)
+}
+
+func restoreRoot(feeds UpdateFeeds, payload []byte) (err error) {
+
+	if len(payload) < len(cipher.PubKey{}) {
+		return ErrMalformedArchive
+	}
+	var pk cipher.PubKey
+	copy(pk[:], payload)
+	roots := feeds.Roots(pk)
+	if roots == nil {
+		return ErrMalformedArchive // root before its feed
+	}
+	payload = payload[len(pk):]
+
+	if len(payload) < rootMetaLen {
+		return ErrMalformedArchive
+	}
+	rm := decodeRootMeta(payload[:rootMetaLen])
+	rp := new(RootPack)
+	if err = encoder.DeserializeRaw(payload[rootMetaLen:], rp); err != nil {
+		return ErrMalformedArchive
+	}
+
+	if err = roots.Add(rp); err == ErrRootAlreadyExists {
+		if !rm.IsFull || roots.IsFull(rp.Seq) {
+			return nil // keep existing
+		}
+	} else if err != nil || rm == (RootMeta{}) {
+		return
+	}
+	return roots.SetMeta(rp.Seq, rm)
+}
+
+func restoreMisc(misc UpdateMisc, payload []byte) (err error) {
+	if len(payload) < 4 {
+		return ErrMalformedArchive
+	}
+	ln := uint64(btorc(payload[:4]))
+	if ln > uint64(len(payload)-4) {
+		return ErrMalformedArchive
+	}
+	return misc.Set(payload[4:4+ln], payload[4+ln:])
+}
+
+func restoreNamespace(tx Tu, payload []byte) (err error) {
+	if len(payload) < 4 {
+		return ErrMalformedArchive
+	}
+	ln := uint64(btorc(payload[:4]))
+	if ln == 0 || ln > uint64(len(payload)-4) {
+		return ErrMalformedArchive
+	}
+	return restoreMisc(tx.Misc(string(payload[4:4+ln])), payload[4+ln:])
+}
@@ -0,0 +1,297 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// testFillArchive fills given DB with objects, two
+// feeds with roots and misc-objects
+func testFillArchive(t *testing.T, db DB, pks []cipher.PubKey) {
+	err := db.Update(func(tx Tu) (err error) {
+		objs := tx.Objects()
+		for _, s := range []string{"one", "two", "three"} {
+			var key cipher.SHA256
+			if key, err = objs.Add([]byte(s)); err != nil {
+				return
+			}
+			if err = objs.SetRefs(key, uint32(len(s))); err != nil {
+				return
+			}
+		}
+		feeds := tx.Feeds()
+		for _, pk := range pks {
+			if err = feeds.Add(pk); err != nil {
+				return
+			}
+			roots := feeds.Roots(pk)
+			for seq := uint64(0); seq < 3; seq++ {
+				rp := getRootPack(seq, pk.Hex())
+				if err = roots.Add(&rp); err != nil {
+					return
+				}
+				if seq == 1 {
+					err = roots.SetMeta(seq, RootMeta{IsFull: true, Space: 10})
+					if err != nil {
+						return
+					}
+				}
+			}
+		}
+		if err = tx.Misc("").Set([]byte("key"), []byte("value")); err != nil {
+			return
+		}
+		return tx.Misc("ns").Set([]byte("key"), []byte("ns value"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testCompareArchived(t *testing.T, want, got DB) {
+	err := want.View(func(wtx Tv) error {
+		return got.View(func(gtx Tv) (err error) {
+
+			gobjs := gtx.Objects()
+			err = wtx.Objects().Ascend(func(key cipher.SHA256,
+				value []byte) (_ error) {
+
+				if !bytes.Equal(gobjs.Get(key), value) {
+					t.Error("missing or wrong object")
+				} else if gobjs.Refs(key) != uint32(len(value)) {
+					t.Error("wrong references counter")
+				}
+				return
+			})
+			if err != nil {
+				return
+			}
+
+			testComparePublicKeyLists(t, wtx.Feeds().List(),
+				gtx.Feeds().List())
+
+			for _, pk := range wtx.Feeds().List() {
+				groots := gtx.Feeds().Roots(pk)
+				if groots == nil {
+					continue
+				}
+				wroots := wtx.Feeds().Roots(pk)
+				err = wroots.Ascend(func(rp *RootPack) (_ error) {
+					wm, _ := wroots.Meta(rp.Seq)
+					gm, _ := groots.Meta(rp.Seq)
+					if gp := groots.Get(rp.Seq); gp == nil {
+						t.Error("missing root", rp.Seq)
+					} else if gp.Hash != rp.Hash || gm != wm {
+						t.Error("wrong root", rp.Seq)
+					}
+					return
+				})
+				if err != nil {
+					return
+				}
+			}
+
+			if v := gtx.Misc("").Get([]byte("key")); string(v) != "value" {
+				t.Errorf("wrong misc-object %q", v)
+			}
+			if v := gtx.Misc("ns").Get([]byte("key")); string(v) != "ns value" {
+				t.Errorf("wrong misc-object of namespace %q", v)
+			}
+			return
+		})
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func testDumpRestore(t *testing.T, src, dst DB) {
+
+	pks := testOrderedPublicKeys()
+
+	if testFillArchive(t, src, pks); t.Failed() {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := Dump(src, &buf); err != nil {
+		t.Fatal(err)
+	}
+	archive := buf.Bytes()
+
+	t.Run("corrupted", func(t *testing.T) {
+		corrupted := append([]byte{}, archive...)
+		corrupted[archiveHeaderLen+10] ^= 0xff
+		err := Restore(dst, bytes.NewReader(corrupted))
+		if err != ErrArchiveChecksum {
+			t.Error("unexpected error:", err)
+		}
+		if dst.Stat().Objects != 0 {
+			t.Error("corrupted archive has been partially restored")
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		large := append([]byte{}, archive...)
+		// length of first record
+		binary.BigEndian.PutUint32(large[archiveHeaderLen+1:], 1<<32-1)
+		err := Restore(dst, bytes.NewReader(large))
+		if err != ErrArchiveRecordTooLarge {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		err := Restore(dst, bytes.NewReader(archive[:len(archive)-20]))
+		if err != ErrMalformedArchive {
+			t.Error("unexpected error:", err)
+		}
+		if dst.Stat().Objects != 0 {
+			t.Error("truncated archive has been partially restored")
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		if err := Restore(dst, bytes.NewReader(archive)); err != nil {
+			t.Fatal(err)
+		}
+		testCompareArchived(t, src, dst)
+	})
+
+	t.Run("incremental", func(t *testing.T) {
+		err := src.Update(func(tx Tu) (err error) {
+			var key cipher.SHA256
+			if key, err = tx.Objects().Add([]byte("four")); err != nil {
+				return
+			}
+			if err = tx.Objects().SetRefs(key, 4); err != nil {
+				return
+			}
+			roots := tx.Feeds().Roots(pks[0])
+			rp := getRootPack(3, "new root")
+			if err = roots.Add(&rp); err != nil {
+				return
+			}
+			return roots.MarkFull(2)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// objects of roots: seq 0 and 1 - one, two;
+		// seq 2 - two, three; seq 3 - one, four
+		objectsOf := func(objs ViewObjects, pk cipher.PubKey, rp *RootPack,
+			fn func(cipher.SHA256) (bool, error)) (err error) {
+
+			var vals []string
+			switch rp.Seq {
+			case 0, 1:
+				vals = []string{"one", "two"}
+			case 2:
+				vals = []string{"two", "three"}
+			case 3:
+				vals = []string{"one", "four"}
+			}
+			for _, val := range vals {
+				if _, err = fn(cipher.SumSHA256([]byte(val))); err != nil {
+					return
+				}
+			}
+			return
+		}
+		var full, inc bytes.Buffer
+		if err = Dump(src, &full); err != nil {
+			t.Fatal(err)
+		}
+		if err = DumpIncremental(src, &inc, 1, objectsOf); err != nil {
+			t.Fatal(err)
+		}
+		if inc.Len() >= full.Len() {
+			t.Error("incremental archive contains old roots")
+		}
+		fresh := NewMemoryDB()
+		if err = Restore(fresh, bytes.NewReader(inc.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+		if objs := fresh.Stat().Objects; objs != 2 { // three and four
+			t.Error("wrong amount of objects in incremental archive:", objs)
+		}
+		if err = Restore(dst, &inc); err != nil {
+			t.Fatal(err)
+		}
+		testCompareArchived(t, src, dst)
+	})
+
+	t.Run("keep counters", func(t *testing.T) {
+		err := dst.Update(func(tx Tu) error {
+			return tx.Objects().SetRefs(cipher.SumSHA256([]byte("one")), 10)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = Restore(dst, bytes.NewReader(archive)); err != nil {
+			t.Fatal(err)
+		}
+		err = dst.View(func(tx Tv) (_ error) {
+			if rc := tx.Objects().Refs(cipher.SumSHA256([]byte("one"))); rc != 10 {
+				t.Error("references counter overwritten:", rc)
+			}
+			return
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("missing ObjectsOfFunc", func(t *testing.T) {
+		if err := DumpIncremental(src, &bytes.Buffer{}, 1, nil); err == nil {
+			t.Error("missing error")
+		}
+	})
+
+}
+
+func TestDump(t *testing.T) {
+	// Dump(db DB, w io.Writer) error
+	// Restore(db DB, r io.Reader) error
+
+	t.Run("memory", func(t *testing.T) {
+		testDumpRestore(t, NewMemoryDB(), NewMemoryDB())
+	})
+
+	t.Run("drive", func(t *testing.T) {
+		src, cleanSrc := testDriveDB(t)
+		defer cleanSrc()
+		dst, cleanDst := testDriveDB(t)
+		defer cleanDst()
+		testDumpRestore(t, src, dst)
+	})
+
+	t.Run("lsm", func(t *testing.T) {
+		src, cleanSrc := testLSMDB(t)
+		defer cleanSrc()
+		dst, cleanDst := testLSMDB(t)
+		defer cleanDst()
+		testDumpRestore(t, src, dst)
+	})
+
+	t.Run("version", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := Dump(NewMemoryDB(), &buf); err != nil {
+			t.Fatal(err)
+		}
+		archive := buf.Bytes()
+		archive[11]++ // version
+		binary.BigEndian.PutUint32(archive[21:], crc32.ChecksumIEEE(archive[:21]))
+		err := Restore(NewMemoryDB(), bytes.NewReader(archive))
+		if err == nil {
+			t.Error("missing error")
+		} else if err == ErrArchiveChecksum || err == ErrMalformedArchive {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+}
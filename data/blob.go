@@ -0,0 +1,667 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/boltdb/bolt"
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// A stored value of object is
+//
+//     value without header, if the value is stored in the DB
+//     magic (4) | kind (1) | length (uvarint) [ | value ]
+//
+// where the length is length of the original value. The file
+// kind means that the value is stored in file. The inline kind
+// (with the value after header) is used only if a value that
+// stored in the DB starts with the magic. Thus, values of an
+// old database are readable (see also compress.go)
+
+var blobMagic = []byte{0xfe, 'c', 'x', 'b'}
+
+const (
+	blobInline byte = 0 // stored in the DB
+	blobFile   byte = 1 // stored in file
+)
+
+// name of directory of files of objects
+const blobDir = "objects"
+
+var errBlobHeader = errors.New("malformed blob header")
+
+type blobDB struct {
+	db        DB
+	dir       string // directory of files of objects
+	threshold int
+
+	mx   sync.Mutex
+	stat blobStat // counters
+}
+
+// A blobStat represents counters of blobDB: difference between
+// length of original and stored values and length of files
+type blobStat struct {
+	space int // original - stored
+	files int // length of files
+}
+
+// add counters of given stored value to the blobStat
+// (or subtract them if the sign is -1)
+func (b *blobStat) add(stored []byte, sign int) {
+	kind, ln, value, err := parseBlobHeader(stored)
+	if err != nil {
+		return // old value that starts with the magic
+	}
+	switch kind {
+	case blobFile:
+		b.space += sign * (int(ln) - len(stored))
+		b.files += sign * int(ln)
+	case blobInline:
+		b.space += sign * (len(value) - len(stored))
+	}
+}
+
+// NewBlobDB wraps given DB keeping values of objects larger
+// than given threshold (in bytes) as files in sharded
+// directory "objects" under given one (objects/ab/cdef...
+// where the abcdef... is hex encoded key of object). Given
+// DB keeps short pointers to the files. Root objects and
+// misc-objects are always stored in given DB. Existing
+// database can be wrapped. A file is written before
+// transaction commits and is removed after transaction that
+// deletes its object commits. Thus, a read-only transaction
+// that started before the deleting can't read the value of
+// the object. The Get returns nil and walking skips such
+// objects. The Space of Stat of the DB is total length of
+// original values and the PhysicalSpace includes files.
+// The NewBlobDB scans objects once to build counters for
+// the Stat and removes files that are not used by objects
+// (left after a crash). Closing returned DB closes given
+func NewBlobDB(db DB, dir string, threshold int) (bdb DB, err error) {
+	if threshold <= 0 {
+		return nil, errors.New("non-positive blob threshold")
+	}
+	dir = filepath.Join(dir, blobDir)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	b := &blobDB{db: db, dir: dir, threshold: threshold}
+	if err = b.open(); err != nil {
+		return
+	}
+	bdb = b
+	return
+}
+
+// open builds counters and removes unused files
+func (b *blobDB) open() (err error) {
+	// read-write transaction to be sure that
+	// no other transaction writes files
+	err = b.db.Update(func(tx Tu) (err error) {
+		objs := tx.Objects()
+		if err = b.count(objs); err != nil {
+			return
+		}
+		return filepath.Walk(b.dir, func(path string, fi os.FileInfo,
+			err error) error {
+
+			if err != nil || fi.IsDir() {
+				return err
+			}
+			if strings.HasPrefix(fi.Name(), ".tmp") {
+				return os.Remove(path) // unfinished writing
+			}
+			dir := filepath.Base(filepath.Dir(path))
+			key, err := cipher.SHA256FromHex(dir + fi.Name())
+			if err != nil || !isBlobFile(objs.Get(key)) {
+				return os.Remove(path) // not a file of an object
+			}
+			return nil
+		})
+	})
+	if err == bolt.ErrDatabaseReadOnly {
+		// nobody writes files of read-only
+		// database, build the counters only
+		err = b.db.View(func(tx Tv) error {
+			return b.count(tx.Objects())
+		})
+	}
+	return
+}
+
+// count builds the counters
+func (b *blobDB) count(objs ViewObjects) (err error) {
+	var st blobStat
+	err = objs.Ascend(func(_ cipher.SHA256, stored []byte) (_ error) {
+		st.add(stored, 1)
+		return
+	})
+	if err == nil {
+		b.stat = st
+	}
+	return
+}
+
+// update the counters by given changes
+func (b *blobDB) update(st blobStat) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	b.stat.space += st.space
+	b.stat.files += st.files
+}
+
+// path returns path to file of object
+func (b *blobDB) path(key cipher.SHA256) string {
+	hex := key.Hex()
+	return filepath.Join(b.dir, hex[:2], hex[2:])
+}
+
+// blobHeader returns header of value stored in file
+func blobHeader(kind byte, ln int) []byte {
+	hd := make([]byte, len(blobMagic)+1+binary.MaxVarintLen64)
+	copy(hd, blobMagic)
+	hd[len(blobMagic)] = kind
+	n := binary.PutUvarint(hd[len(blobMagic)+1:], uint64(ln))
+	return hd[:len(blobMagic)+1+n]
+}
+
+// parseBlobHeader parses header of stored value returning kind,
+// length of original value and the value after header
+func parseBlobHeader(stored []byte) (kind byte, ln uint64, value []byte,
+	err error) {
+
+	if !bytes.HasPrefix(stored, blobMagic) {
+		return blobInline, uint64(len(stored)), stored, nil
+	}
+	value = stored[len(blobMagic):]
+	if len(value) == 0 {
+		err = errBlobHeader
+		return
+	}
+	kind, value = value[0], value[1:]
+	var n int
+	if ln, n = binary.Uvarint(value); n <= 0 {
+		err = errBlobHeader
+		return
+	}
+	value = value[n:]
+	switch kind {
+	case blobInline:
+		ln = uint64(len(value))
+	case blobFile:
+		if len(value) != 0 {
+			err = errBlobHeader
+		}
+	default:
+		err = errBlobHeader
+	}
+	return
+}
+
+// isBlobFile returns true if value of
+// stored object is stored in file
+func isBlobFile(stored []byte) bool {
+	kind, _, _, err := parseBlobHeader(stored)
+	return err == nil && kind == blobFile
+}
+
+// store returns value to store in the DB writing
+// the value to file if it's large enough
+func (b *blobDB) store(key cipher.SHA256, value []byte) ([]byte, error) {
+	if len(value) > b.threshold {
+		if err := b.write(key, value); err != nil {
+			return nil, err
+		}
+		return blobHeader(blobFile, len(value)), nil
+	}
+	if !bytes.HasPrefix(value, blobMagic) {
+		return value, nil // as is
+	}
+	// keep values that starts with the magic
+	return append(blobHeader(blobInline, 0), value...), nil
+}
+
+// write value of object to file, the file is
+// not rewritten if it already exists
+func (b *blobDB) write(key cipher.SHA256, value []byte) (err error) {
+	path := b.path(key)
+	if fi, err := os.Stat(path); err == nil && fi.Size() == int64(len(value)) {
+		return nil // already exists
+	}
+	dir := filepath.Dir(path)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	var tmp *os.File
+	if tmp, err = ioutil.TempFile(dir, ".tmp"); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(value); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	if err = os.Chmod(tmp.Name(), dbMode); err != nil {
+		return
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// exists returns true if value of given stored one can
+// be loaded, it's the same as ok of the load, but the
+// exists doesn't read file
+func (b *blobDB) exists(key cipher.SHA256, stored []byte) bool {
+	kind, ln, _, err := parseBlobHeader(stored)
+	if err != nil || kind != blobFile {
+		return true
+	}
+	if fi, err := os.Stat(b.path(key)); err == nil &&
+		uint64(fi.Size()) == ln {
+
+		return true
+	}
+	return cipher.SumSHA256(stored) == key // old value that looks like pointer
+}
+
+// load returns original value of stored one, the ok is
+// false if the value is stored in file that doesn't exist
+func (b *blobDB) load(key cipher.SHA256, stored []byte) (value []byte,
+	ok bool) {
+
+	if !bytes.HasPrefix(stored, blobMagic) {
+		return stored, true
+	}
+	kind, ln, value, err := parseBlobHeader(stored)
+	if err != nil {
+		return stored, true // old value that starts with the magic
+	}
+	if kind == blobInline {
+		if cipher.SumSHA256(value) != key && cipher.SumSHA256(stored) == key {
+			return stored, true // old value that looks like inline one
+		}
+		return value, true
+	}
+	if value, err = ioutil.ReadFile(b.path(key)); err != nil ||
+		uint64(len(value)) != ln {
+
+		if cipher.SumSHA256(stored) == key {
+			return stored, true // old value that looks like pointer
+		}
+		return nil, false
+	}
+	return value, true
+}
+
+// collect removes files of given objects that are not
+// stored in files. The collect performs read-write
+// transaction to be sure that no other transaction
+// writes the objects at the same time
+func (b *blobDB) collect(keys []cipher.SHA256) {
+	if len(keys) == 0 {
+		return
+	}
+	b.db.Update(func(tx Tu) (_ error) {
+		objs := tx.Objects()
+		for _, key := range keys {
+			if !isBlobFile(objs.Get(key)) {
+				os.Remove(b.path(key)) // ignore error
+			}
+		}
+		return
+	})
+}
+
+func (b *blobDB) View(fn func(t Tv) error) error {
+	return b.db.View(func(tx Tv) error {
+		return fn(&blobTv{b, tx})
+	})
+}
+
+// Update removes files of deleted objects after commit
+// and files written by the transaction after rollback
+func (b *blobDB) Update(fn func(t Tu) error) (err error) {
+	var bt *blobTu
+	err = b.db.Update(func(tx Tu) error {
+		bt = &blobTu{b: b, tx: tx}
+		return fn(bt)
+	})
+	if bt == nil {
+		return
+	}
+	if err == nil {
+		b.update(bt.stat)
+		b.collect(bt.deleted)
+	} else {
+		b.collect(bt.written)
+	}
+	return
+}
+
+// Batch can call given function many times and can't
+// say what call is committed. Thus, the Batch collects
+// files of all calls after. Last call is committed if
+// the Batch returns nil, since failed batch is retried
+func (b *blobDB) Batch(fn func(t Tu) error) (err error) {
+	var touched []cipher.SHA256
+	var last *blobTu
+	err = Batch(b.db, func(tx Tu) (err error) {
+		last = &blobTu{b: b, tx: tx}
+		err = fn(last)
+		touched = append(touched, last.written...)
+		touched = append(touched, last.deleted...)
+		return
+	})
+	if err == nil && last != nil {
+		b.update(last.stat)
+	}
+	b.collect(touched)
+	return
+}
+
+// Stat reports length of original values as the Space,
+// the PhysicalSpace includes length of files
+func (b *blobDB) Stat() (s Stat) {
+	s = b.db.Stat()
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	s.Space += Space(b.stat.space)
+	s.PhysicalSpace += Space(b.stat.files)
+	return
+}
+
+func (b *blobDB) recountStat() (err error) {
+	if err = RecountStat(b.db); err != nil {
+		return
+	}
+	var st blobStat
+	err = b.db.View(func(tx Tv) error {
+		return tx.Objects().Ascend(func(_ cipher.SHA256,
+			stored []byte) (_ error) {
+
+			st.add(stored, 1)
+			return
+		})
+	})
+	if err != nil {
+		return
+	}
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.stat = st
+	return
+}
+
+func (b *blobDB) Close() error {
+	return b.db.Close()
+}
+
+type blobTv struct {
+	b  *blobDB
+	tx Tv
+}
+
+func (b *blobTv) Objects() ViewObjects {
+	return &blobObjects{b: b.b, view: b.tx.Objects()}
+}
+
+func (b *blobTv) Feeds() ViewFeeds {
+	return b.tx.Feeds()
+}
+
+func (b *blobTv) Misc(namespace string) ViewMisc {
+	return b.tx.Misc(namespace)
+}
+
+func (b *blobTv) Namespaces() []string {
+	return b.tx.Namespaces()
+}
+
+// the written and deleted are keys of
+// objects stored in files
+type blobTu struct {
+	b  *blobDB
+	tx Tu
+
+	written []cipher.SHA256
+	deleted []cipher.SHA256
+
+	stat blobStat // changes of counters
+}
+
+func (b *blobTu) Objects() UpdateObjects {
+	objs := b.tx.Objects()
+	return &blobObjects{b.b, b, objs, objs}
+}
+
+func (b *blobTu) Feeds() UpdateFeeds {
+	return b.tx.Feeds()
+}
+
+func (b *blobTu) Misc(namespace string) UpdateMisc {
+	return b.tx.Misc(namespace)
+}
+
+func (b *blobTu) Namespaces() []string {
+	return b.tx.Namespaces()
+}
+
+func (b *blobTu) DelNamespace(namespace string) error {
+	return b.tx.DelNamespace(namespace)
+}
+
+// the tu and upd are nil for read-only transactions
+type blobObjects struct {
+	b    *blobDB
+	tu   *blobTu
+	view ViewObjects
+	upd  UpdateObjects
+}
+
+func (b *blobObjects) Get(key cipher.SHA256) (value []byte) {
+	if stored := b.view.Get(key); stored != nil {
+		value, _ = b.b.load(key, stored)
+	}
+	return
+}
+
+func (b *blobObjects) GetCopy(key cipher.SHA256) (value []byte) {
+	if stored := b.view.GetCopy(key); stored != nil {
+		value, _ = b.b.load(key, stored)
+	}
+	return
+}
+
+// IsExist returns false if value of the object is
+// stored in file that doesn't exist, like the Get
+func (b *blobObjects) IsExist(key cipher.SHA256) bool {
+	if stored := b.view.Get(key); stored != nil {
+		return b.b.exists(key, stored)
+	}
+	return false
+}
+
+func (b *blobObjects) Ascend(
+	fn func(key cipher.SHA256, value []byte) error) error {
+
+	return b.view.Ascend(func(key cipher.SHA256, stored []byte) error {
+		if value, ok := b.b.load(key, stored); ok {
+			return fn(key, value)
+		}
+		return nil // skip
+	})
+}
+
+func (b *blobObjects) Cursor() ObjectsCursor {
+	return &blobCursor{b.b, b.view.Cursor()}
+}
+
+func (b *blobObjects) RangeCursor(from, to cipher.SHA256) ObjectsCursor {
+	return &blobCursor{b.b, b.view.RangeCursor(from, to)}
+}
+
+func (b *blobObjects) Refs(key cipher.SHA256) uint32 {
+	return b.view.Refs(key)
+}
+
+func (b *blobObjects) NeedRecount() bool {
+	return b.view.NeedRecount()
+}
+
+func (b *blobObjects) Del(key cipher.SHA256) error {
+	if old := b.view.Get(key); old != nil {
+		if isBlobFile(old) {
+			b.tu.deleted = append(b.tu.deleted, key)
+		}
+		b.tu.stat.add(old, -1)
+	}
+	return b.upd.Del(key)
+}
+
+func (b *blobObjects) Set(key cipher.SHA256, value []byte) (err error) {
+	var stored []byte
+	if stored, err = b.store(key, value); err != nil {
+		return
+	}
+	return b.upd.Set(key, stored)
+}
+
+// store writes file if it's needed and
+// updates counters of the transaction
+func (b *blobObjects) store(key cipher.SHA256, value []byte) (stored []byte,
+	err error) {
+
+	if stored, err = b.b.store(key, value); err != nil {
+		return
+	}
+	if isBlobFile(stored) {
+		b.tu.written = append(b.tu.written, key)
+	}
+	if old := b.view.Get(key); old != nil {
+		b.tu.stat.add(old, -1)
+	}
+	b.tu.stat.add(stored, 1)
+	return
+}
+
+func (b *blobObjects) Add(value []byte) (key cipher.SHA256, err error) {
+	key = cipher.SumSHA256(value)
+	err = b.Set(key, value)
+	return
+}
+
+func (b *blobObjects) SetMap(m map[cipher.SHA256][]byte) (err error) {
+	stored := make(map[cipher.SHA256][]byte, len(m))
+	for k, v := range m {
+		if stored[k], err = b.store(k, v); err != nil {
+			return
+		}
+	}
+	return b.upd.SetMap(stored)
+}
+
+func (b *blobObjects) AscendDel(
+	fn func(key cipher.SHA256, value []byte) (bool, error)) error {
+
+	return b.upd.AscendDel(func(key cipher.SHA256, stored []byte) (del bool,
+		err error) {
+
+		value, ok := b.b.load(key, stored)
+		if !ok {
+			return // skip
+		}
+		if del, err = fn(key, value); !del {
+			return
+		}
+		if isBlobFile(stored) {
+			b.tu.deleted = append(b.tu.deleted, key)
+		}
+		b.tu.stat.add(stored, -1)
+		return
+	})
+}
+
+func (b *blobObjects) Inc(key cipher.SHA256) (uint32, error) {
+	return b.upd.Inc(key)
+}
+
+func (b *blobObjects) Dec(key cipher.SHA256) (uint32, error) {
+	return b.upd.Dec(key)
+}
+
+func (b *blobObjects) SetRefs(key cipher.SHA256, rc uint32) error {
+	return b.upd.SetRefs(key, rc)
+}
+
+func (b *blobObjects) Recounted() error {
+	return b.upd.Recounted()
+}
+
+// the blobCursor skips objects
+// which files don't exist
+type blobCursor struct {
+	b   *blobDB
+	cur ObjectsCursor
+}
+
+func (b *blobCursor) load(move func() (cipher.SHA256, []byte, bool),
+	key cipher.SHA256, stored []byte, ok bool) (cipher.SHA256, []byte, bool) {
+
+	for ; ok; key, stored, ok = move() {
+		if value, loaded := b.b.load(key, stored); loaded {
+			return key, value, true
+		}
+	}
+	return key, nil, false
+}
+
+func (b *blobCursor) forward(key cipher.SHA256, stored []byte,
+	ok bool) (cipher.SHA256, []byte, bool) {
+
+	return b.load(b.cur.Next, key, stored, ok)
+}
+
+func (b *blobCursor) backward(key cipher.SHA256, stored []byte,
+	ok bool) (cipher.SHA256, []byte, bool) {
+
+	return b.load(b.cur.Prev, key, stored, ok)
+}
+
+func (b *blobCursor) First() (cipher.SHA256, []byte, bool) {
+	return b.forward(b.cur.First())
+}
+
+func (b *blobCursor) Last() (cipher.SHA256, []byte, bool) {
+	return b.backward(b.cur.Last())
+}
+
+func (b *blobCursor) Seek(key cipher.SHA256) (cipher.SHA256, []byte, bool) {
+	return b.forward(b.cur.Seek(key))
+}
+
+func (b *blobCursor) Next() (cipher.SHA256, []byte, bool) {
+	return b.forward(b.cur.Next())
+}
+
+func (b *blobCursor) Prev() (cipher.SHA256, []byte, bool) {
+	return b.backward(b.cur.Prev())
+}
@@ -0,0 +1,325 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func testNewBlobDB(t *testing.T, db DB) {
+
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err = NewBlobDB(db, dir, 0); err == nil {
+		t.Error("missing error for zero threshold")
+	}
+
+	large := bytes.Repeat([]byte("large blob "), 100)
+	short := []byte("short")
+	magic := append(append([]byte{}, blobMagic...), "old value"...)
+
+	// old database
+	err = db.Update(func(tx Tu) (err error) {
+		_, err = tx.Objects().Add(magic)
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bdb, err := NewBlobDB(db, dir, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bdb.Close()
+	b := bdb.(*blobDB)
+
+	err = bdb.Update(func(tx Tu) (err error) {
+		objs := tx.Objects()
+		for _, val := range [][]byte{large, short} {
+			if _, err = objs.Add(val); err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	largeKey := cipher.SumSHA256(large)
+
+	t.Run("stored", func(t *testing.T) {
+		db.View(func(tx Tv) (_ error) {
+			objs := tx.Objects()
+			if got := objs.Get(largeKey); len(got) >= len(large) {
+				t.Error("large value stored in DB")
+			}
+			if got := objs.Get(cipher.SumSHA256(short)); !bytes.Equal(got,
+				short) {
+
+				t.Error("short value is not stored in DB")
+			}
+			return
+		})
+		got, err := ioutil.ReadFile(b.path(largeKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, large) {
+			t.Error("wrong content of file")
+		}
+	})
+
+	t.Run("read", func(t *testing.T) {
+		bdb.View(func(tx Tv) (_ error) {
+			objs := tx.Objects()
+			for _, val := range [][]byte{large, short, magic} {
+				key := cipher.SumSHA256(val)
+				if got := objs.Get(key); !bytes.Equal(got, val) {
+					t.Errorf("wrong value %q", got)
+				}
+				if !objs.IsExist(key) {
+					t.Error("missing object")
+				}
+			}
+			var n int
+			objs.Ascend(func(key cipher.SHA256, value []byte) (_ error) {
+				if cipher.SumSHA256(value) != key {
+					t.Error("wrong value of", key.Hex()[:7])
+				}
+				n++
+				return
+			})
+			if n != 3 {
+				t.Error("wrong amount of objects:", n)
+			}
+			return
+		})
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		s := bdb.Stat()
+		space := Space(len(large) + len(short) + len(magic))
+		if s.Space != space {
+			t.Errorf("wrong space: want %d, got %d", space, s.Space)
+		}
+		if s.PhysicalSpace < Space(len(large)) {
+			t.Error("wrong physical space:", s.PhysicalSpace)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		rolled := bytes.Repeat([]byte("rolled back "), 100)
+		errRollback := errors.New("rollback")
+		err := bdb.Update(func(tx Tu) (err error) {
+			if _, err = tx.Objects().Add(rolled); err != nil {
+				return
+			}
+			return errRollback
+		})
+		if err != errRollback {
+			t.Fatal("unexpected error:", err)
+		}
+		if _, err = os.Stat(b.path(cipher.SumSHA256(rolled))); err == nil {
+			t.Error("file of rolled back object exists")
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		// file of deleted object is kept while a transaction
+		// that deletes the object is not committed
+		err := bdb.Update(func(tx Tu) (err error) {
+			if err = tx.Objects().Del(largeKey); err != nil {
+				return
+			}
+			if _, err = os.Stat(b.path(largeKey)); err != nil {
+				t.Error("file removed before commit")
+			}
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = os.Stat(b.path(largeKey)); err == nil {
+			t.Error("file of deleted object exists")
+		}
+		// add again and delete by AscendDel
+		err = Batch(bdb, func(tx Tu) (err error) {
+			_, err = tx.Objects().Add(large)
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = os.Stat(b.path(largeKey)); err != nil {
+			t.Fatal("missing file:", err)
+		}
+		err = bdb.Update(func(tx Tu) error {
+			return tx.Objects().AscendDel(func(key cipher.SHA256,
+				_ []byte) (bool, error) {
+
+				return key == largeKey, nil
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = os.Stat(b.path(largeKey)); err == nil {
+			t.Error("file of deleted object exists")
+		}
+		space := Space(len(short) + len(magic))
+		if s := bdb.Stat(); s.Space != space {
+			t.Errorf("wrong space: want %d, got %d", space, s.Space)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		err := bdb.Update(func(tx Tu) (err error) {
+			_, err = tx.Objects().Add(large)
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.Remove(b.path(largeKey)); err != nil {
+			t.Fatal(err)
+		}
+		bdb.View(func(tx Tv) (_ error) {
+			objs := tx.Objects()
+			if objs.Get(largeKey) != nil {
+				t.Error("got value of missing file")
+			}
+			if objs.IsExist(largeKey) {
+				t.Error("object of missing file exists")
+			}
+			c := objs.Cursor()
+			for key, _, ok := c.First(); ok; key, _, ok = c.Next() {
+				if key == largeKey {
+					t.Error("cursor doesn't skip missing file")
+				}
+			}
+			return
+		})
+	})
+
+	t.Run("open", func(t *testing.T) {
+		var (
+			orphan = b.path(cipher.SumSHA256([]byte("orphan")))
+			tmp    = filepath.Join(filepath.Dir(b.path(largeKey)), ".tmp123")
+		)
+		for _, path := range []string{orphan, tmp} {
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path, large, 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want := bdb.Stat()
+		reopened, err := NewBlobDB(db, dir, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range []string{orphan, tmp} {
+			if _, err = os.Stat(path); err == nil {
+				t.Error("unused file is not removed:", path)
+			}
+		}
+		got := reopened.Stat()
+		if got.Space != want.Space || got.PhysicalSpace != want.PhysicalSpace {
+			t.Errorf("wrong stat: want %d/%d, got %d/%d", want.Space,
+				want.PhysicalSpace, got.Space, got.PhysicalSpace)
+		}
+	})
+
+}
+
+func TestNewBlobDB(t *testing.T) {
+	// NewBlobDB(db DB, dir string, threshold int) (bdb DB, err error)
+
+	t.Run("memory", func(t *testing.T) {
+		testNewBlobDB(t, NewMemoryDB())
+	})
+
+	t.Run("drive", func(t *testing.T) {
+		db, cleanUp := testDriveDB(t)
+		defer cleanUp()
+		testNewBlobDB(t, db)
+	})
+
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testNewBlobDB(t, db)
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		dbFile := filepath.Join(dir, "test.db")
+		db, err := NewDriveDB(dbFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bdb, err := NewBlobDB(db, dir, 64)
+		if err != nil {
+			db.Close()
+			t.Fatal(err)
+		}
+		large := bytes.Repeat([]byte("large blob "), 100)
+		err = bdb.Update(func(tx Tu) (err error) {
+			_, err = tx.Objects().Add(large)
+			return
+		})
+		if err != nil {
+			bdb.Close()
+			t.Fatal(err)
+		}
+		want := bdb.Stat()
+		if err = bdb.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		opts := SafeDriveOptions()
+		opts.ReadOnly = true
+		if db, err = NewDriveDBOptions(dbFile, opts); err != nil {
+			t.Fatal(err)
+		}
+		if bdb, err = NewBlobDB(db, dir, 64); err != nil {
+			db.Close()
+			t.Fatal(err)
+		}
+		defer bdb.Close()
+
+		got := bdb.Stat()
+		if got.Space != want.Space || got.PhysicalSpace != want.PhysicalSpace {
+			t.Errorf("wrong stat: want %d/%d, got %d/%d", want.Space,
+				want.PhysicalSpace, got.Space, got.PhysicalSpace)
+		}
+		err = bdb.View(func(tx Tv) (_ error) {
+			val := tx.Objects().Get(cipher.SumSHA256(large))
+			if !bytes.Equal(val, large) {
+				t.Error("wrong value")
+			}
+			return
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+}
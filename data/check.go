@@ -0,0 +1,74 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// An ObjectError represents broken object found by Check
+type ObjectError struct {
+	key   cipher.SHA256 // key of object
+	descr string        // description
+}
+
+// Error implements error interface
+func (o *ObjectError) Error() string {
+	return fmt.Sprintf("[%s] %s", shortHex(o.key.Hex()), o.descr)
+}
+
+// Key of broken object
+func (o *ObjectError) Key() cipher.SHA256 { return o.key }
+
+// Check verifies given DB. It checks that key of every object
+// is SHA256 of its value, that hash of every root matches its
+// Root field and that seq numbers and Prev references of roots
+// of every feed make valid chain. It returns all problems found.
+// The problems are *ObjectError and *RootError. The err is error
+// of the database. The Check doesn't decode objects and roots.
+// See (*skyobject.Container).Check for deeper inspection
+func Check(db DB) (problems []error, err error) {
+	err = db.View(func(tx Tv) (err error) {
+		err = tx.Objects().Ascend(func(key cipher.SHA256, value []byte) error {
+			if cipher.SumSHA256(value) != key {
+				problems = append(problems, &ObjectError{
+					key:   key,
+					descr: "key of the object is not hash of its value",
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+		feeds := tx.Feeds()
+		return feeds.Ascend(func(pk cipher.PubKey) error {
+			var prev *RootPack
+			return feeds.Roots(pk).Ascend(func(rp *RootPack) (_ error) {
+				if descr := checkRoot(rp, prev); descr != "" {
+					problems = append(problems, newRootError(pk, rp, descr))
+				}
+				prev = rp
+				return
+			})
+		})
+	})
+	return
+}
+
+// checkRoot returns description of a problem of given
+// RootPack or empty string; the prev is previous RootPack
+// of the feed or nil
+func checkRoot(rp, prev *RootPack) string {
+	switch {
+	case cipher.SumSHA256(rp.Root) != rp.Hash:
+		return "wrong hash of the root"
+	case rp.Seq == 0 && rp.Prev != (cipher.SHA256{}):
+		return "unexpected prev. reference"
+	case rp.Seq != 0 && rp.Prev == (cipher.SHA256{}):
+		return "missing prev. reference"
+	case prev != nil && prev.Seq+1 == rp.Seq && prev.Hash != rp.Prev:
+		return "prev. reference doesn't match hash of previous root"
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func testCheck(t *testing.T, db DB) {
+
+	pk, _ := cipher.GenerateKeyPair()
+
+	// valid
+	err := db.Update(func(tx Tu) (err error) {
+		if _, err = tx.Objects().Add([]byte("valid")); err != nil {
+			return
+		}
+		feeds := tx.Feeds()
+		if err = feeds.Add(pk); err != nil {
+			return
+		}
+		roots := feeds.Roots(pk)
+		rp := getRootPack(0, "zero")
+		if err = roots.Add(&rp); err != nil {
+			return
+		}
+		next := getRootPack(1, "one")
+		next.Prev = rp.Hash
+		return roots.Add(&next)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems, err := Check(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Fatal("unexpected problems:", problems)
+	}
+
+	// broken
+	broken := cipher.SumSHA256([]byte("original"))
+	err = db.Update(func(tx Tu) (err error) {
+		if err = tx.Objects().Set(broken, []byte("corrupted")); err != nil {
+			return
+		}
+		rp := getRootPack(2, "two") // prev. is not hash of 1
+		return tx.Feeds().Roots(pk).Add(&rp)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems, err = Check(db); err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 2 {
+		t.Fatal("wrong problems:", problems)
+	}
+	if oe, ok := problems[0].(*ObjectError); !ok || oe.Key() != broken {
+		t.Error("wrong problem:", problems[0])
+	}
+	if re, ok := problems[1].(*RootError); !ok || re.Seq() != 2 ||
+		re.Feed() != pk {
+
+		t.Error("wrong problem:", problems[1])
+	}
+}
+
+func TestCheck(t *testing.T) {
+	// Check(db DB) (problems []error, err error)
+
+	t.Run("memory", func(t *testing.T) {
+		testCheck(t, NewMemoryDB())
+	})
+
+	t.Run("drive", func(t *testing.T) {
+		db, cleanUp := testDriveDB(t)
+		defer cleanUp()
+		testCheck(t, db)
+	})
+
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testCheck(t, db)
+	})
+
+}
@@ -0,0 +1,461 @@
+package data
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// A stored value of object is
+//
+//     value without header, if the value is not compressed
+//     magic (4) | method (1) | tag (8) | length (uvarint) | value
+//
+// where the tag is first bytes of key of the object and the
+// length is length of the original value. The header is added
+// to not compressed value only if the value starts with the
+// magic. Thus, values of an old database are readable. An old
+// value can't contain first bytes of its own hash after the
+// magic. Thus, an old value that starts with the magic has
+// no valid header and is treated as not compressed
+
+var compressMagic = []byte{0xfe, 'c', 'x', 'z'}
+
+const compressTagLen = 8
+
+const (
+	compressRaw   byte = 0 // not compressed
+	compressFlate byte = 1 // compress/flate
+)
+
+// values shorter than the limit are not compressed
+// (a variable for tests)
+var compressMinLen = 64
+
+var errCompressHeader = errors.New("malformed compression header")
+
+type compressDB struct {
+	db    DB
+	level int
+
+	mx    sync.Mutex
+	space int // original - stored
+}
+
+// NewCompressDB wraps given DB compressing values of objects
+// using compress/flate with given compression level. Values
+// that are not compressible are stored as is. Existing
+// database with not compressed values can be wrapped. Keys
+// of objects are SHA256 of original values. Root objects and
+// misc-objects are not compressed. The Space of Stat of the
+// DB is total length of original values and the PhysicalSpace
+// is space taken by compressed values. The NewCompressDB
+// scans objects once to count the Space. Closing returned
+// DB closes given
+func NewCompressDB(db DB, level int) (cdb DB, err error) {
+	// check the level
+	if _, err = flate.NewWriter(ioutil.Discard, level); err != nil {
+		return
+	}
+	c := &compressDB{db: db, level: level}
+	if c.space, err = countCompressed(db); err != nil {
+		return
+	}
+	cdb = c
+	return
+}
+
+// countCompressed returns difference between length
+// of original and stored values of given DB
+func countCompressed(db DB) (space int, err error) {
+	err = db.View(func(tx Tv) error {
+		return tx.Objects().Ascend(func(key cipher.SHA256,
+			stored []byte) (_ error) {
+
+			space += compressedSpace(key, stored)
+			return
+		})
+	})
+	return
+}
+
+// compressedSpace returns difference between length
+// of original and given stored value
+func compressedSpace(key cipher.SHA256, stored []byte) int {
+	_, ln, _, err := compressHeader(key, stored)
+	if err != nil {
+		return 0
+	}
+	return int(ln) - len(stored)
+}
+
+// compressTag returns header of compressed value
+func compressTag(buf *bytes.Buffer, method byte, key cipher.SHA256) {
+	buf.Write(compressMagic)
+	buf.WriteByte(method)
+	buf.Write(key[:compressTagLen])
+}
+
+// compress returns value to store
+func (c *compressDB) compress(key cipher.SHA256, value []byte) []byte {
+
+	if len(value) >= compressMinLen {
+		var buf bytes.Buffer
+		compressTag(&buf, compressFlate, key)
+		var ln [binary.MaxVarintLen64]byte
+		buf.Write(ln[:binary.PutUvarint(ln[:], uint64(len(value)))])
+
+		fw, err := flate.NewWriter(&buf, c.level)
+		if err != nil {
+			panic(err) // never happens, the level is checked
+		}
+		fw.Write(value)
+		fw.Close()
+
+		if buf.Len() < len(value) {
+			return buf.Bytes()
+		}
+	}
+
+	if !bytes.HasPrefix(value, compressMagic) {
+		return value // as is
+	}
+
+	// keep values that starts with the magic
+	var buf bytes.Buffer
+	buf.Grow(len(compressMagic) + 2 + compressTagLen + len(value))
+	compressTag(&buf, compressRaw, key)
+	buf.WriteByte(0)
+	buf.Write(value)
+	return buf.Bytes()
+}
+
+// compressHeader parses header of stored value of object with
+// given key returning method, length of original value and the
+// value after header. Value without valid header is not compressed
+func compressHeader(key cipher.SHA256, stored []byte) (method byte,
+	ln uint64, value []byte, err error) {
+
+	hl := len(compressMagic) + 1 + compressTagLen
+	if len(stored) < hl || !bytes.HasPrefix(stored, compressMagic) ||
+		!bytes.Equal(stored[hl-compressTagLen:hl], key[:compressTagLen]) {
+
+		return compressRaw, uint64(len(stored)), stored, nil
+	}
+	method, value = stored[len(compressMagic)], stored[hl:]
+	var n int
+	if ln, n = binary.Uvarint(value); n <= 0 {
+		err = errCompressHeader
+		return
+	}
+	value = value[n:]
+	if method == compressRaw {
+		ln = uint64(len(value))
+	}
+	return
+}
+
+// decompress returns original value of stored one
+func decompress(key cipher.SHA256, stored []byte) (value []byte) {
+	if !bytes.HasPrefix(stored, compressMagic) {
+		return stored
+	}
+	value, err := decode(key, stored)
+	if err != nil {
+		return stored // malformed
+	}
+	return
+}
+
+func decode(key cipher.SHA256, stored []byte) (value []byte, err error) {
+	var method byte
+	var ln uint64
+	if method, ln, value, err = compressHeader(key, stored); err != nil {
+		return
+	}
+	switch method {
+	case compressRaw:
+	case compressFlate:
+		fr := flate.NewReader(bytes.NewReader(value))
+		value = make([]byte, ln)
+		_, err = io.ReadFull(fr, value)
+		fr.Close()
+	default:
+		err = errCompressHeader
+	}
+	return
+}
+
+func (c *compressDB) View(fn func(t Tv) error) error {
+	return c.db.View(func(tx Tv) error {
+		return fn(&compressTv{tx})
+	})
+}
+
+func (c *compressDB) Update(fn func(t Tu) error) (err error) {
+	ct := &compressTu{c: c}
+	err = c.db.Update(func(tx Tu) error {
+		ct.tx = tx
+		return fn(ct)
+	})
+	if err == nil {
+		c.update(ct.space)
+	}
+	return
+}
+
+// Batch can call given function many times,
+// last call is committed if the Batch returns nil
+func (c *compressDB) Batch(fn func(t Tu) error) (err error) {
+	var last *compressTu
+	err = Batch(c.db, func(tx Tu) error {
+		last = &compressTu{c: c, tx: tx}
+		return fn(last)
+	})
+	if err == nil && last != nil {
+		c.update(last.space)
+	}
+	return
+}
+
+// update the counter by given changes
+func (c *compressDB) update(space int) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.space += space
+}
+
+// Stat reports length of original values as the Space
+func (c *compressDB) Stat() (s Stat) {
+	s = c.db.Stat()
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	s.Space += Space(c.space)
+	return
+}
+
+func (c *compressDB) recountStat() (err error) {
+	if err = RecountStat(c.db); err != nil {
+		return
+	}
+	var space int
+	if space, err = countCompressed(c.db); err != nil {
+		return
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.space = space
+	return
+}
+
+func (c *compressDB) Close() error {
+	return c.db.Close()
+}
+
+type compressTv struct {
+	tx Tv
+}
+
+func (c *compressTv) Objects() ViewObjects {
+	return &compressObjects{nil, c.tx.Objects(), nil}
+}
+
+func (c *compressTv) Feeds() ViewFeeds {
+	return c.tx.Feeds()
+}
+
+func (c *compressTv) Misc(namespace string) ViewMisc {
+	return c.tx.Misc(namespace)
+}
+
+func (c *compressTv) Namespaces() []string {
+	return c.tx.Namespaces()
+}
+
+// the space is changes of the counter
+type compressTu struct {
+	c     *compressDB
+	tx    Tu
+	space int
+}
+
+func (c *compressTu) Objects() UpdateObjects {
+	objs := c.tx.Objects()
+	return &compressObjects{c, objs, objs}
+}
+
+func (c *compressTu) Feeds() UpdateFeeds {
+	return c.tx.Feeds()
+}
+
+func (c *compressTu) Misc(namespace string) UpdateMisc {
+	return c.tx.Misc(namespace)
+}
+
+func (c *compressTu) Namespaces() []string {
+	return c.tx.Namespaces()
+}
+
+func (c *compressTu) DelNamespace(namespace string) error {
+	return c.tx.DelNamespace(namespace)
+}
+
+// the tu and upd are nil for read-only transactions
+type compressObjects struct {
+	tu   *compressTu
+	view ViewObjects
+	upd  UpdateObjects
+}
+
+func (c *compressObjects) Get(key cipher.SHA256) (value []byte) {
+	if stored := c.view.Get(key); stored != nil {
+		value = decompress(key, stored)
+	}
+	return
+}
+
+func (c *compressObjects) GetCopy(key cipher.SHA256) (value []byte) {
+	if stored := c.view.GetCopy(key); stored != nil {
+		value = decompress(key, stored)
+	}
+	return
+}
+
+func (c *compressObjects) IsExist(key cipher.SHA256) bool {
+	return c.view.IsExist(key)
+}
+
+func (c *compressObjects) Ascend(
+	fn func(key cipher.SHA256, value []byte) error) error {
+
+	return c.view.Ascend(func(key cipher.SHA256, stored []byte) error {
+		return fn(key, decompress(key, stored))
+	})
+}
+
+func (c *compressObjects) Cursor() ObjectsCursor {
+	return &compressCursor{c.view.Cursor()}
+}
+
+func (c *compressObjects) RangeCursor(from, to cipher.SHA256) ObjectsCursor {
+	return &compressCursor{c.view.RangeCursor(from, to)}
+}
+
+func (c *compressObjects) Refs(key cipher.SHA256) uint32 {
+	return c.view.Refs(key)
+}
+
+func (c *compressObjects) NeedRecount() bool {
+	return c.view.NeedRecount()
+}
+
+// forget subtracts space of stored value of given object
+func (c *compressObjects) forget(key cipher.SHA256) {
+	if stored := c.view.Get(key); stored != nil {
+		c.tu.space -= compressedSpace(key, stored)
+	}
+}
+
+// compress returns value to store and counts its space
+func (c *compressObjects) compress(key cipher.SHA256, value []byte) []byte {
+	c.forget(key)
+	stored := c.tu.c.compress(key, value)
+	c.tu.space += compressedSpace(key, stored)
+	return stored
+}
+
+func (c *compressObjects) Del(key cipher.SHA256) error {
+	c.forget(key)
+	return c.upd.Del(key)
+}
+
+func (c *compressObjects) Set(key cipher.SHA256, value []byte) error {
+	return c.upd.Set(key, c.compress(key, value))
+}
+
+func (c *compressObjects) Add(value []byte) (key cipher.SHA256, err error) {
+	key = cipher.SumSHA256(value)
+	err = c.Set(key, value)
+	return
+}
+
+func (c *compressObjects) SetMap(m map[cipher.SHA256][]byte) error {
+	stored := make(map[cipher.SHA256][]byte, len(m))
+	for k, v := range m {
+		stored[k] = c.compress(k, v)
+	}
+	return c.upd.SetMap(stored)
+}
+
+func (c *compressObjects) AscendDel(
+	fn func(key cipher.SHA256, value []byte) (bool, error)) error {
+
+	return c.upd.AscendDel(func(key cipher.SHA256, stored []byte) (del bool,
+		err error) {
+
+		if del, err = fn(key, decompress(key, stored)); del {
+			c.tu.space -= compressedSpace(key, stored)
+		}
+		return
+	})
+}
+
+func (c *compressObjects) Inc(key cipher.SHA256) (uint32, error) {
+	return c.upd.Inc(key)
+}
+
+func (c *compressObjects) Dec(key cipher.SHA256) (uint32, error) {
+	return c.upd.Dec(key)
+}
+
+func (c *compressObjects) SetRefs(key cipher.SHA256, rc uint32) error {
+	return c.upd.SetRefs(key, rc)
+}
+
+func (c *compressObjects) Recounted() error {
+	return c.upd.Recounted()
+}
+
+type compressCursor struct {
+	cur ObjectsCursor
+}
+
+func (c *compressCursor) decompress(key cipher.SHA256, stored []byte,
+	ok bool) (cipher.SHA256, []byte, bool) {
+
+	if !ok {
+		return key, nil, false
+	}
+	return key, decompress(key, stored), true
+}
+
+func (c *compressCursor) First() (cipher.SHA256, []byte, bool) {
+	return c.decompress(c.cur.First())
+}
+
+func (c *compressCursor) Last() (cipher.SHA256, []byte, bool) {
+	return c.decompress(c.cur.Last())
+}
+
+func (c *compressCursor) Seek(key cipher.SHA256) (cipher.SHA256, []byte,
+	bool) {
+
+	return c.decompress(c.cur.Seek(key))
+}
+
+func (c *compressCursor) Next() (cipher.SHA256, []byte, bool) {
+	return c.decompress(c.cur.Next())
+}
+
+func (c *compressCursor) Prev() (cipher.SHA256, []byte, bool) {
+	return c.decompress(c.cur.Prev())
+}
@@ -0,0 +1,156 @@
+package data
+
+import (
+	"bytes"
+	"compress/flate"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestNewCompressDB(t *testing.T) {
+	// NewCompressDB(db DB, level int) (cdb DB, err error)
+
+	if _, err := NewCompressDB(NewMemoryDB(), 100); err == nil {
+		t.Error("missing error for invalid level")
+	}
+
+	db := NewMemoryDB()
+
+	compressible := bytes.Repeat([]byte("string-heavy feed "), 100)
+	short := []byte("short")
+	magic := append(append([]byte{}, compressMagic...), "old value"...)
+
+	// old database
+	err := db.Update(func(tx Tu) (err error) {
+		_, err = tx.Objects().Add(magic)
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cdb, err := NewCompressDB(db, flate.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cdb.Close()
+
+	err = cdb.Update(func(tx Tu) (err error) {
+		objs := tx.Objects()
+		for _, val := range [][]byte{compressible, short} {
+			if _, err = objs.Add(val); err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("stored", func(t *testing.T) {
+		db.View(func(tx Tv) (_ error) {
+			objs := tx.Objects()
+			got := objs.Get(cipher.SumSHA256(compressible))
+			if len(got) >= len(compressible) {
+				t.Error("not compressed")
+			}
+			if got = objs.Get(cipher.SumSHA256(short)); !bytes.Equal(got, short) {
+				t.Error("short value is compressed")
+			}
+			return
+		})
+	})
+
+	t.Run("read", func(t *testing.T) {
+		cdb.View(func(tx Tv) (_ error) {
+			objs := tx.Objects()
+			for _, val := range [][]byte{compressible, short, magic} {
+				got := objs.Get(cipher.SumSHA256(val))
+				if !bytes.Equal(got, val) {
+					t.Errorf("wrong value %q", got)
+				}
+			}
+			return
+		})
+	})
+
+	t.Run("magic", func(t *testing.T) {
+		// new value that starts with the magic
+		err := cdb.Update(func(tx Tu) (err error) {
+			if err = tx.Objects().Del(cipher.SumSHA256(magic)); err != nil {
+				return
+			}
+			_, err = tx.Objects().Add(magic)
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cdb.View(func(tx Tv) (_ error) {
+			got := tx.Objects().Get(cipher.SumSHA256(magic))
+			if !bytes.Equal(got, magic) {
+				t.Errorf("wrong value %q", got)
+			}
+			return
+		})
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		s := cdb.Stat()
+		space := Space(len(compressible) + len(short) + len(magic))
+		if s.Space != space {
+			t.Errorf("wrong space: want %d, got %d", space, s.Space)
+		}
+		if s.PhysicalSpace >= s.Space {
+			t.Error("wrong physical space:", s.PhysicalSpace)
+		}
+		if s.PhysicalSpace != db.Stat().PhysicalSpace {
+			t.Error("physical space differs")
+		}
+		// counters
+		reopened, err := NewCompressDB(db, flate.BestCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := reopened.Stat().Space; got != s.Space {
+			t.Errorf("wrong space of reopened: want %d, got %d", s.Space, got)
+		}
+		err = cdb.Update(func(tx Tu) error {
+			return tx.Objects().Del(cipher.SumSHA256(compressible))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		space -= Space(len(compressible))
+		if got := cdb.Stat().Space; got != space {
+			t.Errorf("wrong space after deleting: want %d, got %d", space, got)
+		}
+	})
+
+	t.Run("legacy", func(t *testing.T) {
+		// old value that looks like a compressed one
+		// without the tag
+		var buf bytes.Buffer
+		buf.Write(compressMagic)
+		buf.WriteByte(compressRaw)
+		buf.WriteString("\x03old")
+		legacy := buf.Bytes()
+		err := db.Update(func(tx Tu) (err error) {
+			_, err = tx.Objects().Add(legacy)
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cdb.View(func(tx Tv) (_ error) {
+			got := tx.Objects().Get(cipher.SumSHA256(legacy))
+			if !bytes.Equal(got, legacy) {
+				t.Errorf("wrong value %q", got)
+			}
+			return
+		})
+	})
+
+}
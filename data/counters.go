@@ -0,0 +1,176 @@
+package data
+
+import (
+	"encoding/binary"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// Drive and memory databases keep statistic counters to
+// avoid scanning whole database every Stat call. A
+// transaction collects changes of the counters in
+// statDelta and stored counters are updated with the
+// delta in the same transaction, if it succeeds
+
+// statDelta represents changes of statistic
+// made by an Update transaction
+type statDelta struct {
+	objects int // amount of objects
+	space   int // space of objects
+
+	feeds      map[cipher.PubKey]*feedDelta
+	namespaces map[string]*nsDelta
+}
+
+// feedDelta represents changes of statistic
+// of a feed made by an Update transaction
+type feedDelta struct {
+	deleted bool // feed deleted, reset stored counters
+	roots   int  // amount of roots
+	space   int  // space of roots
+}
+
+// nsDelta represents changes of statistic of
+// a namespace made by an Update transaction
+type nsDelta struct {
+	deleted bool // namespace deleted, reset stored counters
+	keys    int  // amount of misc-objects
+	space   int  // space of keys and values
+}
+
+// addObject records creation of an object of given length
+func (s *statDelta) addObject(ln int) {
+	s.objects++
+	s.space += ln
+}
+
+// delObject records deletion of an object of given length
+func (s *statDelta) delObject(ln int) {
+	s.objects--
+	s.space -= ln
+}
+
+// feed returns delta of given feed creating it if necessary
+func (s *statDelta) feed(pk cipher.PubKey) (fd *feedDelta) {
+	if s.feeds == nil {
+		s.feeds = make(map[cipher.PubKey]*feedDelta)
+	}
+	if fd = s.feeds[pk]; fd == nil {
+		fd = new(feedDelta)
+		s.feeds[pk] = fd
+	}
+	return
+}
+
+// delFeed records deletion of given feed with all roots
+func (s *statDelta) delFeed(pk cipher.PubKey) {
+	*s.feed(pk) = feedDelta{deleted: true}
+}
+
+// addRoot records creation of a root of given length
+func (s *statDelta) addRoot(pk cipher.PubKey, ln int) {
+	fd := s.feed(pk)
+	fd.roots++
+	fd.space += ln
+}
+
+// delRoot records deletion of root of given length
+func (s *statDelta) delRoot(pk cipher.PubKey, ln int) {
+	fd := s.feed(pk)
+	fd.roots--
+	fd.space -= ln
+}
+
+// namespace returns delta of given namespace
+// creating it if necessary
+func (s *statDelta) namespace(ns string) (nd *nsDelta) {
+	if s.namespaces == nil {
+		s.namespaces = make(map[string]*nsDelta)
+	}
+	if nd = s.namespaces[ns]; nd == nil {
+		nd = new(nsDelta)
+		s.namespaces[ns] = nd
+	}
+	return
+}
+
+// delNamespace records deletion of all
+// misc-objects of given namespace
+func (s *statDelta) delNamespace(ns string) {
+	*s.namespace(ns) = nsDelta{deleted: true}
+}
+
+// addMisc records creation of a misc-object
+// with given lengths of key and value
+func (s *statDelta) addMisc(ns string, kl, vl int) {
+	nd := s.namespace(ns)
+	nd.keys++
+	nd.space += kl + vl
+}
+
+// delMisc records deletion of a misc-object
+// with given lengths of key and value
+func (s *statDelta) delMisc(ns string, kl, vl int) {
+	nd := s.namespace(ns)
+	nd.keys--
+	nd.space -= kl + vl
+}
+
+// empty returns true if nothing changed
+func (s *statDelta) empty() bool {
+	return s.objects == 0 && s.space == 0 && len(s.feeds) == 0 &&
+		len(s.namespaces) == 0
+}
+
+// apply the delta to given stored counters
+func (f *feedDelta) apply(roots, space int) (int, int) {
+	if f.deleted {
+		roots, space = 0, 0
+	}
+	return roots + f.roots, space + f.space
+}
+
+// apply the delta to given stored counters
+func (n *nsDelta) apply(keys, space int) (int, int) {
+	if n.deleted {
+		keys, space = 0, 0
+	}
+	return keys + n.keys, space + n.space
+}
+
+// encodeCounters encodes pair of counters
+func encodeCounters(n, space int) (b []byte) {
+	b = make([]byte, 16)
+	binary.BigEndian.PutUint64(b, uint64(n))
+	binary.BigEndian.PutUint64(b[8:], uint64(space))
+	return
+}
+
+// decodeCounters decodes pair of counters, it returns
+// zeroes if given slice is malformed
+func decodeCounters(b []byte) (n, space int) {
+	if len(b) != 16 {
+		return
+	}
+	n = int(binary.BigEndian.Uint64(b))
+	space = int(binary.BigEndian.Uint64(b[8:]))
+	return
+}
+
+// A statRecounter is DB that keeps statistic counters
+type statRecounter interface {
+	recountStat() error
+}
+
+// RecountStat rebuilds statistic counters of given DB scanning
+// whole database. Drive and memory databases keep counters
+// to make Stat fast. Counters are updated in every Update
+// transaction and the RecountStat is a fallback if they are
+// wrong for some reason. The RecountStat does nothing if given
+// DB doesn't keep counters
+func RecountStat(db DB) error {
+	if sr, ok := db.(statRecounter); ok {
+		return sr.recountStat()
+	}
+	return nil
+}
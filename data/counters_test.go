@@ -0,0 +1,152 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// changes database using all methods
+// that affect statistic counters
+func testChangeStat(t *testing.T, db DB) {
+
+	pk1, _ := cipher.GenerateKeyPair()
+	pk2, _ := cipher.GenerateKeyPair()
+
+	err := db.Update(func(tx Tu) (err error) {
+		objs := tx.Objects()
+		for _, val := range []string{"one", "two", "three", "four"} {
+			if _, err = objs.Add([]byte(val)); err != nil {
+				return
+			}
+		}
+		key := cipher.SumSHA256([]byte("one"))
+		if err = objs.Set(key, []byte("replaced")); err != nil {
+			return
+		}
+		if err = objs.Del(cipher.SumSHA256([]byte("two"))); err != nil {
+			return
+		}
+		err = objs.AscendDel(func(key cipher.SHA256, _ []byte) (bool, error) {
+			return key == cipher.SumSHA256([]byte("three")), nil
+		})
+		if err != nil {
+			return
+		}
+		feeds := tx.Feeds()
+		for _, pk := range []cipher.PubKey{pk1, pk2} {
+			if err = feeds.Add(pk); err != nil {
+				return
+			}
+			roots := feeds.Roots(pk)
+			for i, content := range []string{"zero", "one", "two", "three"} {
+				rp := getRootPack(uint64(i), content)
+				if err = roots.Add(&rp); err != nil {
+					return
+				}
+			}
+			if err = roots.MarkFull(3); err != nil {
+				return
+			}
+			if err = roots.Del(2); err != nil {
+				return
+			}
+			if err = roots.DelBefore(1); err != nil {
+				return
+			}
+		}
+		return feeds.Del(pk2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rollback
+	db.Update(func(tx Tu) (_ error) {
+		tx.Objects().Add([]byte("rollback"))
+		tx.Feeds().Del(pk1)
+		return ErrNotFound
+	})
+}
+
+func testRecountStat(t *testing.T, db DB) {
+
+	if err := RecountStat(db); err != nil {
+		t.Fatal(err)
+	}
+	if s := db.Stat(); s.Objects != 0 || s.Space != 0 || s.Feeds != nil {
+		t.Error("wrong statistic of empty database:", s)
+	}
+
+	testChangeStat(t, db)
+
+	s := db.Stat()
+	if s.Objects != 2 || s.Space != Space(len("replaced")+len("four")) {
+		t.Error("wrong statistic of objects:", s)
+	}
+	if len(s.Feeds) != 1 {
+		t.Fatal("wrong statistic of feeds:", s)
+	}
+	for _, fs := range s.Feeds {
+		if fs.Roots != 2 {
+			t.Error("wrong number of roots:", fs.Roots)
+		}
+	}
+
+	if err := RecountStat(db); err != nil {
+		t.Fatal(err)
+	}
+	if recounted := db.Stat(); !reflect.DeepEqual(s, recounted) {
+		t.Errorf("wrong counters: %s, recounted: %s", s, recounted)
+	}
+}
+
+func TestRecountStat(t *testing.T) {
+	// RecountStat(db DB) error
+
+	t.Run("memory", func(t *testing.T) {
+		testRecountStat(t, NewMemoryDB())
+	})
+
+	t.Run("drive", func(t *testing.T) {
+		db, cleanUp := testDriveDB(t)
+		defer cleanUp()
+		testRecountStat(t, db)
+	})
+
+	t.Run("compress", func(t *testing.T) {
+		db, err := NewCompressDB(NewMemoryDB(), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testRecountStat(t, db)
+	})
+
+}
+
+func Test_migrate(t *testing.T) {
+
+	db, cleanUp := testDriveDB(t)
+	defer cleanUp()
+
+	testChangeStat(t, db)
+	want := db.Stat()
+
+	// database without version has no counters
+	// and keeps RootMeta inside RootPack
+	b := db.(*driveDB).bolt
+	if err := b.Update(testLegacyRoots); err != nil {
+		t.Fatal(err)
+	}
+
+	err := b.Update(func(t *bolt.Tx) error { return migrate(t, nil) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Stat(); !reflect.DeepEqual(want, got) {
+		t.Errorf("wrong counters after migration: %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,801 @@
+package data
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	sky "github.com/skycoin/skycoin/src/cipher"
+)
+
+// crypt errors
+var (
+	// ErrWrongKey occurs when NewCryptDB called with
+	// passphrase that differs from passphrase the
+	// database encrypted with
+	ErrWrongKey = errors.New("wrong key of encrypted database")
+	// ErrNotEncrypted occurs when NewCryptDB called with
+	// non-empty database that is not encrypted
+	ErrNotEncrypted = errors.New("database is not encrypted")
+	// ErrReservedKey occurs when you try to change
+	// misc-object used by encrypted database
+	ErrReservedKey = errors.New("reserved key")
+)
+
+// length of random salt, the key is always
+// 32 bytes long (AES-256)
+const cryptSaltLen = 16
+
+var (
+	// misc key of salt and check value of encrypted database
+	cryptMetaKey = []byte("\x00cxo:crypt")
+	// plain text of the check value
+	cryptCheck = []byte("cxo encrypted database")
+)
+
+// amount of PBKDF2 iterations (a variable for tests)
+var cryptIterations = 1 << 16
+
+// ReadKeyFile reads passphrase for NewCryptDB from given
+// file. Trailing new line (if any) is not a part of the
+// passphrase
+func ReadKeyFile(path string) (passphrase []byte, err error) {
+	if passphrase, err = ioutil.ReadFile(path); err != nil {
+		return
+	}
+	passphrase = bytes.TrimRight(passphrase, "\r\n")
+	if len(passphrase) == 0 {
+		err = errors.New("empty key file: " + path)
+	}
+	return
+}
+
+// deriveKey is PBKDF2 with HMAC-SHA256 for one block
+func deriveKey(passphrase, salt []byte) (key []byte) {
+	mac := hmac.New(sha256.New, passphrase)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1}) // block number
+	u := mac.Sum(nil)
+	key = append([]byte{}, u...)
+	for i := 1; i < cryptIterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return
+}
+
+type cryptDB struct {
+	db   DB
+	aead cipher.AEAD
+}
+
+// NewCryptDB wraps given DB encrypting values of objects,
+// root objects and values of misc-objects using AES-GCM with
+// key derived from given passphrase. Keys of objects are
+// SHA256 of plain values. Thus, content addressing works as
+// usual. Random salt and value to check the passphrase are
+// stored in the DB. Given DB must be empty or encrypted by
+// the NewCryptDB before. The NewCryptDB returns ErrWrongKey
+// if passphrase is wrong. Closing returned DB closes given
+// DB. Values that can't be decrypted or authenticated (e.g.
+// corrupted on disk) are reported as missing: nil values of
+// objects and misc-objects and nil Root of RootPack
+func NewCryptDB(db DB, passphrase []byte) (cdb DB, err error) {
+
+	var meta []byte
+	err = db.View(func(tx Tv) (_ error) {
+		meta = tx.Misc("").GetCopy(cryptMetaKey)
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	c := &cryptDB{db: db}
+
+	if meta == nil {
+		if !isEmpty(db) {
+			return nil, ErrNotEncrypted
+		}
+		salt := make([]byte, cryptSaltLen)
+		if _, err = io.ReadFull(rand.Reader, salt); err != nil {
+			return
+		}
+		if c.aead, err = newAEAD(passphrase, salt); err != nil {
+			return
+		}
+		meta = append(salt, c.seal(cryptCheck, cryptMetaKey)...)
+		err = db.Update(func(tx Tu) error {
+			return tx.Misc("").Set(cryptMetaKey, meta)
+		})
+		if err != nil {
+			return
+		}
+		return c, nil
+	}
+
+	if len(meta) < cryptSaltLen {
+		return nil, ErrWrongKey
+	}
+	if c.aead, err = newAEAD(passphrase, meta[:cryptSaltLen]); err != nil {
+		return
+	}
+	check, err := c.open(meta[cryptSaltLen:], cryptMetaKey)
+	if err != nil || !bytes.Equal(check, cryptCheck) {
+		return nil, ErrWrongKey
+	}
+	return c, nil
+}
+
+func newAEAD(passphrase, salt []byte) (aead cipher.AEAD, err error) {
+	var block cipher.Block
+	if block, err = aes.NewCipher(deriveKey(passphrase, salt)); err != nil {
+		return
+	}
+	return cipher.NewGCM(block)
+}
+
+// isEmpty returns true if given DB has no
+// objects, feeds and misc-objects
+func isEmpty(db DB) (empty bool) {
+	db.View(func(tx Tv) (_ error) {
+		empty = true
+		stop := func() error { empty = false; return ErrStopIteration }
+		tx.Objects().Ascend(func(sky.SHA256, []byte) error { return stop() })
+		tx.Feeds().Ascend(func(sky.PubKey) error { return stop() })
+		tx.Misc("").Ascend(func(_, _ []byte) error { return stop() })
+		if len(tx.Namespaces()) > 0 {
+			empty = false
+		}
+		return
+	})
+	return
+}
+
+// seal encrypts given value, the ad is additional data
+// that binds the encrypted value to its key
+func (c *cryptDB) seal(value, ad []byte) (sealed []byte) {
+	nonce := make([]byte, c.aead.NonceSize(),
+		c.aead.NonceSize()+len(value)+c.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		panic(err) // critical
+	}
+	return c.aead.Seal(nonce, nonce, value, ad)
+}
+
+func (c *cryptDB) open(sealed, ad []byte) (value []byte, err error) {
+	ns := c.aead.NonceSize()
+	if len(sealed) < ns {
+		return nil, ErrWrongKey
+	}
+	return c.aead.Open(nil, sealed[:ns], sealed[ns:], ad)
+}
+
+// openOrNil returns nil if value can't be decrypted,
+// thus corrupted value is reported as missing
+func (c *cryptDB) openOrNil(sealed, ad []byte) (value []byte) {
+	value, _ = c.open(sealed, ad)
+	return
+}
+
+// additional data of root
+func rootAD(pk sky.PubKey, seq uint64) []byte {
+	return append(append([]byte{}, pk[:]...), utob(seq)...)
+}
+
+// additional data of misc-object
+func miscAD(namespace string, key []byte) []byte {
+	if namespace == "" {
+		return append([]byte{'m'}, key...)
+	}
+	ad := append([]byte{'n'}, namespacePrefix(namespace)...)
+	return append(ad, key...)
+}
+
+func (c *cryptDB) View(fn func(t Tv) error) error {
+	return c.db.View(func(tx Tv) error {
+		return fn(&cryptTv{c, tx})
+	})
+}
+
+func (c *cryptDB) Update(fn func(t Tu) error) error {
+	return c.db.Update(func(tx Tu) error {
+		return fn(&cryptTu{c, tx})
+	})
+}
+
+func (c *cryptDB) Batch(fn func(t Tu) error) error {
+	return Batch(c.db, func(tx Tu) error {
+		return fn(&cryptTu{c, tx})
+	})
+}
+
+// Stat reports Space of plain values of objects, since
+// every encrypted value has constant overhead; usage of
+// namespaces reported for plain values too, excluding
+// the hidden meta key
+func (c *cryptDB) Stat() (s Stat) {
+	s = c.db.Stat()
+	overhead := c.aead.NonceSize() + c.aead.Overhead()
+	s.Space -= Space(s.Objects * overhead)
+	for ns, st := range s.Namespaces {
+		st.Space -= Space(st.Keys * overhead)
+		if ns == "" {
+			st.Keys--
+			st.Space -= Space(len(cryptMetaKey) + cryptSaltLen +
+				len(cryptCheck))
+		}
+		if st.Keys <= 0 {
+			delete(s.Namespaces, ns)
+			continue
+		}
+		s.Namespaces[ns] = st
+	}
+	if len(s.Namespaces) == 0 {
+		s.Namespaces = nil
+	}
+	return
+}
+
+func (c *cryptDB) recountStat() error {
+	return RecountStat(c.db)
+}
+
+func (c *cryptDB) Close() error {
+	return c.db.Close()
+}
+
+type cryptTv struct {
+	c  *cryptDB
+	tx Tv
+}
+
+func (c *cryptTv) Objects() ViewObjects {
+	return &cryptObjects{c.c, c.tx.Objects(), nil}
+}
+
+func (c *cryptTv) Feeds() ViewFeeds {
+	return &cryptViewFeeds{c.c, c.tx.Feeds()}
+}
+
+func (c *cryptTv) Misc(namespace string) ViewMisc {
+	return &cryptMisc{c.c, namespace, c.tx.Misc(namespace), nil}
+}
+
+func (c *cryptTv) Namespaces() []string {
+	return c.tx.Namespaces()
+}
+
+type cryptTu struct {
+	c  *cryptDB
+	tx Tu
+}
+
+func (c *cryptTu) Objects() UpdateObjects {
+	objs := c.tx.Objects()
+	return &cryptObjects{c.c, objs, objs}
+}
+
+func (c *cryptTu) Feeds() UpdateFeeds {
+	return &cryptFeeds{c.c, c.tx.Feeds()}
+}
+
+func (c *cryptTu) Misc(namespace string) UpdateMisc {
+	misc := c.tx.Misc(namespace)
+	return &cryptMisc{c.c, namespace, misc, misc}
+}
+
+func (c *cryptTu) Namespaces() []string {
+	return c.tx.Namespaces()
+}
+
+// DelNamespace keeps the hidden meta key
+func (c *cryptTu) DelNamespace(namespace string) error {
+	if namespace == "" {
+		return c.Misc(namespace).AscendDel(func(_, _ []byte) (bool, error) {
+			return true, nil
+		})
+	}
+	return c.tx.DelNamespace(namespace)
+}
+
+//
+// objects
+//
+
+// the upd is nil for read-only transactions
+type cryptObjects struct {
+	c    *cryptDB
+	view ViewObjects
+	upd  UpdateObjects
+}
+
+func (c *cryptObjects) Get(key sky.SHA256) (value []byte) {
+	if sealed := c.view.Get(key); sealed != nil {
+		value = c.c.openOrNil(sealed, key[:])
+	}
+	return
+}
+
+// GetCopy is the same as Get, because decrypted value is a copy
+func (c *cryptObjects) GetCopy(key sky.SHA256) []byte {
+	return c.Get(key)
+}
+
+func (c *cryptObjects) IsExist(key sky.SHA256) bool {
+	return c.view.IsExist(key)
+}
+
+func (c *cryptObjects) Ascend(
+	fn func(key sky.SHA256, value []byte) error) error {
+
+	return c.view.Ascend(func(key sky.SHA256, sealed []byte) error {
+		return fn(key, c.c.openOrNil(sealed, key[:]))
+	})
+}
+
+func (c *cryptObjects) Refs(key sky.SHA256) uint32 {
+	return c.view.Refs(key)
+}
+
+func (c *cryptObjects) NeedRecount() bool {
+	return c.view.NeedRecount()
+}
+
+func (c *cryptObjects) Del(key sky.SHA256) error {
+	return c.upd.Del(key)
+}
+
+func (c *cryptObjects) Set(key sky.SHA256, value []byte) error {
+	return c.upd.Set(key, c.c.seal(value, key[:]))
+}
+
+func (c *cryptObjects) Add(value []byte) (key sky.SHA256, err error) {
+	key = sky.SumSHA256(value)
+	err = c.Set(key, value)
+	return
+}
+
+func (c *cryptObjects) SetMap(m map[sky.SHA256][]byte) error {
+	sealed := make(map[sky.SHA256][]byte, len(m))
+	for k, v := range m {
+		sealed[k] = c.c.seal(v, k[:])
+	}
+	return c.upd.SetMap(sealed)
+}
+
+func (c *cryptObjects) AscendDel(
+	fn func(key sky.SHA256, value []byte) (bool, error)) error {
+
+	return c.upd.AscendDel(func(key sky.SHA256, sealed []byte) (bool,
+		error) {
+
+		return fn(key, c.c.openOrNil(sealed, key[:]))
+	})
+}
+
+func (c *cryptObjects) Inc(key sky.SHA256) (uint32, error) {
+	return c.upd.Inc(key)
+}
+
+func (c *cryptObjects) Dec(key sky.SHA256) (uint32, error) {
+	return c.upd.Dec(key)
+}
+
+func (c *cryptObjects) SetRefs(key sky.SHA256, rc uint32) error {
+	return c.upd.SetRefs(key, rc)
+}
+
+func (c *cryptObjects) Recounted() error {
+	return c.upd.Recounted()
+}
+
+func (c *cryptObjects) Cursor() ObjectsCursor {
+	return &cryptObjectsCursor{c.c, c.view.Cursor()}
+}
+
+func (c *cryptObjects) RangeCursor(from, to sky.SHA256) ObjectsCursor {
+	return &cryptObjectsCursor{c.c, c.view.RangeCursor(from, to)}
+}
+
+type cryptObjectsCursor struct {
+	c   *cryptDB
+	cur ObjectsCursor
+}
+
+func (c *cryptObjectsCursor) open(key sky.SHA256, sealed []byte,
+	ok bool) (sky.SHA256, []byte, bool) {
+
+	if !ok {
+		return key, nil, false
+	}
+	return key, c.c.openOrNil(sealed, key[:]), true
+}
+
+func (c *cryptObjectsCursor) First() (sky.SHA256, []byte, bool) {
+	return c.open(c.cur.First())
+}
+
+func (c *cryptObjectsCursor) Last() (sky.SHA256, []byte, bool) {
+	return c.open(c.cur.Last())
+}
+
+func (c *cryptObjectsCursor) Seek(key sky.SHA256) (sky.SHA256, []byte,
+	bool) {
+
+	return c.open(c.cur.Seek(key))
+}
+
+func (c *cryptObjectsCursor) Next() (sky.SHA256, []byte, bool) {
+	return c.open(c.cur.Next())
+}
+
+func (c *cryptObjectsCursor) Prev() (sky.SHA256, []byte, bool) {
+	return c.open(c.cur.Prev())
+}
+
+//
+// feeds
+//
+
+type cryptViewFeeds struct {
+	c *cryptDB
+	ViewFeeds
+}
+
+func (c *cryptViewFeeds) Roots(pk sky.PubKey) ViewRoots {
+	if roots := c.ViewFeeds.Roots(pk); roots != nil {
+		return &cryptRoots{c.c, roots, nil}
+	}
+	return nil
+}
+
+type cryptFeeds struct {
+	c   *cryptDB
+	upd UpdateFeeds
+}
+
+func (c *cryptFeeds) IsExist(pk sky.PubKey) bool {
+	return c.upd.IsExist(pk)
+}
+
+func (c *cryptFeeds) List() []sky.PubKey {
+	return c.upd.List()
+}
+
+func (c *cryptFeeds) Ascend(fn func(pk sky.PubKey) error) error {
+	return c.upd.Ascend(fn)
+}
+
+func (c *cryptFeeds) Add(pk sky.PubKey) error {
+	return c.upd.Add(pk)
+}
+
+func (c *cryptFeeds) Del(pk sky.PubKey) error {
+	return c.upd.Del(pk)
+}
+
+func (c *cryptFeeds) AscendDel(fn func(pk sky.PubKey) (bool, error)) error {
+	return c.upd.AscendDel(fn)
+}
+
+func (c *cryptFeeds) Roots(pk sky.PubKey) UpdateRoots {
+	if roots := c.upd.Roots(pk); roots != nil {
+		return &cryptRoots{c.c, roots, roots}
+	}
+	return nil
+}
+
+//
+// roots
+//
+
+// The Root field of stored RootPack is encrypted and the
+// Hash field is hash of the encrypted Root, because
+// underlying DB checks the hash. Real hash is hash of
+// decrypted Root
+
+// the upd is nil for read-only transactions
+type cryptRoots struct {
+	c    *cryptDB
+	view ViewRoots
+	upd  UpdateRoots
+}
+
+func (c *cryptRoots) open(sealed *RootPack) (rp *RootPack, err error) {
+	if sealed == nil {
+		return
+	}
+	cp := *sealed
+	if cp.Root, err = c.c.open(sealed.Root,
+		rootAD(c.view.Feed(), sealed.Seq)); err != nil {
+
+		return
+	}
+	cp.Hash = sky.SumSHA256(cp.Root)
+	rp = &cp
+	return
+}
+
+// openOrEmpty returns RootPack with nil Root if the Root can't be
+// decrypted. Hash of such RootPack is hash of the encrypted Root.
+// Thus, corrupted Root can't be unpacked and is reported by Check
+func (c *cryptRoots) openOrEmpty(sealed *RootPack) (rp *RootPack) {
+	var err error
+	if rp, err = c.open(sealed); err != nil {
+		cp := *sealed
+		cp.Root = nil
+		rp = &cp
+	}
+	return
+}
+
+func (c *cryptRoots) Feed() sky.PubKey {
+	return c.view.Feed()
+}
+
+func (c *cryptRoots) Last() *RootPack {
+	return c.openOrEmpty(c.view.Last())
+}
+
+func (c *cryptRoots) Get(seq uint64) *RootPack {
+	return c.openOrEmpty(c.view.Get(seq))
+}
+
+func (c *cryptRoots) AtTime(t int64) *RootPack {
+	return c.openOrEmpty(c.view.AtTime(t))
+}
+
+func (c *cryptRoots) RangeTime(from, to int64,
+	fn func(rp *RootPack) error) error {
+
+	return c.view.RangeTime(from, to, c.each(fn))
+}
+
+func (c *cryptRoots) IsFull(seq uint64) bool {
+	return c.view.IsFull(seq)
+}
+
+func (c *cryptRoots) Meta(seq uint64) (RootMeta, error) {
+	return c.view.Meta(seq)
+}
+
+func (c *cryptRoots) each(fn func(rp *RootPack) error) func(*RootPack) error {
+	return func(sealed *RootPack) error {
+		return fn(c.openOrEmpty(sealed))
+	}
+}
+
+func (c *cryptRoots) Ascend(fn func(rp *RootPack) error) error {
+	return c.view.Ascend(c.each(fn))
+}
+
+func (c *cryptRoots) Descend(fn func(rp *RootPack) error) error {
+	return c.view.Descend(c.each(fn))
+}
+
+func (c *cryptRoots) Cursor() RootsCursor {
+	return &cryptRootsCursor{c, c.view.Cursor()}
+}
+
+func (c *cryptRoots) RangeCursor(from, to uint64) RootsCursor {
+	return &cryptRootsCursor{c, c.view.RangeCursor(from, to)}
+}
+
+type cryptRootsCursor struct {
+	c   *cryptRoots
+	cur RootsCursor
+}
+
+func (c *cryptRootsCursor) First() *RootPack {
+	return c.c.openOrEmpty(c.cur.First())
+}
+
+func (c *cryptRootsCursor) Last() *RootPack {
+	return c.c.openOrEmpty(c.cur.Last())
+}
+
+func (c *cryptRootsCursor) Seek(seq uint64) *RootPack {
+	return c.c.openOrEmpty(c.cur.Seek(seq))
+}
+
+func (c *cryptRootsCursor) Next() *RootPack {
+	return c.c.openOrEmpty(c.cur.Next())
+}
+
+func (c *cryptRootsCursor) Prev() *RootPack {
+	return c.c.openOrEmpty(c.cur.Prev())
+}
+
+func (c *cryptRoots) Add(rp *RootPack) (err error) {
+	if sky.SumSHA256(rp.Root) != rp.Hash {
+		return newRootError(c.Feed(), rp, "wrong hash of the root")
+	}
+	sealed := *rp
+	sealed.Root = c.c.seal(rp.Root, rootAD(c.Feed(), rp.Seq))
+	sealed.Hash = sky.SumSHA256(sealed.Root)
+	if err = c.upd.Add(&sealed); err != nil {
+		if re, ok := err.(*RootError); ok {
+			re.hash = rp.Hash // real hash
+		}
+	}
+	return
+}
+
+func (c *cryptRoots) Del(seq uint64) error {
+	return c.upd.Del(seq)
+}
+
+func (c *cryptRoots) MarkFull(seq uint64) error {
+	return c.upd.MarkFull(seq)
+}
+
+func (c *cryptRoots) SetMeta(seq uint64, rm RootMeta) error {
+	return c.upd.SetMeta(seq, rm)
+}
+
+func (c *cryptRoots) AscendDel(fn func(rp *RootPack) (bool, error)) error {
+	return c.upd.AscendDel(func(sealed *RootPack) (bool, error) {
+		return fn(c.openOrEmpty(sealed))
+	})
+}
+
+func (c *cryptRoots) DelBefore(seq uint64) error {
+	return c.upd.DelBefore(seq)
+}
+
+//
+// misc
+//
+
+// the upd is nil for read-only transactions
+type cryptMisc struct {
+	c    *cryptDB
+	ns   string // namespace
+	view ViewMisc
+	upd  UpdateMisc
+}
+
+// isMeta returns true if given key is the hidden
+// meta key, that lives in the default namespace only
+func (c *cryptMisc) isMeta(key []byte) bool {
+	return c.ns == "" && bytes.Equal(key, cryptMetaKey)
+}
+
+func (c *cryptMisc) Get(key []byte) (value []byte) {
+	if c.isMeta(key) {
+		return // hidden
+	}
+	if sealed := c.view.Get(key); sealed != nil {
+		value = c.c.openOrNil(sealed, miscAD(c.ns, key))
+	}
+	return
+}
+
+// GetCopy is the same as Get, because decrypted value is a copy
+func (c *cryptMisc) GetCopy(key []byte) []byte {
+	return c.Get(key)
+}
+
+func (c *cryptMisc) Ascend(fn func(key, value []byte) error) error {
+	return c.view.Ascend(func(key, sealed []byte) (err error) {
+		if c.isMeta(key) {
+			return // hidden
+		}
+		return fn(key, c.c.openOrNil(sealed, miscAD(c.ns, key)))
+	})
+}
+
+func (c *cryptMisc) Descend(fn func(key, value []byte) error) error {
+	return c.view.Descend(func(key, sealed []byte) (err error) {
+		if c.isMeta(key) {
+			return // hidden
+		}
+		return fn(key, c.c.openOrNil(sealed, miscAD(c.ns, key)))
+	})
+}
+
+func (c *cryptMisc) Cursor() MiscCursor {
+	return &cryptMiscCursor{c, c.view.Cursor()}
+}
+
+func (c *cryptMisc) RangeCursor(from, to []byte) MiscCursor {
+	return &cryptMiscCursor{c, c.view.RangeCursor(from, to)}
+}
+
+func (c *cryptMisc) PrefixCursor(prefix []byte) MiscCursor {
+	return &cryptMiscCursor{c, c.view.PrefixCursor(prefix)}
+}
+
+// the cryptMiscCursor skips the hidden meta key
+type cryptMiscCursor struct {
+	c   *cryptMisc
+	cur MiscCursor
+}
+
+func (c *cryptMiscCursor) open(key, sealed []byte) ([]byte, []byte) {
+	if key == nil {
+		return nil, nil
+	}
+	return key, c.c.c.openOrNil(sealed, miscAD(c.c.ns, key))
+}
+
+// forward skips the meta key moving forward
+func (c *cryptMiscCursor) forward(key, sealed []byte) ([]byte, []byte) {
+	if c.c.isMeta(key) {
+		key, sealed = c.cur.Next()
+	}
+	return c.open(key, sealed)
+}
+
+// backward skips the meta key moving backward
+func (c *cryptMiscCursor) backward(key, sealed []byte) ([]byte, []byte) {
+	if c.c.isMeta(key) {
+		key, sealed = c.cur.Prev()
+	}
+	return c.open(key, sealed)
+}
+
+func (c *cryptMiscCursor) First() ([]byte, []byte) {
+	return c.forward(c.cur.First())
+}
+
+func (c *cryptMiscCursor) Last() ([]byte, []byte) {
+	return c.backward(c.cur.Last())
+}
+
+func (c *cryptMiscCursor) Seek(seek []byte) ([]byte, []byte) {
+	return c.forward(c.cur.Seek(seek))
+}
+
+func (c *cryptMiscCursor) Next() ([]byte, []byte) {
+	return c.forward(c.cur.Next())
+}
+
+func (c *cryptMiscCursor) Prev() ([]byte, []byte) {
+	return c.backward(c.cur.Prev())
+}
+
+func (c *cryptMisc) Set(key, value []byte) error {
+	if c.isMeta(key) {
+		return ErrReservedKey
+	}
+	return c.upd.Set(key, c.c.seal(value, miscAD(c.ns, key)))
+}
+
+func (c *cryptMisc) Del(key []byte) error {
+	if c.isMeta(key) {
+		return ErrReservedKey
+	}
+	return c.upd.Del(key)
+}
+
+func (c *cryptMisc) AscendDel(fn func(key, value []byte) (bool, error)) error {
+	return c.upd.AscendDel(func(key, sealed []byte) (del bool, err error) {
+		if c.isMeta(key) {
+			return // hidden
+		}
+		return fn(key, c.c.openOrNil(sealed, miscAD(c.ns, key)))
+	})
+}
+
+func (c *cryptMisc) DescendDel(
+	fn func(key, value []byte) (bool, error)) error {
+
+	return c.upd.DescendDel(func(key, sealed []byte) (del bool, err error) {
+		if c.isMeta(key) {
+			return // hidden
+		}
+		return fn(key, c.c.openOrNil(sealed, miscAD(c.ns, key)))
+	})
+}
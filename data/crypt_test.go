@@ -0,0 +1,282 @@
+package data
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// set small amount of PBKDF2 iterations for tests
+func testCryptTuning() (restore func()) {
+	pi := cryptIterations
+	cryptIterations = 16
+	return func() {
+		cryptIterations = pi
+	}
+}
+
+func testNewCryptDB(t *testing.T, db DB, reopen func() DB) {
+
+	pk, _ := cipher.GenerateKeyPair()
+	value := []byte("secret value")
+
+	cdb, err := NewCryptDB(db, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cdb.Update(func(tx Tu) (err error) {
+		if _, err = tx.Objects().Add(value); err != nil {
+			return
+		}
+		feeds := tx.Feeds()
+		if err = feeds.Add(pk); err != nil {
+			return
+		}
+		rp := getRootPack(0, "secret root")
+		if err = feeds.Roots(pk).Add(&rp); err != nil {
+			return
+		}
+		return tx.Misc("").Set([]byte("key"), value)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("encrypted", func(t *testing.T) {
+		err := db.View(func(tx Tv) (_ error) {
+			raw := tx.Objects().Get(cipher.SumSHA256(value))
+			if raw == nil {
+				t.Error("missing object")
+			} else if bytes.Contains(raw, value) {
+				t.Error("object is not encrypted")
+			}
+			rp := tx.Feeds().Roots(pk).Get(0)
+			if rp == nil {
+				t.Error("missing root")
+			} else if bytes.Contains(rp.Root, []byte("secret root")) {
+				t.Error("root is not encrypted")
+			}
+			if bytes.Contains(tx.Misc("").Get([]byte("key")), value) {
+				t.Error("misc-object is not encrypted")
+			}
+			return
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("decrypted", func(t *testing.T) {
+		err := cdb.View(func(tx Tv) (_ error) {
+			if got := tx.Objects().Get(cipher.SumSHA256(value)); !bytes.Equal(got,
+				value) {
+
+				t.Errorf("wrong object: %q", got)
+			}
+			want := getRootPack(0, "secret root")
+			if rp := tx.Feeds().Roots(pk).Get(0); rp == nil {
+				t.Error("missing root")
+			} else if !bytes.Equal(rp.Root, want.Root) || rp.Hash != want.Hash {
+				t.Error("wrong root")
+			}
+			if got := tx.Misc("").Get([]byte("key")); !bytes.Equal(got, value) {
+				t.Errorf("wrong misc-object: %q", got)
+			}
+			if tx.Misc("").Get(cryptMetaKey) != nil {
+				t.Error("reserved misc-object is visible")
+			}
+			return
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("reserved key", func(t *testing.T) {
+		err := cdb.Update(func(tx Tu) error {
+			return tx.Misc("").Del(cryptMetaKey)
+		})
+		if err != ErrReservedKey {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+	t.Run("namespaces", func(t *testing.T) {
+		err := cdb.Update(func(tx Tu) (err error) {
+			if err = tx.Misc("ns").Set([]byte("key"), value); err != nil {
+				return
+			}
+			return tx.DelNamespace("") // keeps the meta key
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.View(func(tx Tv) (_ error) {
+			if bytes.Contains(tx.Misc("ns").Get([]byte("key")), value) {
+				t.Error("misc-object of namespace is not encrypted")
+			}
+			if tx.Misc("").Get(cryptMetaKey) == nil {
+				t.Error("reserved misc-object deleted")
+			}
+			return
+		})
+		cdb.View(func(tx Tv) (_ error) {
+			if got := tx.Misc("ns").Get([]byte("key")); !bytes.Equal(got,
+				value) {
+
+				t.Errorf("wrong misc-object: %q", got)
+			}
+			return
+		})
+		if _, ok := cdb.Stat().Namespaces[""]; ok {
+			t.Error("reserved misc-object in stat")
+		}
+	})
+
+	if reopen == nil {
+		return
+	}
+
+	cdb.Close()
+
+	t.Run("wrong key", func(t *testing.T) {
+		db = reopen()
+		defer db.Close()
+		if _, err := NewCryptDB(db, []byte("wrong")); err != ErrWrongKey {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		db = reopen()
+		cdb, err := NewCryptDB(db, []byte("passphrase"))
+		if err != nil {
+			db.Close()
+			t.Fatal(err)
+		}
+		defer cdb.Close()
+		cdb.View(func(tx Tv) (_ error) {
+			if got := tx.Objects().Get(cipher.SumSHA256(value)); !bytes.Equal(got,
+				value) {
+
+				t.Errorf("wrong object: %q", got)
+			}
+			return
+		})
+	})
+
+}
+
+func TestNewCryptDB(t *testing.T) {
+	// NewCryptDB(db DB, passphrase []byte) (cdb DB, err error)
+
+	defer testCryptTuning()()
+
+	t.Run("memory", func(t *testing.T) {
+		testNewCryptDB(t, NewMemoryDB(), nil)
+	})
+
+	t.Run("drive", func(t *testing.T) {
+		dbFile := testPath(t)
+		defer os.Remove(dbFile)
+		db, err := NewDriveDB(dbFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testNewCryptDB(t, db, func() DB {
+			if db, err = NewDriveDB(dbFile); err != nil {
+				t.Fatal(err)
+			}
+			return db
+		})
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		db := NewMemoryDB()
+		cdb, err := NewCryptDB(db, []byte("passphrase"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cdb.Close()
+		var key cipher.SHA256
+		err = cdb.Update(func(tx Tu) (err error) {
+			key, err = tx.Objects().Add([]byte("value"))
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Update(func(tx Tu) error {
+			objs := tx.Objects()
+			sealed := objs.GetCopy(key)
+			sealed[len(sealed)-1] ^= 0xff
+			return objs.Set(key, sealed)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cdb.View(func(tx Tv) (_ error) {
+			if got := tx.Objects().Get(key); got != nil {
+				t.Errorf("got corrupted object: %q", got)
+			}
+			return
+		})
+		problems, err := Check(cdb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(problems) != 1 {
+			t.Fatal("wrong problems:", problems)
+		}
+		if oe, ok := problems[0].(*ObjectError); !ok || oe.Key() != key {
+			t.Error("wrong problem:", problems[0])
+		}
+	})
+
+	t.Run("not encrypted", func(t *testing.T) {
+		db := NewMemoryDB()
+		err := db.Update(func(tx Tu) (err error) {
+			_, err = tx.Objects().Add([]byte("plain"))
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewCryptDB(db, []byte("passphrase")); err != ErrNotEncrypted {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+}
+
+func TestReadKeyFile(t *testing.T) {
+	// ReadKeyFile(path string) (passphrase []byte, err error)
+
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "key")
+	if err = ioutil.WriteFile(keyFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if pass, err := ReadKeyFile(keyFile); err != nil {
+		t.Error(err)
+	} else if string(pass) != "secret" {
+		t.Errorf("wrong passphrase: %q", pass)
+	}
+
+	if err = ioutil.WriteFile(keyFile, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReadKeyFile(keyFile); err == nil {
+		t.Error("missing error")
+	}
+}
@@ -0,0 +1,196 @@
+package data
+
+import (
+	"bytes"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+)
+
+// A rawCursor is cursor over keys of a bucket of a database.
+// Keys are raw (decoded) keys of the bucket without any
+// prefixes. A nil key means that there is nothing more.
+// The *bolt.Cursor implements the interface
+type rawCursor interface {
+	First() (key, value []byte)
+	Last() (key, value []byte)
+	Seek(seek []byte) (key, value []byte)
+	Next() (key, value []byte)
+	Prev() (key, value []byte)
+}
+
+// A boundedCursor limits keys of a rawCursor by
+// [from, to) range. A nil bound means no bound
+type boundedCursor struct {
+	c        rawCursor
+	from, to []byte
+}
+
+// prefixEnd returns first key that greater than all
+// keys with given prefix, or nil if there is no such
+// key (a nil prefix or the prefix consists of 0xff)
+func prefixEnd(prefix []byte) (end []byte) {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			end = append([]byte{}, prefix[:i+1]...)
+			end[i]++
+			return
+		}
+	}
+	return
+}
+
+// check returns nil key if given key is out of bounds
+func (b *boundedCursor) check(k, v []byte) ([]byte, []byte) {
+	if k == nil {
+		return nil, nil
+	}
+	if b.from != nil && bytes.Compare(k, b.from) < 0 {
+		return nil, nil
+	}
+	if b.to != nil && bytes.Compare(k, b.to) >= 0 {
+		return nil, nil
+	}
+	return k, v
+}
+
+func (b *boundedCursor) First() ([]byte, []byte) {
+	if b.from != nil {
+		return b.check(b.c.Seek(b.from))
+	}
+	return b.check(b.c.First())
+}
+
+func (b *boundedCursor) Last() ([]byte, []byte) {
+	if b.to != nil {
+		if k, _ := b.c.Seek(b.to); k != nil {
+			return b.check(b.c.Prev())
+		}
+	}
+	return b.check(b.c.Last())
+}
+
+func (b *boundedCursor) Seek(seek []byte) ([]byte, []byte) {
+	if b.from != nil && bytes.Compare(seek, b.from) < 0 {
+		seek = b.from
+	}
+	return b.check(b.c.Seek(seek))
+}
+
+func (b *boundedCursor) Next() ([]byte, []byte) {
+	return b.check(b.c.Next())
+}
+
+func (b *boundedCursor) Prev() ([]byte, []byte) {
+	return b.check(b.c.Prev())
+}
+
+// objects
+
+type objectsCursor struct {
+	boundedCursor
+}
+
+func newObjectsCursor(c rawCursor, from, to []byte) *objectsCursor {
+	return &objectsCursor{boundedCursor{c, from, to}}
+}
+
+// hashBounds converts [from, to) range of hashes to
+// bounds of a boundedCursor, empty hash means no bound
+func hashBounds(from, to cipher.SHA256) (f, t []byte) {
+	if from != (cipher.SHA256{}) {
+		f = from[:]
+	}
+	if to != (cipher.SHA256{}) {
+		t = to[:]
+	}
+	return
+}
+
+func (o *objectsCursor) object(k, v []byte) (key cipher.SHA256,
+	value []byte, ok bool) {
+
+	if k == nil {
+		return
+	}
+	copy(key[:], k)
+	return key, v, true
+}
+
+func (o *objectsCursor) First() (cipher.SHA256, []byte, bool) {
+	return o.object(o.boundedCursor.First())
+}
+
+func (o *objectsCursor) Last() (cipher.SHA256, []byte, bool) {
+	return o.object(o.boundedCursor.Last())
+}
+
+func (o *objectsCursor) Seek(key cipher.SHA256) (cipher.SHA256, []byte,
+	bool) {
+
+	return o.object(o.boundedCursor.Seek(key[:]))
+}
+
+func (o *objectsCursor) Next() (cipher.SHA256, []byte, bool) {
+	return o.object(o.boundedCursor.Next())
+}
+
+func (o *objectsCursor) Prev() (cipher.SHA256, []byte, bool) {
+	return o.object(o.boundedCursor.Prev())
+}
+
+// roots
+
+type rootsCursor struct {
+	boundedCursor
+}
+
+func newRootsCursor(c rawCursor, from, to []byte) *rootsCursor {
+	return &rootsCursor{boundedCursor{c, from, to}}
+}
+
+// seqBounds converts [from, to) range of seq numbers
+// to bounds of a boundedCursor, zero to means no bound
+func seqBounds(from, to uint64) (f, t []byte) {
+	if f = utob(from); to != 0 {
+		t = utob(to)
+	}
+	return
+}
+
+func (r *rootsCursor) root(k, v []byte) (rp *RootPack) {
+	if k == nil {
+		return
+	}
+	rp = new(RootPack)
+	if err := encoder.DeserializeRaw(v, rp); err != nil {
+		panic(err) // critical
+	}
+	return
+}
+
+func (r *rootsCursor) First() *RootPack {
+	return r.root(r.boundedCursor.First())
+}
+
+func (r *rootsCursor) Last() *RootPack {
+	return r.root(r.boundedCursor.Last())
+}
+
+func (r *rootsCursor) Seek(seq uint64) *RootPack {
+	return r.root(r.boundedCursor.Seek(utob(seq)))
+}
+
+func (r *rootsCursor) Next() *RootPack {
+	return r.root(r.boundedCursor.Next())
+}
+
+func (r *rootsCursor) Prev() *RootPack {
+	return r.root(r.boundedCursor.Prev())
+}
+
+// misc
+
+func newMiscCursor(c rawCursor, from, to []byte) MiscCursor {
+	return &boundedCursor{c, from, to}
+}
@@ -0,0 +1,22 @@
+package data
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_prefixEnd(t *testing.T) {
+	for _, tc := range []struct {
+		prefix, end []byte
+	}{
+		{nil, nil},
+		{[]byte("ab"), []byte("ac")},
+		{[]byte{'a', 0xff}, []byte("b")},
+		{[]byte{0xff, 0xff}, nil},
+	} {
+		if end := prefixEnd(tc.prefix); !bytes.Equal(end, tc.end) {
+			t.Errorf("wrong end of %q: want %q, got %q", tc.prefix, tc.end,
+				end)
+		}
+	}
+}
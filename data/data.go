@@ -35,18 +35,38 @@ type ViewObjects interface {
 	IsExist(key cipher.SHA256) (ok bool)
 	// Ascend over all objects. Use ErrStopIteration to break iteration
 	Ascend(func(key cipher.SHA256, value []byte) error) (err error)
+
+	// Refs returns references counter of object with given key.
+	// The counter is amount of root objects and other objects
+	// that refer to the object. It returns zero if the object
+	// doesn't exist or is not referenced
+	Refs(key cipher.SHA256) (rc uint32)
+	// NeedRecount returns true if references counters of
+	// the database are not actual and should be recounted.
+	// For example, after migration of an old database
+	NeedRecount() (need bool)
+
+	// Cursor returns cursor over all objects ordered by key.
+	// The cursor valid only inside current transaction
+	Cursor() ObjectsCursor
+	// RangeCursor returns cursor over objects with keys in
+	// [from, to) range. Empty to means no upper bound
+	RangeCursor(from, to cipher.SHA256) ObjectsCursor
 }
 
 // UpdateObjects represents read-write bucket of objects
 type UpdateObjects interface {
 	ViewObjects
 
-	// Del deletes object by key. It never returns
-	// "not found" error. The Del is low level method
+	// Del deletes object by key with its references
+	// counter. It never returns "not found" error.
+	// The Del is low level method
 	// and you should not use it. Otherwise, it can
 	// break some things of skyobject package
 	Del(key cipher.SHA256) (err error)
-	// Set key->value pair
+	// Set key->value pair. It keeps references
+	// counter of existing object. A new object
+	// has zero references
 	Set(key cipher.SHA256, value []byte) (err error)
 	// Add value getting key
 	Add(value []byte) (key cipher.SHA256, err error)
@@ -63,6 +83,24 @@ type UpdateObjects interface {
 	// Note: it can delete objects when given function
 	// returns. And it can delete them after all
 	AscendDel(func(key cipher.SHA256, value []byte) (del bool, err error)) error
+
+	// Inc increments references counter of object with given key
+	// and returns new value. It returns ErrNotFound if the object
+	// doesn't exist
+	Inc(key cipher.SHA256) (rc uint32, err error)
+	// Dec decrements references counter of object with given key
+	// and returns new value. It returns ErrNotFound if the object
+	// doesn't exist. The Dec never deletes the object even if the
+	// counter turns to zero, and never makes the counter negative.
+	// Use Del to remove unreferenced object
+	Dec(key cipher.SHA256) (rc uint32, err error)
+	// SetRefs sets references counter of object with given key.
+	// It returns ErrNotFound if the object doesn't exist. The
+	// SetRefs is low level method used to recount references
+	SetRefs(key cipher.SHA256, rc uint32) (err error)
+	// Recounted resets NeedRecount flag. Call it after
+	// references counters of all objects has been recounted
+	Recounted() (err error)
 }
 
 // ViewFeeds represents read-only bucket of feeds
@@ -124,6 +162,24 @@ type ViewRoots interface {
 	// Get a root object by seq number
 	Get(seq uint64) (rp *RootPack)
 
+	// AtTime returns latest root with time at or before
+	// given one (unix nano). It returns nil if there is no
+	// such root. Roots with zero RootMeta.Time are not
+	// indexed and never returned
+	AtTime(t int64) (rp *RootPack)
+	// RangeTime itterates root objects with time in [from, to)
+	// range ordered by time. Zero to means no upper bound. Use
+	// ErrStopIteration to break the iteration. Roots with zero
+	// RootMeta.Time are not indexed and never itterated
+	RangeTime(from, to int64, fn func(rp *RootPack) (err error)) error
+
+	// IsFull returns true if root with given seq number
+	// is full. It returns false if the root doesn't exist
+	IsFull(seq uint64) bool
+	// Meta returns machine-local metadata of root with
+	// given seq number. The method can return ErrNotFound
+	Meta(seq uint64) (rm RootMeta, err error)
+
 	// Ascend itterates all root objects ordered
 	// by seq from oldest to newest. Use ErrStopIteration
 	// to break the iteration
@@ -132,6 +188,13 @@ type ViewRoots interface {
 	// E.g. it itterates all root objects ordered by seq
 	// from newest (latest) to oldest
 	Descend(fn func(rp *RootPack) (err error)) error
+
+	// Cursor returns cursor over all root objects ordered
+	// by seq. The cursor valid only inside current transaction
+	Cursor() RootsCursor
+	// RangeCursor returns cursor over root objects with seq
+	// numbers in [from, to) range. Zero to means no upper bound
+	RangeCursor(from, to uint64) RootsCursor
 }
 
 // UpdateRoots represents read-write bucket of Root obejcts
@@ -140,15 +203,18 @@ type UpdateRoots interface {
 
 	// Add a root object. It returns ErrRootAlreadyExists
 	// if root with the same seq number already exists.
-	// The method doesn't modify rp. And if rp.IsFull is
-	// true then it will be saved as full
+	// The method doesn't modify rp. New root is not full
+	// and has zero RootMeta
 	Add(rp *RootPack) (err error)
-	// Del deletes root object by seq number. It never
-	// returns "not found" error
+	// Del deletes root object (and its metadata) by seq
+	// number. It never returns "not found" error
 	Del(seq uint64) (err error)
-	// MarkFull marks root with given seq as full.
-	// The method can return ErrNotFound
+	// MarkFull marks root with given seq as full keeping
+	// other metadata. The method can return ErrNotFound
 	MarkFull(seq uint64) (err error)
+	// SetMeta replaces machine-local metadata of root with
+	// given seq number. The method can return ErrNotFound
+	SetMeta(seq uint64, rm RootMeta) (err error)
 
 	// AscendDel used to delete Root obejcts.
 	// If given function returns del = true, then
@@ -178,11 +244,18 @@ type ViewMisc interface {
 	// by key from. Use ErrStopIteration to break the
 	// iteration
 	Ascend(func(key, value []byte) (err error)) error
-
-	// TODO (kostyarin): add
-	//
-	// // Descend is the same as Ascend in reversed order
-	// Descend(fn func(key, value []byte) (err error)) error
+	// Descend is the same as Ascend in reversed order
+	Descend(fn func(key, value []byte) (err error)) error
+
+	// Cursor returns cursor over all misc-objects ordered
+	// by key. The cursor valid only inside current transaction
+	Cursor() MiscCursor
+	// RangeCursor returns cursor over misc-objects with keys
+	// in [from, to) range. A nil bound means no bound
+	RangeCursor(from, to []byte) MiscCursor
+	// PrefixCursor returns cursor over misc-objects with keys
+	// that starts with given prefix
+	PrefixCursor(prefix []byte) MiscCursor
 }
 
 type UpdateMisc interface {
@@ -201,13 +274,62 @@ type UpdateMisc interface {
 	// Note: it can delete objects when given function
 	// returns. And it can delete them after all
 	AscendDel(func(key, value []byte) (del bool, err error)) error
+	// DescendDel is the same as AscendDel in reversed order
+	DescendDel(func(key, value []byte) (del bool, err error)) error
+}
+
+// An ObjectsCursor used to walk through objects in any
+// direction seeking any key. The ok is false if there
+// is nothing more. A value valid only inside current
+// transaction. Changing objects during walking
+// invalidates the cursor
+type ObjectsCursor interface {
+	First() (key cipher.SHA256, value []byte, ok bool)
+	Last() (key cipher.SHA256, value []byte, ok bool)
+	// Seek moves the cursor to given key, or to next
+	// key if given one doesn't exist
+	Seek(key cipher.SHA256) (k cipher.SHA256, value []byte, ok bool)
+	Next() (key cipher.SHA256, value []byte, ok bool)
+	Prev() (key cipher.SHA256, value []byte, ok bool)
+}
+
+// A RootsCursor used to walk through root objects of a
+// feed in any direction seeking any seq number. Methods of
+// the cursor return nil if there is nothing more. Changing
+// roots during walking invalidates the cursor
+type RootsCursor interface {
+	First() (rp *RootPack)
+	Last() (rp *RootPack)
+	// Seek moves the cursor to given seq, or to next
+	// seq if root with given seq doesn't exist
+	Seek(seq uint64) (rp *RootPack)
+	Next() (rp *RootPack)
+	Prev() (rp *RootPack)
+}
 
-	// TODO (kostyarin): DescendDel
+// A MiscCursor used to walk through misc-objects in any
+// direction seeking any key. Methods of the cursor return
+// nil key if there is nothing more. Keys and values valid
+// only inside current transaction. Changing misc-objects
+// during walking invalidates the cursor
+type MiscCursor interface {
+	First() (key, value []byte)
+	Last() (key, value []byte)
+	// Seek moves the cursor to given key, or to next
+	// key if given one doesn't exist
+	Seek(seek []byte) (key, value []byte)
+	Next() (key, value []byte)
+	Prev() (key, value []byte)
 }
 
 // A Tv represents read-only transaction
 type Tv interface {
-	Misc() ViewMisc // bucket for end-user needs
+	// Misc returns bucket for end-user needs. Every namespace
+	// is isolated bucket. The empty namespace is default one
+	Misc(namespace string) ViewMisc
+	// Namespaces returns sorted list of non-empty namespaces
+	// except the default one
+	Namespaces() (list []string)
 
 	Objects() ViewObjects // access objects
 	Feeds() ViewFeeds     // access feeds
@@ -215,7 +337,15 @@ type Tv interface {
 
 // A Tu represents read-write transaction
 type Tu interface {
-	Misc() UpdateMisc // bucket for end-user needs
+	// Misc returns bucket for end-user needs. Every namespace
+	// is isolated bucket. The empty namespace is default one
+	Misc(namespace string) UpdateMisc
+	// Namespaces returns sorted list of non-empty namespaces
+	// except the default one
+	Namespaces() (list []string)
+	// DelNamespace deletes all misc-objects of given namespace.
+	// It never returns "not found" errors
+	DelNamespace(namespace string) (err error)
 
 	Objects() UpdateObjects // access objects
 	Feeds() UpdateFeeds     // access feeds
@@ -230,6 +360,25 @@ type DB interface {
 	Close() (err error)                  // clsoe database
 }
 
+// A Batcher is DB that can combine concurrent Batch calls
+// into one transaction to reduce number of commits (and
+// fsyncs). The function of Batch can be called few times
+// and must be idempotent. Implementing the Batcher is
+// optional, use the Batch function to perform batched
+// transaction on any DB
+type Batcher interface {
+	Batch(func(t Tu) error) (err error) // perform a batched transaction
+}
+
+// Batch performs batched transaction if given DB
+// is Batcher. Otherwise, it performs Update
+func Batch(db DB, fn func(t Tu) error) error {
+	if b, ok := db.(Batcher); ok {
+		return b.Batch(fn)
+	}
+	return db.Update(fn)
+}
+
 // A RootPack represents encoded root object with signature,
 // seq number, and next/prev/this hashes
 type RootPack struct {
@@ -244,17 +393,26 @@ type RootPack struct {
 	Hash cipher.SHA256 // hash of the Root filed
 	Sig  cipher.Sig    // signature of the Hash field
 
-	// TODO (kostyarin): move the field from here making it
-	//                   machine local
+}
 
-	// IsFull is true if the Root has all related objects in
-	// this DB. The field is temporary added and likely to
-	// be moved outside the RootPack
+// A RootMeta represents machine-local metadata of a Root.
+// The metadata is not a part of RootPack, it is never
+// signed and never sent through network. A DB keeps it
+// separately from RootPack and a new Root has zero RootMeta
+type RootMeta struct {
+	// IsFull is true if the Root has all related objects
+	// in this DB
 	IsFull bool
-
-	// Space holded by all obejcts of the Root. The field is
-	// machine-local and likely to be removed from the RootPack
+	// Space holded by all obejcts of the Root
 	Space uint64
+	// Time is timestamp of the Root (unix nano). Roots
+	// with non-zero Time are indexed by the time. See
+	// ViewRoots.AtTime and ViewRoots.RangeTime
+	Time int64
+	// Refill is true if the Root was full, but lost some
+	// objects and should be filled again. It's cleared
+	// by MarkFull. See (*skyobject.Container).Check
+	Refill bool
 }
 
 // A RootError represents error that can be returned by AddRoot method
@@ -283,6 +441,14 @@ func (r *RootError) Hash() cipher.SHA256 { return r.hash }
 // Seq of erroneous Root
 func (r *RootError) Seq() uint64 { return r.seq }
 
+// NewRootError creates RootError using given feed, RootPack
+// and description. The rp argument must not be nil
+func NewRootError(pk cipher.PubKey, rp *RootPack,
+	descr string) (r *RootError) {
+
+	return newRootError(pk, rp, descr)
+}
+
 func newRootError(pk cipher.PubKey, rp *RootPack, descr string) (r *RootError) {
 	return &RootError{
 		feed:  pk,
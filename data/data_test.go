@@ -10,6 +10,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/boltdb/bolt"
+
 	"github.com/skycoin/skycoin/src/cipher"
 )
 
@@ -64,6 +66,22 @@ func testDriveDB(t *testing.T) (db DB, cleanUp func()) {
 	return
 }
 
+func testLSMDB(t *testing.T) (db DB, cleanUp func()) {
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db, err = NewLSMDB(dir); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	cleanUp = func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+	return
+}
+
 // returns RootPack that contains dummy Root field,
 // the field can't be used to encode/decode
 func getRootPack(seq uint64, content string) (rp RootPack) {
@@ -199,6 +217,12 @@ func TestDB_View(t *testing.T) {
 		testDBView(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testDBView(t, db)
+	})
+
 }
 
 func testDBUpdate(t *testing.T, db DB) {
@@ -243,6 +267,82 @@ func TestDB_Update(t *testing.T) {
 		testDBUpdate(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testDBUpdate(t, db)
+	})
+
+}
+
+func testDBBatch(t *testing.T, db DB) {
+
+	const n = 100
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- Batch(db, func(tx Tu) (err error) {
+				_, err = tx.Objects().Add(testLSMValue(i))
+				return
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testLSMCheck(t, db, n, func(int) bool { return false })
+
+	if s := db.Stat(); s.Objects != n {
+		t.Errorf("wrong number of objects: %d, want %d", s.Objects, n)
+	}
+
+	// rollback
+	ae := errors.New("just an average error to rollback this transaction")
+	err := Batch(db, func(tx Tu) (err error) {
+		if _, err = tx.Objects().Add([]byte("a value")); err != nil {
+			return
+		}
+		return ae
+	})
+	if err != ae {
+		t.Error(err)
+	}
+	if s := db.Stat(); s.Objects != n {
+		t.Error("doesn't rolled back")
+	}
+
+}
+
+func TestDB_Batch(t *testing.T) {
+	// Batch(func(t Tu) error) (err error)
+
+	t.Run("memory", func(t *testing.T) {
+		testDBBatch(t, NewMemoryDB())
+	})
+
+	t.Run("drive", func(t *testing.T) {
+		db, cleanUp := testDriveDB(t)
+		defer cleanUp()
+		testDBBatch(t, db)
+	})
+
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testDBBatch(t, db)
+	})
+
 }
 
 func testDBStat(t *testing.T, db DB) {
@@ -321,6 +421,12 @@ func TestDB_Stat(t *testing.T) {
 		testDBStat(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testDBStat(t, db)
+	})
+
 }
 
 func testDBClose(t *testing.T, db DB) {
@@ -345,6 +451,12 @@ func TestDB_Close(t *testing.T) {
 		testDBClose(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testDBClose(t, db)
+	})
+
 }
 
 //
@@ -377,6 +489,12 @@ func TestTv_Objects(t *testing.T) {
 		testTvObjects(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testTvObjects(t, db)
+	})
+
 }
 
 func testTvFeeds(t *testing.T, db DB) {
@@ -404,11 +522,17 @@ func TestTv_Feeds(t *testing.T) {
 		testTvFeeds(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testTvFeeds(t, db)
+	})
+
 }
 
 func testTvMisc(t *testing.T, db DB) {
 	err := db.View(func(tx Tv) (_ error) {
-		if tx.Misc() == nil {
+		if tx.Misc("") == nil {
 			t.Error("Tv.Misc returns nil")
 		}
 		return
@@ -431,6 +555,12 @@ func TestTv_Misc(t *testing.T) {
 		testTvMisc(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testTvMisc(t, db)
+	})
+
 }
 
 //
@@ -463,6 +593,12 @@ func TestTu_Objects(t *testing.T) {
 		testTuObjects(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testTuObjects(t, db)
+	})
+
 }
 
 func testTuFeeds(t *testing.T, db DB) {
@@ -490,11 +626,17 @@ func TestTu_Feeds(t *testing.T) {
 		testTuFeeds(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testTuFeeds(t, db)
+	})
+
 }
 
 func testTuMisc(t *testing.T, db DB) {
 	err := db.Update(func(tx Tu) (_ error) {
-		if tx.Misc() == nil {
+		if tx.Misc("") == nil {
 			t.Error("Tu.Misc returns nil")
 		}
 		return
@@ -517,4 +659,118 @@ func TestTu_Misc(t *testing.T) {
 		testTuMisc(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testTuMisc(t, db)
+	})
+
+}
+
+func TestNewDriveDBOptions(t *testing.T) {
+	// NewDriveDBOptions(path string, opts *DriveOptions) (DB, error)
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, opts := range []DriveOptions{
+			{Timeout: -1},
+			{InitialMmapSize: -1},
+			{MaxBatchSize: -1},
+			{MaxBatchDelay: -1},
+		} {
+			if _, err := NewDriveDBOptions(testPath(t), &opts); err == nil {
+				t.Error("missing error")
+			}
+		}
+	})
+
+	t.Run("durability", func(t *testing.T) {
+		if d := SafeDriveOptions().Durability(); d != SafeDurability {
+			t.Error("wrong durability:", d)
+		}
+		fast := FastDriveOptions()
+		if !fast.NoSync || !fast.NoGrowSync {
+			t.Error("fast options are synced")
+		}
+		var d Durability
+		if err := d.Set("fast"); err != nil {
+			t.Error(err)
+		} else if d != FastDurability || d.String() != "fast" {
+			t.Error("wrong durability:", d)
+		}
+		if err := d.Set("unknown"); err == nil {
+			t.Error("missing error")
+		}
+	})
+
+	t.Run("fast", func(t *testing.T) {
+		dbFile := testPath(t)
+		defer os.Remove(dbFile)
+
+		db, err := NewDriveDBOptions(dbFile, FastDriveOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		testDBUpdate(t, db)
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		db, cleanUp := testDriveDB(t)
+		defer cleanUp()
+
+		var key cipher.SHA256
+		err := db.Update(func(tx Tu) (err error) {
+			key, err = tx.Objects().Add([]byte("value"))
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbFile := db.(*driveDB).bolt.Path()
+		db.Close()
+
+		opts := SafeDriveOptions()
+		opts.ReadOnly = true
+
+		ro, err := NewDriveDBOptions(dbFile, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ro.View(func(tx Tv) (_ error) {
+			if tx.Objects().Get(key) == nil {
+				t.Error("missing object")
+			}
+			return
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		err = ro.Update(func(tx Tu) (err error) {
+			_, err = tx.Objects().Add([]byte("another value"))
+			return
+		})
+		if err == nil {
+			t.Error("missing error")
+		}
+		ro.Close()
+
+		// old version can't be opened in read-only mode
+		db, err = NewDriveDB(dbFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.(*driveDB).bolt.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(metaBucket).Put(versionKey,
+				utob(driveVersion-1))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+
+		if _, err = NewDriveDBOptions(dbFile, opts); err == nil {
+			t.Error("missing error")
+		}
+	})
+
 }
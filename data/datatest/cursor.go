@@ -0,0 +1,245 @@
+package datatest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+// walkObjects returns keys of all objects of given cursor
+// from first to last (or from last to first if desc is true)
+func walkObjects(t *testing.T, c data.ObjectsCursor, values map[cipher.SHA256]string,
+	desc bool) (keys []cipher.SHA256) {
+
+	next, key, value, ok := c.Next, cipher.SHA256{}, []byte(nil), false
+	if desc {
+		next = c.Prev
+		key, value, ok = c.Last()
+	} else {
+		key, value, ok = c.First()
+	}
+	for ; ok; key, value, ok = next() {
+		if string(value) != values[key] {
+			t.Errorf("wrong value %q", value)
+		}
+		keys = append(keys, key)
+	}
+	return
+}
+
+// walkRoots returns seq numbers of all roots of given
+// cursor from first to last (or from last to first)
+func walkRoots(c data.RootsCursor, desc bool) (seqs []uint64) {
+	next, rp := c.Next, (*data.RootPack)(nil)
+	if desc {
+		next, rp = c.Prev, c.Last()
+	} else {
+		rp = c.First()
+	}
+	for ; rp != nil; rp = next() {
+		seqs = append(seqs, rp.Seq)
+	}
+	return
+}
+
+// walkMisc returns keys of all misc-objects of given
+// cursor from first to last (or from last to first)
+func walkMisc(t *testing.T, c data.MiscCursor, desc bool) (keys [][]byte) {
+	next, key, value := c.Next, []byte(nil), []byte(nil)
+	if desc {
+		next = c.Prev
+		key, value = c.Last()
+	} else {
+		key, value = c.First()
+	}
+	for ; key != nil; key, value = next() {
+		if !bytes.Equal(key, value) {
+			t.Errorf("wrong value %q", value)
+		}
+		keys = append(keys, append([]byte{}, key...))
+	}
+	return
+}
+
+func reversedHashes(hs []cipher.SHA256) (rev []cipher.SHA256) {
+	for i := len(hs) - 1; i >= 0; i-- {
+		rev = append(rev, hs[i])
+	}
+	return
+}
+
+// Cursors tests cursors of objects, roots and misc-objects
+// of a data.DB
+func Cursors(t *testing.T, newDB Constructor) {
+
+	with(t, newDB, "empty", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		addRoots(t, db, pk)
+		view(t, db, func(tx data.Tv) (_ error) {
+			if _, _, ok := tx.Objects().Cursor().First(); ok {
+				t.Error("object in empty database")
+			}
+			if _, _, ok := tx.Objects().Cursor().Last(); ok {
+				t.Error("object in empty database")
+			}
+			if tx.Feeds().Roots(pk).Cursor().First() != nil {
+				t.Error("root in empty feed")
+			}
+			if key, _ := tx.Misc("").Cursor().Last(); key != nil {
+				t.Error("misc-object in empty database")
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "objects", func(t *testing.T, db data.DB) {
+		list := []string{"one", "two", "three", "four", "five"}
+		values := make(map[cipher.SHA256]string, len(list))
+		update(t, db, func(tx data.Tu) (err error) {
+			for _, v := range list {
+				var key cipher.SHA256
+				if key, err = tx.Objects().Add([]byte(v)); err != nil {
+					return
+				}
+				values[key] = v
+			}
+			return
+		})
+		hs := sortedHashes(list...)
+
+		view(t, db, func(tx data.Tv) (_ error) {
+			objs := tx.Objects()
+
+			c := objs.Cursor()
+			compareHashes(t, hs, walkObjects(t, c, values, false))
+			compareHashes(t, reversedHashes(hs), walkObjects(t, c, values, true))
+
+			if key, _, ok := c.Seek(hs[2]); !ok || key != hs[2] {
+				t.Error("wrong Seek result:", key.Hex(), ok)
+			}
+			if key, _, ok := c.Next(); !ok || key != hs[3] {
+				t.Error("wrong Next after Seek:", key.Hex(), ok)
+			}
+			if key, _, ok := c.Prev(); !ok || key != hs[2] {
+				t.Error("wrong Prev after Next:", key.Hex(), ok)
+			}
+
+			// [hs[1], hs[3])
+			c = objs.RangeCursor(hs[1], hs[3])
+			compareHashes(t, hs[1:3], walkObjects(t, c, values, false))
+			compareHashes(t, reversedHashes(hs[1:3]),
+				walkObjects(t, c, values, true))
+			if key, _, ok := c.Seek(hs[0]); !ok || key != hs[1] {
+				t.Error("Seek out of range:", key.Hex(), ok)
+			}
+			if key, _, ok := c.Seek(hs[3]); ok {
+				t.Error("Seek out of range:", key.Hex())
+			}
+
+			// [hs[2], end)
+			c = objs.RangeCursor(hs[2], cipher.SHA256{})
+			compareHashes(t, hs[2:], walkObjects(t, c, values, false))
+			return
+		})
+	})
+
+	with(t, newDB, "roots", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		seqs := []uint64{1, 2, 3, 5, 8}
+		addRoots(t, db, pk, seqs...)
+
+		view(t, db, func(tx data.Tv) (_ error) {
+			roots := tx.Feeds().Roots(pk)
+
+			c := roots.Cursor()
+			compareSeqs(t, seqs, walkRoots(c, false))
+			compareSeqs(t, []uint64{8, 5, 3, 2, 1}, walkRoots(c, true))
+
+			if rp := c.Seek(4); rp == nil || rp.Seq != 5 {
+				t.Error("wrong Seek result:", rp)
+			} else if rp = c.Prev(); rp == nil || rp.Seq != 3 {
+				t.Error("wrong Prev after Seek:", rp)
+			} else {
+				compareRootPacks(t, rootPack(3, "root"), rp)
+			}
+			if rp := c.Seek(9); rp != nil {
+				t.Error("Seek after last:", rp.Seq)
+			}
+
+			// [2, 6)
+			c = roots.RangeCursor(2, 6)
+			compareSeqs(t, []uint64{2, 3, 5}, walkRoots(c, false))
+			compareSeqs(t, []uint64{5, 3, 2}, walkRoots(c, true))
+			if rp := c.Seek(0); rp == nil || rp.Seq != 2 {
+				t.Error("Seek out of range:", rp)
+			}
+
+			// [3, end)
+			c = roots.RangeCursor(3, 0)
+			compareSeqs(t, []uint64{3, 5, 8}, walkRoots(c, false))
+			return
+		})
+	})
+
+	with(t, newDB, "misc", func(t *testing.T, db data.DB) {
+		keys := [][]byte{
+			[]byte("a"),
+			[]byte("ab"),
+			[]byte("abc"),
+			[]byte("b"),
+			{0xff},
+			{0xff, 0x01},
+		}
+		update(t, db, func(tx data.Tu) (err error) {
+			for _, k := range keys {
+				if err = tx.Misc("").Set(k, k); err != nil {
+					return
+				}
+			}
+			return
+		})
+
+		view(t, db, func(tx data.Tv) (_ error) {
+			misc := tx.Misc("")
+
+			c := misc.Cursor()
+			compareKeys(t, keys, walkMisc(t, c, false))
+			compareKeys(t, reversed(keys), walkMisc(t, c, true))
+			if key, _ := c.Seek([]byte("aa")); !bytes.Equal(key, keys[1]) {
+				t.Errorf("wrong Seek result: %q", key)
+			}
+			if key, _ := c.Next(); !bytes.Equal(key, keys[2]) {
+				t.Errorf("wrong Next after Seek: %q", key)
+			}
+
+			c = misc.PrefixCursor([]byte("ab"))
+			compareKeys(t, keys[1:3], walkMisc(t, c, false))
+			compareKeys(t, reversed(keys[1:3]), walkMisc(t, c, true))
+			if key, _ := c.Seek([]byte("a")); !bytes.Equal(key, keys[1]) {
+				t.Errorf("Seek out of prefix: %q", key)
+			}
+
+			c = misc.PrefixCursor([]byte{0xff})
+			compareKeys(t, keys[4:], walkMisc(t, c, false))
+			compareKeys(t, reversed(keys[4:]), walkMisc(t, c, true))
+
+			c = misc.PrefixCursor([]byte("c"))
+			compareKeys(t, nil, walkMisc(t, c, false))
+
+			c = misc.RangeCursor([]byte("ab"), []byte("b"))
+			compareKeys(t, keys[1:3], walkMisc(t, c, false))
+			compareKeys(t, reversed(keys[1:3]), walkMisc(t, c, true))
+
+			c = misc.RangeCursor(nil, []byte("abc"))
+			compareKeys(t, keys[:2], walkMisc(t, c, false))
+
+			c = misc.RangeCursor([]byte("b"), nil)
+			compareKeys(t, keys[3:], walkMisc(t, c, false))
+			return
+		})
+	})
+
+}
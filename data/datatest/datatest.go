@@ -0,0 +1,211 @@
+// Package datatest implements conformance tests for data.DB
+// implementations. A new implementation of the data.DB should
+// pass all the tests to be used by skyobject and node packages.
+// For example
+//
+//	func newMyDB(t *testing.T) (db data.DB, cleanUp func()) {
+//	    db, err := mydb.Open(testDSN)
+//	    if err != nil {
+//	        t.Fatal(err)
+//	    }
+//	    return db, func() { db.Close() }
+//	}
+//
+//	func TestMyDB(t *testing.T) {
+//	    datatest.Run(t, newMyDB)
+//	}
+//
+// Every test creates new empty database using given
+// constructor and calls the cleanUp function after
+package datatest
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+// A Constructor creates new empty database. The cleanUp
+// function should close the database and release all
+// related resources (remove files, drop tables, etc). Use
+// (*testing.T).Fatal if the database can't be created
+type Constructor func(t *testing.T) (db data.DB, cleanUp func())
+
+// errTest is returned by callbacks to check
+// that errors of callbacks bubble up
+var errTest = errors.New("test error")
+
+// Run runs all conformance tests using given constructor
+func Run(t *testing.T, newDB Constructor) {
+	t.Run("DB", func(t *testing.T) { DB(t, newDB) })
+	t.Run("Objects", func(t *testing.T) { Objects(t, newDB) })
+	t.Run("Feeds", func(t *testing.T) { Feeds(t, newDB) })
+	t.Run("Roots", func(t *testing.T) { Roots(t, newDB) })
+	t.Run("Misc", func(t *testing.T) { Misc(t, newDB) })
+	t.Run("Namespaces", func(t *testing.T) { Namespaces(t, newDB) })
+	t.Run("Stat", func(t *testing.T) { Stat(t, newDB) })
+	t.Run("Cursors", func(t *testing.T) { Cursors(t, newDB) })
+}
+
+// with creates new DB using given constructor, calls
+// given function with it and cleans the DB up
+func with(t *testing.T, newDB Constructor, name string,
+	fn func(t *testing.T, db data.DB)) {
+
+	t.Run(name, func(t *testing.T) {
+		db, cleanUp := newDB(t)
+		defer cleanUp()
+		fn(t, db)
+	})
+}
+
+// update performs Update failing the test on error
+func update(t *testing.T, db data.DB, fn func(tx data.Tu) error) {
+	if err := db.Update(fn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// view performs View failing the test on error
+func view(t *testing.T, db data.DB, fn func(tx data.Tv) error) {
+	if err := db.View(fn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// DB tests transactions of a data.DB
+func DB(t *testing.T, newDB Constructor) {
+
+	with(t, newDB, "commit", func(t *testing.T, db data.DB) {
+		update(t, db, func(tx data.Tu) (err error) {
+			_, err = tx.Objects().Add([]byte("commited"))
+			return
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			if !tx.Objects().IsExist(cipher.SumSHA256([]byte("commited"))) {
+				t.Error("commited object doesn't exist")
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "rollback", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		err := db.Update(func(tx data.Tu) (err error) {
+			if _, err = tx.Objects().Add([]byte("rolled back")); err != nil {
+				return
+			}
+			if err = tx.Feeds().Add(pk); err != nil {
+				return
+			}
+			if err = tx.Misc("").Set([]byte("k"), []byte("v")); err != nil {
+				return
+			}
+			return errTest
+		})
+		if err != errTest {
+			t.Fatal("unexpected error:", err)
+		}
+		view(t, db, func(tx data.Tv) (_ error) {
+			if tx.Objects().IsExist(cipher.SumSHA256([]byte("rolled back"))) {
+				t.Error("rolled back object exists")
+			}
+			if tx.Feeds().IsExist(pk) {
+				t.Error("rolled back feed exists")
+			}
+			if tx.Misc("").Get([]byte("k")) != nil {
+				t.Error("rolled back misc-object exists")
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "Batch", func(t *testing.T, db data.DB) {
+		const n = 10
+
+		var wg sync.WaitGroup
+		errs := make(chan error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs <- data.Batch(db, func(tx data.Tu) (err error) {
+					_, err = tx.Objects().Add([]byte{byte(i)})
+					return
+				})
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err := data.Batch(db, func(tx data.Tu) (err error) {
+			if _, err = tx.Objects().Add([]byte("rolled back")); err != nil {
+				return
+			}
+			return errTest
+		})
+		if err != errTest {
+			t.Fatal("unexpected error:", err)
+		}
+
+		view(t, db, func(tx data.Tv) (_ error) {
+			objs := tx.Objects()
+			for i := 0; i < n; i++ {
+				if !objs.IsExist(cipher.SumSHA256([]byte{byte(i)})) {
+					t.Error("missing object", i)
+				}
+			}
+			if objs.IsExist(cipher.SumSHA256([]byte("rolled back"))) {
+				t.Error("rolled back object exists")
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "view error", func(t *testing.T, db data.DB) {
+		if err := db.View(func(data.Tv) error { return errTest }); err != errTest {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+}
+
+// sortedHashes returns sorted list of hashes of given values
+func sortedHashes(values ...string) (hs []cipher.SHA256) {
+	for _, v := range values {
+		hs = append(hs, cipher.SumSHA256([]byte(v)))
+	}
+	sort.Slice(hs, func(i, j int) bool {
+		return bytes.Compare(hs[i][:], hs[j][:]) < 0
+	})
+	return
+}
+
+// orderedPublicKeys returns n unique public keys in
+// ascending order
+func orderedPublicKeys(n int) (pks []cipher.PubKey) {
+	seen := make(map[cipher.PubKey]struct{}, n)
+	for len(pks) < n {
+		pk, _ := cipher.GenerateKeyPair()
+		if _, ok := seen[pk]; ok {
+			continue
+		}
+		seen[pk] = struct{}{}
+		pks = append(pks, pk)
+	}
+	sort.Slice(pks, func(i, j int) bool {
+		return bytes.Compare(pks[i][:], pks[j][:]) < 0
+	})
+	return
+}
@@ -0,0 +1,100 @@
+package datatest
+
+import (
+	"compress/flate"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skycoin/cxo/data"
+)
+
+func newMemoryDB(*testing.T) (data.DB, func()) {
+	db := data.NewMemoryDB()
+	return db, func() { db.Close() }
+}
+
+func newDriveDB(t *testing.T) (data.DB, func()) {
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := data.NewDriveDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func newLSMDB(t *testing.T) (data.DB, func()) {
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := data.NewLSMDB(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func newCryptDB(t *testing.T) (data.DB, func()) {
+	db, err := data.NewCryptDB(data.NewMemoryDB(), []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, func() { db.Close() }
+}
+
+func newCompressDB(t *testing.T) (data.DB, func()) {
+	db, err := data.NewCompressDB(data.NewMemoryDB(), flate.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, func() { db.Close() }
+}
+
+// the threshold is small to keep most
+// values of the tests in files
+func newBlobDB(t *testing.T) (data.DB, func()) {
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := data.NewBlobDB(data.NewMemoryDB(), dir, 3)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// the watcher is never read, thus, events are
+// lost, but changes are watched anyway
+func newWatchDB(*testing.T) (data.DB, func()) {
+	db := data.NewWatchDB(data.NewMemoryDB())
+	db.Watch(1)
+	return db, func() { db.Close() }
+}
+
+func TestRun(t *testing.T) {
+	t.Run("memory", func(t *testing.T) { Run(t, newMemoryDB) })
+	t.Run("drive", func(t *testing.T) { Run(t, newDriveDB) })
+	t.Run("lsm", func(t *testing.T) { Run(t, newLSMDB) })
+	t.Run("crypt", func(t *testing.T) { Run(t, newCryptDB) })
+	t.Run("compress", func(t *testing.T) { Run(t, newCompressDB) })
+	t.Run("blob", func(t *testing.T) { Run(t, newBlobDB) })
+	t.Run("watch", func(t *testing.T) { Run(t, newWatchDB) })
+}
@@ -0,0 +1,221 @@
+package datatest
+
+import (
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+// Feeds tests ViewFeeds and UpdateFeeds of a data.DB
+func Feeds(t *testing.T, newDB Constructor) {
+
+	with(t, newDB, "Add IsExist", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		view(t, db, func(tx data.Tv) (_ error) {
+			feeds := tx.Feeds()
+			if feeds.IsExist(pk) {
+				t.Error("missing feed exists")
+			}
+			if feeds.Roots(pk) != nil {
+				t.Error("Roots of missing feed is not nil")
+			}
+			return
+		})
+		update(t, db, func(tx data.Tu) (err error) {
+			feeds := tx.Feeds()
+			if feeds.Roots(pk) != nil {
+				t.Error("Roots of missing feed is not nil")
+			}
+			if err = feeds.Add(pk); err != nil {
+				return
+			}
+			// the Add is idempotent
+			if err = feeds.Add(pk); err != nil {
+				return
+			}
+			if err = feeds.Roots(pk).Add(rootPack(0, "root")); err != nil {
+				return
+			}
+			// and keeps roots of existing feed
+			if err = feeds.Add(pk); err != nil {
+				return
+			}
+			if feeds.Roots(pk).Last() == nil {
+				t.Error("Add of existing feed removes its roots")
+			}
+			return
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			feeds := tx.Feeds()
+			if !feeds.IsExist(pk) {
+				t.Error("feed doesn't exist")
+			}
+			if roots := feeds.Roots(pk); roots == nil {
+				t.Error("Roots of existing feed is nil")
+			} else if roots.Feed() != pk {
+				t.Error("wrong feed of Roots")
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "List Ascend", func(t *testing.T, db data.DB) {
+		view(t, db, func(tx data.Tv) (_ error) {
+			if list := tx.Feeds().List(); len(list) != 0 {
+				t.Error("List of empty database is not empty")
+			}
+			return
+		})
+		pks := orderedPublicKeys(3)
+		update(t, db, func(tx data.Tu) (err error) {
+			for i := len(pks) - 1; i >= 0; i-- {
+				if err = tx.Feeds().Add(pks[i]); err != nil {
+					return
+				}
+			}
+			return
+		})
+		view(t, db, func(tx data.Tv) (err error) {
+			feeds := tx.Feeds()
+
+			comparePublicKeys(t, pks, feeds.List())
+
+			var got []cipher.PubKey
+			err = feeds.Ascend(func(pk cipher.PubKey) (_ error) {
+				got = append(got, pk)
+				return
+			})
+			if err != nil {
+				return
+			}
+			comparePublicKeys(t, pks, got)
+
+			// stop
+			got = got[:0]
+			err = feeds.Ascend(func(pk cipher.PubKey) (_ error) {
+				got = append(got, pk)
+				return data.ErrStopIteration
+			})
+			if err != nil {
+				t.Error("ErrStopIteration bubbles up:", err)
+			}
+			comparePublicKeys(t, pks[:1], got)
+
+			// error
+			err = feeds.Ascend(func(cipher.PubKey) error { return errTest })
+			if err != errTest {
+				t.Error("unexpected error:", err)
+			}
+			return nil
+		})
+	})
+
+	with(t, newDB, "Del", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		update(t, db, func(tx data.Tu) (err error) {
+			feeds := tx.Feeds()
+			if err = feeds.Del(pk); err != nil {
+				t.Error("Del of missing feed returns error:", err)
+				return nil
+			}
+			if err = feeds.Add(pk); err != nil {
+				return
+			}
+			if err = feeds.Roots(pk).Add(rootPack(0, "root")); err != nil {
+				return
+			}
+			return feeds.Del(pk)
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			if tx.Feeds().IsExist(pk) {
+				t.Error("deleted feed exists")
+			}
+			return
+		})
+		// roots of deleted feed must be deleted too
+		update(t, db, func(tx data.Tu) (err error) {
+			if err = tx.Feeds().Add(pk); err != nil {
+				return
+			}
+			if tx.Feeds().Roots(pk).Last() != nil {
+				t.Error("roots of deleted feed exist")
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "AscendDel", func(t *testing.T, db data.DB) {
+		pks := orderedPublicKeys(4)
+		update(t, db, func(tx data.Tu) (err error) {
+			for _, pk := range pks {
+				if err = tx.Feeds().Add(pk); err != nil {
+					return
+				}
+				err = tx.Feeds().Roots(pk).Add(rootPack(0, pk.Hex()))
+				if err != nil {
+					return
+				}
+			}
+			return
+		})
+		// delete first and third, stop at fourth
+		update(t, db, func(tx data.Tu) (err error) {
+			var got []cipher.PubKey
+			err = tx.Feeds().AscendDel(func(pk cipher.PubKey) (bool, error) {
+				got = append(got, pk)
+				if len(got) == 4 {
+					return true, data.ErrStopIteration // not deleted
+				}
+				return len(got)%2 == 1, nil
+			})
+			if err != nil {
+				t.Error("ErrStopIteration bubbles up:", err)
+				return nil
+			}
+			comparePublicKeys(t, pks, got)
+			return
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			comparePublicKeys(t, []cipher.PubKey{pks[1], pks[3]},
+				tx.Feeds().List())
+			return
+		})
+		// roots of deleted feed must be deleted too
+		update(t, db, func(tx data.Tu) (err error) {
+			if err = tx.Feeds().Add(pks[0]); err != nil {
+				return
+			}
+			if tx.Feeds().Roots(pks[0]).Last() != nil {
+				t.Error("roots of deleted feed exist")
+			}
+			return
+		})
+
+		// error
+		err := db.Update(func(tx data.Tu) error {
+			return tx.Feeds().AscendDel(func(cipher.PubKey) (bool, error) {
+				return false, errTest
+			})
+		})
+		if err != errTest {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+}
+
+func comparePublicKeys(t *testing.T, want, got []cipher.PubKey) {
+	if len(want) != len(got) {
+		t.Errorf("wrong length: want %d, got %d", len(want), len(got))
+		return
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("wrong item %d: want %s, got %s", i,
+				w.Hex()[:7],
+				got[i].Hex()[:7])
+		}
+	}
+}
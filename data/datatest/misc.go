@@ -0,0 +1,259 @@
+package datatest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/skycoin/cxo/data"
+)
+
+func compareKeys(t *testing.T, want, got [][]byte) {
+	if len(want) != len(got) {
+		t.Errorf("wrong keys: want %q, got %q", want, got)
+		return
+	}
+	for i, w := range want {
+		if !bytes.Equal(got[i], w) {
+			t.Errorf("wrong keys: want %q, got %q", want, got)
+			return
+		}
+	}
+}
+
+// Misc tests ViewMisc and UpdateMisc of a data.DB
+func Misc(t *testing.T, newDB Constructor) {
+
+	with(t, newDB, "Set Get Del", func(t *testing.T, db data.DB) {
+		key, value := []byte("key"), []byte("value")
+		update(t, db, func(tx data.Tu) (err error) {
+			misc := tx.Misc("")
+			if misc.Get(key) != nil {
+				t.Error("got missing value")
+			}
+			if err = misc.Del(key); err != nil {
+				t.Error("Del of missing value returns error:", err)
+				return nil
+			}
+			return misc.Set(key, value)
+		})
+		var cp []byte
+		view(t, db, func(tx data.Tv) (_ error) {
+			misc := tx.Misc("")
+			if got := misc.Get(key); !bytes.Equal(got, value) {
+				t.Errorf("wrong value: %q", got)
+			}
+			cp = misc.GetCopy(key)
+			return
+		})
+		update(t, db, func(tx data.Tu) error {
+			return tx.Misc("").Set(key, []byte("overwritten"))
+		})
+		if !bytes.Equal(cp, value) {
+			t.Errorf("copy has been changed: %q", cp)
+		}
+		update(t, db, func(tx data.Tu) error {
+			return tx.Misc("").Del(key)
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			if tx.Misc("").Get(key) != nil {
+				t.Error("deleted value exists")
+			}
+			return
+		})
+	})
+
+	keys := [][]byte{
+		[]byte("a"),
+		[]byte("ab"),
+		[]byte("b"),
+		{0xff, 0x00},
+	}
+
+	fill := func(t *testing.T, db data.DB) {
+		update(t, db, func(tx data.Tu) (err error) {
+			for i := len(keys) - 1; i >= 0; i-- {
+				if err = tx.Misc("").Set(keys[i], keys[i]); err != nil {
+					return
+				}
+			}
+			return
+		})
+	}
+
+	with(t, newDB, "Ascend", func(t *testing.T, db data.DB) {
+		fill(t, db)
+		view(t, db, func(tx data.Tv) (err error) {
+			misc := tx.Misc("")
+
+			var got [][]byte
+			err = misc.Ascend(func(key, value []byte) (_ error) {
+				if !bytes.Equal(key, value) {
+					t.Error("wrong value")
+				}
+				got = append(got, append([]byte{}, key...))
+				return
+			})
+			if err != nil {
+				return
+			}
+			compareKeys(t, keys, got)
+
+			// stop
+			got = got[:0]
+			err = misc.Ascend(func(key, _ []byte) (_ error) {
+				got = append(got, append([]byte{}, key...))
+				return data.ErrStopIteration
+			})
+			if err != nil {
+				t.Error("ErrStopIteration bubbles up:", err)
+			}
+			compareKeys(t, keys[:1], got)
+
+			// error
+			if err = misc.Ascend(func(_, _ []byte) error {
+				return errTest
+			}); err != errTest {
+				t.Error("unexpected error:", err)
+			}
+			return nil
+		})
+	})
+
+	with(t, newDB, "AscendDel", func(t *testing.T, db data.DB) {
+		fill(t, db)
+		update(t, db, func(tx data.Tu) (err error) {
+			var got [][]byte
+			err = tx.Misc("").AscendDel(func(key, _ []byte) (bool, error) {
+				got = append(got, append([]byte{}, key...))
+				if len(got) == 3 {
+					return true, data.ErrStopIteration // not deleted
+				}
+				return len(got) == 1, nil
+			})
+			if err != nil {
+				t.Error("ErrStopIteration bubbles up:", err)
+				return nil
+			}
+			compareKeys(t, keys[:3], got)
+			return
+		})
+		var got [][]byte
+		view(t, db, func(tx data.Tv) error {
+			return tx.Misc("").Ascend(func(key, _ []byte) (_ error) {
+				got = append(got, append([]byte{}, key...))
+				return
+			})
+		})
+		compareKeys(t, keys[1:], got)
+
+		// error
+		err := db.Update(func(tx data.Tu) error {
+			return tx.Misc("").AscendDel(func(_, _ []byte) (bool, error) {
+				return false, errTest
+			})
+		})
+		if err != errTest {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+	with(t, newDB, "Descend", func(t *testing.T, db data.DB) {
+		fill(t, db)
+		view(t, db, func(tx data.Tv) (err error) {
+			misc := tx.Misc("")
+
+			var got [][]byte
+			err = misc.Descend(func(key, value []byte) (_ error) {
+				if !bytes.Equal(key, value) {
+					t.Error("wrong value")
+				}
+				got = append(got, append([]byte{}, key...))
+				return
+			})
+			if err != nil {
+				return
+			}
+			compareKeys(t, reversed(keys), got)
+
+			// stop
+			got = got[:0]
+			err = misc.Descend(func(key, _ []byte) (_ error) {
+				got = append(got, append([]byte{}, key...))
+				return data.ErrStopIteration
+			})
+			if err != nil {
+				t.Error("ErrStopIteration bubbles up:", err)
+			}
+			compareKeys(t, keys[len(keys)-1:], got)
+
+			// error
+			if err = misc.Descend(func(_, _ []byte) error {
+				return errTest
+			}); err != errTest {
+				t.Error("unexpected error:", err)
+			}
+			return nil
+		})
+	})
+
+	with(t, newDB, "DescendDel", func(t *testing.T, db data.DB) {
+		fill(t, db)
+		update(t, db, func(tx data.Tu) (err error) {
+			var got [][]byte
+			err = tx.Misc("").DescendDel(func(key, _ []byte) (bool, error) {
+				got = append(got, append([]byte{}, key...))
+				if len(got) == 3 {
+					return true, data.ErrStopIteration // not deleted
+				}
+				return len(got) == 1, nil
+			})
+			if err != nil {
+				t.Error("ErrStopIteration bubbles up:", err)
+				return nil
+			}
+			compareKeys(t, reversed(keys[1:]), got)
+			return
+		})
+		var got [][]byte
+		view(t, db, func(tx data.Tv) error {
+			return tx.Misc("").Ascend(func(key, _ []byte) (_ error) {
+				got = append(got, append([]byte{}, key...))
+				return
+			})
+		})
+		compareKeys(t, keys[:len(keys)-1], got)
+
+		// delete all
+		update(t, db, func(tx data.Tu) error {
+			return tx.Misc("").DescendDel(func(_, _ []byte) (bool, error) {
+				return true, nil
+			})
+		})
+		view(t, db, func(tx data.Tv) error {
+			return tx.Misc("").Ascend(func(key, _ []byte) (_ error) {
+				t.Errorf("not deleted %q", key)
+				return
+			})
+		})
+
+		// error
+		fill(t, db)
+		err := db.Update(func(tx data.Tu) error {
+			return tx.Misc("").DescendDel(func(_, _ []byte) (bool, error) {
+				return false, errTest
+			})
+		})
+		if err != errTest {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+}
+
+// reversed returns reversed copy of given keys
+func reversed(keys [][]byte) (rev [][]byte) {
+	for i := len(keys) - 1; i >= 0; i-- {
+		rev = append(rev, keys[i])
+	}
+	return
+}
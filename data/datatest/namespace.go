@@ -0,0 +1,246 @@
+package datatest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/skycoin/cxo/data"
+)
+
+func compareNamespaces(t *testing.T, want, got []string) {
+	if len(want) != len(got) {
+		t.Errorf("wrong namespaces: want %q, got %q", want, got)
+		return
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("wrong namespaces: want %q, got %q", want, got)
+			return
+		}
+	}
+}
+
+// Namespaces tests namespaces of misc-objects of a data.DB
+func Namespaces(t *testing.T, newDB Constructor) {
+
+	// the "a" and the "ab" have common prefix
+	namespaces := []string{"a", "ab", "b"}
+
+	// fill sets the same keys with different values in the
+	// default namespace and in every namespace of the list
+	fill := func(t *testing.T, db data.DB) {
+		update(t, db, func(tx data.Tu) (err error) {
+			for _, ns := range append([]string{""}, namespaces...) {
+				misc := tx.Misc(ns)
+				for _, k := range []string{"k1", "k2"} {
+					if err = misc.Set([]byte(k), []byte(ns+k)); err != nil {
+						return
+					}
+				}
+			}
+			return
+		})
+	}
+
+	with(t, newDB, "isolation", func(t *testing.T, db data.DB) {
+		fill(t, db)
+		view(t, db, func(tx data.Tv) (_ error) {
+			for _, ns := range append([]string{""}, namespaces...) {
+				misc := tx.Misc(ns)
+				for _, k := range []string{"k1", "k2"} {
+					got := misc.Get([]byte(k))
+					if !bytes.Equal(got, []byte(ns+k)) {
+						t.Errorf("wrong value of %q:%q: %q", ns, k, got)
+					}
+				}
+				var keys [][]byte
+				misc.Ascend(func(key, _ []byte) (_ error) {
+					keys = append(keys, append([]byte{}, key...))
+					return
+				})
+				compareKeys(t, [][]byte{[]byte("k1"), []byte("k2")}, keys)
+				cur := misc.PrefixCursor([]byte("k"))
+				for key, value := cur.First(); key != nil; key, value = cur.Next() {
+					if !bytes.Equal(value, []byte(ns+string(key))) {
+						t.Errorf("wrong value of %q:%q: %q", ns, key, value)
+					}
+				}
+			}
+			return
+		})
+		update(t, db, func(tx data.Tu) error {
+			return tx.Misc("a").Del([]byte("k1"))
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			if tx.Misc("a").Get([]byte("k1")) != nil {
+				t.Error("deleted value exists")
+			}
+			for _, ns := range []string{"", "ab", "b"} {
+				if tx.Misc(ns).Get([]byte("k1")) == nil {
+					t.Errorf("value of %q deleted", ns)
+				}
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "Namespaces", func(t *testing.T, db data.DB) {
+		view(t, db, func(tx data.Tv) (_ error) {
+			compareNamespaces(t, nil, tx.Namespaces())
+			if tx.Misc("none").Get([]byte("k1")) != nil {
+				t.Error("got value of missing namespace")
+			}
+			return
+		})
+		fill(t, db)
+		view(t, db, func(tx data.Tv) (_ error) {
+			compareNamespaces(t, namespaces, tx.Namespaces())
+			return
+		})
+		// empty namespace is not listed
+		update(t, db, func(tx data.Tu) error {
+			return tx.Misc("b").AscendDel(func(_, _ []byte) (bool, error) {
+				return true, nil
+			})
+		})
+		update(t, db, func(tx data.Tu) (_ error) {
+			compareNamespaces(t, namespaces[:2], tx.Namespaces())
+			return
+		})
+	})
+
+	with(t, newDB, "DelNamespace", func(t *testing.T, db data.DB) {
+		fill(t, db)
+		update(t, db, func(tx data.Tu) (err error) {
+			if err = tx.DelNamespace("a"); err != nil {
+				return
+			}
+			return tx.DelNamespace("none") // not found
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			compareNamespaces(t, namespaces[1:], tx.Namespaces())
+			if tx.Misc("a").Get([]byte("k1")) != nil {
+				t.Error("value of deleted namespace exists")
+			}
+			if tx.Misc("ab").Get([]byte("k1")) == nil {
+				t.Error("value of another namespace deleted")
+			}
+			return
+		})
+		// default namespace
+		update(t, db, func(tx data.Tu) error {
+			return tx.DelNamespace("")
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			tx.Misc("").Ascend(func(key, _ []byte) (_ error) {
+				t.Errorf("not deleted %q", key)
+				return
+			})
+			compareNamespaces(t, namespaces[1:], tx.Namespaces())
+			return
+		})
+		// rollback
+		err := db.Update(func(tx data.Tu) (err error) {
+			if err = tx.DelNamespace("b"); err != nil {
+				return
+			}
+			return errTest
+		})
+		if err != errTest {
+			t.Fatal("unexpected error:", err)
+		}
+		view(t, db, func(tx data.Tv) (_ error) {
+			compareNamespaces(t, namespaces[1:], tx.Namespaces())
+			return
+		})
+	})
+
+	with(t, newDB, "Stat", func(t *testing.T, db data.DB) {
+		if s := db.Stat(); s.Namespaces != nil {
+			t.Error("namespaces in empty database:", s.Namespaces)
+		}
+		fill(t, db)
+		s := db.Stat()
+		if len(s.Namespaces) != len(namespaces)+1 {
+			t.Fatal("wrong amount of namespaces:", len(s.Namespaces))
+		}
+		for _, ns := range append([]string{""}, namespaces...) {
+			want := data.NamespaceStat{
+				Keys:  2,
+				Space: data.Space(2*len("k1") + 2*len(ns+"k1")),
+			}
+			if got := s.Namespaces[ns]; got != want {
+				t.Errorf("wrong stat of %q: want %v, got %v", ns, want, got)
+			}
+		}
+		// replace and delete
+		update(t, db, func(tx data.Tu) (err error) {
+			misc := tx.Misc("a")
+			if err = misc.Set([]byte("k1"), []byte("value")); err != nil {
+				return
+			}
+			return misc.Del([]byte("k2"))
+		})
+		want := data.NamespaceStat{Keys: 1, Space: data.Space(len("k1value"))}
+		if got := db.Stat().Namespaces["a"]; got != want {
+			t.Errorf("wrong stat: want %v, got %v", want, got)
+		}
+		update(t, db, func(tx data.Tu) error {
+			return tx.DelNamespace("b")
+		})
+		if _, ok := db.Stat().Namespaces["b"]; ok {
+			t.Error("deleted namespace in stat")
+		}
+		// the same after recounting
+		s = db.Stat()
+		if err := data.RecountStat(db); err != nil {
+			t.Fatal(err)
+		}
+		r := db.Stat()
+		if len(r.Namespaces) != len(s.Namespaces) {
+			t.Fatalf("wrong namespaces after recount: %v", r.Namespaces)
+		}
+		for ns, st := range s.Namespaces {
+			if r.Namespaces[ns] != st {
+				t.Errorf("wrong stat of %q after recount: want %v, got %v",
+					ns, st, r.Namespaces[ns])
+			}
+		}
+	})
+
+	with(t, newDB, "handles", func(t *testing.T, db data.DB) {
+		update(t, db, func(tx data.Tu) (err error) {
+			first, second := tx.Misc("x"), tx.Misc("x")
+			if err = first.Set([]byte("k1"), []byte("v1")); err != nil {
+				return
+			}
+			if err = second.Set([]byte("k2"), []byte("v2")); err != nil {
+				return
+			}
+			if second.Get([]byte("k1")) == nil {
+				t.Error("value is not visible through another handle")
+			}
+			if err = tx.DelNamespace("x"); err != nil {
+				return
+			}
+			if first.Get([]byte("k1")) != nil {
+				t.Error("value of deleted namespace exists")
+			}
+			return first.Set([]byte("k3"), []byte("v3"))
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			var keys [][]byte
+			tx.Misc("x").Ascend(func(key, _ []byte) (_ error) {
+				keys = append(keys, append([]byte{}, key...))
+				return
+			})
+			compareKeys(t, [][]byte{[]byte("k3")}, keys)
+			return
+		})
+		want := data.NamespaceStat{Keys: 1, Space: data.Space(len("k3v3"))}
+		if got := db.Stat().Namespaces["x"]; got != want {
+			t.Errorf("wrong stat: want %v, got %v", want, got)
+		}
+	})
+
+}
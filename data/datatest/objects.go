@@ -0,0 +1,358 @@
+package datatest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+// Objects tests ViewObjects and UpdateObjects of a data.DB
+func Objects(t *testing.T, newDB Constructor) {
+
+	with(t, newDB, "Set Get IsExist", func(t *testing.T, db data.DB) {
+		value := []byte("value")
+		key := cipher.SumSHA256(value)
+		view(t, db, func(tx data.Tv) (_ error) {
+			objs := tx.Objects()
+			if objs.Get(key) != nil {
+				t.Error("got missing object")
+			}
+			if objs.GetCopy(key) != nil {
+				t.Error("got copy of missing object")
+			}
+			if objs.IsExist(key) {
+				t.Error("missing object exists")
+			}
+			return
+		})
+		update(t, db, func(tx data.Tu) error {
+			return tx.Objects().Set(key, value)
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			objs := tx.Objects()
+			if got := objs.Get(key); !bytes.Equal(got, value) {
+				t.Errorf("wrong value: %q", got)
+			}
+			if !objs.IsExist(key) {
+				t.Error("object doesn't exist")
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "Add", func(t *testing.T, db data.DB) {
+		value := []byte("value")
+		update(t, db, func(tx data.Tu) (err error) {
+			var key cipher.SHA256
+			if key, err = tx.Objects().Add(value); err != nil {
+				return
+			}
+			if key != cipher.SumSHA256(value) {
+				t.Error("wrong key")
+			}
+			return
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			got := tx.Objects().Get(cipher.SumSHA256(value))
+			if !bytes.Equal(got, value) {
+				t.Errorf("wrong value: %q", got)
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "SetMap", func(t *testing.T, db data.DB) {
+		m := make(map[cipher.SHA256][]byte)
+		for _, s := range []string{"one", "two", "three"} {
+			m[cipher.SumSHA256([]byte(s))] = []byte(s)
+		}
+		update(t, db, func(tx data.Tu) error {
+			return tx.Objects().SetMap(m)
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			objs := tx.Objects()
+			for k, v := range m {
+				if got := objs.Get(k); !bytes.Equal(got, v) {
+					t.Errorf("wrong value: want %q, got %q", v, got)
+				}
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "Del", func(t *testing.T, db data.DB) {
+		value := []byte("value")
+		key := cipher.SumSHA256(value)
+		update(t, db, func(tx data.Tu) (err error) {
+			objs := tx.Objects()
+			if err = objs.Del(key); err != nil {
+				t.Error("Del of missing object returns error:", err)
+				return nil
+			}
+			if _, err = objs.Add(value); err != nil {
+				return
+			}
+			if _, err = objs.Inc(key); err != nil {
+				return
+			}
+			return objs.Del(key)
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			objs := tx.Objects()
+			if objs.IsExist(key) {
+				t.Error("deleted object exists")
+			}
+			if objs.Refs(key) != 0 {
+				t.Error("references counter of deleted object is not zero")
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "GetCopy", func(t *testing.T, db data.DB) {
+		value := []byte("value")
+		key := cipher.SumSHA256(value)
+		update(t, db, func(tx data.Tu) error {
+			return tx.Objects().Set(key, value)
+		})
+		var cp []byte
+		view(t, db, func(tx data.Tv) (_ error) {
+			cp = tx.Objects().GetCopy(key)
+			return
+		})
+		if !bytes.Equal(cp, value) {
+			t.Fatalf("wrong copy: %q", cp)
+		}
+		// the copy must survive next transactions
+		update(t, db, func(tx data.Tu) error {
+			return tx.Objects().Set(key, []byte("overwritten"))
+		})
+		update(t, db, func(tx data.Tu) error {
+			_, err := tx.Objects().Add([]byte("another one"))
+			return err
+		})
+		if !bytes.Equal(cp, value) {
+			t.Fatalf("copy has been changed: %q", cp)
+		}
+		// changes of the copy must not affect database
+		cp[0] = 'X'
+		view(t, db, func(tx data.Tv) (_ error) {
+			got := tx.Objects().Get(key)
+			if !bytes.Equal(got, []byte("overwritten")) {
+				t.Errorf("wrong value: %q", got)
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "Ascend", func(t *testing.T, db data.DB) {
+		values := []string{"one", "two", "three", "four"}
+		update(t, db, func(tx data.Tu) (err error) {
+			for _, v := range values {
+				if _, err = tx.Objects().Add([]byte(v)); err != nil {
+					return
+				}
+			}
+			return
+		})
+		want := sortedHashes(values...)
+		view(t, db, func(tx data.Tv) (err error) {
+			objs := tx.Objects()
+
+			// all in order
+			var got []cipher.SHA256
+			err = objs.Ascend(func(key cipher.SHA256, value []byte) (_ error) {
+				if cipher.SumSHA256(value) != key {
+					t.Error("wrong value")
+				}
+				got = append(got, key)
+				return
+			})
+			if err != nil {
+				return
+			}
+			compareHashes(t, want, got)
+
+			// stop
+			got = got[:0]
+			err = objs.Ascend(func(key cipher.SHA256, _ []byte) (_ error) {
+				if got = append(got, key); len(got) == 2 {
+					return data.ErrStopIteration
+				}
+				return
+			})
+			if err != nil {
+				t.Error("ErrStopIteration bubbles up:", err)
+			}
+			compareHashes(t, want[:2], got)
+
+			// error
+			var called int
+			err = objs.Ascend(func(cipher.SHA256, []byte) error {
+				called++
+				return errTest
+			})
+			if err != errTest {
+				t.Error("unexpected error:", err)
+			}
+			if called != 1 {
+				t.Error("iteration is not stopped by error")
+			}
+			return nil
+		})
+	})
+
+	with(t, newDB, "AscendDel", func(t *testing.T, db data.DB) {
+		values := []string{"one", "two", "three", "four", "five", "six"}
+		update(t, db, func(tx data.Tu) (err error) {
+			for _, v := range values {
+				if _, err = tx.Objects().Add([]byte(v)); err != nil {
+					return
+				}
+			}
+			return
+		})
+		want := sortedHashes(values...)
+
+		// delete every second object, stop at fifth
+		update(t, db, func(tx data.Tu) (err error) {
+			var got []cipher.SHA256
+			err = tx.Objects().AscendDel(func(key cipher.SHA256,
+				value []byte) (del bool, err error) {
+
+				if cipher.SumSHA256(value) != key {
+					t.Error("wrong value")
+				}
+				got = append(got, key)
+				if len(got) == 5 {
+					return true, data.ErrStopIteration // not deleted
+				}
+				return len(got)%2 == 1, nil
+			})
+			if err != nil {
+				t.Error("ErrStopIteration bubbles up:", err)
+				return nil
+			}
+			compareHashes(t, want[:5], got)
+			return
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			objs := tx.Objects()
+			for i, key := range want {
+				if del := i < 4 && i%2 == 0; objs.IsExist(key) == del {
+					t.Errorf("wrong state of object %d: deleted %t", i, !del)
+				}
+			}
+			return
+		})
+
+		// error
+		err := db.Update(func(tx data.Tu) error {
+			return tx.Objects().AscendDel(func(cipher.SHA256,
+				[]byte) (bool, error) {
+
+				return false, errTest
+			})
+		})
+		if err != errTest {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+	with(t, newDB, "Refs", func(t *testing.T, db data.DB) {
+		value := []byte("value")
+		key := cipher.SumSHA256(value)
+		update(t, db, func(tx data.Tu) (err error) {
+			objs := tx.Objects()
+
+			if _, err = objs.Inc(key); err != data.ErrNotFound {
+				t.Error("Inc of missing object: unexpected error:", err)
+			}
+			if _, err = objs.Dec(key); err != data.ErrNotFound {
+				t.Error("Dec of missing object: unexpected error:", err)
+			}
+			if err = objs.SetRefs(key, 1); err != data.ErrNotFound {
+				t.Error("SetRefs of missing object: unexpected error:", err)
+			}
+
+			if _, err = objs.Add(value); err != nil {
+				return
+			}
+			if rc := objs.Refs(key); rc != 0 {
+				t.Error("references counter of new object is", rc)
+			}
+
+			var rc uint32
+			for i := uint32(1); i <= 2; i++ {
+				if rc, err = objs.Inc(key); err != nil {
+					return
+				} else if rc != i {
+					t.Errorf("Inc: want %d, got %d", i, rc)
+				}
+			}
+
+			// Set keeps the counter
+			if err = objs.Set(key, value); err != nil {
+				return
+			}
+			if rc = objs.Refs(key); rc != 2 {
+				t.Error("Set changes references counter:", rc)
+			}
+
+			for _, want := range []uint32{1, 0, 0} {
+				if rc, err = objs.Dec(key); err != nil {
+					return
+				} else if rc != want {
+					t.Errorf("Dec: want %d, got %d", want, rc)
+				}
+			}
+			if !objs.IsExist(key) {
+				t.Error("Dec deletes object")
+			}
+
+			if err = objs.SetRefs(key, 10); err != nil {
+				return
+			}
+			if rc = objs.Refs(key); rc != 10 {
+				t.Error("SetRefs: wrong counter", rc)
+			}
+			return
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			if rc := tx.Objects().Refs(key); rc != 10 {
+				t.Error("counter is not saved:", rc)
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "Recounted", func(t *testing.T, db data.DB) {
+		update(t, db, func(tx data.Tu) error {
+			return tx.Objects().Recounted()
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			if tx.Objects().NeedRecount() {
+				t.Error("NeedRecount after Recounted")
+			}
+			return
+		})
+	})
+
+}
+
+func compareHashes(t *testing.T, want, got []cipher.SHA256) {
+	if len(want) != len(got) {
+		t.Errorf("wrong length: want %d, got %d", len(want), len(got))
+		return
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("wrong item %d: want %s, got %s", i,
+				w.Hex()[:7],
+				got[i].Hex()[:7])
+		}
+	}
+}
@@ -0,0 +1,468 @@
+package datatest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+// rootPack returns RootPack with dummy Root field,
+// the field can't be decoded to skyobject.Root
+func rootPack(seq uint64, content string) (rp *data.RootPack) {
+	rp = new(data.RootPack)
+	rp.Seq = seq
+	if seq != 0 {
+		rp.Prev = cipher.SumSHA256([]byte("any"))
+	}
+	rp.Root = []byte(content)
+	rp.Hash = cipher.SumSHA256(rp.Root)
+	return
+}
+
+// addRoots creates feed and adds roots with given seq numbers
+func addRoots(t *testing.T, db data.DB, pk cipher.PubKey, seqs ...uint64) {
+	update(t, db, func(tx data.Tu) (err error) {
+		feeds := tx.Feeds()
+		if err = feeds.Add(pk); err != nil {
+			return
+		}
+		roots := feeds.Roots(pk)
+		for _, seq := range seqs {
+			if err = roots.Add(rootPack(seq, "root")); err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// seqsOf returns seq numbers of all roots of given feed
+// in order of Ascend (or Descend if desc is true)
+func seqsOf(t *testing.T, db data.DB, pk cipher.PubKey,
+	desc bool) (seqs []uint64) {
+
+	view(t, db, func(tx data.Tv) error {
+		roots := tx.Feeds().Roots(pk)
+		fn := func(rp *data.RootPack) (_ error) {
+			seqs = append(seqs, rp.Seq)
+			return
+		}
+		if desc {
+			return roots.Descend(fn)
+		}
+		return roots.Ascend(fn)
+	})
+	return
+}
+
+func compareSeqs(t *testing.T, want, got []uint64) {
+	if len(want) != len(got) {
+		t.Errorf("wrong seqs: want %v, got %v", want, got)
+		return
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("wrong seqs: want %v, got %v", want, got)
+			return
+		}
+	}
+}
+
+// Roots tests ViewRoots and UpdateRoots of a data.DB
+func Roots(t *testing.T, newDB Constructor) {
+
+	with(t, newDB, "Add Get Last", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		addRoots(t, db, pk)
+		view(t, db, func(tx data.Tv) (_ error) {
+			roots := tx.Feeds().Roots(pk)
+			if roots.Last() != nil {
+				t.Error("Last of empty feed is not nil")
+			}
+			if roots.Get(0) != nil {
+				t.Error("got missing root")
+			}
+			return
+		})
+		want := rootPack(0, "root")
+		want.Sig = cipher.Sig{1, 2, 3}
+		update(t, db, func(tx data.Tu) (err error) {
+			roots := tx.Feeds().Roots(pk)
+			if err = roots.Add(want); err != nil {
+				return
+			}
+			if want.Sig != (cipher.Sig{1, 2, 3}) || want.Seq != 0 {
+				t.Error("Add modifies given RootPack")
+			}
+			if err = roots.Add(rootPack(0, "another")); err != data.ErrRootAlreadyExists {
+				t.Error("unexpected error:", err)
+			}
+			return roots.Add(rootPack(1, "next"))
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			roots := tx.Feeds().Roots(pk)
+			if got := roots.Get(0); got == nil {
+				t.Error("missing root")
+			} else {
+				compareRootPacks(t, want, got)
+			}
+			if roots.IsFull(0) {
+				t.Error("new root is full")
+			}
+			if last := roots.Last(); last == nil {
+				t.Error("missing last root")
+			} else if last.Seq != 1 {
+				t.Error("wrong last root:", last.Seq)
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "Add invalid", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		addRoots(t, db, pk)
+
+		withPrev := rootPack(0, "root")
+		withPrev.Prev = cipher.SumSHA256([]byte("prev"))
+
+		noPrev := rootPack(1, "root")
+		noPrev.Prev = cipher.SHA256{}
+
+		wrongHash := rootPack(0, "root")
+		wrongHash.Hash = cipher.SumSHA256([]byte("wrong"))
+
+		update(t, db, func(tx data.Tu) (_ error) {
+			roots := tx.Feeds().Roots(pk)
+			for _, rp := range []*data.RootPack{withPrev, noPrev, wrongHash} {
+				err := roots.Add(rp)
+				if re, ok := err.(*data.RootError); !ok {
+					t.Errorf("want *RootError for seq %d, got %v", rp.Seq, err)
+				} else if re.Feed() != pk || re.Seq() != rp.Seq ||
+					re.Hash() != rp.Hash {
+
+					t.Error("wrong RootError:", re)
+				}
+			}
+			if roots.Last() != nil {
+				t.Error("invalid root has been saved")
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "Ascend Descend", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		addRoots(t, db, pk, 4, 1, 300, 0, 2)
+
+		compareSeqs(t, []uint64{0, 1, 2, 4, 300}, seqsOf(t, db, pk, false))
+		compareSeqs(t, []uint64{300, 4, 2, 1, 0}, seqsOf(t, db, pk, true))
+
+		view(t, db, func(tx data.Tv) (err error) {
+			roots := tx.Feeds().Roots(pk)
+			for _, iterate := range []func(func(*data.RootPack) error) error{
+				roots.Ascend,
+				roots.Descend,
+			} {
+				// stop
+				var called int
+				err = iterate(func(*data.RootPack) error {
+					called++
+					return data.ErrStopIteration
+				})
+				if err != nil {
+					t.Error("ErrStopIteration bubbles up:", err)
+				}
+				if called != 1 {
+					t.Error("iteration is not stopped")
+				}
+				// error
+				if err = iterate(func(*data.RootPack) error {
+					return errTest
+				}); err != errTest {
+					t.Error("unexpected error:", err)
+				}
+			}
+			return nil
+		})
+	})
+
+	with(t, newDB, "Del", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		addRoots(t, db, pk, 0, 1, 2)
+		update(t, db, func(tx data.Tu) (err error) {
+			roots := tx.Feeds().Roots(pk)
+			if err = roots.Del(100); err != nil {
+				t.Error("Del of missing root returns error:", err)
+				return nil
+			}
+			return roots.Del(1)
+		})
+		compareSeqs(t, []uint64{0, 2}, seqsOf(t, db, pk, false))
+	})
+
+	with(t, newDB, "MarkFull", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		addRoots(t, db, pk, 0, 1)
+		update(t, db, func(tx data.Tu) (err error) {
+			roots := tx.Feeds().Roots(pk)
+			if err = roots.MarkFull(100); err != data.ErrNotFound {
+				t.Error("unexpected error:", err)
+			}
+			return roots.MarkFull(1)
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			roots := tx.Feeds().Roots(pk)
+			if roots.IsFull(0) {
+				t.Error("MarkFull marks another root")
+			}
+			if !roots.IsFull(1) {
+				t.Error("not marked as full")
+			}
+			if roots.IsFull(100) {
+				t.Error("missing root is full")
+			}
+			compareRootPacks(t, rootPack(1, "root"), roots.Get(1))
+			return
+		})
+	})
+
+	with(t, newDB, "Meta SetMeta", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		addRoots(t, db, pk, 0, 1)
+		update(t, db, func(tx data.Tu) (err error) {
+			roots := tx.Feeds().Roots(pk)
+			if _, err = roots.Meta(100); err != data.ErrNotFound {
+				t.Error("unexpected error:", err)
+			}
+			err = roots.SetMeta(100, data.RootMeta{IsFull: true})
+			if err != data.ErrNotFound {
+				t.Error("unexpected error:", err)
+			}
+			err = roots.SetMeta(0, data.RootMeta{Space: 10, Refill: true})
+			if err != nil {
+				return
+			}
+			if err = roots.MarkFull(0); err != nil {
+				return
+			}
+			return roots.SetMeta(1, data.RootMeta{IsFull: true, Space: 20})
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			roots := tx.Feeds().Roots(pk)
+			want := []data.RootMeta{
+				{IsFull: true, Space: 10},
+				{IsFull: true, Space: 20},
+			}
+			for seq, w := range want {
+				if rm, err := roots.Meta(uint64(seq)); err != nil {
+					t.Error(err)
+				} else if rm != w {
+					t.Errorf("wrong meta of %d: %v, want %v", seq, rm, w)
+				}
+			}
+			compareRootPacks(t, rootPack(0, "root"), roots.Get(0))
+			return
+		})
+		// metadata deleted with root and feed
+		update(t, db, func(tx data.Tu) (err error) {
+			feeds := tx.Feeds()
+			roots := feeds.Roots(pk)
+			if err = roots.Del(0); err != nil {
+				return
+			}
+			if err = roots.Add(rootPack(0, "root")); err != nil {
+				return
+			}
+			if rm, _ := roots.Meta(0); rm != (data.RootMeta{}) {
+				t.Error("metadata of deleted root:", rm)
+			}
+			if err = feeds.Del(pk); err != nil {
+				return
+			}
+			if err = feeds.Add(pk); err != nil {
+				return
+			}
+			if err = feeds.Roots(pk).Add(rootPack(1, "root")); err != nil {
+				return
+			}
+			if feeds.Roots(pk).IsFull(1) {
+				t.Error("metadata of deleted feed")
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "AtTime RangeTime", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		addRoots(t, db, pk, 0, 1, 2, 3, 4)
+		// seq -> time; the 2 has no time and the
+		// 3 has the same time as the 1
+		times := map[uint64]int64{0: 10, 1: 20, 3: 20, 4: 40}
+		update(t, db, func(tx data.Tu) (err error) {
+			roots := tx.Feeds().Roots(pk)
+			for seq, tm := range times {
+				if err = roots.SetMeta(seq, data.RootMeta{Time: 1}); err != nil {
+					return
+				}
+				// reindex
+				if err = roots.SetMeta(seq, data.RootMeta{Time: tm}); err != nil {
+					return
+				}
+			}
+			return roots.MarkFull(4) // keeps time
+		})
+		view(t, db, func(tx data.Tv) (_ error) {
+			roots := tx.Feeds().Roots(pk)
+			for _, at := range []struct {
+				t    int64
+				seq  uint64
+				none bool
+			}{
+				{t: 5, none: true},
+				{t: 10, seq: 0},
+				{t: 15, seq: 0},
+				{t: 20, seq: 3},
+				{t: 39, seq: 3},
+				{t: 100, seq: 4},
+			} {
+				rp := roots.AtTime(at.t)
+				if at.none {
+					if rp != nil {
+						t.Errorf("AtTime(%d): unexpected root %d", at.t, rp.Seq)
+					}
+				} else if rp == nil {
+					t.Errorf("AtTime(%d): missing root", at.t)
+				} else if rp.Seq != at.seq {
+					t.Errorf("AtTime(%d): wrong root %d, want %d", at.t, rp.Seq,
+						at.seq)
+				}
+			}
+			for _, rng := range []struct {
+				from, to int64
+				want     []uint64
+			}{
+				{0, 0, []uint64{0, 1, 3, 4}},
+				{15, 40, []uint64{1, 3}},
+				{20, 0, []uint64{1, 3, 4}},
+				{41, 0, nil},
+			} {
+				var got []uint64
+				err := roots.RangeTime(rng.from, rng.to,
+					func(rp *data.RootPack) (_ error) {
+						got = append(got, rp.Seq)
+						return
+					})
+				if err != nil {
+					t.Error(err)
+				}
+				compareSeqs(t, rng.want, got)
+			}
+			// stop
+			var got []uint64
+			err := roots.RangeTime(0, 0, func(rp *data.RootPack) error {
+				got = append(got, rp.Seq)
+				return data.ErrStopIteration
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			compareSeqs(t, []uint64{0}, got)
+			return
+		})
+		// deleted roots are removed from the index
+		update(t, db, func(tx data.Tu) (err error) {
+			roots := tx.Feeds().Roots(pk)
+			if err = roots.Del(4); err != nil {
+				return
+			}
+			if err = roots.DelBefore(1); err != nil {
+				return
+			}
+			if rp := roots.AtTime(100); rp == nil || rp.Seq != 3 {
+				t.Error("wrong AtTime after Del")
+			}
+			if rp := roots.AtTime(15); rp != nil {
+				t.Error("wrong AtTime after DelBefore")
+			}
+			return
+		})
+	})
+
+	with(t, newDB, "AscendDel", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		addRoots(t, db, pk, 0, 1, 2, 3, 4, 5)
+		// delete odd, stop at 4
+		update(t, db, func(tx data.Tu) (err error) {
+			var got []uint64
+			err = tx.Feeds().Roots(pk).AscendDel(func(rp *data.RootPack) (bool,
+				error) {
+
+				got = append(got, rp.Seq)
+				if rp.Seq == 4 {
+					return true, data.ErrStopIteration // not deleted
+				}
+				return rp.Seq%2 == 1, nil
+			})
+			if err != nil {
+				t.Error("ErrStopIteration bubbles up:", err)
+				return nil
+			}
+			compareSeqs(t, []uint64{0, 1, 2, 3, 4}, got)
+			return
+		})
+		compareSeqs(t, []uint64{0, 2, 4, 5}, seqsOf(t, db, pk, false))
+
+		// error
+		err := db.Update(func(tx data.Tu) error {
+			return tx.Feeds().Roots(pk).AscendDel(func(*data.RootPack) (bool,
+				error) {
+
+				return false, errTest
+			})
+		})
+		if err != errTest {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+	with(t, newDB, "DelBefore", func(t *testing.T, db data.DB) {
+		pk, _ := cipher.GenerateKeyPair()
+		addRoots(t, db, pk, 0, 1, 2, 5, 6)
+		for _, step := range []struct {
+			before uint64
+			want   []uint64
+		}{
+			{0, []uint64{0, 1, 2, 5, 6}},
+			{2, []uint64{2, 5, 6}},
+			{4, []uint64{5, 6}}, // missing seq
+			{7, nil},            // all
+		} {
+			update(t, db, func(tx data.Tu) error {
+				return tx.Feeds().Roots(pk).DelBefore(step.before)
+			})
+			compareSeqs(t, step.want, seqsOf(t, db, pk, false))
+		}
+	})
+
+}
+
+func compareRootPacks(t *testing.T, want, got *data.RootPack) {
+	if !bytes.Equal(want.Root, got.Root) {
+		t.Error("wrong Root field")
+	}
+	if want.Seq != got.Seq {
+		t.Error("wrong Seq field")
+	}
+	if want.Prev != got.Prev {
+		t.Error("wrong Prev field")
+	}
+	if want.Hash != got.Hash {
+		t.Error("wrong Hash field")
+	}
+	if want.Sig != got.Sig {
+		t.Error("wrong Sig field")
+	}
+}
@@ -0,0 +1,121 @@
+package datatest
+
+import (
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+// Stat tests Stat method of a data.DB. The Space of objects
+// must be exactly total length of all values. The Space of
+// roots depends on encoding and is not checked exactly
+func Stat(t *testing.T, newDB Constructor) {
+
+	with(t, newDB, "empty", func(t *testing.T, db data.DB) {
+		s := db.Stat()
+		if s.Objects != 0 || s.Space != 0 || s.PhysicalSpace != 0 {
+			t.Errorf("non-empty stat of empty database: %d, %s, %s",
+				s.Objects,
+				s.Space,
+				s.PhysicalSpace)
+		}
+		if s.Feeds != nil {
+			t.Error("feeds in empty database")
+		}
+	})
+
+	with(t, newDB, "objects", func(t *testing.T, db data.DB) {
+		values := []string{"one", "two", "three", "four"}
+		var space data.Space
+		update(t, db, func(tx data.Tu) (err error) {
+			for _, v := range values {
+				if _, err = tx.Objects().Add([]byte(v)); err != nil {
+					return
+				}
+				space += data.Space(len(v))
+			}
+			return
+		})
+		s := db.Stat()
+		if s.Objects != len(values) {
+			t.Errorf("wrong amount of objects: want %d, got %d", len(values),
+				s.Objects)
+		}
+		if s.Space != space {
+			t.Errorf("wrong space: want %d, got %d", space, s.Space)
+		}
+		if s.PhysicalSpace == 0 {
+			t.Error("zero physical space")
+		}
+		// references counters are not objects
+		update(t, db, func(tx data.Tu) (err error) {
+			_, err = tx.Objects().Inc(cipher.SumSHA256([]byte("one")))
+			return
+		})
+		update(t, db, func(tx data.Tu) (err error) {
+			return tx.Objects().Del(cipher.SumSHA256([]byte("four")))
+		})
+		s = db.Stat()
+		if s.Objects != len(values)-1 {
+			t.Errorf("wrong amount of objects: want %d, got %d",
+				len(values)-1,
+				s.Objects)
+		}
+		if space -= data.Space(len("four")); s.Space != space {
+			t.Errorf("wrong space: want %d, got %d", space, s.Space)
+		}
+		// misc-objects are not objects
+		update(t, db, func(tx data.Tu) error {
+			return tx.Misc("").Set([]byte("key"), []byte("value"))
+		})
+		if s = db.Stat(); s.Objects != len(values)-1 || s.Space != space {
+			t.Error("misc-objects are counted as objects")
+		}
+	})
+
+	with(t, newDB, "feeds", func(t *testing.T, db data.DB) {
+		pks := orderedPublicKeys(2)
+		addRoots(t, db, pks[0], 0, 1, 2)
+		addRoots(t, db, pks[1])
+
+		s := db.Stat()
+		if len(s.Feeds) != 2 {
+			t.Fatal("wrong amount of feeds:", len(s.Feeds))
+		}
+		fs := s.Feeds[pks[0]]
+		if fs.Roots != 3 {
+			t.Error("wrong amount of roots:", fs.Roots)
+		}
+		if fs.Space == 0 {
+			t.Error("zero space of roots")
+		}
+		if fs = s.Feeds[pks[1]]; fs.Roots != 0 || fs.Space != 0 {
+			t.Error("non-empty stat of empty feed:", fs)
+		}
+		if s.Objects != 0 || s.Space != 0 {
+			t.Error("roots are counted as objects")
+		}
+
+		space := s.Feeds[pks[0]].Space
+		update(t, db, func(tx data.Tu) error {
+			return tx.Feeds().Roots(pks[0]).Del(0)
+		})
+		fs = db.Stat().Feeds[pks[0]]
+		if fs.Roots != 2 {
+			t.Error("wrong amount of roots:", fs.Roots)
+		}
+		if fs.Space >= space {
+			t.Error("space is not reduced")
+		}
+
+		update(t, db, func(tx data.Tu) error {
+			return tx.Feeds().Del(pks[0])
+		})
+		if _, ok := db.Stat().Feeds[pks[0]]; ok {
+			t.Error("deleted feed in stat")
+		}
+	})
+
+}
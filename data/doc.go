@@ -1,5 +1,8 @@
 // Package data represents CXO database. The package includes in-memory
-// database and on-drive database. Both databases implements the same interface.
+// database, on-drive database (boltdb) and write-optimised on-drive
+// LSM-tree database. All databases implements the same interface.
+// The in-memory database can be persisted using snapshot file, see
+// NewMemorySnapshotDB.
 //
 // The DB is ACID and uses transactions, that can be rolled back.
 // There are read-only and read-write transactions. See docs for
@@ -19,5 +22,40 @@
 // read-write transaction returns UpdateObjects. Thus, you will never
 // modify any read-only transaction.
 //
+// Metadata of roots. A RootPack contains only signed fields of
+// a Root and can be sent through network as is. Machine-local
+// metadata of a root (RootMeta: is it full, space of its objects)
+// is stored separately, see ViewRoots.Meta and UpdateRoots.SetMeta.
+// New root is not full. Drive database keeps version of its layout
+// and migrates older layouts when it is opened.
+//
+// Cursors. Objects, Roots and Misc provide cursors to walk through
+// them in any direction seeking any key. A cursor can be limited
+// by [from, to) range or, for Misc, by prefix of keys.
+//
+// Namespaces. Misc-objects are grouped by namespaces, every namespace
+// is isolated bucket. The empty namespace is the default one. A whole
+// namespace can be deleted using Tu.DelNamespace, and the Stat reports
+// usage of every namespace.
+//
+// Blobs. Wrap a DB using NewBlobDB to keep large values of objects
+// as files in sharded directory. The DB keeps short pointers only.
+//
+// Watching. Wrap a DB using NewWatchDB to receive events about added,
+// filled and deleted roots, about added and deleted feeds and about
+// changed misc-objects. The events are delivered after a transaction
+// commits through buffered channel of a Watcher.
+//
+// Statistic. Drive and memory databases keep counters of objects
+// and roots, that are updated by every Update transaction. Thus,
+// the Stat is cheap and can be called often. Use RecountStat to
+// rebuild the counters if they are suspected to be wrong. There
+// are no counters of objects of a feed, since a DB doesn't know
+// what objects belong to a feed, and the ObjectsSpace of a FeedStat
+// is calculated by (*skyobject.Container).DBStat walking roots.
+//
+// Other implementations of the DB can be checked using
+// conformance tests of the datatest package.
+//
 // TODO (kostyarin) improve the docs
 package data
@@ -3,6 +3,7 @@ package data
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"sync"
 	"time"
 
@@ -14,46 +15,393 @@ import (
 
 const dbMode = 0644
 
+// version of database layout, see migrate
+const driveVersion uint64 = 1
+
 // names of buckets
 var (
 	objectsBucket = []byte("objects")
+	refsBucket    = []byte("refs")
 	feedsBucket   = []byte("feeds")
 	miscBucket    = []byte("misc")
+	metaBucket    = []byte("meta")
+	statBucket    = []byte("stat")
+	localBucket   = []byte("local")
+	timesBucket   = []byte("times")
+	nsBucket      = []byte("namespaces")
+	nsStatBucket  = []byte("nsstat") // statistic of namespaces
+)
+
+// keys of meta bucket
+var (
+	versionKey = []byte("version")
+	recountKey = []byte("recount")
+
+	objectsStatKey = []byte("objects") // key of stat bucket
 )
 
 // buckets:
 //  - objects hash -> []byte (including schemas)
+//  - refs    hash -> references counter (uint32)
 //  - feeds   pubkey -> (roots) { seq -> root }
+//  - misc    key -> value
+//  - meta    version, recount flag
+//  - stat    "objects" -> counters, pubkey -> counters of roots
+//  - local   pubkey -> { seq -> RootMeta }
+//  - times   pubkey -> { time + seq -> nothing }
+//  - namespaces namespace -> { key -> value }
 type driveDB struct {
 	bolt   *bolt.DB
 	closeo sync.Once // boltdb panics when Close closed database
 }
 
+// A DriveOptions represents options of drive database.
+// See SafeDriveOptions and FastDriveOptions for durability
+// profiles
+type DriveOptions struct {
+	// Timeout to obtain lock of database file. Database
+	// file can be opened by one process only. Zero
+	// timeout means wait forever
+	Timeout time.Duration
+
+	// NoSync skips fsync after every commit. It's unsafe,
+	// the database can be corrupted if system crashes
+	NoSync bool
+	// NoGrowSync skips truncate call when the database file
+	// grows. It's safe on ext3/ext4 only
+	NoGrowSync bool
+	// InitialMmapSize in bytes. Read-only transactions don't
+	// block read-write transactions if the size is large
+	// enough to hold the database. Zero means default size
+	InitialMmapSize int
+
+	// MaxBatchSize is max number of concurrent transactions
+	// performed by Batch as one transaction. Zero disables
+	// batching
+	MaxBatchSize int
+	// MaxBatchDelay is max time Batch waits for concurrent
+	// transactions to perform them as one transaction
+	MaxBatchDelay time.Duration
+
+	// ReadOnly opens database in read-only mode. Update and
+	// Batch of such database return error. The database can
+	// be opened by few read-only processes at the same time,
+	// but it can't be migrated from an old version
+	ReadOnly bool
+
+	// RootTime is used to index roots of database without
+	// version by time, when the database is migrated. If it's
+	// nil, then the roots are not indexed. See RootTimeFunc
+	RootTime RootTimeFunc
+}
+
+// A RootTimeFunc returns timestamp of Root of given RootPack.
+// The DB knows nothing about encoding of the Root, thus the
+// skyobject package provides the function
+type RootTimeFunc func(rp *RootPack) (time int64, err error)
+
+// A Durability represents durability profile of drive
+// database, see SafeDriveOptions and FastDriveOptions
+type Durability int
+
+// durability profiles
+const (
+	SafeDurability Durability = iota // sync every commit
+	FastDurability                   // never sync
+)
+
+// String implements fmt.Stringer interface
+func (d Durability) String() string {
+	switch d {
+	case SafeDurability:
+		return "safe"
+	case FastDurability:
+		return "fast"
+	}
+	return fmt.Sprintf("Durability<%d>", d)
+}
+
+// Set implements flag.Value interface
+func (d *Durability) Set(s string) error {
+	switch s {
+	case "safe":
+		*d = SafeDurability
+	case "fast":
+		*d = FastDurability
+	default:
+		return fmt.Errorf("unknown durability %q", s)
+	}
+	return nil
+}
+
+// default batching of drive database
+const (
+	DriveMaxBatchSize  int           = 1000
+	DriveMaxBatchDelay time.Duration = 2 * time.Millisecond
+)
+
+// SafeDriveOptions returns default options of drive
+// database. Every commit is synced to drive
+func SafeDriveOptions() *DriveOptions {
+	return &DriveOptions{
+		Timeout:       500 * time.Millisecond,
+		MaxBatchSize:  DriveMaxBatchSize,
+		MaxBatchDelay: DriveMaxBatchDelay,
+	}
+}
+
+// FastDriveOptions returns options of drive database
+// that never syncs commits. It's faster, but the
+// database can be corrupted if system crashes
+func FastDriveOptions() (d *DriveOptions) {
+	d = SafeDriveOptions()
+	d.SetDurability(FastDurability)
+	return
+}
+
+// Durability returns durability profile of the DriveOptions
+func (d *DriveOptions) Durability() Durability {
+	if d.NoSync {
+		return FastDurability
+	}
+	return SafeDurability
+}
+
+// SetDurability sets NoSync and NoGrowSync
+// options according to given profile
+func (d *DriveOptions) SetDurability(dur Durability) {
+	fast := dur == FastDurability
+	d.NoSync, d.NoGrowSync = fast, fast
+}
+
+// Validate the DriveOptions
+func (d *DriveOptions) Validate() error {
+	if d.Timeout < 0 {
+		return fmt.Errorf("negative DriveOptions.Timeout: %v", d.Timeout)
+	}
+	if d.InitialMmapSize < 0 {
+		return fmt.Errorf("negative DriveOptions.InitialMmapSize: %d",
+			d.InitialMmapSize)
+	}
+	if d.MaxBatchSize < 0 {
+		return fmt.Errorf("negative DriveOptions.MaxBatchSize: %d",
+			d.MaxBatchSize)
+	}
+	if d.MaxBatchDelay < 0 {
+		return fmt.Errorf("negative DriveOptions.MaxBatchDelay: %v",
+			d.MaxBatchDelay)
+	}
+	return nil
+}
+
 // NewDriveDB creates new database using given path
-// to create or use existsing database file
+// to create or use existsing database file. It uses
+// SafeDriveOptions
 func NewDriveDB(path string) (db DB, err error) {
+	return NewDriveDBOptions(path, nil)
+}
+
+// NewDriveDBOptions creates new database using given path
+// and options. If the options is nil, then SafeDriveOptions
+// used
+func NewDriveDBOptions(path string, opts *DriveOptions) (db DB, err error) {
+	if opts == nil {
+		opts = SafeDriveOptions()
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
 	var b *bolt.DB
 	b, err = bolt.Open(path, dbMode, &bolt.Options{
-		Timeout: 500 * time.Millisecond,
+		Timeout:         opts.Timeout,
+		NoGrowSync:      opts.NoGrowSync,
+		ReadOnly:        opts.ReadOnly,
+		InitialMmapSize: opts.InitialMmapSize,
 	})
 	if err != nil {
 		return
 	}
-	err = b.Update(func(t *bolt.Tx) (err error) {
-		if _, err = t.CreateBucketIfNotExists(objectsBucket); err != nil {
+	b.NoSync = opts.NoSync
+	b.MaxBatchSize = opts.MaxBatchSize
+	b.MaxBatchDelay = opts.MaxBatchDelay
+
+	if opts.ReadOnly {
+		err = b.View(checkVersion)
+	} else {
+		err = b.Update(func(t *bolt.Tx) error {
+			return migrate(t, opts.RootTime)
+		})
+	}
+	if err != nil {
+		b.Close()
+		return
+	}
+	db = &driveDB{bolt: b}
+	return
+}
+
+// checkVersion returns error if database
+// should be migrated, used by read-only mode
+func checkVersion(t *bolt.Tx) (err error) {
+	var version uint64
+	if meta := t.Bucket(metaBucket); meta != nil {
+		if vb := meta.Get(versionKey); vb != nil {
+			version = btou(vb)
+		}
+	}
+	if version > driveVersion {
+		return fmt.Errorf("unsupported version of database: %d", version)
+	}
+	if version < driveVersion {
+		return fmt.Errorf("version of database is %d, but %d expected:"+
+			" the database must be migrated first, a read-only database"+
+			" can't be migrated, open it once in read-write mode to"+
+			" migrate", version, driveVersion)
+	}
+	return
+}
+
+// migrate creates buckets of new database or updates
+// layout of existing one up to driveVersion, given
+// function used to index roots by time
+func migrate(t *bolt.Tx, rootTime RootTimeFunc) (err error) {
+	var meta *bolt.Bucket
+
+	// database created before versioning has no meta bucket
+	// but can contain objects
+	if meta = t.Bucket(metaBucket); meta == nil {
+		if meta, err = t.CreateBucket(metaBucket); err != nil {
 			return
 		}
-		if _, err = t.CreateBucketIfNotExists(feedsBucket); err != nil {
+	}
 
+	var version uint64
+	if vb := meta.Get(versionKey); vb != nil {
+		version = btou(vb)
+	}
+
+	if version > driveVersion {
+		return fmt.Errorf("unsupported version of database: %d", version)
+	}
+
+	for _, name := range [][]byte{
+		objectsBucket,
+		feedsBucket,
+		miscBucket,
+		localBucket,
+		timesBucket,
+		nsBucket,
+	} {
+		if _, err = t.CreateBucketIfNotExists(name); err != nil {
+			return
+		}
+	}
+
+	if version < 1 {
+		// version 1: references counters, statistic counters,
+		// RootMeta moved out of RootPack and time index
+		if _, err = t.CreateBucketIfNotExists(refsBucket); err != nil {
+			return
+		}
+		// existing objects have no counters
+		if k, _ := t.Bucket(objectsBucket).Cursor().First(); k != nil {
+			if err = meta.Put(recountKey, []byte{1}); err != nil {
+				return
+			}
+		}
+		if err = moveRootMeta(t, rootTime); err != nil {
+			return
+		}
+		if err = recountDriveStat(t); err != nil {
+			return
+		}
+	}
+
+	return meta.Put(versionKey, utob(driveVersion))
+}
+
+// legacyRootPack is RootPack with machine-local
+// fields, that used by database without version
+type legacyRootPack struct {
+	Root []byte
+
+	Seq  uint64
+	Prev cipher.SHA256
+
+	Hash cipher.SHA256
+	Sig  cipher.Sig
+
+	IsFull bool
+	Space  uint64
+}
+
+// moveRootMeta moves machine-local fields from encoded
+// RootPack objects to the local bucket and creates time
+// index of the roots using given function if it's not nil
+func moveRootMeta(t *bolt.Tx, rootTime RootTimeFunc) error {
+	feeds, local := t.Bucket(feedsBucket), t.Bucket(localBucket)
+	times := t.Bucket(timesBucket)
+
+	return feeds.ForEach(func(pk, _ []byte) (err error) {
+
+		var index, metas *bolt.Bucket
+		if index, err = times.CreateBucketIfNotExists(pk); err != nil {
+			return
+		}
+		if metas, err = local.CreateBucketIfNotExists(pk); err != nil {
+			return
+		}
+
+		roots := feeds.Bucket(pk)
+
+		// bolt doesn't allow to change a bucket inside ForEach
+		var seqs, packs [][]byte
+		var rms []RootMeta
+
+		err = roots.ForEach(func(seqb, val []byte) (err error) {
+			var lp legacyRootPack
+			if err = encoder.DeserializeRaw(val, &lp); err != nil {
+				return
+			}
+			rp := &RootPack{
+				Root: lp.Root,
+				Seq:  lp.Seq,
+				Prev: lp.Prev,
+				Hash: lp.Hash,
+				Sig:  lp.Sig,
+			}
+			rm := RootMeta{IsFull: lp.IsFull, Space: lp.Space}
+			if rootTime != nil {
+				// a Root that can't be decoded is not indexed
+				rm.Time, _ = rootTime(rp)
+			}
+			seqs = append(seqs, append([]byte{}, seqb...))
+			packs = append(packs, encoder.Serialize(rp))
+			rms = append(rms, rm)
+			return
+		})
+		if err != nil {
+			return
+		}
+
+		for i, seqb := range seqs {
+			if err = roots.Put(seqb, packs[i]); err != nil {
+				return
+			}
+			if err = metas.Put(seqb, encodeRootMeta(rms[i])); err != nil {
+				return
+			}
+			if rms[i].Time == 0 {
+				continue // not indexed
+			}
+			err = index.Put(timeKey(rms[i].Time, btou(seqb)), []byte{})
+			if err != nil {
+				return
+			}
 		}
-		_, err = t.CreateBucketIfNotExists(miscBucket)
 		return
 	})
-	if err != nil {
-		return
-	}
-	db = &driveDB{bolt: b}
-	return
 }
 
 func (d *driveDB) View(fn func(t Tv) error) (err error) {
@@ -66,62 +414,213 @@ func (d *driveDB) View(fn func(t Tv) error) (err error) {
 }
 
 func (d *driveDB) Update(fn func(t Tu) error) (err error) {
-	err = d.bolt.Update(func(t *bolt.Tx) error {
+	err = d.bolt.Update(func(t *bolt.Tx) (err error) {
 		tx := new(driveTu)
 		tx.tx = t
-		return fn(tx)
+		if err = fn(tx); err != nil {
+			return
+		}
+		return saveDriveStat(t, &tx.stat)
 	})
 	return
 }
 
+// Batch uses bolt.Batch to perform concurrent
+// transactions as one
+func (d *driveDB) Batch(fn func(t Tu) error) (err error) {
+	err = d.bolt.Batch(func(t *bolt.Tx) (err error) {
+		tx := new(driveTu)
+		tx.tx = t
+		if err = fn(tx); err != nil {
+			return
+		}
+		return saveDriveStat(t, &tx.stat)
+	})
+	return
+}
+
+// Stat reads statistic counters and never
+// scans objects, roots and misc-objects
 func (d *driveDB) Stat() (s Stat) {
 
 	d.bolt.View(func(t *bolt.Tx) (_ error) {
 
-		// objects
+		stat := t.Bucket(statBucket)
 
-		objects := t.Bucket(objectsBucket)
-		s.Objects = objects.Stats().KeyN
+		// objects
 
-		objects.ForEach(func(_, v []byte) (_ error) {
-			s.Space += Space(len(v))
-			return
-		})
+		var space int
+		s.Objects, space = decodeCounters(stat.Get(objectsStatKey))
+		s.Space = Space(space)
+		s.PhysicalSpace = s.Space
 
 		// feeds (and roots)
 
-		feeds := t.Bucket(feedsBucket)
-		fln := feeds.Stats().KeyN
-
-		if fln == 0 {
-			return // no feeds
-		}
+		c := t.Bucket(feedsBucket).Cursor()
 
-		s.Feeds = make(map[cipher.PubKey]FeedStat, fln)
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
 
-		feeds.ForEach(func(kk, _ []byte) (_ error) {
-
-			roots := feeds.Bucket(kk)
+			if s.Feeds == nil {
+				s.Feeds = make(map[cipher.PubKey]FeedStat)
+			}
 
 			var fs FeedStat
 			var cp cipher.PubKey
 
-			fs.Roots = roots.Stats().KeyN
-			roots.ForEach(func(_, v []byte) (_ error) {
-				fs.Space += Space(len(v))
-				return
-			})
+			fs.Roots, space = decodeCounters(stat.Get(k))
+			fs.Space = Space(space)
 
-			copy(cp[:], kk)
+			copy(cp[:], k)
 			s.Feeds[cp] = fs
+		}
+
+		// namespaces
+
+		c = t.Bucket(nsStatBucket).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+
+			ns, n := decodeNamespacePrefix(k)
+			if n == 0 {
+				continue
+			}
+
+			if s.Namespaces == nil {
+				s.Namespaces = make(map[string]NamespaceStat)
+			}
+
+			var st NamespaceStat
+			st.Keys, space = decodeCounters(v)
+			st.Space = Space(space)
+			s.Namespaces[ns] = st
+		}
+
+		return
 
+	})
+
+	return
+}
+
+func (d *driveDB) recountStat() error {
+	return d.bolt.Update(recountDriveStat)
+}
+
+// recountDriveStat builds statistic
+// counters scanning whole database
+func recountDriveStat(t *bolt.Tx) (err error) {
+	if t.Bucket(statBucket) != nil {
+		if err = t.DeleteBucket(statBucket); err != nil {
+			return
+		}
+	}
+	var stat *bolt.Bucket
+	if stat, err = t.CreateBucket(statBucket); err != nil {
+		return
+	}
+
+	var n, space int
+	t.Bucket(objectsBucket).ForEach(func(_, v []byte) (_ error) {
+		n++
+		space += len(v)
+		return
+	})
+	if err = stat.Put(objectsStatKey, encodeCounters(n, space)); err != nil {
+		return
+	}
+
+	feeds := t.Bucket(feedsBucket)
+	err = feeds.ForEach(func(pk, _ []byte) error {
+		var roots, space int
+		feeds.Bucket(pk).ForEach(func(_, v []byte) (_ error) {
+			roots++
+			space += len(v)
+			return
+		})
+		return stat.Put(pk, encodeCounters(roots, space))
+	})
+	if err != nil {
+		return
+	}
+
+	if t.Bucket(nsStatBucket) != nil {
+		if err = t.DeleteBucket(nsStatBucket); err != nil {
+			return
+		}
+	}
+	var nsStat *bolt.Bucket
+	if nsStat, err = t.CreateBucket(nsStatBucket); err != nil {
+		return
+	}
+
+	// put counters of given bucket of misc-objects
+	var putNamespace = func(ns string, bk *bolt.Bucket) error {
+		var keys, space int
+		bk.ForEach(func(k, v []byte) (_ error) {
+			keys++
+			space += len(k) + len(v)
 			return
 		})
+		if keys == 0 {
+			return nil
+		}
+		return nsStat.Put(namespacePrefix(ns), encodeCounters(keys, space))
+	}
 
+	if err = putNamespace("", t.Bucket(miscBucket)); err != nil {
 		return
+	}
 
+	nss := t.Bucket(nsBucket)
+	return nss.ForEach(func(name, _ []byte) error {
+		return putNamespace(string(name), nss.Bucket(name))
 	})
+}
+
+// saveDriveStat updates stored statistic counters by given delta
+func saveDriveStat(t *bolt.Tx, s *statDelta) (err error) {
+	if s.empty() {
+		return // nothing changed
+	}
+
+	stat := t.Bucket(statBucket)
+
+	if s.objects != 0 || s.space != 0 {
+		n, space := decodeCounters(stat.Get(objectsStatKey))
+		err = stat.Put(objectsStatKey,
+			encodeCounters(n+s.objects, space+s.space))
+		if err != nil {
+			return
+		}
+	}
+
+	feeds := t.Bucket(feedsBucket)
+	for pk, fd := range s.feeds {
+		if feeds.Bucket(pk[:]) == nil {
+			if err = stat.Delete(pk[:]); err != nil {
+				return
+			}
+			continue
+		}
+		roots, space := fd.apply(decodeCounters(stat.Get(pk[:])))
+		if err = stat.Put(pk[:], encodeCounters(roots, space)); err != nil {
+			return
+		}
+	}
 
+	nsStat := t.Bucket(nsStatBucket)
+	for ns, nd := range s.namespaces {
+		k := namespacePrefix(ns)
+		keys, space := nd.apply(decodeCounters(nsStat.Get(k)))
+		if keys <= 0 {
+			err = nsStat.Delete(k)
+		} else {
+			err = nsStat.Put(k, encodeCounters(keys, space))
+		}
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
@@ -137,55 +636,161 @@ type driveTv struct {
 }
 
 func (d *driveTv) Objects() ViewObjects {
-	o := new(driveObjects)
-	o.bk = d.tx.Bucket(objectsBucket)
-	return o
+	return newDriveObjects(d.tx, nil)
 }
 
 func (d *driveTv) Feeds() ViewFeeds {
 	f := new(driveFeeds)
 	f.bk = d.tx.Bucket(feedsBucket)
+	f.local = d.tx.Bucket(localBucket)
+	f.times = d.tx.Bucket(timesBucket)
 	return &driveViewFeeds{f}
 }
 
-func (d *driveTv) Misc() ViewMisc {
-	m := new(driveMisc)
-	m.bk = d.tx.Bucket(miscBucket)
-	return m
+func (d *driveTv) Misc(namespace string) ViewMisc {
+	return newDriveMisc(d.tx, namespace, nil)
+}
+
+func (d *driveTv) Namespaces() []string {
+	return driveNamespaces(d.tx)
 }
 
 type driveTu struct {
-	tx *bolt.Tx
+	tx   *bolt.Tx
+	stat statDelta
 }
 
 func (d *driveTu) Objects() UpdateObjects {
-	o := new(driveObjects)
-	o.bk = d.tx.Bucket(objectsBucket)
-	return o
+	return newDriveObjects(d.tx, &d.stat)
 }
 
 func (d *driveTu) Feeds() UpdateFeeds {
 	f := new(driveFeeds)
 	f.bk = d.tx.Bucket(feedsBucket)
+	f.local = d.tx.Bucket(localBucket)
+	f.times = d.tx.Bucket(timesBucket)
+	f.stat = &d.stat
 	return f
 }
 
-func (d *driveTu) Misc() UpdateMisc {
-	m := new(driveMisc)
-	m.bk = d.tx.Bucket(miscBucket)
-	return m
+func (d *driveTu) Misc(namespace string) UpdateMisc {
+	return newDriveMisc(d.tx, namespace, &d.stat)
+}
+
+func (d *driveTu) Namespaces() []string {
+	return driveNamespaces(d.tx)
+}
+
+func (d *driveTu) DelNamespace(namespace string) (err error) {
+	if namespace == "" {
+		return d.Misc("").AscendDel(func(_, _ []byte) (bool, error) {
+			return true, nil
+		})
+	}
+	err = d.tx.Bucket(nsBucket).DeleteBucket([]byte(namespace))
+	if err == bolt.ErrBucketNotFound {
+		return nil
+	}
+	if err == nil {
+		d.stat.delNamespace(namespace)
+	}
+	return
+}
+
+// driveNamespaces returns non-empty namespaces
+func driveNamespaces(tx *bolt.Tx) (list []string) {
+	nss := tx.Bucket(nsBucket)
+	nss.ForEach(func(name, _ []byte) (_ error) {
+		if k, _ := nss.Bucket(name).Cursor().First(); k != nil {
+			list = append(list, string(name))
+		}
+		return
+	})
+	return
 }
 
 type driveObjects struct {
-	bk *bolt.Bucket
+	bk   *bolt.Bucket // objects
+	refs *bolt.Bucket // references counters
+	meta *bolt.Bucket // meta information
+	stat *statDelta   // changes of statistic (nil for read-only)
 }
 
-func (d *driveObjects) Set(key cipher.SHA256, value []byte) (err error) {
+func newDriveObjects(tx *bolt.Tx, stat *statDelta) (o *driveObjects) {
+	o = new(driveObjects)
+	o.bk = tx.Bucket(objectsBucket)
+	o.refs = tx.Bucket(refsBucket)
+	o.meta = tx.Bucket(metaBucket)
+	o.stat = stat
+	return
+}
+
+// put given object updating statistic
+func (d *driveObjects) put(key cipher.SHA256, value []byte) (err error) {
+	if old := d.bk.Get(key[:]); old != nil {
+		d.stat.delObject(len(old))
+	}
+	d.stat.addObject(len(value))
 	return d.bk.Put(key[:], value)
 }
 
+func (d *driveObjects) Set(key cipher.SHA256, value []byte) (err error) {
+	return d.put(key, value)
+}
+
 func (d *driveObjects) Del(key cipher.SHA256) (err error) {
-	return d.bk.Delete(key[:])
+	if old := d.bk.Get(key[:]); old != nil {
+		d.stat.delObject(len(old))
+	}
+	if err = d.bk.Delete(key[:]); err != nil {
+		return
+	}
+	return d.refs.Delete(key[:])
+}
+
+func (d *driveObjects) Refs(key cipher.SHA256) (rc uint32) {
+	if rb := d.refs.Get(key[:]); rb != nil {
+		rc = btorc(rb)
+	}
+	return
+}
+
+func (d *driveObjects) NeedRecount() bool {
+	return d.meta.Get(recountKey) != nil
+}
+
+func (d *driveObjects) Inc(key cipher.SHA256) (rc uint32, err error) {
+	if d.bk.Get(key[:]) == nil {
+		err = ErrNotFound
+		return
+	}
+	rc = d.Refs(key) + 1
+	err = d.refs.Put(key[:], rctob(rc))
+	return
+}
+
+func (d *driveObjects) Dec(key cipher.SHA256) (rc uint32, err error) {
+	if d.bk.Get(key[:]) == nil {
+		err = ErrNotFound
+		return
+	}
+	if rc = d.Refs(key); rc == 0 {
+		return // already zero
+	}
+	rc--
+	err = d.refs.Put(key[:], rctob(rc))
+	return
+}
+
+func (d *driveObjects) SetRefs(key cipher.SHA256, rc uint32) (err error) {
+	if d.bk.Get(key[:]) == nil {
+		return ErrNotFound
+	}
+	return d.refs.Put(key[:], rctob(rc))
+}
+
+func (d *driveObjects) Recounted() error {
+	return d.meta.Delete(recountKey)
 }
 
 func (d *driveObjects) Get(key cipher.SHA256) (val []byte) {
@@ -205,7 +810,7 @@ func (d *driveObjects) GetCopy(key cipher.SHA256) (value []byte) {
 
 func (d *driveObjects) Add(value []byte) (key cipher.SHA256, err error) {
 	key = cipher.SumSHA256(value)
-	err = d.bk.Put(key[:], value)
+	err = d.put(key, value)
 	return
 }
 
@@ -215,7 +820,7 @@ func (d *driveObjects) IsExist(key cipher.SHA256) bool {
 
 func (d *driveObjects) SetMap(m map[cipher.SHA256][]byte) (err error) {
 	for _, kv := range sortMap(m) {
-		if err = d.bk.Put(kv.key[:], kv.val); err != nil {
+		if err = d.put(kv.key, kv.val); err != nil {
 			return
 		}
 	}
@@ -262,9 +867,13 @@ func (d *driveObjects) AscendDel(
 				return
 			}
 			if del {
+				d.stat.delObject(len(v))
 				if err = c.Delete(); err != nil {
 					return
 				}
+				if err = d.refs.Delete(ck[:]); err != nil {
+					return
+				}
 				// coninue seek loop, because after deleting
 				// we have got invalid cusor and we need to
 				// call Seek to make it valid; the Seek will
@@ -282,27 +891,95 @@ func (d *driveObjects) AscendDel(
 	return
 }
 
+func (d *driveObjects) Cursor() ObjectsCursor {
+	return newObjectsCursor(d.bk.Cursor(), nil, nil)
+}
+
+func (d *driveObjects) RangeCursor(from, to cipher.SHA256) ObjectsCursor {
+	f, t := hashBounds(from, to)
+	return newObjectsCursor(d.bk.Cursor(), f, t)
+}
+
+// the driveMisc looks up bucket of the namespace for every
+// operation, because another handle of the same transaction
+// can create or delete the bucket
 type driveMisc struct {
-	bk *bolt.Bucket
+	tx   *bolt.Tx
+	ns   string     // namespace
+	stat *statDelta // changes of statistic (nil for read-only)
+}
+
+func newDriveMisc(tx *bolt.Tx, namespace string,
+	stat *statDelta) (d *driveMisc) {
+
+	d = new(driveMisc)
+	d.tx, d.ns, d.stat = tx, namespace, stat
+	return
+}
+
+// bucket of the namespace or nil if it doesn't exist
+func (d *driveMisc) bucket() *bolt.Bucket {
+	if d.ns == "" {
+		return d.tx.Bucket(miscBucket)
+	}
+	return d.tx.Bucket(nsBucket).Bucket([]byte(d.ns))
+}
+
+// cursor of the bucket
+func (d *driveMisc) cursor() rawCursor {
+	if bk := d.bucket(); bk != nil {
+		return bk.Cursor()
+	}
+	return emptyCursor{}
 }
 
 func (d *driveMisc) Set(key, value []byte) (err error) {
-	return d.bk.Put(key, value)
+	var bk *bolt.Bucket
+	if d.ns == "" {
+		bk = d.tx.Bucket(miscBucket)
+	} else {
+		bk, err = d.tx.Bucket(nsBucket).CreateBucketIfNotExists([]byte(d.ns))
+		if err != nil {
+			return
+		}
+	}
+	if old := bk.Get(key); old != nil {
+		d.stat.delMisc(d.ns, len(key), len(old))
+	}
+	d.stat.addMisc(d.ns, len(key), len(value))
+	return bk.Put(key, value)
 }
 
 func (d *driveMisc) Del(key []byte) (err error) {
-	return d.bk.Delete(key)
+	bk := d.bucket()
+	if bk == nil {
+		return
+	}
+	old := bk.Get(key)
+	if old == nil {
+		return
+	}
+	d.stat.delMisc(d.ns, len(key), len(old))
+	return bk.Delete(key)
 }
 
 func (d *driveMisc) Get(key []byte) (val []byte) {
-	if val = d.bk.Get(key); len(val) == 0 {
+	bk := d.bucket()
+	if bk == nil {
+		return
+	}
+	if val = bk.Get(key); len(val) == 0 {
 		val = nil
 	}
 	return
 }
 
 func (d *driveMisc) GetCopy(key []byte) (value []byte) {
-	if g := d.bk.Get(key); g != nil {
+	bk := d.bucket()
+	if bk == nil {
+		return
+	}
+	if g := bk.Get(key); g != nil {
 		value = make([]byte, len(g))
 		copy(value, g)
 	}
@@ -311,7 +988,7 @@ func (d *driveMisc) GetCopy(key []byte) (value []byte) {
 
 func (d *driveMisc) Ascend(fn func(key, value []byte) error) (err error) {
 
-	c := d.bk.Cursor()
+	c := d.cursor()
 
 	for k, v := c.First(); k != nil; k, v = c.Next() {
 		if err = fn(k, v); err != nil {
@@ -328,7 +1005,12 @@ func (d *driveMisc) Ascend(fn func(key, value []byte) error) (err error) {
 func (d *driveMisc) AscendDel(
 	fn func(key, value []byte) (bool, error)) (err error) {
 
-	c := d.bk.Cursor()
+	bk := d.bucket()
+	if bk == nil {
+		return
+	}
+
+	c := bk.Cursor()
 
 	var del bool
 
@@ -343,6 +1025,7 @@ func (d *driveMisc) AscendDel(
 				return
 			}
 			if del {
+				d.stat.delMisc(d.ns, len(k), len(v))
 				if err = c.Delete(); err != nil {
 					return
 				}
@@ -363,12 +1046,89 @@ func (d *driveMisc) AscendDel(
 	return
 }
 
+func (d *driveMisc) Descend(fn func(key, value []byte) error) (err error) {
+
+	c := d.cursor()
+
+	for k, v := c.Last(); k != nil; k, v = c.Prev() {
+		if err = fn(k, v); err != nil {
+			break
+		}
+	}
+
+	if err == ErrStopIteration {
+		err = nil
+	}
+	return
+}
+
+func (d *driveMisc) DescendDel(
+	fn func(key, value []byte) (bool, error)) (err error) {
+
+	bk := d.bucket()
+	if bk == nil {
+		return
+	}
+
+	c := bk.Cursor()
+
+	var del bool
+
+	for k, v := c.Last(); k != nil; {
+		if del, err = fn(k, v); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+		if !del {
+			k, v = c.Prev()
+			continue
+		}
+		d.stat.delMisc(d.ns, len(k), len(v))
+		k = append([]byte{}, k...) // the k is not valid after deleting
+		if err = c.Delete(); err != nil {
+			return
+		}
+		// the cursor is not valid after deleting, the Seek
+		// points to next item, because current one has been
+		// deleted, or to nothing if it was the last one
+		if k, _ = c.Seek(k); k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+	}
+
+	return
+}
+
+func (d *driveMisc) Cursor() MiscCursor {
+	return newMiscCursor(d.cursor(), nil, nil)
+}
+
+func (d *driveMisc) RangeCursor(from, to []byte) MiscCursor {
+	return newMiscCursor(d.cursor(), from, to)
+}
+
+func (d *driveMisc) PrefixCursor(prefix []byte) MiscCursor {
+	return newMiscCursor(d.cursor(), prefix, prefixEnd(prefix))
+}
+
 type driveFeeds struct {
-	bk *bolt.Bucket
+	bk    *bolt.Bucket
+	local *bolt.Bucket // metadata of roots
+	times *bolt.Bucket // time index
+	stat  *statDelta   // changes of statistic (nil for read-only)
 }
 
 func (d *driveFeeds) Add(pk cipher.PubKey) (err error) {
-	_, err = d.bk.CreateBucketIfNotExists(pk[:])
+	for _, bk := range []*bolt.Bucket{d.bk, d.local, d.times} {
+		if _, err = bk.CreateBucketIfNotExists(pk[:]); err != nil {
+			return
+		}
+	}
+	d.stat.feed(pk) // create counters if not exist
 	return
 }
 
@@ -377,6 +1137,22 @@ func (d *driveFeeds) Del(pk cipher.PubKey) (err error) {
 		if err == bolt.ErrBucketNotFound {
 			err = nil
 		}
+		return
+	}
+	if err = d.delLocal(pk[:]); err == nil {
+		d.stat.delFeed(pk)
+	}
+	return
+}
+
+// delLocal deletes metadata and time index of roots of given feed
+func (d *driveFeeds) delLocal(pk []byte) (err error) {
+	for _, bk := range []*bolt.Bucket{d.local, d.times} {
+		if err = bk.DeleteBucket(pk); err == bolt.ErrBucketNotFound {
+			err = nil
+		} else if err != nil {
+			return
+		}
 	}
 	return
 }
@@ -446,6 +1222,10 @@ func (d *driveFeeds) AscendDel(
 				if err = d.bk.DeleteBucket(k); err != nil {
 					return
 				}
+				if err = d.delLocal(cp[:]); err != nil {
+					return
+				}
+				d.stat.delFeed(cp)
 				break // break "next loop" (= continue "seek loop")
 			}
 			if k, _ = c.Next(); k == nil {
@@ -462,11 +1242,14 @@ func (d *driveFeeds) AscendDel(
 func (d *driveFeeds) Roots(pk cipher.PubKey) UpdateRoots {
 	r := new(driveRoots)
 	r.feed = pk
+	r.stat = d.stat
 	bk := d.bk.Bucket(pk[:])
 	if bk == nil {
 		return nil
 	}
 	r.bk = bk
+	r.local = d.local.Bucket(pk[:])
+	r.times = d.times.Bucket(pk[:])
 	return r
 }
 
@@ -479,8 +1262,11 @@ func (d *driveViewFeeds) Roots(pk cipher.PubKey) ViewRoots {
 }
 
 type driveRoots struct {
-	feed cipher.PubKey
-	bk   *bolt.Bucket
+	feed  cipher.PubKey
+	bk    *bolt.Bucket
+	local *bolt.Bucket // seq -> RootMeta
+	times *bolt.Bucket // time + seq -> nothing
+	stat  *statDelta   // changes of statistic (nil for read-only)
 }
 
 func (d *driveRoots) Feed() cipher.PubKey {
@@ -514,6 +1300,7 @@ func (d *driveRoots) Add(rp *RootPack) (err error) {
 
 		// not found
 
+		d.stat.addRoot(d.feed, len(data))
 		err = d.bk.Put(seqb, data) // store
 		return
 	}
@@ -549,18 +1336,126 @@ func (d *driveRoots) Get(seq uint64) (rp *RootPack) {
 	return
 }
 
-func (d *driveRoots) Del(seq uint64) error {
+func (d *driveRoots) IsFull(seq uint64) bool {
+	rm, _ := d.Meta(seq)
+	return rm.IsFull
+}
+
+func (d *driveRoots) Meta(seq uint64) (rm RootMeta, err error) {
 	seqb := utob(seq)
-	return d.bk.Delete(seqb)
+	if d.bk.Get(seqb) == nil {
+		err = ErrNotFound
+		return
+	}
+	rm = d.meta(seqb)
+	return
+}
+
+// meta returns stored RootMeta (or zero RootMeta)
+func (d *driveRoots) meta(seqb []byte) (rm RootMeta) {
+	if d.local != nil {
+		rm = decodeRootMeta(d.local.Get(seqb))
+	}
+	return
+}
+
+// putMeta replaces given old RootMeta with given
+// new one updating time index
+func (d *driveRoots) putMeta(seqb []byte, old, rm RootMeta) (err error) {
+	if old.Time != rm.Time {
+		seq := btou(seqb)
+		if old.Time != 0 {
+			if err = d.times.Delete(timeKey(old.Time, seq)); err != nil {
+				return
+			}
+		}
+		if rm.Time != 0 {
+			if err = d.times.Put(timeKey(rm.Time, seq), []byte{}); err != nil {
+				return
+			}
+		}
+	}
+	return d.local.Put(seqb, encodeRootMeta(rm))
+}
+
+// delMeta deletes RootMeta and time index of a root
+func (d *driveRoots) delMeta(seqb []byte) (err error) {
+	if old := d.meta(seqb); old.Time != 0 {
+		if err = d.times.Delete(timeKey(old.Time, btou(seqb))); err != nil {
+			return
+		}
+	}
+	return d.local.Delete(seqb)
+}
+
+func (d *driveRoots) Del(seq uint64) (err error) {
+	seqb := utob(seq)
+	if old := d.bk.Get(seqb); old != nil {
+		d.stat.delRoot(d.feed, len(old))
+	}
+	if err = d.bk.Delete(seqb); err != nil {
+		return
+	}
+	return d.delMeta(seqb)
 }
 
 func (d *driveRoots) MarkFull(seq uint64) (err error) {
-	rp := d.Get(seq)
-	if rp == nil {
-		return ErrNotFound
+	var old RootMeta
+	if old, err = d.Meta(seq); err != nil {
+		return
+	}
+	rm := old
+	rm.IsFull, rm.Refill = true, false
+	return d.putMeta(utob(seq), old, rm)
+}
+
+func (d *driveRoots) SetMeta(seq uint64, rm RootMeta) (err error) {
+	var old RootMeta
+	if old, err = d.Meta(seq); err != nil {
+		return
+	}
+	return d.putMeta(utob(seq), old, rm)
+}
+
+func (d *driveRoots) AtTime(t int64) (rp *RootPack) {
+	if d.times == nil {
+		return
+	}
+	c := d.times.Cursor()
+	pivot := timeKey(t, 1<<64-1)
+	k, _ := c.Seek(pivot)
+	if k == nil {
+		k, _ = c.Last()
+	} else if !bytes.Equal(k, pivot) {
+		k, _ = c.Prev()
+	}
+	if k != nil {
+		_, seq := timeSeq(k)
+		rp = d.Get(seq)
+	}
+	return
+}
+
+func (d *driveRoots) RangeTime(from, to int64,
+	fn func(rp *RootPack) error) (err error) {
+
+	if d.times == nil {
+		return
 	}
-	rp.IsFull = true
-	return d.bk.Put(utob(seq), encoder.Serialize(rp))
+	c := d.times.Cursor()
+	for k, _ := c.Seek(timeBytes(from)); k != nil; k, _ = c.Next() {
+		t, seq := timeSeq(k)
+		if !inTimeRange(t, from, to) {
+			return
+		}
+		if err = fn(d.Get(seq)); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+	}
+	return
 }
 
 func (d *driveRoots) Ascend(fn func(rp *RootPack) error) (err error) {
@@ -601,6 +1496,15 @@ func (d *driveRoots) Descend(fn func(rp *RootPack) error) (err error) {
 	return
 }
 
+func (d *driveRoots) Cursor() RootsCursor {
+	return newRootsCursor(d.bk.Cursor(), nil, nil)
+}
+
+func (d *driveRoots) RangeCursor(from, to uint64) RootsCursor {
+	f, t := seqBounds(from, to)
+	return newRootsCursor(d.bk.Cursor(), f, t)
+}
+
 func (d *driveRoots) AscendDel(
 	fn func(rp *RootPack) (bool, error)) (err error) {
 
@@ -625,6 +1529,10 @@ func (d *driveRoots) AscendDel(
 				return
 			}
 			if del {
+				d.stat.delRoot(d.feed, len(v))
+				if err = d.delMeta(k); err != nil {
+					return
+				}
 				if err = c.Delete(); err != nil {
 					return
 				}
@@ -645,12 +1553,16 @@ func (d *driveRoots) DelBefore(seq uint64) (err error) {
 
 	c := d.bk.Cursor()
 
-	for k, _ := c.First(); k != nil; k, _ = c.Seek(k) {
+	for k, v := c.First(); k != nil; k, v = c.Seek(k) {
 
 		if btou(k) >= seq {
 			return
 		}
 
+		d.stat.delRoot(d.feed, len(v))
+		if err = d.delMeta(k); err != nil {
+			return
+		}
 		if err = c.Delete(); err != nil {
 			return
 		}
@@ -674,3 +1586,14 @@ func btou(b []byte) (seq uint64) {
 	seq = binary.BigEndian.Uint64(b)
 	return
 }
+
+func rctob(rc uint32) (b []byte) {
+	b = make([]byte, 4)
+	binary.BigEndian.PutUint32(b, rc)
+	return
+}
+
+func btorc(b []byte) (rc uint32) {
+	rc = binary.BigEndian.Uint32(b)
+	return
+}
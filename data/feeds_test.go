@@ -62,6 +62,12 @@ func TestViewFeeds_IsExist(t *testing.T) {
 		testViewFeedsIsExist(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewFeedsIsExist(t, db)
+	})
+
 }
 
 func testViewFeedsList(t *testing.T, db DB) {
@@ -121,6 +127,12 @@ func TestViewFeeds_List(t *testing.T) {
 		testViewFeedsList(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewFeedsList(t, db)
+	})
+
 }
 
 func testViewFeedsAscend(t *testing.T, db DB) {
@@ -222,6 +234,12 @@ func TestViewFeeds_Ascend(t *testing.T) {
 		testViewFeedsAscend(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewFeedsAscend(t, db)
+	})
+
 }
 
 func testViewFeedsRoots(t *testing.T, db DB) {
@@ -276,6 +294,12 @@ func TestViewFeeds_Roots(t *testing.T) {
 		testViewFeedsRoots(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewFeedsRoots(t, db)
+	})
+
 }
 
 //
@@ -352,6 +376,12 @@ func TestUpdateFeeds_Add(t *testing.T) {
 		testUpdateFeedsAdd(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateFeedsAdd(t, db)
+	})
+
 }
 
 func testUpdateFeedsDel(t *testing.T, db DB) {
@@ -433,6 +463,12 @@ func TestUpdateFeeds_Del(t *testing.T) {
 		testUpdateFeedsDel(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateFeedsDel(t, db)
+	})
+
 }
 
 func testUpdateFeedsAscendDel(t *testing.T, db DB) {
@@ -551,6 +587,12 @@ func TestUpdateFeeds_AscendDel(t *testing.T) {
 		testUpdateFeedsAscendDel(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateFeedsAscendDel(t, db)
+	})
+
 }
 
 func testUpdateFeedsRoots(t *testing.T, db DB) {
@@ -605,4 +647,10 @@ func TestUpdateFeeds_Roots(t *testing.T) {
 		testUpdateFeedsRoots(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateFeedsRoots(t, db)
+	})
+
 }
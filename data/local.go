@@ -0,0 +1,74 @@
+package data
+
+import (
+	"encoding/binary"
+)
+
+// Machine-local metadata of roots (RootMeta) is stored
+// separately from RootPack. Thus RootPack contains only
+// signed fields and can be sent through network as is
+
+// length of encoded RootMeta: flags, space and time
+const rootMetaLen = 1 + 8 + 8
+
+// flags of encoded RootMeta
+const (
+	rootMetaFull   byte = 1 << iota // IsFull
+	rootMetaRefill                  // Refill
+)
+
+// encodeRootMeta encodes given RootMeta
+func encodeRootMeta(rm RootMeta) (b []byte) {
+	b = make([]byte, rootMetaLen)
+	if rm.IsFull {
+		b[0] |= rootMetaFull
+	}
+	if rm.Refill {
+		b[0] |= rootMetaRefill
+	}
+	binary.BigEndian.PutUint64(b[1:], rm.Space)
+	binary.BigEndian.PutUint64(b[9:], uint64(rm.Time))
+	return
+}
+
+// decodeRootMeta decodes RootMeta, it returns
+// zero RootMeta if given slice is malformed
+func decodeRootMeta(b []byte) (rm RootMeta) {
+	if len(b) != rootMetaLen {
+		return
+	}
+	rm.IsFull = b[0]&rootMetaFull != 0
+	rm.Refill = b[0]&rootMetaRefill != 0
+	rm.Space = binary.BigEndian.Uint64(b[1:])
+	rm.Time = int64(binary.BigEndian.Uint64(b[9:]))
+	return
+}
+
+// Roots with known time are indexed by the time. Key of
+// the index is time (8) | seq (8). The time is encoded
+// with flipped sign bit to keep order of negative values
+
+// timeBytes encodes given time keeping order
+func timeBytes(t int64) (b []byte) {
+	b = make([]byte, 8, 16)
+	binary.BigEndian.PutUint64(b, uint64(t)^(1<<63))
+	return
+}
+
+// timeKey returns key of time index
+func timeKey(t int64, seq uint64) []byte {
+	return append(timeBytes(t), utob(seq)...)
+}
+
+// timeSeq decodes key of time index
+func timeSeq(k []byte) (t int64, seq uint64) {
+	t = int64(binary.BigEndian.Uint64(k) ^ (1 << 63))
+	seq = binary.BigEndian.Uint64(k[8:])
+	return
+}
+
+// inTimeRange returns true if given time is in [from, to)
+// range, where zero to means no upper bound
+func inTimeRange(t, from, to int64) bool {
+	return t >= from && (to == 0 || t < to)
+}
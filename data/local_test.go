@@ -0,0 +1,252 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+)
+
+func Test_encodeRootMeta(t *testing.T) {
+	for _, rm := range []RootMeta{
+		{},
+		{IsFull: true},
+		{Space: 1024},
+		{IsFull: true, Space: 1<<64 - 1},
+		{Time: -1},
+		{IsFull: true, Space: 10, Time: 1e18},
+		{Refill: true},
+		{Refill: true, Space: 10, Time: 10},
+	} {
+		if got := decodeRootMeta(encodeRootMeta(rm)); got != rm {
+			t.Errorf("wrong decoded %v, want %v", got, rm)
+		}
+	}
+	if rm := decodeRootMeta([]byte{1, 2, 3}); rm != (RootMeta{}) {
+		t.Error("malformed RootMeta decoded:", rm)
+	}
+}
+
+// testLegacyRoots moves RootMeta of all roots back to
+// RootPack and removes buckets of versioned database,
+// like a drive database without version keeps them
+func testLegacyRoots(t *bolt.Tx) error {
+	feeds, local := t.Bucket(feedsBucket), t.Bucket(localBucket)
+
+	err := feeds.ForEach(func(pk, _ []byte) (err error) {
+		roots, metas := feeds.Bucket(pk), local.Bucket(pk)
+
+		var seqs, packs [][]byte
+
+		err = roots.ForEach(func(seqb, val []byte) (err error) {
+			var rp RootPack
+			if err = encoder.DeserializeRaw(val, &rp); err != nil {
+				return
+			}
+			rm := decodeRootMeta(metas.Get(seqb))
+			lp := legacyRootPack{
+				Root:   rp.Root,
+				Seq:    rp.Seq,
+				Prev:   rp.Prev,
+				Hash:   rp.Hash,
+				Sig:    rp.Sig,
+				IsFull: rm.IsFull,
+				Space:  rm.Space,
+			}
+			seqs = append(seqs, append([]byte{}, seqb...))
+			packs = append(packs, encoder.Serialize(&lp))
+			return
+		})
+		if err != nil {
+			return
+		}
+
+		for i, seqb := range seqs {
+			if err = roots.Put(seqb, packs[i]); err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range [][]byte{
+		localBucket,
+		timesBucket,
+		refsBucket,
+		statBucket,
+		nsBucket,
+		metaBucket,
+	} {
+		if err = t.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func Test_timeKey(t *testing.T) {
+	times := []int64{-1 << 63, -10, -1, 0, 1, 10, 1<<63 - 1}
+	for i, tm := range times {
+		k := timeKey(tm, uint64(i))
+		if gt, gs := timeSeq(k); gt != tm || gs != uint64(i) {
+			t.Errorf("wrong decoded %d %d, want %d %d", gt, gs, tm, i)
+		}
+		if i > 0 && bytes.Compare(timeKey(times[i-1], 0), k) >= 0 {
+			t.Errorf("wrong order of %d and %d", times[i-1], tm)
+		}
+	}
+}
+
+func Test_moveRootMeta(t *testing.T) {
+
+	db, cleanUp := testDriveDB(t)
+	defer cleanUp()
+
+	pk, _ := cipher.GenerateKeyPair()
+
+	metas := []RootMeta{{IsFull: true, Space: 10}, {}, {Space: 20}}
+
+	err := db.Update(func(tx Tu) (err error) {
+		feeds := tx.Feeds()
+		if err = feeds.Add(pk); err != nil {
+			return
+		}
+		roots := feeds.Roots(pk)
+		for i, rm := range metas {
+			rp := getRootPack(uint64(i), "root")
+			if err = roots.Add(&rp); err != nil {
+				return
+			}
+			if err = roots.SetMeta(rp.Seq, rm); err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := db.Stat()
+
+	b := db.(*driveDB).bolt
+	if err = b.Update(testLegacyRoots); err != nil {
+		t.Fatal(err)
+	}
+	err = b.Update(func(t *bolt.Tx) error { return migrate(t, nil) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(tx Tv) (_ error) {
+		roots := tx.Feeds().Roots(pk)
+		for i, rm := range metas {
+			seq := uint64(i)
+			if rp := roots.Get(seq); rp == nil {
+				t.Error("missing root", seq)
+			} else if rp.Hash != getRootPack(seq, "root").Hash {
+				t.Error("wrong root", seq)
+			}
+			if got, err := roots.Meta(seq); err != nil {
+				t.Error(err)
+			} else if got != rm {
+				t.Errorf("wrong meta of %d: %v, want %v", seq, got, rm)
+			}
+		}
+		return
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if got := db.Stat(); !reflect.DeepEqual(want, got) {
+		t.Errorf("wrong counters after migration: %s, want %s", got, want)
+	}
+}
+
+// testRootTime is RootTimeFunc of roots that
+// are encoded time or can't be decoded
+func testRootTime(rp *RootPack) (tm int64, err error) {
+	if len(rp.Root) != 8 {
+		return 0, errors.New("malformed Root")
+	}
+	return int64(btou(rp.Root)), nil
+}
+
+func Test_moveRootMeta_rootTime(t *testing.T) {
+
+	db, cleanUp := testDriveDB(t)
+	defer cleanUp()
+
+	pk, _ := cipher.GenerateKeyPair()
+
+	// seq -> time, the 1 can't be decoded
+	times := []int64{10, 0, 30}
+
+	err := db.Update(func(tx Tu) (err error) {
+		feeds := tx.Feeds()
+		if err = feeds.Add(pk); err != nil {
+			return
+		}
+		roots := feeds.Roots(pk)
+		for i, tm := range times {
+			rp := &RootPack{Seq: uint64(i)}
+			if i != 0 {
+				rp.Prev = cipher.SumSHA256([]byte("any"))
+			}
+			if tm != 0 {
+				rp.Root = utob(uint64(tm))
+			} else {
+				rp.Root = []byte("malformed")
+			}
+			rp.Hash = cipher.SumSHA256(rp.Root)
+			if err = roots.Add(rp); err != nil {
+				return
+			}
+		}
+		return roots.SetMeta(0, RootMeta{IsFull: true, Space: 10})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := db.(*driveDB).bolt
+	if err = b.Update(testLegacyRoots); err != nil {
+		t.Fatal(err)
+	}
+	err = b.Update(func(t *bolt.Tx) error { return migrate(t, testRootTime) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []RootMeta{{IsFull: true, Space: 10, Time: 10}, {}, {Time: 30}}
+
+	err = db.View(func(tx Tv) (_ error) {
+		roots := tx.Feeds().Roots(pk)
+		for i, rm := range want {
+			if got, err := roots.Meta(uint64(i)); err != nil {
+				t.Error(err)
+			} else if got != rm {
+				t.Errorf("wrong meta of %d: %v, want %v", i, got, rm)
+			}
+		}
+		if rp := roots.AtTime(20); rp == nil || rp.Seq != 0 {
+			t.Error("wrong root at time:", rp)
+		}
+		if rp := roots.AtTime(30); rp == nil || rp.Seq != 2 {
+			t.Error("wrong root at time:", rp)
+		}
+		return
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,1157 @@
+package data
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+)
+
+// LSM-tree tuning (variables for tests)
+var (
+	// size of memtable after which the
+	// memtable will be flushed to new SSTable
+	lsmMemtableSize = 4 * 1024 * 1024
+	// number of SSTables after which all
+	// the tables will be compacted
+	lsmMaxTables = 8
+)
+
+// names of files of LSM-tree database
+const (
+	lsmWALFile      = "wal.log"
+	lsmManifestFile = "MANIFEST"
+)
+
+// prefixes of keys of LSM-tree
+const (
+	lsmObjectPrefix = "o" // o + hash -> value
+	lsmRefsPrefix   = "r" // r + hash -> references counter
+	lsmFeedPrefix   = "f" // f + pk -> nothing
+	lsmRootPrefix   = "s" // s + pk + seq -> RootPack
+	lsmMiscPrefix   = "m" // m + key -> value
+	lsmLocalPrefix  = "l" // l + pk + seq -> RootMeta
+	lsmTimePrefix   = "t" // t + pk + time + seq -> nothing
+	lsmNSPrefix     = "n" // n + namespace prefix + key -> value
+)
+
+var errLSMClosed = errors.New("database closed")
+
+// buckets (prefixes of keys):
+//  - objects o + hash -> []byte (including schemas)
+//  - refs    r + hash -> references counter (uint32)
+//  - feeds   f + pubkey -> nothing
+//  - roots   s + pubkey + seq -> RootPack
+//  - misc    m + key -> value
+//  - local   l + pubkey + seq -> RootMeta
+//  - time    t + pubkey + time + seq -> nothing
+//  - ns      n + len(namespace) + namespace + key -> value
+//
+// writes are appended to WAL and kept in memtable. Full memtable
+// flushed to new SSTable. When number of SSTables reaches
+// lsmMaxTables, all tables compacted to one table without
+// deleted keys
+type lsmDB struct {
+	dir string
+
+	wmx sync.Mutex   // single writer
+	mx  sync.RWMutex // lock memtable and tables
+
+	mem    *lsmMemtable
+	tables []*lsmTable // newest first
+	wal    *lsmWAL
+	seq    uint64 // number of last table
+
+	// error of last flushing or compaction; changes are
+	// durable (they are in WAL) when the flushing starts,
+	// thus the error is not error of a transaction; the
+	// flushing is retried by next commit and the Close
+	// returns the error if it's not resolved
+	flushErr error
+
+	closed bool
+	closeo sync.Once
+}
+
+// NewLSMDB creates new write-optimised database using given
+// directory to create or use existing LSM-tree (log-structured
+// merge-tree). Writes are appended to write-ahead log and
+// in-memory table (memtable). Full memtable flushed to sorted
+// table (SSTable) on drive. Tables are compacted from time to
+// time. The directory must not be used by another process
+func NewLSMDB(dir string) (db DB, err error) {
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+
+	d := &lsmDB{dir: dir, mem: newLSMMemtable()}
+
+	if err = d.openTables(); err != nil {
+		return
+	}
+
+	if d.wal, err = openLSMWAL(d.path(lsmWALFile), d.mem); err != nil {
+		d.closeTables()
+		return
+	}
+
+	db = d
+	return
+}
+
+func (d *lsmDB) path(name string) string {
+	return filepath.Join(d.dir, name)
+}
+
+// openTables opens tables listed in manifest
+// and removes tables that are not listed
+func (d *lsmDB) openTables() (err error) {
+
+	var manifest []byte
+	if manifest, err = ioutil.ReadFile(d.path(lsmManifestFile)); err != nil {
+		if !os.IsNotExist(err) {
+			return
+		}
+		err = nil // new database
+	}
+
+	live := make(map[string]struct{})
+
+	for _, name := range strings.Fields(string(manifest)) {
+		var seq uint64
+		if _, err = fmt.Sscanf(name, "%d.sst", &seq); err != nil {
+			d.closeTables()
+			return fmt.Errorf("invalid manifest: %v", err)
+		}
+		var t *lsmTable
+		if t, err = openLSMTable(d.path(name), seq); err != nil {
+			d.closeTables()
+			return
+		}
+		d.tables = append(d.tables, t) // newest first
+		live[name] = struct{}{}
+		if seq > d.seq {
+			d.seq = seq
+		}
+	}
+
+	// remove tables that are not listed (unfinished
+	// flushing or compaction)
+
+	var stray []string
+	if stray, err = filepath.Glob(d.path("*.sst")); err != nil {
+		d.closeTables()
+		return
+	}
+	for _, path := range stray {
+		if _, ok := live[filepath.Base(path)]; !ok {
+			os.Remove(path)
+		}
+	}
+
+	return
+}
+
+func (d *lsmDB) closeTables() {
+	for _, t := range d.tables {
+		t.Close()
+	}
+}
+
+// writeManifest saves list of actual tables
+func (d *lsmDB) writeManifest() (err error) {
+	tmp := d.path(lsmManifestFile + ".tmp")
+
+	var fd *os.File
+	if fd, err = os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY,
+		dbMode); err != nil {
+
+		return
+	}
+
+	w := bufio.NewWriter(fd)
+	for _, t := range d.tables {
+		fmt.Fprintln(w, lsmTableName(t.seq))
+	}
+	if err = w.Flush(); err == nil {
+		err = fd.Sync()
+	}
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	return os.Rename(tmp, d.path(lsmManifestFile))
+}
+
+// view of the database, it must be called under lock
+func (d *lsmDB) view(buf *lsmMemtable) (v *lsmView) {
+	v = new(lsmView)
+	if buf != nil {
+		v.layers = append(v.layers, buf)
+	}
+	v.layers = append(v.layers, d.mem)
+	for _, t := range d.tables {
+		v.layers = append(v.layers, t)
+	}
+	return
+}
+
+func (d *lsmDB) View(fn func(t Tv) error) (err error) {
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+
+	if d.closed {
+		return errLSMClosed
+	}
+	return fn(&lsmTv{d.view(nil)})
+}
+
+func (d *lsmDB) Update(fn func(t Tu) error) (err error) {
+	d.wmx.Lock()
+	defer d.wmx.Unlock()
+
+	// the memtable and the tables can be changed
+	// only by writer, thus we don't need the mx
+
+	if d.closed {
+		return errLSMClosed
+	}
+
+	buf := newLSMMemtable()
+	if err = fn(&lsmTu{d.view(buf)}); err != nil {
+		return // rollback
+	}
+	return d.commit(buf)
+}
+
+// commit changes of a transaction, the commit
+// must be called by writer
+func (d *lsmDB) commit(buf *lsmMemtable) (err error) {
+	if len(buf.kv) == 0 {
+		return // nothing has been changed
+	}
+
+	if err = d.wal.write(buf.encode()); err != nil {
+		return
+	}
+
+	d.mx.Lock()
+	for k, e := range buf.kv {
+		d.mem.set(k, e)
+	}
+	d.mx.Unlock()
+
+	// the changes are durable, errors of
+	// the maintenance are deferred
+	d.flushErr = d.maintain()
+	return
+}
+
+// maintain flushes full memtable and compacts
+// tables if there are too many tables
+func (d *lsmDB) maintain() (err error) {
+	if d.mem.size >= lsmMemtableSize {
+		if err = d.flush(); err != nil {
+			return
+		}
+	}
+	if len(d.tables) >= lsmMaxTables {
+		return d.compact()
+	}
+	return
+}
+
+// flush memtable to new SSTable
+func (d *lsmDB) flush() (err error) {
+	seq := d.seq + 1
+
+	var t *lsmTable
+	t, err = writeLSMTable(d.path(lsmTableName(seq)), seq, d.mem.keys(""),
+		d.mem.get, len(d.tables) == 0)
+	if err != nil {
+		return
+	}
+
+	d.mx.Lock()
+	d.seq = seq
+	d.tables = append([]*lsmTable{t}, d.tables...)
+	d.mem = newLSMMemtable()
+	d.mx.Unlock()
+
+	if err = d.writeManifest(); err != nil {
+		return
+	}
+	return d.wal.reset()
+}
+
+// compact all tables to one table
+func (d *lsmDB) compact() (err error) {
+	seq := d.seq + 1
+
+	v := new(lsmView)
+	for _, t := range d.tables {
+		v.layers = append(v.layers, t)
+	}
+
+	var t *lsmTable
+	t, err = writeLSMTable(d.path(lsmTableName(seq)), seq, v.keys(""),
+		v.get, true)
+	if err != nil {
+		return
+	}
+
+	d.mx.Lock()
+	old := d.tables
+	d.seq = seq
+	d.tables = []*lsmTable{t}
+	d.mx.Unlock()
+
+	if err = d.writeManifest(); err != nil {
+		return
+	}
+
+	for _, o := range old {
+		o.Close()
+		os.Remove(o.path)
+	}
+	return
+}
+
+func (d *lsmDB) Stat() (s Stat) {
+
+	d.View(func(tx Tv) (_ error) {
+
+		v := tx.(*lsmTv).v
+
+		// objects
+
+		for _, k := range v.keys(lsmObjectPrefix) {
+			e, _ := v.get(k)
+			s.Objects++
+			s.Space += Space(e.ln)
+		}
+		s.PhysicalSpace = s.Space
+
+		// feeds (and roots)
+
+		for _, k := range v.keys(lsmFeedPrefix) {
+
+			var pk cipher.PubKey
+			copy(pk[:], k[len(lsmFeedPrefix):])
+
+			var fs FeedStat
+			for _, rk := range v.keys(lsmRootPrefix + k[len(lsmFeedPrefix):]) {
+				e, _ := v.get(rk)
+				fs.Roots++
+				fs.Space += Space(e.ln)
+			}
+
+			if s.Feeds == nil {
+				s.Feeds = make(map[cipher.PubKey]FeedStat)
+			}
+			s.Feeds[pk] = fs
+		}
+
+		// namespaces (length of key and value of misc-objects)
+
+		var count = func(ns string, kl int, e lsmEntry) {
+			if s.Namespaces == nil {
+				s.Namespaces = make(map[string]NamespaceStat)
+			}
+			st := s.Namespaces[ns]
+			st.Keys++
+			st.Space += Space(kl + e.ln)
+			s.Namespaces[ns] = st
+		}
+
+		for _, k := range v.keys(lsmMiscPrefix) {
+			e, _ := v.get(k)
+			count("", len(k)-len(lsmMiscPrefix), e)
+		}
+
+		for _, k := range v.keys(lsmNSPrefix) {
+			ns, n := decodeNamespacePrefix([]byte(k[len(lsmNSPrefix):]))
+			if n == 0 {
+				continue
+			}
+			e, _ := v.get(k)
+			count(ns, len(k)-len(lsmNSPrefix)-n, e)
+		}
+
+		return
+	})
+
+	return
+}
+
+func (d *lsmDB) Close() (err error) {
+	d.closeo.Do(func() {
+		d.wmx.Lock()
+		defer d.wmx.Unlock()
+
+		d.mx.Lock()
+		defer d.mx.Unlock()
+
+		d.closed = true
+		if err = d.wal.Close(); err == nil {
+			err = d.flushErr // deferred error
+		}
+		d.closeTables()
+	})
+	return
+}
+
+type lsmTv struct {
+	v *lsmView
+}
+
+func (l *lsmTv) Objects() ViewObjects {
+	return &lsmObjects{l.v}
+}
+
+func (l *lsmTv) Feeds() ViewFeeds {
+	return &lsmViewFeeds{lsmFeeds{l.v}}
+}
+
+func (l *lsmTv) Misc(namespace string) ViewMisc {
+	return newLSMMisc(l.v, namespace)
+}
+
+func (l *lsmTv) Namespaces() []string {
+	return lsmNamespaces(l.v)
+}
+
+type lsmTu struct {
+	v *lsmView
+}
+
+func (l *lsmTu) Objects() UpdateObjects {
+	return &lsmObjects{l.v}
+}
+
+func (l *lsmTu) Feeds() UpdateFeeds {
+	return &lsmFeeds{l.v}
+}
+
+func (l *lsmTu) Misc(namespace string) UpdateMisc {
+	return newLSMMisc(l.v, namespace)
+}
+
+func (l *lsmTu) Namespaces() []string {
+	return lsmNamespaces(l.v)
+}
+
+func (l *lsmTu) DelNamespace(namespace string) error {
+	return l.Misc(namespace).AscendDel(func(_, _ []byte) (bool, error) {
+		return true, nil
+	})
+}
+
+// lsmNamespaces returns non-empty namespaces
+func lsmNamespaces(v *lsmView) []string {
+	set := make(map[string]struct{})
+	for _, k := range v.keys(lsmNSPrefix) {
+		ns, n := decodeNamespacePrefix([]byte(k[len(lsmNSPrefix):]))
+		if n > 0 {
+			set[ns] = struct{}{}
+		}
+	}
+	return sortedNamespaces(set)
+}
+
+type lsmObjects struct {
+	v *lsmView
+}
+
+func (l *lsmObjects) key(key cipher.SHA256) string {
+	return lsmObjectPrefix + string(key[:])
+}
+
+func (l *lsmObjects) refsKey(key cipher.SHA256) string {
+	return lsmRefsPrefix + string(key[:])
+}
+
+func (l *lsmObjects) getKey(k string) (key cipher.SHA256) {
+	copy(key[:], k[len(lsmObjectPrefix):])
+	return
+}
+
+func (l *lsmObjects) Set(key cipher.SHA256, value []byte) (err error) {
+	l.v.put(l.key(key), value)
+	return
+}
+
+func (l *lsmObjects) Del(key cipher.SHA256) (err error) {
+	l.v.del(l.key(key))
+	l.v.del(l.refsKey(key))
+	return
+}
+
+// Get returns nil for missing, deleted and corrupted objects,
+// deleted objects are tombstones; existing empty value is not nil
+func (l *lsmObjects) Get(key cipher.SHA256) (value []byte) {
+	if e, ok := l.v.get(l.key(key)); ok {
+		var err error
+		if value, err = e.read(); err != nil {
+			return nil // corrupted
+		}
+		if value == nil {
+			value = []byte{}
+		}
+	}
+	return
+}
+
+func (l *lsmObjects) GetCopy(key cipher.SHA256) (value []byte) {
+	if g := l.Get(key); g != nil {
+		value = make([]byte, len(g))
+		copy(value, g)
+	}
+	return
+}
+
+func (l *lsmObjects) Add(value []byte) (key cipher.SHA256, err error) {
+	key = cipher.SumSHA256(value)
+	err = l.Set(key, value)
+	return
+}
+
+func (l *lsmObjects) IsExist(key cipher.SHA256) bool {
+	_, ok := l.v.get(l.key(key))
+	return ok
+}
+
+func (l *lsmObjects) SetMap(m map[cipher.SHA256][]byte) (err error) {
+	for _, kv := range sortMap(m) {
+		if err = l.Set(kv.key, kv.val); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (l *lsmObjects) Ascend(
+	fn func(key cipher.SHA256, value []byte) error) (err error) {
+
+	for _, k := range l.v.keys(lsmObjectPrefix) {
+		e, _ := l.v.get(k)
+		if err = fn(l.getKey(k), e.value()); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+	}
+	return
+}
+
+func (l *lsmObjects) AscendDel(
+	fn func(key cipher.SHA256, value []byte) (bool, error)) (err error) {
+
+	var del bool
+
+	for _, k := range l.v.keys(lsmObjectPrefix) {
+		e, _ := l.v.get(k)
+		key := l.getKey(k)
+		if del, err = fn(key, e.value()); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+		if del {
+			l.Del(key)
+		}
+	}
+	return
+}
+
+func (l *lsmObjects) Refs(key cipher.SHA256) (rc uint32) {
+	if e, ok := l.v.get(l.refsKey(key)); ok {
+		if val := e.value(); len(val) == 4 {
+			rc = btorc(val)
+		}
+	}
+	return
+}
+
+// the lsmDB never needs recount
+func (l *lsmObjects) NeedRecount() bool {
+	return false
+}
+
+func (l *lsmObjects) Inc(key cipher.SHA256) (rc uint32, err error) {
+	if !l.IsExist(key) {
+		err = ErrNotFound
+		return
+	}
+	rc = l.Refs(key) + 1
+	l.v.put(l.refsKey(key), rctob(rc))
+	return
+}
+
+func (l *lsmObjects) Dec(key cipher.SHA256) (rc uint32, err error) {
+	if !l.IsExist(key) {
+		err = ErrNotFound
+		return
+	}
+	if rc = l.Refs(key); rc == 0 {
+		return // already zero
+	}
+	rc--
+	l.v.put(l.refsKey(key), rctob(rc))
+	return
+}
+
+func (l *lsmObjects) SetRefs(key cipher.SHA256, rc uint32) (err error) {
+	if !l.IsExist(key) {
+		return ErrNotFound
+	}
+	l.v.put(l.refsKey(key), rctob(rc))
+	return
+}
+
+func (l *lsmObjects) Recounted() (_ error) {
+	return
+}
+
+func (l *lsmObjects) Cursor() ObjectsCursor {
+	return newObjectsCursor(newLSMCursor(l.v, lsmObjectPrefix), nil, nil)
+}
+
+func (l *lsmObjects) RangeCursor(from, to cipher.SHA256) ObjectsCursor {
+	f, t := hashBounds(from, to)
+	return newObjectsCursor(newLSMCursor(l.v, lsmObjectPrefix), f, t)
+}
+
+type lsmMisc struct {
+	v      *lsmView
+	prefix string // lsmMiscPrefix or prefix of a namespace
+}
+
+func newLSMMisc(v *lsmView, namespace string) *lsmMisc {
+	if namespace == "" {
+		return &lsmMisc{v, lsmMiscPrefix}
+	}
+	return &lsmMisc{v, lsmNSPrefix + string(namespacePrefix(namespace))}
+}
+
+func (l *lsmMisc) key(key []byte) string {
+	return l.prefix + string(key)
+}
+
+func (l *lsmMisc) Set(key, value []byte) (err error) {
+	l.v.put(l.key(key), value)
+	return
+}
+
+func (l *lsmMisc) Del(key []byte) (err error) {
+	l.v.del(l.key(key))
+	return
+}
+
+func (l *lsmMisc) Get(key []byte) (value []byte) {
+	if e, ok := l.v.get(l.key(key)); ok {
+		if value = e.value(); len(value) == 0 {
+			value = nil
+		}
+	}
+	return
+}
+
+func (l *lsmMisc) GetCopy(key []byte) (value []byte) {
+	if g := l.Get(key); g != nil {
+		value = make([]byte, len(g))
+		copy(value, g)
+	}
+	return
+}
+
+func (l *lsmMisc) Ascend(fn func(key, value []byte) error) (err error) {
+	for _, k := range l.v.keys(l.prefix) {
+		e, _ := l.v.get(k)
+		if err = fn([]byte(k[len(l.prefix):]), e.value()); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+	}
+	return
+}
+
+func (l *lsmMisc) AscendDel(
+	fn func(key, value []byte) (bool, error)) (err error) {
+
+	var del bool
+
+	for _, k := range l.v.keys(l.prefix) {
+		e, _ := l.v.get(k)
+		if del, err = fn([]byte(k[len(l.prefix):]), e.value()); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+		if del {
+			l.v.del(k)
+		}
+	}
+	return
+}
+
+func (l *lsmMisc) Descend(fn func(key, value []byte) error) (err error) {
+	keys := l.v.keys(l.prefix)
+	for i := len(keys) - 1; i >= 0; i-- {
+		e, _ := l.v.get(keys[i])
+		err = fn([]byte(keys[i][len(l.prefix):]), e.value())
+		if err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+	}
+	return
+}
+
+func (l *lsmMisc) DescendDel(
+	fn func(key, value []byte) (bool, error)) (err error) {
+
+	var del bool
+
+	keys := l.v.keys(l.prefix)
+	for i := len(keys) - 1; i >= 0; i-- {
+		e, _ := l.v.get(keys[i])
+		del, err = fn([]byte(keys[i][len(l.prefix):]), e.value())
+		if err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+		if del {
+			l.v.del(keys[i])
+		}
+	}
+	return
+}
+
+func (l *lsmMisc) Cursor() MiscCursor {
+	return newMiscCursor(newLSMCursor(l.v, l.prefix), nil, nil)
+}
+
+func (l *lsmMisc) RangeCursor(from, to []byte) MiscCursor {
+	return newMiscCursor(newLSMCursor(l.v, l.prefix), from, to)
+}
+
+func (l *lsmMisc) PrefixCursor(prefix []byte) MiscCursor {
+	return newMiscCursor(newLSMCursor(l.v, l.prefix), prefix,
+		prefixEnd(prefix))
+}
+
+type lsmFeeds struct {
+	v *lsmView
+}
+
+func (l *lsmFeeds) key(pk cipher.PubKey) string {
+	return lsmFeedPrefix + string(pk[:])
+}
+
+func (l *lsmFeeds) getKey(k string) (pk cipher.PubKey) {
+	copy(pk[:], k[len(lsmFeedPrefix):])
+	return
+}
+
+func (l *lsmFeeds) Add(pk cipher.PubKey) (err error) {
+	if !l.IsExist(pk) {
+		l.v.put(l.key(pk), nil)
+	}
+	return
+}
+
+func (l *lsmFeeds) Del(pk cipher.PubKey) (err error) {
+	if !l.IsExist(pk) {
+		return
+	}
+	for _, k := range l.v.keys(lsmRootPrefix + string(pk[:])) {
+		l.v.del(k)
+	}
+	for _, k := range l.v.keys(lsmLocalPrefix + string(pk[:])) {
+		l.v.del(k)
+	}
+	for _, k := range l.v.keys(lsmTimePrefix + string(pk[:])) {
+		l.v.del(k)
+	}
+	l.v.del(l.key(pk))
+	return
+}
+
+func (l *lsmFeeds) IsExist(pk cipher.PubKey) (ok bool) {
+	_, ok = l.v.get(l.key(pk))
+	return
+}
+
+func (l *lsmFeeds) List() (list []cipher.PubKey) {
+	for _, k := range l.v.keys(lsmFeedPrefix) {
+		list = append(list, l.getKey(k))
+	}
+	return
+}
+
+func (l *lsmFeeds) Ascend(fn func(pk cipher.PubKey) error) (err error) {
+	for _, k := range l.v.keys(lsmFeedPrefix) {
+		if err = fn(l.getKey(k)); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+	}
+	return
+}
+
+func (l *lsmFeeds) AscendDel(
+	fn func(pk cipher.PubKey) (bool, error)) (err error) {
+
+	var del bool
+
+	for _, k := range l.v.keys(lsmFeedPrefix) {
+		pk := l.getKey(k)
+		if del, err = fn(pk); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+		if del {
+			l.Del(pk)
+		}
+	}
+	return
+}
+
+func (l *lsmFeeds) Roots(pk cipher.PubKey) UpdateRoots {
+	if !l.IsExist(pk) {
+		return nil
+	}
+	return &lsmRoots{
+		feed:   pk,
+		prefix: lsmRootPrefix + string(pk[:]),
+		local:  lsmLocalPrefix + string(pk[:]),
+		times:  lsmTimePrefix + string(pk[:]),
+		v:      l.v,
+	}
+}
+
+type lsmViewFeeds struct {
+	lsmFeeds
+}
+
+func (l *lsmViewFeeds) Roots(pk cipher.PubKey) ViewRoots {
+	return l.lsmFeeds.Roots(pk)
+}
+
+type lsmRoots struct {
+	feed   cipher.PubKey
+	prefix string // s + pk
+	local  string // l + pk
+	times  string // t + pk
+	v      *lsmView
+}
+
+func (l *lsmRoots) Feed() cipher.PubKey {
+	return l.feed
+}
+
+func (l *lsmRoots) key(seq uint64) string {
+	return l.prefix + string(utob(seq))
+}
+
+// decode RootPack by key; it returns RootPack with
+// nil Root and zero Hash if stored RootPack is corrupted,
+// thus the Root can't be unpacked and is reported by Check
+func (l *lsmRoots) decode(k string) (rp *RootPack) {
+	e, _ := l.v.get(k)
+	rp = new(RootPack)
+	if err := encoder.DeserializeRaw(e.value(), rp); err != nil {
+		rp = &RootPack{Seq: btou([]byte(k[len(l.prefix):]))}
+	}
+	return
+}
+
+func (l *lsmRoots) Add(rp *RootPack) (err error) {
+
+	// check
+
+	if rp.Seq == 0 {
+		if rp.Prev != (cipher.SHA256{}) {
+			err = newRootError(l.feed, rp, "unexpected prev. reference")
+			return
+		}
+	} else if rp.Prev == (cipher.SHA256{}) {
+		err = newRootError(l.feed, rp, "missing prev. reference")
+		return
+	}
+	hash := cipher.SumSHA256(rp.Root)
+	if hash != rp.Hash {
+		err = newRootError(l.feed, rp, "wrong hash of the root")
+		return
+	}
+
+	key := l.key(rp.Seq)
+
+	// find
+
+	if _, ok := l.v.get(key); ok {
+		return ErrRootAlreadyExists
+	}
+
+	l.v.put(key, encoder.Serialize(rp))
+	return
+}
+
+func (l *lsmRoots) Last() (rp *RootPack) {
+	if k, ok := l.v.last(l.prefix); ok {
+		rp = l.decode(k)
+	}
+	return
+}
+
+func (l *lsmRoots) Get(seq uint64) (rp *RootPack) {
+	if _, ok := l.v.get(l.key(seq)); ok {
+		rp = l.decode(l.key(seq))
+	}
+	return
+}
+
+// localKey returns key of metadata of root by key of the root
+func (l *lsmRoots) localKey(k string) string {
+	return l.local + k[len(l.prefix):]
+}
+
+func (l *lsmRoots) IsFull(seq uint64) bool {
+	rm, _ := l.Meta(seq)
+	return rm.IsFull
+}
+
+func (l *lsmRoots) Meta(seq uint64) (rm RootMeta, err error) {
+	key := l.key(seq)
+	if _, ok := l.v.get(key); !ok {
+		err = ErrNotFound
+		return
+	}
+	rm = l.meta(key)
+	return
+}
+
+// meta returns stored RootMeta (or zero RootMeta)
+// by key of the root
+func (l *lsmRoots) meta(k string) (rm RootMeta) {
+	if e, ok := l.v.get(l.localKey(k)); ok {
+		rm = decodeRootMeta(e.value())
+	}
+	return
+}
+
+// timeKey returns key of time index
+func (l *lsmRoots) timeKey(t int64, seq uint64) string {
+	return l.times + string(timeKey(t, seq))
+}
+
+// delLocal deletes metadata and time index
+// of root by key of the root
+func (l *lsmRoots) delLocal(k string) {
+	if old := l.meta(k); old.Time != 0 {
+		l.v.del(l.timeKey(old.Time, btou([]byte(k[len(l.prefix):]))))
+	}
+	l.v.del(l.localKey(k))
+}
+
+func (l *lsmRoots) Del(seq uint64) (err error) {
+	key := l.key(seq)
+	l.v.del(key)
+	l.delLocal(key)
+	return
+}
+
+func (l *lsmRoots) MarkFull(seq uint64) (err error) {
+	var rm RootMeta
+	if rm, err = l.Meta(seq); err != nil {
+		return
+	}
+	rm.IsFull, rm.Refill = true, false
+	return l.SetMeta(seq, rm)
+}
+
+func (l *lsmRoots) SetMeta(seq uint64, rm RootMeta) (err error) {
+	var old RootMeta
+	if old, err = l.Meta(seq); err != nil {
+		return
+	}
+	if old.Time != rm.Time {
+		if old.Time != 0 {
+			l.v.del(l.timeKey(old.Time, seq))
+		}
+		if rm.Time != 0 {
+			l.v.put(l.timeKey(rm.Time, seq), nil)
+		}
+	}
+	l.v.put(l.localKey(l.key(seq)), encodeRootMeta(rm))
+	return
+}
+
+func (l *lsmRoots) AtTime(t int64) (rp *RootPack) {
+	keys := l.v.keys(l.times)
+	pivot := l.timeKey(t, 1<<64-1)
+	// first key greater then the pivot
+	i := sort.Search(len(keys), func(i int) bool { return keys[i] > pivot })
+	if i > 0 {
+		_, seq := timeSeq([]byte(keys[i-1][len(l.times):]))
+		rp = l.Get(seq)
+	}
+	return
+}
+
+func (l *lsmRoots) RangeTime(from, to int64,
+	fn func(rp *RootPack) error) (err error) {
+
+	keys := l.v.keys(l.times)
+	pivot := l.times + string(timeBytes(from))
+	i := sort.Search(len(keys), func(i int) bool { return keys[i] >= pivot })
+	for _, k := range keys[i:] {
+		t, seq := timeSeq([]byte(k[len(l.times):]))
+		if !inTimeRange(t, from, to) {
+			return
+		}
+		if err = fn(l.Get(seq)); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+	}
+	return
+}
+
+func (l *lsmRoots) Ascend(fn func(rp *RootPack) error) (err error) {
+	for _, k := range l.v.keys(l.prefix) {
+		if err = fn(l.decode(k)); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+	}
+	return
+}
+
+func (l *lsmRoots) Descend(fn func(rp *RootPack) error) (err error) {
+	keys := l.v.keys(l.prefix)
+	for i := len(keys) - 1; i >= 0; i-- {
+		if err = fn(l.decode(keys[i])); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+	}
+	return
+}
+
+func (l *lsmRoots) Cursor() RootsCursor {
+	return newRootsCursor(newLSMCursor(l.v, l.prefix), nil, nil)
+}
+
+func (l *lsmRoots) RangeCursor(from, to uint64) RootsCursor {
+	f, t := seqBounds(from, to)
+	return newRootsCursor(newLSMCursor(l.v, l.prefix), f, t)
+}
+
+func (l *lsmRoots) AscendDel(
+	fn func(rp *RootPack) (bool, error)) (err error) {
+
+	var del bool
+
+	for _, k := range l.v.keys(l.prefix) {
+		if del, err = fn(l.decode(k)); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
+		if del {
+			l.v.del(k)
+			l.delLocal(k)
+		}
+	}
+	return
+}
+
+func (l *lsmRoots) DelBefore(seq uint64) (err error) {
+	for _, k := range l.v.keys(l.prefix) {
+		if btou([]byte(k[len(l.prefix):])) >= seq {
+			return
+		}
+		l.v.del(k)
+		l.delLocal(k)
+	}
+	return
+}
+
+// A lsmCursor is rawCursor over sorted keys with given
+// prefix. The keys are taken when the cursor created
+type lsmCursor struct {
+	v      *lsmView
+	prefix string
+	keys   []string
+	i      int // current position
+}
+
+func newLSMCursor(v *lsmView, prefix string) *lsmCursor {
+	return &lsmCursor{v: v, prefix: prefix, keys: v.keys(prefix), i: -1}
+}
+
+func (l *lsmCursor) at(i int) (key, value []byte) {
+	if i < 0 {
+		l.i = -1 // before first
+		return
+	}
+	if i >= len(l.keys) {
+		l.i = len(l.keys) // after last
+		return
+	}
+	l.i = i
+	e, _ := l.v.get(l.keys[i])
+	return []byte(l.keys[i][len(l.prefix):]), e.value()
+}
+
+func (l *lsmCursor) First() ([]byte, []byte) {
+	return l.at(0)
+}
+
+func (l *lsmCursor) Last() ([]byte, []byte) {
+	return l.at(len(l.keys) - 1)
+}
+
+func (l *lsmCursor) Seek(seek []byte) ([]byte, []byte) {
+	return l.at(sort.SearchStrings(l.keys, l.prefix+string(seek)))
+}
+
+func (l *lsmCursor) Next() ([]byte, []byte) {
+	return l.at(l.i + 1)
+}
+
+func (l *lsmCursor) Prev() ([]byte, []byte) {
+	return l.at(l.i - 1)
+}
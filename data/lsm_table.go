@@ -0,0 +1,587 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// magic number of SSTable footer
+var lsmTableMagic = []byte("CXOLSMT1")
+
+const lsmFooterSize = 32 // index offset, index length, crc, count, magic
+
+// errors of LSM-tree files
+var (
+	errLSMCorrupted = errors.New("corrupted file of LSM-tree database")
+)
+
+// An lsmEntry represents value of a layer. Values of SSTables
+// are loaded lazily, values of memtables are kept in memory
+type lsmEntry struct {
+	del bool   // tombstone
+	val []byte // value of a memtable
+	ln  int    // length of value
+
+	t   *lsmTable // table of the value (if not loaded)
+	off int64     // offset of the value in the table
+	crc uint32    // checksum of the value
+}
+
+// read returns value of the entry reading it from table if need
+func (e *lsmEntry) read() ([]byte, error) {
+	if e.t == nil || e.del {
+		return e.val, nil
+	}
+	return e.t.read(e.off, e.ln, e.crc)
+}
+
+// value returns value of the entry or nil if the value can't
+// be read, thus corrupted value is reported as missing
+func (e *lsmEntry) value() (val []byte) {
+	val, _ = e.read()
+	return
+}
+
+// An lsmLayer represents memtable, SSTable
+// or write buffer of a transaction
+type lsmLayer interface {
+	// get an entry by key, the ok is false
+	// if the layer doesn't know about the key
+	get(key string) (e lsmEntry, ok bool)
+	// keys with given prefix including deleted ones
+	keys(prefix string) []string
+}
+
+// prefixRange returns range of sorted keys with given prefix
+func prefixRange(sorted []string, prefix string) []string {
+	i := sort.SearchStrings(sorted, prefix)
+	j := i
+	for j < len(sorted) && strings.HasPrefix(sorted[j], prefix) {
+		j++
+	}
+	return sorted[i:j]
+}
+
+// mergeSorted merges given sorted lists of keys
+// to new sorted list without duplicates
+func mergeSorted(lists ...[]string) (merged []string) {
+	var n int
+	for _, l := range lists {
+		n += len(l)
+	}
+	if n == 0 {
+		return
+	}
+	merged = make([]string, 0, n)
+	for {
+		var min string
+		var found bool
+		for _, l := range lists {
+			if len(l) > 0 && (!found || l[0] < min) {
+				min, found = l[0], true
+			}
+		}
+		if !found {
+			return
+		}
+		merged = append(merged, min)
+		for i, l := range lists {
+			if len(l) > 0 && l[0] == min {
+				lists[i] = l[1:]
+			}
+		}
+	}
+}
+
+// An lsmMemtable represents in-memory sorted table. It used
+// as memtable of a database and as write buffer of a read-write
+// transaction. The memtable is not thread safe, except keys
+// method, that can be called by many readers
+type lsmMemtable struct {
+	kv   map[string]lsmEntry
+	size int // approx. size of the memtable
+
+	mx     sync.Mutex // lock the sorted and the added
+	sorted []string   // sorted keys
+	added  []string   // keys added after last sorting
+}
+
+func newLSMMemtable() (m *lsmMemtable) {
+	m = new(lsmMemtable)
+	m.kv = make(map[string]lsmEntry)
+	return
+}
+
+func (m *lsmMemtable) set(key string, e lsmEntry) {
+	if old, ok := m.kv[key]; ok {
+		m.size -= len(key) + len(old.val)
+	} else {
+		m.mx.Lock()
+		m.added = append(m.added, key)
+		m.mx.Unlock()
+	}
+	m.kv[key] = e
+	m.size += len(key) + len(e.val)
+}
+
+func (m *lsmMemtable) put(key string, val []byte) {
+	cp := make([]byte, len(val))
+	copy(cp, val)
+	m.set(key, lsmEntry{val: cp, ln: len(cp)})
+}
+
+func (m *lsmMemtable) del(key string) {
+	m.set(key, lsmEntry{del: true})
+}
+
+func (m *lsmMemtable) get(key string) (e lsmEntry, ok bool) {
+	e, ok = m.kv[key]
+	return
+}
+
+func (m *lsmMemtable) keys(prefix string) []string {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	// merge new keys instead of sorting all keys
+	if len(m.added) > 0 {
+		sort.Strings(m.added)
+		m.sorted = mergeSorted(m.sorted, m.added)
+		m.added = nil
+	}
+	return prefixRange(m.sorted, prefix)
+}
+
+// encode entries of the memtable to write them to WAL
+func (m *lsmMemtable) encode() []byte {
+	var buf bytes.Buffer
+	var vb [binary.MaxVarintLen64]byte
+	for k, e := range m.kv {
+		if e.del {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		buf.Write(vb[:binary.PutUvarint(vb[:], uint64(len(k)))])
+		buf.WriteString(k)
+		buf.Write(vb[:binary.PutUvarint(vb[:], uint64(len(e.val)))])
+		buf.Write(e.val)
+	}
+	return buf.Bytes()
+}
+
+// decode entries encoded by the encode method
+func (m *lsmMemtable) decode(p []byte) (err error) {
+	r := bytes.NewReader(p)
+	for r.Len() > 0 {
+		var flag byte
+		var kl, vl uint64
+		if flag, err = r.ReadByte(); err != nil {
+			return
+		}
+		if kl, err = binary.ReadUvarint(r); err != nil {
+			return
+		}
+		if kl > uint64(r.Len()) {
+			return errLSMCorrupted
+		}
+		key := make([]byte, kl)
+		r.Read(key)
+		if vl, err = binary.ReadUvarint(r); err != nil {
+			return
+		}
+		if vl > uint64(r.Len()) {
+			return errLSMCorrupted
+		}
+		val := make([]byte, vl)
+		r.Read(val)
+		if flag == 1 {
+			m.del(string(key))
+		} else {
+			m.set(string(key), lsmEntry{val: val, ln: len(val)})
+		}
+	}
+	return
+}
+
+// An lsmIndex represents index entry of an SSTable
+type lsmIndex struct {
+	del bool
+	off int64
+	ln  int
+	crc uint32
+}
+
+// An lsmTable represents immutable sorted table on drive. The
+// table contains values followed by index and footer. Index of
+// a table is kept in memory. The lsmTable is thread safe
+type lsmTable struct {
+	seq  uint64 // number of the table
+	path string
+	fd   *os.File
+
+	sorted []string   // sorted keys
+	index  []lsmIndex // index of the keys
+}
+
+func lsmTableName(seq uint64) string {
+	return fmt.Sprintf("%08d.sst", seq)
+}
+
+// writeLSMTable writes sorted keys and values to new SSTable. If
+// dropDeleted is true, then tombstones will not be written
+func writeLSMTable(path string, seq uint64, keys []string,
+	get func(key string) (e lsmEntry, ok bool),
+	dropDeleted bool) (t *lsmTable, err error) {
+
+	var fd *os.File
+	if fd, err = os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR,
+		dbMode); err != nil {
+
+		return
+	}
+
+	t = &lsmTable{seq: seq, path: path, fd: fd}
+
+	w := bufio.NewWriter(fd)
+
+	var off int64
+
+	for _, k := range keys {
+		e, ok := get(k)
+		if !ok || (e.del && dropDeleted) {
+			continue
+		}
+		ix := lsmIndex{del: e.del, off: off}
+		if !e.del {
+			var val []byte
+			if val, err = e.read(); err != nil {
+				break // don't lose corrupted values
+			}
+			if _, err = w.Write(val); err != nil {
+				break
+			}
+			ix.ln = len(val)
+			ix.crc = crc32.ChecksumIEEE(val)
+			off += int64(len(val))
+		}
+		t.sorted = append(t.sorted, k)
+		t.index = append(t.index, ix)
+	}
+
+	if err == nil {
+		err = t.writeIndex(w, off)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = fd.Sync()
+	}
+	if err != nil {
+		fd.Close()
+		os.Remove(path)
+		t = nil
+	}
+	return
+}
+
+func (t *lsmTable) writeIndex(w io.Writer, indexOff int64) (err error) {
+	var buf bytes.Buffer
+	var vb [binary.MaxVarintLen64]byte
+	for i, k := range t.sorted {
+		ix := t.index[i]
+		buf.Write(vb[:binary.PutUvarint(vb[:], uint64(len(k)))])
+		buf.WriteString(k)
+		if ix.del {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		buf.Write(vb[:binary.PutUvarint(vb[:], uint64(ix.off))])
+		buf.Write(vb[:binary.PutUvarint(vb[:], uint64(ix.ln))])
+		binary.Write(&buf, binary.BigEndian, ix.crc)
+	}
+	var footer [lsmFooterSize]byte
+	binary.BigEndian.PutUint64(footer[0:], uint64(indexOff))
+	binary.BigEndian.PutUint64(footer[8:], uint64(buf.Len()))
+	binary.BigEndian.PutUint32(footer[16:], crc32.ChecksumIEEE(buf.Bytes()))
+	binary.BigEndian.PutUint32(footer[20:], uint32(len(t.sorted)))
+	copy(footer[24:], lsmTableMagic)
+	if _, err = w.Write(buf.Bytes()); err != nil {
+		return
+	}
+	_, err = w.Write(footer[:])
+	return
+}
+
+// openLSMTable opens existing SSTable loading its index
+func openLSMTable(path string, seq uint64) (t *lsmTable, err error) {
+	var fd *os.File
+	if fd, err = os.Open(path); err != nil {
+		return
+	}
+	t = &lsmTable{seq: seq, path: path, fd: fd}
+	if err = t.readIndex(); err != nil {
+		fd.Close()
+		err = fmt.Errorf("%s: %v", path, err)
+		t = nil
+	}
+	return
+}
+
+func (t *lsmTable) readIndex() (err error) {
+	var fi os.FileInfo
+	if fi, err = t.fd.Stat(); err != nil {
+		return
+	}
+	if fi.Size() < lsmFooterSize {
+		return errLSMCorrupted
+	}
+	var footer [lsmFooterSize]byte
+	if _, err = t.fd.ReadAt(footer[:], fi.Size()-lsmFooterSize); err != nil {
+		return
+	}
+	if !bytes.Equal(footer[24:], lsmTableMagic) {
+		return errLSMCorrupted
+	}
+	indexOff := int64(binary.BigEndian.Uint64(footer[0:]))
+	indexLen := int64(binary.BigEndian.Uint64(footer[8:]))
+	count := int(binary.BigEndian.Uint32(footer[20:]))
+	if indexOff+indexLen+lsmFooterSize != fi.Size() {
+		return errLSMCorrupted
+	}
+	index := make([]byte, indexLen)
+	if _, err = t.fd.ReadAt(index, indexOff); err != nil {
+		return
+	}
+	if crc32.ChecksumIEEE(index) != binary.BigEndian.Uint32(footer[16:]) {
+		return errLSMCorrupted
+	}
+	t.sorted = make([]string, 0, count)
+	t.index = make([]lsmIndex, 0, count)
+	r := bytes.NewReader(index)
+	for i := 0; i < count; i++ {
+		var kl, off, ln uint64
+		var flag byte
+		var ix lsmIndex
+		if kl, err = binary.ReadUvarint(r); err != nil {
+			return errLSMCorrupted
+		}
+		if kl > uint64(r.Len()) {
+			return errLSMCorrupted
+		}
+		key := make([]byte, kl)
+		r.Read(key)
+		if flag, err = r.ReadByte(); err != nil {
+			return errLSMCorrupted
+		}
+		if off, err = binary.ReadUvarint(r); err != nil {
+			return errLSMCorrupted
+		}
+		if ln, err = binary.ReadUvarint(r); err != nil {
+			return errLSMCorrupted
+		}
+		if err = binary.Read(r, binary.BigEndian, &ix.crc); err != nil {
+			return errLSMCorrupted
+		}
+		ix.del, ix.off, ix.ln = flag == 1, int64(off), int(ln)
+		t.sorted = append(t.sorted, string(key))
+		t.index = append(t.index, ix)
+	}
+	return
+}
+
+func (t *lsmTable) get(key string) (e lsmEntry, ok bool) {
+	i := sort.SearchStrings(t.sorted, key)
+	if i == len(t.sorted) || t.sorted[i] != key {
+		return
+	}
+	ix := t.index[i]
+	e = lsmEntry{del: ix.del, ln: ix.ln, t: t, off: ix.off, crc: ix.crc}
+	ok = true
+	return
+}
+
+func (t *lsmTable) keys(prefix string) []string {
+	return prefixRange(t.sorted, prefix)
+}
+
+// read value of the table
+func (t *lsmTable) read(off int64, ln int, crc uint32) (val []byte,
+	err error) {
+
+	val = make([]byte, ln)
+	if _, err = t.fd.ReadAt(val, off); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(val) != crc {
+		return nil, fmt.Errorf("%s: checksum mismatch", t.path)
+	}
+	return
+}
+
+func (t *lsmTable) Close() error {
+	return t.fd.Close()
+}
+
+// An lsmView represents ordered layers of a
+// database from newest to oldest
+type lsmView struct {
+	layers []lsmLayer
+}
+
+// get an entry looking for it from newest to oldest
+// layer; the ok is false for deleted and missing keys
+func (v *lsmView) get(key string) (e lsmEntry, ok bool) {
+	for _, l := range v.layers {
+		if e, ok = l.get(key); ok {
+			if e.del {
+				ok = false
+			}
+			return
+		}
+	}
+	return
+}
+
+// keys returns sorted keys with given prefix
+// excluding deleted ones; keys of every layer
+// are sorted and the keys merges them
+func (v *lsmView) keys(prefix string) (keys []string) {
+	lists := make([][]string, 0, len(v.layers))
+	for _, l := range v.layers {
+		lists = append(lists, l.keys(prefix))
+	}
+	for _, k := range mergeSorted(lists...) {
+		if _, ok := v.get(k); ok {
+			keys = append(keys, k)
+		}
+	}
+	return
+}
+
+// last returns greatest not deleted key with given
+// prefix, the ok is false if there are no such keys
+func (v *lsmView) last(prefix string) (key string, ok bool) {
+	lists := make([][]string, 0, len(v.layers))
+	for _, l := range v.layers {
+		lists = append(lists, l.keys(prefix))
+	}
+	for {
+		var max string
+		var found bool
+		for _, l := range lists {
+			if n := len(l); n > 0 && (!found || l[n-1] > max) {
+				max, found = l[n-1], true
+			}
+		}
+		if !found {
+			return
+		}
+		if _, ok = v.get(max); ok {
+			return max, true
+		}
+		for i, l := range lists {
+			if n := len(l); n > 0 && l[n-1] == max {
+				lists[i] = l[:n-1]
+			}
+		}
+	}
+}
+
+// write buffer of read-write view; it
+// panics if the view is read-only
+func (v *lsmView) buffer() *lsmMemtable {
+	return v.layers[0].(*lsmMemtable)
+}
+
+func (v *lsmView) put(key string, val []byte) {
+	v.buffer().put(key, val)
+}
+
+func (v *lsmView) del(key string) {
+	v.buffer().del(key)
+}
+
+// lsmWAL represents write-ahead log of
+// LSM-tree database
+type lsmWAL struct {
+	fd *os.File
+}
+
+// openLSMWAL opens or creates WAL file and
+// replays it to given memtable
+func openLSMWAL(path string, mem *lsmMemtable) (w *lsmWAL, err error) {
+	var fd *os.File
+	if fd, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR, dbMode); err != nil {
+		return
+	}
+	var good int64 // end of last good record
+	r := bufio.NewReader(fd)
+	for {
+		var head [8]byte
+		if _, err = io.ReadFull(r, head[:]); err != nil {
+			break
+		}
+		ln := binary.BigEndian.Uint32(head[0:])
+		crc := binary.BigEndian.Uint32(head[4:])
+		p := make([]byte, ln)
+		if _, err = io.ReadFull(r, p); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(p) != crc {
+			break // torn write
+		}
+		if err = mem.decode(p); err != nil {
+			break
+		}
+		good += int64(len(head)) + int64(ln)
+	}
+	// drop torn tail if any
+	if err = fd.Truncate(good); err == nil {
+		_, err = fd.Seek(good, io.SeekStart)
+	}
+	if err != nil {
+		fd.Close()
+		return
+	}
+	w = &lsmWAL{fd}
+	return
+}
+
+// write record and sync the WAL
+func (w *lsmWAL) write(p []byte) (err error) {
+	rec := make([]byte, 8, 8+len(p))
+	binary.BigEndian.PutUint32(rec[0:], uint32(len(p)))
+	binary.BigEndian.PutUint32(rec[4:], crc32.ChecksumIEEE(p))
+	rec = append(rec, p...)
+	if _, err = w.fd.Write(rec); err != nil {
+		return
+	}
+	return w.fd.Sync()
+}
+
+// reset the WAL after flushing memtable
+func (w *lsmWAL) reset() (err error) {
+	if err = w.fd.Truncate(0); err != nil {
+		return
+	}
+	if _, err = w.fd.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	return w.fd.Sync()
+}
+
+func (w *lsmWAL) Close() error {
+	return w.fd.Close()
+}
@@ -0,0 +1,388 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// set small memtable and few tables for tests
+func testLSMTuning(memtable, tables int) (restore func()) {
+	pm, pt := lsmMemtableSize, lsmMaxTables
+	lsmMemtableSize, lsmMaxTables = memtable, tables
+	return func() {
+		lsmMemtableSize, lsmMaxTables = pm, pt
+	}
+}
+
+func testLSMValue(i int) []byte {
+	return []byte(fmt.Sprintf("value number %d", i))
+}
+
+func testLSMCheck(t *testing.T, db DB, n int, deleted func(i int) bool) {
+	err := db.View(func(tx Tv) (_ error) {
+		objs := tx.Objects()
+		for i := 0; i < n; i++ {
+			val := testLSMValue(i)
+			got := objs.Get(cipher.SumSHA256(val))
+			if deleted(i) {
+				if got != nil {
+					t.Errorf("deleted object %d exists", i)
+				}
+				continue
+			}
+			if !bytes.Equal(got, val) {
+				t.Errorf("wrong value of object %d: %q", i, got)
+			}
+		}
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewLSMDB(t *testing.T) {
+	// NewLSMDB(dir string) (db DB, err error)
+
+	defer testLSMTuning(256, 3)()
+
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewLSMDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 100
+
+	// many small transactions, that cause flushing and compaction
+	for i := 0; i < n; i++ {
+		err = db.Update(func(tx Tu) (err error) {
+			_, err = tx.Objects().Add(testLSMValue(i))
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// delete every third object
+	deleted := func(i int) bool { return i%3 == 0 }
+
+	for i := 0; i < n; i++ {
+		if !deleted(i) {
+			continue
+		}
+		err = db.Update(func(tx Tu) error {
+			return tx.Objects().Del(cipher.SumSHA256(testLSMValue(i)))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testLSMCheck(t, db, n, deleted)
+
+	if tables, _ := filepath.Glob(filepath.Join(dir, "*.sst")); len(tables) == 0 {
+		t.Error("memtable has not been flushed")
+	} else if len(tables) >= lsmMaxTables {
+		t.Error("tables has not been compacted:", len(tables))
+	}
+
+	if s := db.Stat(); s.Objects != n-(n+2)/3 {
+		t.Error("wrong amount of objects:", s.Objects)
+	}
+
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen (tables + WAL)
+
+	if db, err = NewLSMDB(dir); err != nil {
+		t.Fatal(err)
+	}
+	testLSMCheck(t, db, n, deleted)
+	db.Close()
+
+	// torn write of WAL
+
+	wal, err := os.OpenFile(filepath.Join(dir, lsmWALFile),
+		os.O_WRONLY|os.O_APPEND, dbMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal.Write([]byte{0, 0, 1, 0, 1, 2})
+	wal.Close()
+
+	if db, err = NewLSMDB(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	testLSMCheck(t, db, n, deleted)
+
+	// rollback
+
+	errRollback := fmt.Errorf("rollback")
+	err = db.Update(func(tx Tu) (err error) {
+		if _, err = tx.Objects().Add([]byte("rolled back")); err != nil {
+			return
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal("unexpected error:", err)
+	}
+	db.View(func(tx Tv) (_ error) {
+		if tx.Objects().IsExist(cipher.SumSHA256([]byte("rolled back"))) {
+			t.Error("rolled back object exists")
+		}
+		return
+	})
+
+}
+
+func TestLSMDB_commit(t *testing.T) {
+
+	defer testLSMTuning(64, 100)()
+
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewLSMDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the flushing fails, because path of the table is a directory
+	table := filepath.Join(dir, lsmTableName(1))
+	if err = os.MkdirAll(filepath.Join(table, "x"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Update(func(tx Tu) (err error) {
+		_, err = tx.Objects().Add(bytes.Repeat([]byte("x"), 128))
+		return
+	})
+	if err != nil {
+		t.Fatal("error of flushing returned:", err)
+	}
+	db.View(func(tx Tv) (_ error) {
+		if !tx.Objects().IsExist(cipher.SumSHA256(bytes.Repeat([]byte("x"),
+			128))) {
+
+			t.Error("missing object")
+		}
+		return
+	})
+
+	// retried by next commit
+	os.RemoveAll(table)
+	err = db.Update(func(tx Tu) (err error) {
+		_, err = tx.Objects().Add(testLSMValue(0))
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(table); err != nil {
+		t.Error("memtable has not been flushed:", err)
+	}
+	if err = db.Close(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+
+	// not resolved
+	if db, err = NewLSMDB(dir); err != nil {
+		t.Fatal(err)
+	}
+	table = filepath.Join(dir, lsmTableName(2))
+	if err = os.MkdirAll(filepath.Join(table, "x"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx Tu) (err error) {
+		_, err = tx.Objects().Add(testLSMValue(1))
+		return tx.Misc("").Set([]byte("k"), bytes.Repeat([]byte("v"), 128))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(); err == nil {
+		t.Error("missing error")
+	}
+
+}
+
+func TestLSMDB_corrupted(t *testing.T) {
+
+	defer testLSMTuning(64, 100)()
+
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewLSMDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx Tu) (err error) {
+		_, err = tx.Objects().Add(bytes.Repeat([]byte("x"), 128))
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the value is the first in the table
+	fd, err := os.OpenFile(filepath.Join(dir, lsmTableName(1)), os.O_RDWR,
+		dbMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fd.WriteAt([]byte("y"), 0)
+	fd.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db, err = NewLSMDB(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	key := cipher.SumSHA256(bytes.Repeat([]byte("x"), 128))
+	db.View(func(tx Tv) (_ error) {
+		if got := tx.Objects().Get(key); got != nil {
+			t.Errorf("got corrupted object: %q", got)
+		}
+		return
+	})
+	problems, err := Check(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 {
+		t.Fatal("wrong problems:", problems)
+	}
+	if oe, ok := problems[0].(*ObjectError); !ok || oe.Key() != key {
+		t.Error("wrong problem:", problems[0])
+	}
+}
+
+func TestLSMDB_tombstones(t *testing.T) {
+
+	defer testLSMTuning(64, 100)()
+
+	db, cleanUp := testLSMDB(t)
+	defer cleanUp()
+
+	empty := cipher.SumSHA256(nil)
+	check := func(exist bool) {
+		db.View(func(tx Tv) (_ error) {
+			objs := tx.Objects()
+			if objs.IsExist(empty) != exist {
+				t.Errorf("IsExist: want %t", exist)
+			}
+			if (objs.Get(empty) != nil) != exist {
+				t.Errorf("Get: want exist %t", exist)
+			}
+			return
+		})
+	}
+
+	err := db.Update(func(tx Tu) (err error) {
+		_, err = tx.Objects().Add(nil)
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(true)
+
+	// flush
+	err = db.Update(func(tx Tu) error {
+		return tx.Misc("").Set([]byte("k"), bytes.Repeat([]byte("v"), 128))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(true)
+
+	err = db.Update(func(tx Tu) error {
+		return tx.Objects().Del(empty)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(false)
+
+}
+
+func TestLSMDB_last(t *testing.T) {
+
+	defer testLSMTuning(64, 100)()
+
+	db, cleanUp := testLSMDB(t)
+	defer cleanUp()
+
+	pk, _ := cipher.GenerateKeyPair()
+
+	// every root in its own table
+	for i, content := range []string{"hey", "hoy", "gde kon' moy voronoy"} {
+		err := db.Update(func(tx Tu) (err error) {
+			feeds := tx.Feeds()
+			if err = feeds.Add(pk); err != nil {
+				return
+			}
+			rp := getRootPack(uint64(i), content)
+			if err = feeds.Roots(pk).Add(&rp); err != nil {
+				return
+			}
+			return tx.Misc("").Set([]byte("k"), bytes.Repeat([]byte("v"), 128))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	last := func(want uint64) {
+		db.View(func(tx Tv) (_ error) {
+			if rp := tx.Feeds().Roots(pk).Last(); rp == nil {
+				t.Error("missing last root")
+			} else if rp.Seq != want {
+				t.Errorf("wrong last root %d, want %d", rp.Seq, want)
+			}
+			return
+		})
+	}
+
+	last(2)
+
+	// the newest is deleted in memtable
+	err := db.Update(func(tx Tu) error {
+		return tx.Feeds().Roots(pk).Del(2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last(1)
+
+}
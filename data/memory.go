@@ -1,9 +1,16 @@
 package data
 
 import (
+	"bufio"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/tidwall/buntdb"
 
@@ -13,10 +20,21 @@ import (
 
 // buckets:
 //  - objects hash -> []byte (including schemas)
+//  - refs    hash -> references counter
 //  - feeds   pubkey -> { seq -> RootPack }
 //  - misc    key -> value
+//  - local   pubkey -> { seq -> RootMeta }
+//  - time    pubkey -> { time + seq -> nothing }
+//  - ns      namespace -> { key -> value }
 type memoryDB struct {
 	bunt *buntdb.DB
+
+	// snapshot
+	path   string        // path to snapshot file, if any
+	smx    sync.Mutex    // lock snapshot file
+	closeq chan struct{} // stop snapshots by interval
+	closeo sync.Once
+	await  sync.WaitGroup
 }
 
 // NewMemoryDB creates new database in memory
@@ -25,10 +43,113 @@ func NewMemoryDB() (db DB) {
 	if err != nil {
 		panic(err)
 	}
-	db = &memoryDB{bunt}
+	db = &memoryDB{bunt: bunt}
+	return
+}
+
+// NewMemorySnapshotDB creates new database in memory that
+// persists using snapshot file. The database is loaded from
+// the file if it exists. A snapshot is written to the file
+// on Close and every interval if the interval is greater than
+// zero. Snapshots are atomic: a snapshot written to temporary
+// file that replaces previous one. Errors of snapshots by
+// interval are ignored, but Close returns error of last
+// snapshot. Changes made after last snapshot are lost if the
+// database is not closed properly
+func NewMemorySnapshotDB(path string,
+	interval time.Duration) (db DB, err error) {
+
+	if path == "" {
+		return nil, errors.New("empty path to snapshot")
+	}
+
+	var bunt *buntdb.DB
+	if bunt, err = buntdb.Open(":memory:"); err != nil {
+		return
+	}
+
+	m := &memoryDB{bunt: bunt, path: path}
+
+	if err = m.load(); err != nil {
+		bunt.Close()
+		return
+	}
+
+	m.closeq = make(chan struct{})
+	if interval > 0 {
+		m.await.Add(1)
+		go m.snapshotByInterval(interval)
+	}
+
+	db = m
+	return
+}
+
+// load snapshot if it exists
+func (m *memoryDB) load() (err error) {
+	var fd *os.File
+	if fd, err = os.Open(m.path); err != nil {
+		if os.IsNotExist(err) {
+			err = nil // new database
+		}
+		return
+	}
+	defer fd.Close()
+
+	return m.bunt.Load(bufio.NewReader(fd))
+}
+
+// snapshot writes snapshot of the database to
+// temporary file and replaces previous snapshot
+func (m *memoryDB) snapshot() (err error) {
+	m.smx.Lock()
+	defer m.smx.Unlock()
+
+	var fd *os.File
+	fd, err = ioutil.TempFile(filepath.Dir(m.path),
+		filepath.Base(m.path)+".tmp")
+	if err != nil {
+		return
+	}
+	tmp := fd.Name()
+
+	w := bufio.NewWriter(fd)
+	if err = m.bunt.Save(w); err == nil {
+		if err = w.Flush(); err == nil {
+			err = fd.Sync()
+		}
+	}
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, dbMode)
+	}
+	if err == nil {
+		err = os.Rename(tmp, m.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
 	return
 }
 
+func (m *memoryDB) snapshotByInterval(interval time.Duration) {
+	defer m.await.Done()
+
+	tk := time.NewTicker(interval)
+	defer tk.Stop()
+
+	for {
+		select {
+		case <-tk.C:
+			m.snapshot() // ignore error
+		case <-m.closeq:
+			return
+		}
+	}
+}
+
 func (m *memoryDB) View(fn func(t Tv) error) error {
 	return m.bunt.View(func(t *buntdb.Tx) error {
 		return fn(&memoryTv{t})
@@ -36,63 +157,259 @@ func (m *memoryDB) View(fn func(t Tv) error) error {
 }
 
 func (m *memoryDB) Update(fn func(t Tu) error) error {
-	return m.bunt.Update(func(t *buntdb.Tx) error {
-		return fn(&memoryTu{t})
+	return m.bunt.Update(func(t *buntdb.Tx) (err error) {
+		tx := &memoryTu{tx: t}
+		if err = fn(tx); err != nil {
+			return
+		}
+		return saveMemoryStat(t, &tx.stat)
 	})
 }
 
+// keys of statistic counters
+const (
+	memoryObjectsStat = "stat:objects"
+	memoryFeedStat    = "stat:feed:" // + hex(pk)
+	memoryNSStat      = "stat:ns:"   // + hex(ns)
+)
+
+// memoryCounters returns counters by given key
+func memoryCounters(t *buntdb.Tx, key string) (n, space int) {
+	if val, err := t.Get(key); err == nil {
+		n, space = decodeCounters(decValue(val))
+	}
+	return
+}
+
+// Stat reads statistic counters and never
+// scans objects, roots and misc-objects
 func (m *memoryDB) Stat() (s Stat) {
 
 	m.bunt.View(func(t *buntdb.Tx) (_ error) {
 
 		// objects
 
-		t.AscendKeys("object:*", func(_, v string) bool {
-			s.Objects++
-			s.Space += Space(len(v))
-			return true // continue
-		})
+		var space int
+		s.Objects, space = memoryCounters(t, memoryObjectsStat)
+		s.Space = Space(space)
+		s.PhysicalSpace = s.Space
 
 		// feeds (and roots)
 
-		s.Feeds = make(map[cipher.PubKey]FeedStat)
-
-		t.AscendKeys("feed:*", func(k, v string) bool {
+		t.AscendKeys(memoryFeedStat+"*", func(k, v string) bool {
 
-			// k is "feed:pub_key:seq" or "feed:pub_key"
+			pk, err := cipher.PubKeyFromHex(k[len(memoryFeedStat):])
+			if err != nil {
+				panic(err)
+			}
 
-			if len(v) == 0 {
-				return true // (continue) is not a root object
+			if s.Feeds == nil {
+				s.Feeds = make(map[cipher.PubKey]FeedStat)
 			}
 
-			pk, err := cipher.PubKeyFromHex(strings.Split(k, ":")[1])
+			var fs FeedStat
+			fs.Roots, space = decodeCounters(decValue(v))
+			fs.Space = Space(space)
+
+			s.Feeds[pk] = fs
+
+			return true // continue
+
+		})
+
+		// namespaces
+
+		t.AscendKeys(memoryNSStat+"*", func(k, v string) bool {
+
+			ns, err := hex.DecodeString(k[len(memoryNSStat):])
 			if err != nil {
 				panic(err)
 			}
 
-			fs := s.Feeds[pk]
-			fs.Roots++
-			fs.Space += Space(len(v))
+			if s.Namespaces == nil {
+				s.Namespaces = make(map[string]NamespaceStat)
+			}
 
-			s.Feeds[pk] = fs
+			var st NamespaceStat
+			st.Keys, space = decodeCounters(decValue(v))
+			st.Space = Space(space)
+
+			s.Namespaces[string(ns)] = st
 
 			return true // continue
 
 		})
 
-		if len(s.Feeds) == 0 {
-			s.Feeds = nil
+		return
+
+	})
+
+	return
+}
+
+func (m *memoryDB) recountStat() error {
+	return m.bunt.Update(recountMemoryStat)
+}
+
+// recountMemoryStat builds statistic
+// counters scanning whole database
+func recountMemoryStat(t *buntdb.Tx) (err error) {
+
+	var s Stat
+	s.Feeds = make(map[cipher.PubKey]FeedStat)
+
+	t.AscendKeys("object:*", func(_, v string) bool {
+		s.Objects++
+		s.Space += Space(len(v) / 2) // hex encoded
+		return true                  // continue
+	})
+
+	t.AscendKeys("feed:*", func(k, v string) bool {
+
+		// k is "feed:pub_key:seq" or "feed:pub_key"
+
+		pk, err := cipher.PubKeyFromHex(strings.Split(k, ":")[1])
+		if err != nil {
+			panic(err)
 		}
 
-		return
+		fs := s.Feeds[pk]
 
+		if len(v) != 0 { // is a root object
+			fs.Roots++
+			fs.Space += Space(len(v) / 2) // hex encoded
+		}
+
+		s.Feeds[pk] = fs
+
+		return true // continue
+
+	})
+
+	nss := make(map[string]NamespaceStat)
+
+	// count misc-object of given namespace
+	var countMisc = func(ns, k, v string) {
+		st := nss[ns]
+		st.Keys++
+		st.Space += Space(len(k)/2 + len(v)/2) // hex encoded
+		nss[ns] = st
+	}
+
+	t.AscendKeys("misc:*", func(k, v string) bool {
+		countMisc("", k[len("misc:"):], v)
+		return true // continue
 	})
 
+	t.AscendKeys(memoryNamespacePrefix+"*", func(k, v string) bool {
+
+		// k is "ns:hex(ns):hex(key)"
+
+		k = k[len(memoryNamespacePrefix):]
+		if i := strings.IndexByte(k, ':'); i >= 0 {
+			if ns, err := hex.DecodeString(k[:i]); err == nil {
+				countMisc(string(ns), k[i+1:], v)
+			}
+		}
+		return true // continue
+
+	})
+
+	// remove old counters
+	var old []string
+	t.AscendKeys("stat:*", func(k, _ string) bool {
+		old = append(old, k)
+		return true // continue
+	})
+	for _, k := range old {
+		if _, err = t.Delete(k); err != nil {
+			return
+		}
+	}
+
+	_, _, err = t.Set(memoryObjectsStat,
+		encValue(encodeCounters(s.Objects, int(s.Space))), nil)
+	if err != nil {
+		return
+	}
+	for pk, fs := range s.Feeds {
+		_, _, err = t.Set(memoryFeedStat+pk.Hex(),
+			encValue(encodeCounters(fs.Roots, int(fs.Space))), nil)
+		if err != nil {
+			return
+		}
+	}
+	for ns, st := range nss {
+		_, _, err = t.Set(memoryNSStat+hex.EncodeToString([]byte(ns)),
+			encValue(encodeCounters(st.Keys, int(st.Space))), nil)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// saveMemoryStat updates stored statistic counters by given delta
+func saveMemoryStat(t *buntdb.Tx, s *statDelta) (err error) {
+	if s.objects != 0 || s.space != 0 {
+		n, space := memoryCounters(t, memoryObjectsStat)
+		_, _, err = t.Set(memoryObjectsStat,
+			encValue(encodeCounters(n+s.objects, space+s.space)), nil)
+		if err != nil {
+			return
+		}
+	}
+
+	for pk, fd := range s.feeds {
+		key := memoryFeedStat + pk.Hex()
+		if _, err = t.Get("feed:" + pk.Hex()); err == buntdb.ErrNotFound {
+			if _, err = t.Delete(key); err == buntdb.ErrNotFound {
+				err = nil
+			} else if err != nil {
+				return
+			}
+			continue
+		} else if err != nil {
+			return
+		}
+		roots, space := fd.apply(memoryCounters(t, key))
+		_, _, err = t.Set(key, encValue(encodeCounters(roots, space)), nil)
+		if err != nil {
+			return
+		}
+	}
+
+	for ns, nd := range s.namespaces {
+		key := memoryNSStat + hex.EncodeToString([]byte(ns))
+		keys, space := nd.apply(memoryCounters(t, key))
+		if keys <= 0 {
+			if _, err = t.Delete(key); err == buntdb.ErrNotFound {
+				err = nil
+			}
+		} else {
+			_, _, err = t.Set(key, encValue(encodeCounters(keys, space)), nil)
+		}
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
-func (m *memoryDB) Close() error {
-	return m.bunt.Close()
+func (m *memoryDB) Close() (err error) {
+	if m.path == "" {
+		return m.bunt.Close()
+	}
+	m.closeo.Do(func() {
+		close(m.closeq)
+		m.await.Wait()
+
+		err = m.snapshot()
+		if cerr := m.bunt.Close(); err == nil {
+			err = cerr
+		}
+	})
+	return
 }
 
 type memoryTv struct {
@@ -100,35 +417,69 @@ type memoryTv struct {
 }
 
 func (m *memoryTv) Objects() ViewObjects {
-	return &memoryObjects{m.tx}
+	return &memoryObjects{m.tx, nil}
 }
 
 func (m *memoryTv) Feeds() ViewFeeds {
-	return &memoryViewFeeds{memoryFeeds{m.tx}}
+	return &memoryViewFeeds{memoryFeeds{m.tx, nil}}
 }
 
-func (m *memoryTv) Misc() ViewMisc {
-	return &memoryMisc{m.tx}
+func (m *memoryTv) Misc(namespace string) ViewMisc {
+	return newMemoryMisc(m.tx, namespace, nil)
+}
+
+func (m *memoryTv) Namespaces() []string {
+	return memoryNamespaces(m.tx)
 }
 
 type memoryTu struct {
-	tx *buntdb.Tx
+	tx   *buntdb.Tx
+	stat statDelta
 }
 
 func (m *memoryTu) Objects() UpdateObjects {
-	return &memoryObjects{m.tx}
+	return &memoryObjects{m.tx, &m.stat}
 }
 
 func (m *memoryTu) Feeds() UpdateFeeds {
-	return &memoryFeeds{m.tx}
+	return &memoryFeeds{m.tx, &m.stat}
 }
 
-func (m *memoryTu) Misc() UpdateMisc {
-	return &memoryMisc{m.tx}
+func (m *memoryTu) Misc(namespace string) UpdateMisc {
+	return newMemoryMisc(m.tx, namespace, &m.stat)
+}
+
+func (m *memoryTu) Namespaces() []string {
+	return memoryNamespaces(m.tx)
+}
+
+func (m *memoryTu) DelNamespace(namespace string) error {
+	return m.Misc(namespace).AscendDel(func(_, _ []byte) (bool, error) {
+		return true, nil
+	})
+}
+
+// prefix of keys of namespaces
+const memoryNamespacePrefix = "ns:" // + hex(ns) + ":" + hex(key)
+
+// memoryNamespaces returns non-empty namespaces
+func memoryNamespaces(tx *buntdb.Tx) (list []string) {
+	set := make(map[string]struct{})
+	tx.AscendKeys(memoryNamespacePrefix+"*", func(k, _ string) bool {
+		k = strings.TrimPrefix(k, memoryNamespacePrefix)
+		if i := strings.IndexByte(k, ':'); i >= 0 {
+			if ns, err := hex.DecodeString(k[:i]); err == nil {
+				set[string(ns)] = struct{}{}
+			}
+		}
+		return true // continue
+	})
+	return sortedNamespaces(set)
 }
 
 type memoryObjects struct {
-	tx *buntdb.Tx
+	tx   *buntdb.Tx
+	stat *statDelta // changes of statistic (nil for read-only)
 }
 
 func (m *memoryObjects) key(key cipher.SHA256) string {
@@ -136,17 +487,92 @@ func (m *memoryObjects) key(key cipher.SHA256) string {
 }
 
 func (m *memoryObjects) Set(key cipher.SHA256, value []byte) (err error) {
-	_, _, err = m.tx.Set(m.key(key), encValue(value), nil)
+	var prev string
+	var replaced bool
+	if prev, replaced, err = m.tx.Set(m.key(key), encValue(value),
+		nil); err != nil {
+
+		return
+	}
+	if replaced {
+		m.stat.delObject(len(prev) / 2) // hex encoded
+	}
+	m.stat.addObject(len(value))
 	return
 }
 
+func (m *memoryObjects) refsKey(key cipher.SHA256) string {
+	return "refs:" + key.Hex()
+}
+
 func (m *memoryObjects) Del(key cipher.SHA256) (err error) {
-	if _, err = m.tx.Delete(m.key(key)); err == buntdb.ErrNotFound {
+	var prev string
+	if prev, err = m.tx.Delete(m.key(key)); err == buntdb.ErrNotFound {
+		err = nil
+	} else if err != nil {
+		return
+	} else {
+		m.stat.delObject(len(prev) / 2) // hex encoded
+	}
+	if _, err = m.tx.Delete(m.refsKey(key)); err == buntdb.ErrNotFound {
 		err = nil
 	}
 	return
 }
 
+func (m *memoryObjects) Refs(key cipher.SHA256) (rc uint32) {
+	if val, err := m.tx.Get(m.refsKey(key)); err == nil {
+		rc = binary.BigEndian.Uint32(decValue(val))
+	}
+	return
+}
+
+// the memoryDB never needs recount
+func (m *memoryObjects) NeedRecount() bool {
+	return false
+}
+
+func (m *memoryObjects) setRefs(key cipher.SHA256, rc uint32) (err error) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, rc)
+	_, _, err = m.tx.Set(m.refsKey(key), encValue(b), nil)
+	return
+}
+
+func (m *memoryObjects) Inc(key cipher.SHA256) (rc uint32, err error) {
+	if !m.IsExist(key) {
+		err = ErrNotFound
+		return
+	}
+	rc = m.Refs(key) + 1
+	err = m.setRefs(key, rc)
+	return
+}
+
+func (m *memoryObjects) Dec(key cipher.SHA256) (rc uint32, err error) {
+	if !m.IsExist(key) {
+		err = ErrNotFound
+		return
+	}
+	if rc = m.Refs(key); rc == 0 {
+		return // already zero
+	}
+	rc--
+	err = m.setRefs(key, rc)
+	return
+}
+
+func (m *memoryObjects) SetRefs(key cipher.SHA256, rc uint32) (err error) {
+	if !m.IsExist(key) {
+		return ErrNotFound
+	}
+	return m.setRefs(key, rc)
+}
+
+func (m *memoryObjects) Recounted() (_ error) {
+	return
+}
+
 func (m *memoryObjects) Get(key cipher.SHA256) (p []byte) {
 	if val, _ := m.tx.Get(m.key(key)); len(val) != 0 {
 		if p = decValue(val); len(p) == 0 {
@@ -242,7 +668,7 @@ func (m *memoryObjects) AscendDel(
 	}
 
 	for _, k := range collect {
-		if _, err = m.tx.Delete(k); err != nil {
+		if err = m.Del(m.getKey(k)); err != nil {
 			return
 		}
 	}
@@ -250,23 +676,58 @@ func (m *memoryObjects) AscendDel(
 	return
 }
 
+func (m *memoryObjects) Cursor() ObjectsCursor {
+	return newObjectsCursor(newMemoryCursor(m.tx, "object:"), nil, nil)
+}
+
+func (m *memoryObjects) RangeCursor(from, to cipher.SHA256) ObjectsCursor {
+	f, t := hashBounds(from, to)
+	return newObjectsCursor(newMemoryCursor(m.tx, "object:"), f, t)
+}
+
 type memoryMisc struct {
-	tx *buntdb.Tx
+	tx     *buntdb.Tx
+	ns     string     // namespace
+	prefix string     // "misc:" or prefix of the namespace
+	stat   *statDelta // changes of statistic (nil for read-only)
+}
+
+func newMemoryMisc(tx *buntdb.Tx, namespace string,
+	stat *statDelta) *memoryMisc {
+
+	if namespace == "" {
+		return &memoryMisc{tx, namespace, "misc:", stat}
+	}
+	return &memoryMisc{tx, namespace, memoryNamespacePrefix +
+		hex.EncodeToString([]byte(namespace)) + ":", stat}
 }
 
 func (m *memoryMisc) key(key []byte) string {
-	return "misc:" + hex.EncodeToString(key)
+	return m.prefix + hex.EncodeToString(key)
 }
 
 func (m *memoryMisc) Set(key, value []byte) (err error) {
-	_, _, err = m.tx.Set(m.key(key), encValue(value), nil)
+	var prev string
+	var replaced bool
+	prev, replaced, err = m.tx.Set(m.key(key), encValue(value), nil)
+	if err != nil {
+		return
+	}
+	if replaced {
+		m.stat.delMisc(m.ns, len(key), len(prev)/2) // hex encoded
+	}
+	m.stat.addMisc(m.ns, len(key), len(value))
 	return
 }
 
 func (m *memoryMisc) Del(key []byte) (err error) {
-	if _, err = m.tx.Delete(m.key(key)); err == buntdb.ErrNotFound {
-		err = nil
+	var prev string
+	if prev, err = m.tx.Delete(m.key(key)); err == buntdb.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return
 	}
+	m.stat.delMisc(m.ns, len(key), len(prev)/2) // hex encoded
 	return
 }
 
@@ -285,7 +746,7 @@ func (m *memoryMisc) GetCopy(key []byte) []byte {
 }
 
 func (m *memoryMisc) getKey(k string) []byte {
-	key, err := hex.DecodeString(strings.TrimPrefix(k, "misc:"))
+	key, err := hex.DecodeString(strings.TrimPrefix(k, m.prefix))
 	if err != nil {
 		panic(err)
 	}
@@ -294,7 +755,7 @@ func (m *memoryMisc) getKey(k string) []byte {
 
 func (m *memoryMisc) Ascend(fn func(key, value []byte) error) (err error) {
 
-	m.tx.AscendKeys("misc:*", func(k, v string) bool {
+	m.tx.AscendKeys(m.prefix+"*", func(k, v string) bool {
 		if err = fn(m.getKey(k), decValue(v)); err != nil {
 			if err == ErrStopIteration {
 				err = nil
@@ -314,7 +775,7 @@ func (m *memoryMisc) AscendDel(
 	// See TODO note below
 	collect := []string{}
 
-	m.tx.AscendKeys("misc:*", func(k, v string) bool {
+	m.tx.AscendKeys(m.prefix+"*", func(k, v string) bool {
 		if del, err = fn(m.getKey(k), decValue(v)); err != nil {
 			if err == ErrStopIteration {
 				err = nil
@@ -345,7 +806,56 @@ func (m *memoryMisc) AscendDel(
 	}
 
 	for _, k := range collect {
-		if _, err = m.tx.Delete(k); err != nil {
+		if err = m.Del(m.getKey(k)); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (m *memoryMisc) Descend(fn func(key, value []byte) error) (err error) {
+
+	m.tx.DescendKeys(m.prefix+"*", func(k, v string) bool {
+		if err = fn(m.getKey(k), decValue(v)); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return false // break
+		}
+		return true // continue
+	})
+	return
+}
+
+func (m *memoryMisc) DescendDel(
+	fn func(key, value []byte) (bool, error)) (err error) {
+
+	var del bool
+
+	// See TODO note of AscendDel
+	collect := []string{}
+
+	m.tx.DescendKeys(m.prefix+"*", func(k, v string) bool {
+		if del, err = fn(m.getKey(k), decValue(v)); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return false // break
+		}
+		if del {
+			collect = append(collect, k)
+		}
+		return true // continue
+	})
+
+	// temporary (buntdb#24)
+	if err != nil {
+		return
+	}
+
+	for _, k := range collect {
+		if err = m.Del(m.getKey(k)); err != nil {
 			return
 		}
 	}
@@ -353,8 +863,22 @@ func (m *memoryMisc) AscendDel(
 	return
 }
 
+func (m *memoryMisc) Cursor() MiscCursor {
+	return newMiscCursor(newMemoryCursor(m.tx, m.prefix), nil, nil)
+}
+
+func (m *memoryMisc) RangeCursor(from, to []byte) MiscCursor {
+	return newMiscCursor(newMemoryCursor(m.tx, m.prefix), from, to)
+}
+
+func (m *memoryMisc) PrefixCursor(prefix []byte) MiscCursor {
+	return newMiscCursor(newMemoryCursor(m.tx, m.prefix), prefix,
+		prefixEnd(prefix))
+}
+
 type memoryFeeds struct {
-	tx *buntdb.Tx
+	tx   *buntdb.Tx
+	stat *statDelta // changes of statistic (nil for read-only)
 }
 
 func (m *memoryFeeds) key(pk cipher.PubKey) string {
@@ -362,7 +886,9 @@ func (m *memoryFeeds) key(pk cipher.PubKey) string {
 }
 
 func (m *memoryFeeds) Add(pk cipher.PubKey) (err error) {
-	_, _, err = m.tx.Set(m.key(pk), "", nil)
+	if _, _, err = m.tx.Set(m.key(pk), "", nil); err == nil {
+		m.stat.feed(pk) // create counters if not exist
+	}
 	return
 }
 
@@ -374,6 +900,8 @@ func (m *memoryFeeds) Del(pk cipher.PubKey) (err error) {
 		return
 	}
 
+	m.stat.delFeed(pk)
+
 	// see TODO note below
 	collect := []string{}
 
@@ -393,6 +921,14 @@ func (m *memoryFeeds) Del(pk cipher.PubKey) (err error) {
 		return true // continue
 	})
 
+	// metadata and time index of the roots
+	for _, prefix := range []string{memoryLocalPrefix, memoryTimePrefix} {
+		m.tx.AscendKeys(prefix+pk.Hex()+":*", func(k, _ string) bool {
+			collect = append(collect, k)
+			return true // continue
+		})
+	}
+
 	// See TODO note above
 	// Until #24 of buntdb is open
 	for _, k := range collect {
@@ -509,9 +1045,9 @@ func (m *memoryFeeds) AscendDel(
 		return
 	}
 
-	// see TODO above
+	// see TODO above; delete feeds with roots
 	for _, k := range collect {
-		if _, err = m.tx.Delete(k); err != nil {
+		if err = m.Del(m.getKey(k)); err != nil {
 			return
 		}
 	}
@@ -525,7 +1061,14 @@ func (m *memoryFeeds) Roots(pk cipher.PubKey) UpdateRoots {
 		return nil
 	}
 
-	return &memoryRoots{pk, m.key(pk) + ":", m.tx}
+	return &memoryRoots{
+		feed:   pk,
+		prefix: m.key(pk) + ":",
+		local:  memoryLocalPrefix + pk.Hex() + ":",
+		times:  memoryTimePrefix + pk.Hex() + ":",
+		tx:     m.tx,
+		stat:   m.stat,
+	}
 }
 
 type memoryViewFeeds struct {
@@ -536,10 +1079,20 @@ func (m *memoryViewFeeds) Roots(pk cipher.PubKey) ViewRoots {
 	return m.memoryFeeds.Roots(pk)
 }
 
+// prefixes of keys of metadata of roots and time
+// index (+ hex(pk) + ":" + seq or time + seq)
+const (
+	memoryLocalPrefix = "local:"
+	memoryTimePrefix  = "time:"
+)
+
 type memoryRoots struct {
 	feed   cipher.PubKey
-	prefix string
+	prefix string // feed:pk:
+	local  string // local:pk:
+	times  string // time:pk:
 	tx     *buntdb.Tx
+	stat   *statDelta // changes of statistic (nil for read-only)
 }
 
 func (m *memoryRoots) Feed() cipher.PubKey {
@@ -577,7 +1130,9 @@ func (m *memoryRoots) Add(rp *RootPack) (err error) {
 
 		// not found
 
-		_, _, err = m.tx.Set(key, data, nil)
+		if _, _, err = m.tx.Set(key, data, nil); err == nil {
+			m.stat.addRoot(m.feed, len(data)/2) // hex encoded
+		}
 		return
 
 	} else if err != nil {
@@ -617,22 +1172,156 @@ func (m *memoryRoots) Get(seq uint64) (rp *RootPack) {
 	return
 }
 
+// localKey returns key of metadata of root
+// by key of the root
+func (m *memoryRoots) localKey(k string) string {
+	return m.local + k[len(m.prefix):]
+}
+
+// timeKey returns key of time index
+func (m *memoryRoots) timeKey(t int64, seq uint64) string {
+	return m.times + hex.EncodeToString(timeKey(t, seq))
+}
+
+// timeSeq decodes key of time index
+func (m *memoryRoots) timeSeq(k string) (t int64, seq uint64) {
+	return timeSeq(decValue(k[len(m.times):]))
+}
+
+// meta returns stored RootMeta (or zero RootMeta)
+// by key of the root
+func (m *memoryRoots) meta(k string) (rm RootMeta) {
+	if val, err := m.tx.Get(m.localKey(k)); err == nil {
+		rm = decodeRootMeta(decValue(val))
+	}
+	return
+}
+
+// delLocal deletes metadata and time index
+// of root by key of the root
+func (m *memoryRoots) delLocal(k string) (err error) {
+	if old := m.meta(k); old.Time != 0 {
+		_, err = m.tx.Delete(m.timeKey(old.Time, m.seqOf(k)))
+		if err != nil && err != buntdb.ErrNotFound {
+			return
+		}
+	}
+	if _, err = m.tx.Delete(m.localKey(k)); err == buntdb.ErrNotFound {
+		err = nil
+	}
+	return
+}
+
+// seqOf returns seq number by key of root
+func (m *memoryRoots) seqOf(k string) uint64 {
+	return btou(decValue(k[len(m.prefix):]))
+}
+
+func (m *memoryRoots) IsFull(seq uint64) bool {
+	rm, _ := m.Meta(seq)
+	return rm.IsFull
+}
+
+func (m *memoryRoots) Meta(seq uint64) (rm RootMeta, err error) {
+	key := m.key(seq)
+	if _, err = m.tx.Get(key); err != nil {
+		if err == buntdb.ErrNotFound {
+			err = ErrNotFound
+		}
+		return
+	}
+	rm = m.meta(key)
+	return
+}
+
 func (m *memoryRoots) Del(seq uint64) (err error) {
-	if _, err = m.tx.Delete(m.key(seq)); err == buntdb.ErrNotFound {
+	var prev string
+	key := m.key(seq)
+	if prev, err = m.tx.Delete(key); err == buntdb.ErrNotFound {
 		err = nil
+	} else if err == nil {
+		m.stat.delRoot(m.feed, len(prev)/2) // hex encoded
+		err = m.delLocal(key)
 	}
 	return
 }
 
 func (m *memoryRoots) MarkFull(seq uint64) (err error) {
-	rp := m.Get(seq)
-	if rp == nil {
-		return ErrNotFound
+	var rm RootMeta
+	if rm, err = m.Meta(seq); err != nil {
+		return
+	}
+	rm.IsFull, rm.Refill = true, false
+	return m.SetMeta(seq, rm)
+}
+
+func (m *memoryRoots) SetMeta(seq uint64, rm RootMeta) (err error) {
+	var old RootMeta
+	if old, err = m.Meta(seq); err != nil {
+		return
+	}
+	if old.Time != rm.Time {
+		if old.Time != 0 {
+			if _, err = m.tx.Delete(m.timeKey(old.Time, seq)); err != nil {
+				return
+			}
+		}
+		if rm.Time != 0 {
+			if _, _, err = m.tx.Set(m.timeKey(rm.Time, seq), "",
+				nil); err != nil {
+
+				return
+			}
+		}
+	}
+	_, _, err = m.tx.Set(m.localKey(m.key(seq)), encValue(encodeRootMeta(rm)),
+		nil)
+	return
+}
+
+func (m *memoryRoots) AtTime(t int64) (rp *RootPack) {
+	var seq uint64
+	var ok bool
+	pivot := m.timeKey(t, 1<<64-1)
+	m.tx.DescendLessOrEqual("", pivot, func(k, _ string) bool {
+		if ok = strings.HasPrefix(k, m.times); ok {
+			_, seq = m.timeSeq(k)
+		}
+		return false // break
+	})
+	if ok {
+		rp = m.Get(seq)
+	}
+	return
+}
+
+func (m *memoryRoots) RangeTime(from, to int64,
+	fn func(rp *RootPack) error) (err error) {
+
+	// waithing for #24 of buntdb
+	var seqs []uint64
+
+	pivot := m.times + hex.EncodeToString(timeBytes(from))
+	m.tx.AscendGreaterOrEqual("", pivot, func(k, _ string) bool {
+		if !strings.HasPrefix(k, m.times) {
+			return false // break
+		}
+		t, seq := m.timeSeq(k)
+		if !inTimeRange(t, from, to) {
+			return false // break
+		}
+		seqs = append(seqs, seq)
+		return true // continue
+	})
+
+	for _, seq := range seqs {
+		if err = fn(m.Get(seq)); err != nil {
+			if err == ErrStopIteration {
+				err = nil
+			}
+			return
+		}
 	}
-	rp.IsFull = true
-	data := encValue(encoder.Serialize(rp))
-	key := m.key(rp.Seq) // feed:pk:seq
-	_, _, err = m.tx.Set(key, data, nil)
 	return
 }
 
@@ -680,6 +1369,15 @@ func (m *memoryRoots) Descend(fn func(rp *RootPack) error) (err error) {
 	return
 }
 
+func (m *memoryRoots) Cursor() RootsCursor {
+	return newRootsCursor(newMemoryCursor(m.tx, m.prefix), nil, nil)
+}
+
+func (m *memoryRoots) RangeCursor(from, to uint64) RootsCursor {
+	f, t := seqBounds(from, to)
+	return newRootsCursor(newMemoryCursor(m.tx, m.prefix), f, t)
+}
+
 func (m *memoryRoots) AscendDel(
 	fn func(rp *RootPack) (bool, error)) (err error) {
 
@@ -719,10 +1417,15 @@ func (m *memoryRoots) AscendDel(
 	}
 
 	// See TODO note above
+	var prev string
 	for _, k := range collect {
-		if _, err = m.tx.Delete(k); err != nil {
+		if prev, err = m.tx.Delete(k); err != nil {
 			return // break
 		}
+		m.stat.delRoot(m.feed, len(prev)/2) // hex encoded
+		if err = m.delLocal(k); err != nil {
+			return
+		}
 	}
 
 	return
@@ -740,6 +1443,93 @@ func (m *memoryRoots) DelBefore(seq uint64) (err error) {
 	return
 }
 
+// A memoryCursor is rawCursor over keys with given prefix.
+// Keys and values are hex-encoded and the hex encoding keeps
+// order of keys. Since the buntdb doesn't provide cursors,
+// the memoryCursor keeps current key and looks for next or
+// previous one every time
+type memoryCursor struct {
+	tx     *buntdb.Tx
+	prefix string
+	cur    string // current key
+	pos    int    // -1 before first, 0 at the cur, 1 after last
+}
+
+func newMemoryCursor(tx *buntdb.Tx, prefix string) *memoryCursor {
+	return &memoryCursor{tx: tx, prefix: prefix, pos: -1}
+}
+
+// ascend finds first key greater or equal to the pivot,
+// if the skip is true, then the pivot is skipped
+func (m *memoryCursor) ascend(pivot string, skip bool) (key, value []byte) {
+	m.pos = 1 // after last if not found
+	m.tx.AscendGreaterOrEqual("", pivot, func(k, v string) bool {
+		if skip && k == pivot {
+			return true // continue
+		}
+		if strings.HasPrefix(k, m.prefix) {
+			key, value = m.item(k, v)
+		}
+		return false // break
+	})
+	return
+}
+
+// descend finds first key less or equal to the pivot,
+// if the skip is true, then the pivot is skipped
+func (m *memoryCursor) descend(pivot string, skip bool) (key, value []byte) {
+	m.pos = -1 // before first if not found
+	m.tx.DescendLessOrEqual("", pivot, func(k, v string) bool {
+		if skip && k == pivot {
+			return true // continue
+		}
+		if strings.HasPrefix(k, m.prefix) {
+			key, value = m.item(k, v)
+		}
+		return false // break
+	})
+	return
+}
+
+func (m *memoryCursor) item(k, v string) (key, value []byte) {
+	m.cur, m.pos = k, 0
+	return decValue(k[len(m.prefix):]), decValue(v)
+}
+
+func (m *memoryCursor) First() ([]byte, []byte) {
+	return m.ascend(m.prefix, false)
+}
+
+// Last uses the 0xff that greater than any key with the
+// prefix, since hex-encoded keys never contain it
+func (m *memoryCursor) Last() ([]byte, []byte) {
+	return m.descend(m.prefix+"\xff", false)
+}
+
+func (m *memoryCursor) Seek(seek []byte) ([]byte, []byte) {
+	return m.ascend(m.prefix+hex.EncodeToString(seek), false)
+}
+
+func (m *memoryCursor) Next() ([]byte, []byte) {
+	switch m.pos {
+	case -1:
+		return m.First()
+	case 1:
+		return nil, nil
+	}
+	return m.ascend(m.cur, true)
+}
+
+func (m *memoryCursor) Prev() ([]byte, []byte) {
+	switch m.pos {
+	case -1:
+		return nil, nil
+	case 1:
+		return m.Last()
+	}
+	return m.descend(m.cur, true)
+}
+
 //
 // utilities
 //
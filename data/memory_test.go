@@ -0,0 +1,144 @@
+package data
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestNewMemorySnapshotDB(t *testing.T) {
+	// NewMemorySnapshotDB(path string, interval time.Duration) (DB, error)
+
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err = NewMemorySnapshotDB("", 0); err == nil {
+		t.Error("missing error")
+	}
+
+	path := filepath.Join(dir, "snapshot")
+
+	pk, _ := cipher.GenerateKeyPair()
+	val := []byte("value")
+	rp := getRootPack(0, "root")
+	rm := RootMeta{IsFull: true, Space: 10, Time: 20}
+
+	t.Run("create", func(t *testing.T) {
+		db, err := NewMemorySnapshotDB(path, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Update(func(tx Tu) (err error) {
+			if _, err = tx.Objects().Add(val); err != nil {
+				return
+			}
+			if err = tx.Misc("").Set([]byte("key"), val); err != nil {
+				return
+			}
+			feeds := tx.Feeds()
+			if err = feeds.Add(pk); err != nil {
+				return
+			}
+			roots := feeds.Roots(pk)
+			if err = roots.Add(&rp); err != nil {
+				return
+			}
+			return roots.SetMeta(rp.Seq, rm)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = db.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if tmps, _ := filepath.Glob(path + ".tmp*"); len(tmps) != 0 {
+			t.Error("temporary files left:", tmps)
+		}
+	})
+
+	t.Run("load", func(t *testing.T) {
+		db, err := NewMemorySnapshotDB(path, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		err = db.View(func(tx Tv) (_ error) {
+			if string(tx.Objects().Get(cipher.SumSHA256(val))) != string(val) {
+				t.Error("missing or wrong object")
+			}
+			if string(tx.Misc("").Get([]byte("key"))) != string(val) {
+				t.Error("missing or wrong misc value")
+			}
+			roots := tx.Feeds().Roots(pk)
+			if roots == nil {
+				t.Fatal("missing feed")
+			}
+			if got := roots.Get(rp.Seq); got == nil || got.Hash != rp.Hash {
+				t.Error("missing or wrong root")
+			}
+			if got, err := roots.Meta(rp.Seq); err != nil {
+				t.Error(err)
+			} else if got != rm {
+				t.Errorf("wrong meta %v, want %v", got, rm)
+			}
+			return
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if s := db.Stat(); s.Objects != 1 || len(s.Feeds) != 1 {
+			t.Error("wrong stat:", s)
+		}
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		db, err := NewMemorySnapshotDB(path, TM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		err = db.Update(func(tx Tu) error {
+			return tx.Feeds().Del(pk)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := db.Stat()
+
+		time.Sleep(3 * TM)
+
+		// load snapshot written by interval
+		db2, err := NewMemorySnapshotDB(path, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := db2.Stat(); !reflect.DeepEqual(got, want) {
+			t.Errorf("wrong snapshot: %s, want %s", got, want)
+		}
+		if err = db2.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		if err := ioutil.WriteFile(path, []byte("malformed"),
+			0644); err != nil {
+
+			t.Fatal(err)
+		}
+		if _, err := NewMemorySnapshotDB(path, 0); err == nil {
+			t.Error("missing error")
+		}
+	})
+
+}
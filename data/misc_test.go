@@ -15,7 +15,7 @@ func testViewMiscGet(t *testing.T, db DB) {
 
 	t.Run("not exist", func(t *testing.T) {
 		err := db.View(func(tx Tv) (_ error) {
-			misc := tx.Misc()
+			misc := tx.Misc("")
 
 			if misc.Get(key) != nil {
 				t.Error("got unexisting value")
@@ -29,7 +29,7 @@ func testViewMiscGet(t *testing.T, db DB) {
 	})
 
 	err := db.Update(func(tx Tu) (_ error) {
-		return tx.Misc().Set(key, value)
+		return tx.Misc("").Set(key, value)
 	})
 	if err != nil {
 		t.Error(err)
@@ -38,7 +38,7 @@ func testViewMiscGet(t *testing.T, db DB) {
 
 	t.Run("exists", func(t *testing.T) {
 		err := db.View(func(tx Tv) (_ error) {
-			misc := tx.Misc()
+			misc := tx.Misc("")
 
 			got := misc.Get(key)
 
@@ -73,6 +73,12 @@ func TestViewMisc_Get(t *testing.T) {
 		testViewMiscGet(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewMiscGet(t, db)
+	})
+
 }
 
 func testViewMiscGetCopy(t *testing.T, db DB) {
@@ -85,7 +91,7 @@ func testViewMiscGetCopy(t *testing.T, db DB) {
 
 	t.Run("not exist", func(t *testing.T) {
 		err := db.View(func(tx Tv) (_ error) {
-			misc := tx.Misc()
+			misc := tx.Misc("")
 
 			if misc.GetCopy(key) != nil {
 				t.Error("got unexisting value")
@@ -99,7 +105,7 @@ func testViewMiscGetCopy(t *testing.T, db DB) {
 	})
 
 	err := db.Update(func(tx Tu) (_ error) {
-		return tx.Misc().Set(key, value)
+		return tx.Misc("").Set(key, value)
 	})
 	if err != nil {
 		t.Error(err)
@@ -108,7 +114,7 @@ func testViewMiscGetCopy(t *testing.T, db DB) {
 
 	t.Run("exists", func(t *testing.T) {
 		err := db.View(func(tx Tv) (_ error) {
-			misc := tx.Misc()
+			misc := tx.Misc("")
 
 			got := misc.GetCopy(key)
 
@@ -143,13 +149,19 @@ func TestViewMisc_GetCopy(t *testing.T) {
 		testViewMiscGetCopy(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewMiscGetCopy(t, db)
+	})
+
 }
 
 func testViewMiscAscend(t *testing.T, db DB) {
 
 	t.Run("empty", func(t *testing.T) {
 		err := db.View(func(tx Tv) (_ error) {
-			misc := tx.Misc()
+			misc := tx.Misc("")
 
 			var called int
 
@@ -179,7 +191,7 @@ func testViewMiscAscend(t *testing.T, db DB) {
 	}
 
 	err := db.Update(func(tx Tu) (_ error) {
-		misc := tx.Misc()
+		misc := tx.Misc("")
 
 		for _, o := range to {
 			if err := misc.Set(o.key, o.value); err != nil {
@@ -196,7 +208,7 @@ func testViewMiscAscend(t *testing.T, db DB) {
 
 	t.Run("full", func(t *testing.T) {
 		err := db.View(func(tx Tv) (_ error) {
-			misc := tx.Misc()
+			misc := tx.Misc("")
 
 			var called int
 
@@ -231,7 +243,7 @@ func testViewMiscAscend(t *testing.T, db DB) {
 
 	t.Run("stop iteration", func(t *testing.T) {
 		err := db.View(func(tx Tv) (_ error) {
-			misc := tx.Misc()
+			misc := tx.Misc("")
 
 			var called int
 
@@ -267,6 +279,12 @@ func TestViewMisc_Ascend(t *testing.T) {
 		testViewMiscAscend(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewMiscAscend(t, db)
+	})
+
 }
 
 //
@@ -297,7 +315,7 @@ func testUpdateMiscDel(t *testing.T, db DB) {
 
 	t.Run("not exist", func(t *testing.T) {
 		err := db.Update(func(tx Tu) (_ error) {
-			if err := tx.Misc().Del(key); err != nil {
+			if err := tx.Misc("").Del(key); err != nil {
 				t.Error(err)
 			}
 			return
@@ -309,7 +327,7 @@ func testUpdateMiscDel(t *testing.T, db DB) {
 
 	// fill
 	err := db.Update(func(tx Tu) error {
-		return tx.Misc().Set(key, value)
+		return tx.Misc("").Set(key, value)
 	})
 	if err != nil {
 		t.Error(err)
@@ -317,7 +335,7 @@ func testUpdateMiscDel(t *testing.T, db DB) {
 
 	t.Run("delete", func(t *testing.T) {
 		err := db.Update(func(tx Tu) (_ error) {
-			if err := tx.Misc().Del(key); err != nil {
+			if err := tx.Misc("").Del(key); err != nil {
 				t.Error(err)
 			}
 			return
@@ -342,6 +360,12 @@ func TestUpdateMisc_Del(t *testing.T) {
 		testUpdateMiscDel(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateMiscDel(t, db)
+	})
+
 }
 
 func testUpdateMiscSet(t *testing.T, db DB) {
@@ -351,7 +375,7 @@ func testUpdateMiscSet(t *testing.T, db DB) {
 	t.Run("set", func(t *testing.T) {
 
 		err := db.Update(func(tx Tu) (_ error) {
-			objs := tx.Misc()
+			objs := tx.Misc("")
 
 			if err := objs.Set(key, value); err != nil {
 				t.Error(err)
@@ -374,7 +398,7 @@ func testUpdateMiscSet(t *testing.T, db DB) {
 
 	t.Run("overwrite", func(t *testing.T) {
 		err := db.Update(func(tx Tu) (_ error) {
-			objs := tx.Misc()
+			objs := tx.Misc("")
 
 			replace := []byte("zorro!")
 
@@ -409,6 +433,12 @@ func TestUpdateMisc_Set(t *testing.T) {
 		testUpdateMiscSet(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateMiscSet(t, db)
+	})
+
 }
 
 // not implemented yet
@@ -560,5 +590,11 @@ func TestUpdateMisc_AscendDel(t *testing.T) {
 		testUpdateMiscAscendDel(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateMiscAscendDel(t, db)
+	})
+
 }
 */
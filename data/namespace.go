@@ -0,0 +1,50 @@
+package data
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// Misc-objects are grouped by namespaces. The default
+// (empty) namespace is the misc bucket that existed
+// before namespaces. Other namespaces are nested buckets
+// of drive database or keys with prefix of namespace in
+// other databases
+
+// namespacePrefix returns prefix of keys of given namespace:
+// length of the namespace (uvarint) and the namespace; the
+// length keeps prefixes of different namespaces distinct
+func namespacePrefix(namespace string) []byte {
+	var ln [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(ln[:], uint64(len(namespace)))
+	return append(ln[:n:n], namespace...)
+}
+
+// decodeNamespacePrefix returns namespace and length of
+// its prefix, the n is zero if given key is malformed
+func decodeNamespacePrefix(k []byte) (namespace string, n int) {
+	ln, m := binary.Uvarint(k)
+	if m <= 0 || uint64(len(k)-m) < ln {
+		return
+	}
+	return string(k[m : m+int(ln)]), m + int(ln)
+}
+
+// sortedNamespaces returns sorted list of given set
+func sortedNamespaces(set map[string]struct{}) (list []string) {
+	for ns := range set {
+		list = append(list, ns)
+	}
+	sort.Strings(list)
+	return
+}
+
+// emptyCursor is rawCursor of a namespace
+// that doesn't exist in drive database
+type emptyCursor struct{}
+
+func (emptyCursor) First() (_, _ []byte)        { return }
+func (emptyCursor) Last() (_, _ []byte)         { return }
+func (emptyCursor) Seek(_ []byte) (_, _ []byte) { return }
+func (emptyCursor) Next() (_, _ []byte)         { return }
+func (emptyCursor) Prev() (_, _ []byte)         { return }
@@ -2,8 +2,11 @@ package data
 
 import (
 	"bytes"
+	"os"
 	"testing"
 
+	"github.com/boltdb/bolt"
+
 	"github.com/skycoin/skycoin/src/cipher"
 )
 
@@ -76,6 +79,12 @@ func TestViewObjects_Get(t *testing.T) {
 		testViewObjectsGet(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewObjectsGet(t, db)
+	})
+
 }
 
 func testViewObjectsGetCopy(t *testing.T, db DB) {
@@ -147,6 +156,12 @@ func TestViewObjects_GetCopy(t *testing.T) {
 		testViewObjectsGetCopy(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewObjectsGetCopy(t, db)
+	})
+
 }
 
 func testViewObjectsIsExists(t *testing.T, db DB) {
@@ -207,6 +222,12 @@ func TestViewObjects_IsExist(t *testing.T) {
 		testViewObjectsIsExists(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewObjectsIsExists(t, db)
+	})
+
 }
 
 func testViewObjectsAscend(t *testing.T, db DB) {
@@ -335,6 +356,12 @@ func TestViewObjects_Ascend(t *testing.T) {
 		testViewObjectsAscend(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewObjectsAscend(t, db)
+	})
+
 }
 
 //
@@ -408,6 +435,12 @@ func TestUpdateObjects_Get(t *testing.T) {
 		testUpdateObjectsGet(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateObjectsGet(t, db)
+	})
+
 }
 
 func testUpdateObjectsGetCopy(t *testing.T, db DB) {
@@ -479,6 +512,12 @@ func TestUpdateObjects_GetCopy(t *testing.T) {
 		testUpdateObjectsGetCopy(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateObjectsGetCopy(t, db)
+	})
+
 }
 
 func testUpdateObjectsIsExists(t *testing.T, db DB) {
@@ -539,6 +578,12 @@ func TestUpdateObjects_IsExist(t *testing.T) {
 		testUpdateObjectsIsExists(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateObjectsIsExists(t, db)
+	})
+
 }
 
 func testUpdateObjectsAscend(t *testing.T, db DB) {
@@ -667,6 +712,12 @@ func TestUpdateObjects_Ascend(t *testing.T) {
 		testUpdateObjectsAscend(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateObjectsAscend(t, db)
+	})
+
 }
 
 // UpdateObjects
@@ -723,6 +774,12 @@ func TestUpdateObjects_Del(t *testing.T) {
 		testUpdateObjectsDel(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateObjectsDel(t, db)
+	})
+
 }
 
 func testUpdateObjectsSet(t *testing.T, db DB) {
@@ -791,6 +848,12 @@ func TestUpdateObjects_Set(t *testing.T) {
 		testUpdateObjectsSet(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateObjectsSet(t, db)
+	})
+
 }
 
 func testUpdateObjectsAdd(t *testing.T, db DB) {
@@ -841,6 +904,12 @@ func TestUpdateObjects_Add(t *testing.T) {
 		testUpdateObjectsAdd(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateObjectsAdd(t, db)
+	})
+
 }
 
 func testUpdateObjectsSetMap(t *testing.T, db DB) {
@@ -893,6 +962,12 @@ func TestUpdateObjects_SetMap(t *testing.T) {
 		testUpdateObjectsSetMap(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateObjectsSetMap(t, db)
+	})
+
 }
 
 func testUpdateObjectsAscendDel(t *testing.T, db DB) {
@@ -1041,4 +1116,235 @@ func TestUpdateObjects_AscendDel(t *testing.T) {
 		testUpdateObjectsAscendDel(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateObjectsAscendDel(t, db)
+	})
+
+}
+
+func testUpdateObjectsIncDec(t *testing.T, db DB) {
+
+	value := []byte("counted")
+	key := cipher.SumSHA256(value)
+
+	t.Run("not exist", func(t *testing.T) {
+		err := db.Update(func(tx Tu) (_ error) {
+			objs := tx.Objects()
+			if _, err := objs.Inc(key); err != ErrNotFound {
+				t.Error("unexpected error:", err)
+			}
+			if _, err := objs.Dec(key); err != ErrNotFound {
+				t.Error("unexpected error:", err)
+			}
+			if rc := objs.Refs(key); rc != 0 {
+				t.Error("wrong references counter:", rc)
+			}
+			return
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	// fill
+	err := db.Update(func(tx Tu) error {
+		return tx.Objects().Set(key, value)
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Run("inc dec", func(t *testing.T) {
+		err := db.Update(func(tx Tu) (err error) {
+			objs := tx.Objects()
+			var rc uint32
+			for i := uint32(1); i <= 3; i++ {
+				if rc, err = objs.Inc(key); err != nil {
+					return
+				}
+				if rc != i {
+					t.Errorf("wrong references counter %d, want %d", rc, i)
+				}
+			}
+			// keep counter
+			if err = objs.Set(key, value); err != nil {
+				return
+			}
+			for i := uint32(2); ; i-- {
+				if rc, err = objs.Dec(key); err != nil {
+					return
+				}
+				if rc != i {
+					t.Errorf("wrong references counter %d, want %d", rc, i)
+				}
+				if i == 0 {
+					break
+				}
+			}
+			// never negative
+			if rc, err = objs.Dec(key); err != nil {
+				return
+			} else if rc != 0 {
+				t.Error("wrong references counter:", rc)
+			}
+			// never deletes
+			if !objs.IsExist(key) {
+				t.Error("object deleted by Dec")
+			}
+			return
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("del", func(t *testing.T) {
+		err := db.Update(func(tx Tu) (err error) {
+			objs := tx.Objects()
+			if _, err = objs.Inc(key); err != nil {
+				return
+			}
+			if err = objs.Del(key); err != nil {
+				return
+			}
+			if err = objs.Set(key, value); err != nil {
+				return
+			}
+			if rc := objs.Refs(key); rc != 0 {
+				t.Error("counter of deleted object kept:", rc)
+			}
+			return
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+}
+
+func TestUpdateObjects_Inc(t *testing.T) {
+	// Inc(key cipher.SHA256) (rc uint32, err error)
+	// Dec(key cipher.SHA256) (rc uint32, err error)
+
+	t.Run("memory", func(t *testing.T) {
+		testUpdateObjectsIncDec(t, NewMemoryDB())
+	})
+
+	t.Run("drive", func(t *testing.T) {
+		db, cleanUp := testDriveDB(t)
+		defer cleanUp()
+		testUpdateObjectsIncDec(t, db)
+	})
+
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateObjectsIncDec(t, db)
+	})
+
+}
+
+func testUpdateObjectsSetRefs(t *testing.T, db DB) {
+
+	value := []byte("recounted")
+	key := cipher.SumSHA256(value)
+
+	err := db.Update(func(tx Tu) (err error) {
+		objs := tx.Objects()
+		if err = objs.SetRefs(key, 1); err != ErrNotFound {
+			t.Error("unexpected error:", err)
+		}
+		if err = objs.Set(key, value); err != nil {
+			return
+		}
+		if err = objs.SetRefs(key, 10); err != nil {
+			return
+		}
+		if rc := objs.Refs(key); rc != 10 {
+			t.Error("wrong references counter:", rc)
+		}
+		if objs.NeedRecount() {
+			t.Error("new database needs recount")
+		}
+		return objs.Recounted()
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+}
+
+func TestUpdateObjects_SetRefs(t *testing.T) {
+	// SetRefs(key cipher.SHA256, rc uint32) (err error)
+
+	t.Run("memory", func(t *testing.T) {
+		testUpdateObjectsSetRefs(t, NewMemoryDB())
+	})
+
+	t.Run("drive", func(t *testing.T) {
+		db, cleanUp := testDriveDB(t)
+		defer cleanUp()
+		testUpdateObjectsSetRefs(t, db)
+	})
+
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateObjectsSetRefs(t, db)
+	})
+
+}
+
+func TestViewObjects_NeedRecount(t *testing.T) {
+	// NeedRecount() (need bool)
+
+	// database created before references counters
+
+	dbFile := testPath(t)
+	defer os.Remove(dbFile)
+
+	b, err := bolt.Open(dbFile, dbMode, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Update(func(t *bolt.Tx) (err error) {
+		var objs *bolt.Bucket
+		if objs, err = t.CreateBucket(objectsBucket); err != nil {
+			return
+		}
+		key := cipher.SumSHA256([]byte("old"))
+		return objs.Put(key[:], []byte("old"))
+	})
+	b.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := NewDriveDB(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx Tu) (_ error) {
+		objs := tx.Objects()
+		if !objs.NeedRecount() {
+			t.Error("migrated database doesn't need recount")
+		}
+		return objs.Recounted()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.View(func(tx Tv) (_ error) {
+		if tx.Objects().NeedRecount() {
+			t.Error("need recount after Recounted")
+		}
+		return
+	})
+
 }
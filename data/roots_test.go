@@ -70,6 +70,12 @@ func TestViewRoots_Feed(t *testing.T) {
 		testViewRootsFeed(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewRootsFeed(t, db)
+	})
+
 }
 
 func testViewRootsLast(t *testing.T, db DB) {
@@ -132,6 +138,12 @@ func TestViewRoots_Last(t *testing.T) {
 		testViewRootsLast(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewRootsLast(t, db)
+	})
+
 }
 
 func testViewRootsGet(t *testing.T, db DB) {
@@ -198,6 +210,12 @@ func TestViewRoots_Get(t *testing.T) {
 		testViewRootsGet(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewRootsGet(t, db)
+	})
+
 }
 
 func testViewRootsAscend(t *testing.T, db DB) {
@@ -305,6 +323,12 @@ func TestViewRoots_Ascend(t *testing.T) {
 		testViewRootsAscend(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewRootsAscend(t, db)
+	})
+
 }
 
 func testViewRootsDescend(t *testing.T, db DB) {
@@ -412,6 +436,12 @@ func TestViewRoots_Descend(t *testing.T) {
 		testViewRootsDescend(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testViewRootsDescend(t, db)
+	})
+
 }
 
 //
@@ -521,6 +551,12 @@ func TestUpdateRoots_Add(t *testing.T) {
 		testUpdateRootsAdd(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateRootsAdd(t, db)
+	})
+
 }
 
 func testUpdateRootsDel(t *testing.T, db DB) {
@@ -558,6 +594,12 @@ func TestUpdateRoots_Del(t *testing.T) {
 		testUpdateRootsDel(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateRootsDel(t, db)
+	})
+
 }
 
 func testUpdateRootsMarkFull(t *testing.T, db DB) {
@@ -574,7 +616,7 @@ func testUpdateRootsMarkFull(t *testing.T, db DB) {
 		}
 		if rp := roots.Get(0); rp == nil {
 			t.Error("missing Root after MarkFull")
-		} else if rp.IsFull == false {
+		} else if !roots.IsFull(0) {
 			t.Error("not marked as full")
 		}
 		return
@@ -597,6 +639,12 @@ func TestUpdateRoots_MarkFull(t *testing.T) {
 		testUpdateRootsMarkFull(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateRootsMarkFull(t, db)
+	})
+
 }
 
 func testUpdateRootsAscendDel(t *testing.T, db DB) {
@@ -704,6 +752,12 @@ func TestUpdateRoots_AscendDel(t *testing.T) {
 		testUpdateRootsAscendDel(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateRootsAscendDel(t, db)
+	})
+
 }
 
 func testUpdateRootsDelBefore(t *testing.T, db DB) {
@@ -748,4 +802,10 @@ func TestUpdateRoots_DelBefore(t *testing.T) {
 		testUpdateRootsDelBefore(t, db)
 	})
 
+	t.Run("lsm", func(t *testing.T) {
+		db, cleanUp := testLSMDB(t)
+		defer cleanUp()
+		testUpdateRootsDelBefore(t, db)
+	})
+
 }
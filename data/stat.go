@@ -3,6 +3,7 @@ package data
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/skycoin/skycoin/src/cipher"
 )
@@ -26,12 +27,28 @@ type Stat struct {
 	// doesn't include space taken by
 	// key and other
 	Space Space `json:"space"`
+	// PhysicalSpace is space actually taken
+	// by the Objects. It's less than the
+	// Space if objects are compressed and
+	// greater if objects are encrypted
+	PhysicalSpace Space `json:"physical_space"`
 
 	// Feeds represents statistic
 	// of root objects by feed. This
 	// map is nil if database
 	// doesn't contains feeds
 	Feeds map[cipher.PubKey]FeedStat `json:"feeds"` // feeds
+
+	// Namespaces represents statistic of misc-objects
+	// by namespace, including the default (empty) one.
+	// This map is nil if database doesn't contain
+	// misc-objects
+	Namespaces map[string]NamespaceStat `json:"namespaces"`
+
+	// Quota is global quota of all feeds. A DB
+	// doesn't enforce quotas and never sets the
+	// field. See (*skyobject.Container).DBStat
+	Quota Quota `json:"quota"`
 }
 
 // A FeedStat represents statistic
@@ -42,6 +59,52 @@ type FeedStat struct {
 	Roots int `json:"roots"`
 	// Space taken by root (only root) objects of feed
 	Space Space `json:"space"`
+
+	// ObjectsSpace is space taken by objects of root
+	// objects of the feed and by the root objects.
+	// Shared objects are counted for every feed. It's
+	// not a counter, a DB never sets it. It's
+	// calculated walking all roots of the feed, and
+	// only if space of the feed is limited by a Quota.
+	// See (*skyobject.Container).DBStat
+	ObjectsSpace Space `json:"objects_space"`
+	// Quota of the feed, if set
+	Quota Quota `json:"quota"`
+}
+
+// A NamespaceStat represents statistic
+// of misc-objects of a namespace
+type NamespaceStat struct {
+	// Keys is amount of misc-objects
+	Keys int `json:"keys"`
+	// Space taken by keys and values of the misc-objects
+	Space Space `json:"space"`
+}
+
+// A Quota represents limits of a feed or of all
+// feeds. Zero value of a field means no limit.
+// Quotas are enforced by skyobject.Container
+type Quota struct {
+	// MaxSpace is max space taken by objects
+	// and root objects
+	MaxSpace Space `json:"max_space"`
+	// MaxRoots is max number of root objects
+	MaxRoots int `json:"max_roots"`
+	// MaxAge is max age of root objects
+	MaxAge time.Duration `json:"max_age"`
+}
+
+// IsZero returns true if the Quota has no limits
+func (q Quota) IsZero() bool {
+	return q == Quota{}
+}
+
+// String implements fmt.Stringer interface
+func (q Quota) String() string {
+	return fmt.Sprintf("{max space: %s, max roots: %d, max age: %v}",
+		q.MaxSpace.String(),
+		q.MaxRoots,
+		q.MaxAge)
 }
 
 func shortHex(a string) string {
@@ -53,15 +116,38 @@ func shortHex(a string) string {
 func (s Stat) String() (x string) {
 	feeds := ""
 	for k, r := range s.Feeds {
-		feeds += fmt.Sprintf("<%s>{roots %d, space: %s}",
+		var quota string
+		if !r.Quota.IsZero() {
+			quota = fmt.Sprintf(", objects space: %s, quota: %s",
+				r.ObjectsSpace.String(),
+				r.Quota.String())
+		}
+		feeds += fmt.Sprintf("<%s>{roots %d, space: %s%s}",
 			shortHex(k.Hex()),
 			r.Roots,
-			r.Space.String())
+			r.Space.String(),
+			quota)
 	}
-	x = fmt.Sprintf("{objects: %d, space: %s, feeds: [%s]}",
+	x = fmt.Sprintf("{objects: %d, space: %s, physical space: %s, "+
+		"feeds: [%s]",
 		s.Objects,
 		s.Space.String(),
+		s.PhysicalSpace.String(),
 		feeds)
+	if len(s.Namespaces) > 0 {
+		namespaces := ""
+		for ns, n := range s.Namespaces {
+			namespaces += fmt.Sprintf("<%q>{keys: %d, space: %s}",
+				ns,
+				n.Keys,
+				n.Space.String())
+		}
+		x += ", namespaces: [" + namespaces + "]"
+	}
+	if !s.Quota.IsZero() {
+		x += ", quota: " + s.Quota.String()
+	}
+	x += "}"
 	return
 }
 
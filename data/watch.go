@@ -0,0 +1,471 @@
+package data
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// An EventType represents type of an Event
+type EventType int
+
+// types of events
+const (
+	EventRootAdded   EventType = iota + 1 // root added
+	EventRootFull                         // root marked as full
+	EventRootDeleted                      // root deleted
+	EventFeedAdded                        // feed added
+	EventFeedDeleted                      // feed deleted
+	EventMiscChanged                      // misc-object set or deleted
+	EventOverflow                         // some events have been lost
+)
+
+// String implements fmt.Stringer interface
+func (e EventType) String() string {
+	switch e {
+	case EventRootAdded:
+		return "root added"
+	case EventRootFull:
+		return "root full"
+	case EventRootDeleted:
+		return "root deleted"
+	case EventFeedAdded:
+		return "feed added"
+	case EventFeedDeleted:
+		return "feed deleted"
+	case EventMiscChanged:
+		return "misc changed"
+	case EventOverflow:
+		return "overflow"
+	}
+	return fmt.Sprintf("EventType(%d)", int(e))
+}
+
+// An Event represents a change of a database. Events
+// are delivered after transaction commits
+type Event struct {
+	Type EventType     // type of the event
+	Feed cipher.PubKey // feed of feed and root events
+	Seq  uint64        // seq number of root events
+	Key  []byte        // key of misc-object
+	NS   string        // namespace of misc-object
+	Lost int           // number of lost events (EventOverflow)
+}
+
+// String implements fmt.Stringer interface
+func (e Event) String() string {
+	switch e.Type {
+	case EventRootAdded, EventRootFull, EventRootDeleted:
+		return fmt.Sprintf("%s {%s:%d}", e.Type, e.Feed.Hex()[:7], e.Seq)
+	case EventFeedAdded, EventFeedDeleted:
+		return fmt.Sprintf("%s %s", e.Type, e.Feed.Hex()[:7])
+	case EventMiscChanged:
+		if e.NS != "" {
+			return fmt.Sprintf("%s %q:%q", e.Type, e.NS, e.Key)
+		}
+		return fmt.Sprintf("%s %q", e.Type, e.Key)
+	case EventOverflow:
+		return fmt.Sprintf("%s (%d lost)", e.Type, e.Lost)
+	}
+	return e.Type.String()
+}
+
+// A WatchDB is DB that notifies about its changes
+type WatchDB interface {
+	DB
+
+	// Watch creates Watcher with given size of
+	// buffer of events. Only changes made through
+	// the WatchDB are reported
+	Watch(size int) (w *Watcher)
+}
+
+// A Watcher receives events of a WatchDB. If buffer of
+// the Watcher is full, then new events are dropped and
+// EventOverflow with number of lost events is delivered
+// as soon as the buffer has free space. Any consumer
+// should rescan database after the EventOverflow
+type Watcher struct {
+	db     *watchDB
+	events chan Event
+	lost   int // lost events (guarded by mutex of the db)
+}
+
+// Events returns channel of events. The channel
+// is closed after the Watcher or its DB is closed
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Close the Watcher. It's safe to close a
+// Watcher many times
+func (w *Watcher) Close() {
+	w.db.unwatch(w)
+}
+
+// send an event without blocking
+func (w *Watcher) send(e Event) {
+	if w.lost > 0 {
+		select {
+		case w.events <- Event{Type: EventOverflow, Lost: w.lost}:
+			w.lost = 0
+		default:
+			w.lost++
+			return
+		}
+	}
+	select {
+	case w.events <- e:
+	default:
+		w.lost++
+	}
+}
+
+type watchDB struct {
+	db DB
+
+	umx sync.Mutex // keeps order of events of Update
+
+	mx       sync.Mutex
+	watchers map[*Watcher]struct{}
+}
+
+// NewWatchDB wraps given DB to watch its changes.
+// Events are collected inside Update and delivered
+// to all Watchers after the transaction commits.
+// Objects are not watched. Closing returned DB closes
+// given and all Watchers
+func NewWatchDB(db DB) WatchDB {
+	return &watchDB{db: db, watchers: make(map[*Watcher]struct{})}
+}
+
+func (w *watchDB) Watch(size int) (wt *Watcher) {
+	wt = &Watcher{db: w, events: make(chan Event, size)}
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if w.watchers == nil {
+		close(wt.events) // closed DB
+		return
+	}
+	w.watchers[wt] = struct{}{}
+	return
+}
+
+func (w *watchDB) unwatch(wt *Watcher) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if _, ok := w.watchers[wt]; ok {
+		delete(w.watchers, wt)
+		close(wt.events)
+	}
+}
+
+func (w *watchDB) watched() bool {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	return len(w.watchers) > 0
+}
+
+func (w *watchDB) deliver(events []Event) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	for wt := range w.watchers {
+		for _, e := range events {
+			wt.send(e)
+		}
+	}
+}
+
+func (w *watchDB) View(fn func(t Tv) error) error {
+	return w.db.View(fn)
+}
+
+func (w *watchDB) Update(fn func(t Tu) error) (err error) {
+	if !w.watched() {
+		return w.db.Update(fn)
+	}
+
+	w.umx.Lock()
+	defer w.umx.Unlock()
+
+	var events []Event
+	err = w.db.Update(func(tx Tu) error {
+		events = events[:0]
+		return fn(&watchTu{tx, &events})
+	})
+	if err == nil && len(events) > 0 {
+		w.deliver(events)
+	}
+	return
+}
+
+// Batch doesn't lock other Update and Batch calls, thus
+// events of concurrent batches can be delivered in any order
+func (w *watchDB) Batch(fn func(t Tu) error) (err error) {
+	if !w.watched() {
+		return Batch(w.db, fn)
+	}
+
+	var events []Event
+	err = Batch(w.db, func(tx Tu) error {
+		events = events[:0]
+		return fn(&watchTu{tx, &events})
+	})
+	if err == nil && len(events) > 0 {
+		w.deliver(events)
+	}
+	return
+}
+
+func (w *watchDB) Stat() Stat {
+	return w.db.Stat()
+}
+
+func (w *watchDB) recountStat() error {
+	return RecountStat(w.db)
+}
+
+func (w *watchDB) Close() error {
+	w.mx.Lock()
+	for wt := range w.watchers {
+		close(wt.events)
+	}
+	w.watchers = nil
+	w.mx.Unlock()
+	return w.db.Close()
+}
+
+// events of current transaction
+type watchTu struct {
+	tx     Tu
+	events *[]Event
+}
+
+func (w *watchTu) Objects() UpdateObjects {
+	return w.tx.Objects()
+}
+
+func (w *watchTu) Feeds() UpdateFeeds {
+	return &watchFeeds{w.tx.Feeds(), w.events}
+}
+
+func (w *watchTu) Misc(namespace string) UpdateMisc {
+	return &watchMisc{w.tx.Misc(namespace), namespace, w.events}
+}
+
+func (w *watchTu) Namespaces() []string {
+	return w.tx.Namespaces()
+}
+
+// DelNamespace reports every deleted misc-object
+func (w *watchTu) DelNamespace(namespace string) (err error) {
+	var deleted [][]byte
+	err = w.tx.Misc(namespace).Ascend(func(key, _ []byte) (_ error) {
+		deleted = append(deleted, append([]byte{}, key...))
+		return
+	})
+	if err != nil {
+		return
+	}
+	if err = w.tx.DelNamespace(namespace); err != nil {
+		return
+	}
+	misc := &watchMisc{nil, namespace, w.events}
+	for _, key := range deleted {
+		misc.changed(key)
+	}
+	return
+}
+
+//
+// feeds
+//
+
+type watchFeeds struct {
+	UpdateFeeds
+	events *[]Event
+}
+
+func (w *watchFeeds) Add(pk cipher.PubKey) (err error) {
+	exist := w.UpdateFeeds.IsExist(pk)
+	if err = w.UpdateFeeds.Add(pk); err == nil && !exist {
+		*w.events = append(*w.events, Event{Type: EventFeedAdded, Feed: pk})
+	}
+	return
+}
+
+func (w *watchFeeds) Del(pk cipher.PubKey) (err error) {
+	exist := w.UpdateFeeds.IsExist(pk)
+	if err = w.UpdateFeeds.Del(pk); err == nil && exist {
+		*w.events = append(*w.events, Event{Type: EventFeedDeleted, Feed: pk})
+	}
+	return
+}
+
+func (w *watchFeeds) AscendDel(
+	fn func(pk cipher.PubKey) (bool, error)) (err error) {
+
+	var deleted []Event
+	err = w.UpdateFeeds.AscendDel(func(pk cipher.PubKey) (del bool,
+		err error) {
+
+		if del, err = fn(pk); del && err == nil {
+			deleted = append(deleted, Event{Type: EventFeedDeleted, Feed: pk})
+		}
+		return
+	})
+	if err == nil {
+		*w.events = append(*w.events, deleted...)
+	}
+	return
+}
+
+func (w *watchFeeds) Roots(pk cipher.PubKey) UpdateRoots {
+	if roots := w.UpdateFeeds.Roots(pk); roots != nil {
+		return &watchRoots{roots, w.events}
+	}
+	return nil
+}
+
+//
+// roots
+//
+
+type watchRoots struct {
+	UpdateRoots
+	events *[]Event
+}
+
+func (w *watchRoots) event(typ EventType, seq uint64) Event {
+	return Event{Type: typ, Feed: w.Feed(), Seq: seq}
+}
+
+func (w *watchRoots) Add(rp *RootPack) (err error) {
+	if err = w.UpdateRoots.Add(rp); err == nil {
+		*w.events = append(*w.events, w.event(EventRootAdded, rp.Seq))
+	}
+	return
+}
+
+func (w *watchRoots) Del(seq uint64) (err error) {
+	exist := w.UpdateRoots.Get(seq) != nil
+	if err = w.UpdateRoots.Del(seq); err == nil && exist {
+		*w.events = append(*w.events, w.event(EventRootDeleted, seq))
+	}
+	return
+}
+
+func (w *watchRoots) MarkFull(seq uint64) (err error) {
+	full := w.UpdateRoots.IsFull(seq)
+	if err = w.UpdateRoots.MarkFull(seq); err == nil && !full {
+		*w.events = append(*w.events, w.event(EventRootFull, seq))
+	}
+	return
+}
+
+func (w *watchRoots) SetMeta(seq uint64, rm RootMeta) (err error) {
+	full := w.UpdateRoots.IsFull(seq)
+	if err = w.UpdateRoots.SetMeta(seq, rm); err == nil && rm.IsFull &&
+		!full {
+
+		*w.events = append(*w.events, w.event(EventRootFull, seq))
+	}
+	return
+}
+
+func (w *watchRoots) AscendDel(
+	fn func(rp *RootPack) (bool, error)) (err error) {
+
+	var deleted []Event
+	err = w.UpdateRoots.AscendDel(func(rp *RootPack) (del bool, err error) {
+		if del, err = fn(rp); del && err == nil {
+			deleted = append(deleted, w.event(EventRootDeleted, rp.Seq))
+		}
+		return
+	})
+	if err == nil {
+		*w.events = append(*w.events, deleted...)
+	}
+	return
+}
+
+func (w *watchRoots) DelBefore(seq uint64) (err error) {
+	var deleted []Event
+	c := w.UpdateRoots.Cursor()
+	for rp := c.First(); rp != nil && rp.Seq < seq; rp = c.Next() {
+		deleted = append(deleted, w.event(EventRootDeleted, rp.Seq))
+	}
+	if err = w.UpdateRoots.DelBefore(seq); err == nil {
+		*w.events = append(*w.events, deleted...)
+	}
+	return
+}
+
+//
+// misc
+//
+
+type watchMisc struct {
+	UpdateMisc
+	ns     string
+	events *[]Event
+}
+
+func (w *watchMisc) changed(key []byte) {
+	*w.events = append(*w.events, Event{
+		Type: EventMiscChanged,
+		Key:  append([]byte{}, key...),
+		NS:   w.ns,
+	})
+}
+
+func (w *watchMisc) Set(key, value []byte) (err error) {
+	if err = w.UpdateMisc.Set(key, value); err == nil {
+		w.changed(key)
+	}
+	return
+}
+
+func (w *watchMisc) Del(key []byte) (err error) {
+	exist := w.UpdateMisc.Get(key) != nil
+	if err = w.UpdateMisc.Del(key); err == nil && exist {
+		w.changed(key)
+	}
+	return
+}
+
+func (w *watchMisc) delFunc(fn func(key, value []byte) (bool, error),
+	deleted *[][]byte) func(key, value []byte) (bool, error) {
+
+	return func(key, value []byte) (del bool, err error) {
+		if del, err = fn(key, value); del && err == nil {
+			*deleted = append(*deleted, append([]byte{}, key...))
+		}
+		return
+	}
+}
+
+func (w *watchMisc) AscendDel(
+	fn func(key, value []byte) (bool, error)) (err error) {
+
+	var deleted [][]byte
+	if err = w.UpdateMisc.AscendDel(w.delFunc(fn, &deleted)); err == nil {
+		for _, key := range deleted {
+			w.changed(key)
+		}
+	}
+	return
+}
+
+func (w *watchMisc) DescendDel(
+	fn func(key, value []byte) (bool, error)) (err error) {
+
+	var deleted [][]byte
+	if err = w.UpdateMisc.DescendDel(w.delFunc(fn, &deleted)); err == nil {
+		for _, key := range deleted {
+			w.changed(key)
+		}
+	}
+	return
+}
@@ -0,0 +1,217 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// receive all events from the Watcher
+func receiveEvents(w *Watcher) (events []Event) {
+	for {
+		select {
+		case e := <-w.Events():
+			events = append(events, e)
+		default:
+			return
+		}
+	}
+}
+
+func compareEvents(t *testing.T, want, got []Event) {
+	if len(want) != len(got) {
+		t.Errorf("wrong events: want %v, got %v", want, got)
+		return
+	}
+	for i, w := range want {
+		g := got[i]
+		if w.Type != g.Type || w.Feed != g.Feed || w.Seq != g.Seq ||
+			!bytes.Equal(w.Key, g.Key) || w.NS != g.NS || w.Lost != g.Lost {
+
+			t.Errorf("wrong events: want %v, got %v", want, got)
+			return
+		}
+	}
+}
+
+func TestNewWatchDB(t *testing.T) {
+	// NewWatchDB(db DB) WatchDB
+
+	pk, _ := cipher.GenerateKeyPair()
+	rp := getRootPack(0, "root")
+
+	t.Run("events", func(t *testing.T) {
+		db := NewWatchDB(NewMemoryDB())
+		defer db.Close()
+
+		w := db.Watch(32)
+
+		err := db.Update(func(tx Tu) (err error) {
+			feeds := tx.Feeds()
+			if err = feeds.Add(pk); err != nil {
+				return
+			}
+			if err = feeds.Add(pk); err != nil { // already exists
+				return
+			}
+			roots := feeds.Roots(pk)
+			if err = roots.Add(&rp); err != nil {
+				return
+			}
+			if err = roots.MarkFull(0); err != nil {
+				return
+			}
+			if err = roots.MarkFull(0); err != nil { // already full
+				return
+			}
+			if len(receiveEvents(w)) != 0 {
+				t.Error("events delivered before commit")
+			}
+			misc := tx.Misc("")
+			if err = misc.Set([]byte("k"), []byte("v")); err != nil {
+				return
+			}
+			return misc.Del([]byte("missing"))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareEvents(t, []Event{
+			{Type: EventFeedAdded, Feed: pk},
+			{Type: EventRootAdded, Feed: pk},
+			{Type: EventRootFull, Feed: pk},
+			{Type: EventMiscChanged, Key: []byte("k")},
+		}, receiveEvents(w))
+
+		err = db.Update(func(tx Tu) (err error) {
+			if err = tx.Misc("").DescendDel(func(_, _ []byte) (bool, error) {
+				return true, nil
+			}); err != nil {
+				return
+			}
+			if err = tx.Feeds().Roots(pk).DelBefore(1); err != nil {
+				return
+			}
+			return tx.Feeds().Del(pk)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareEvents(t, []Event{
+			{Type: EventMiscChanged, Key: []byte("k")},
+			{Type: EventRootDeleted, Feed: pk},
+			{Type: EventFeedDeleted, Feed: pk},
+		}, receiveEvents(w))
+	})
+
+	t.Run("namespaces", func(t *testing.T) {
+		db := NewWatchDB(NewMemoryDB())
+		defer db.Close()
+
+		w := db.Watch(32)
+
+		err := db.Update(func(tx Tu) (err error) {
+			misc := tx.Misc("ns")
+			for _, key := range []string{"a", "b"} {
+				if err = misc.Set([]byte(key), []byte("v")); err != nil {
+					return
+				}
+			}
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareEvents(t, []Event{
+			{Type: EventMiscChanged, Key: []byte("a"), NS: "ns"},
+			{Type: EventMiscChanged, Key: []byte("b"), NS: "ns"},
+		}, receiveEvents(w))
+
+		err = db.Update(func(tx Tu) (err error) {
+			if err = tx.DelNamespace("missing"); err != nil {
+				return
+			}
+			return tx.DelNamespace("ns")
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareEvents(t, []Event{
+			{Type: EventMiscChanged, Key: []byte("a"), NS: "ns"},
+			{Type: EventMiscChanged, Key: []byte("b"), NS: "ns"},
+		}, receiveEvents(w))
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		db := NewWatchDB(NewMemoryDB())
+		defer db.Close()
+
+		w := db.Watch(32)
+
+		errRollback := errors.New("rollback")
+		err := db.Update(func(tx Tu) (err error) {
+			if err = tx.Feeds().Add(pk); err != nil {
+				return
+			}
+			return errRollback
+		})
+		if err != errRollback {
+			t.Fatal("unexpected error:", err)
+		}
+		compareEvents(t, nil, receiveEvents(w))
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		db := NewWatchDB(NewMemoryDB())
+		defer db.Close()
+
+		w := db.Watch(2)
+
+		set := func(key string) {
+			err := db.Update(func(tx Tu) error {
+				return tx.Misc("").Set([]byte(key), nil)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, key := range []string{"a", "b", "c", "d"} {
+			set(key)
+		}
+		compareEvents(t, []Event{
+			{Type: EventMiscChanged, Key: []byte("a")},
+			{Type: EventMiscChanged, Key: []byte("b")},
+		}, receiveEvents(w))
+
+		set("e")
+		compareEvents(t, []Event{
+			{Type: EventOverflow, Lost: 2},
+			{Type: EventMiscChanged, Key: []byte("e")},
+		}, receiveEvents(w))
+	})
+
+	t.Run("close", func(t *testing.T) {
+		db := NewWatchDB(NewMemoryDB())
+
+		w1, w2 := db.Watch(1), db.Watch(1)
+		w1.Close()
+		w1.Close() // twice
+		if _, ok := <-w1.Events(); ok {
+			t.Error("channel of closed Watcher is not closed")
+		}
+
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := <-w2.Events(); ok {
+			t.Error("channel is not closed after DB closing")
+		}
+		if _, ok := <-db.Watch(1).Events(); ok {
+			t.Error("channel of closed DB is not closed")
+		}
+	})
+
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/skycoin/skycoin/src/cipher"
 
+	"github.com/skycoin/cxo/data"
 	"github.com/skycoin/cxo/node/gnet"
 	"github.com/skycoin/cxo/node/log"
 	"github.com/skycoin/cxo/skyobject"
@@ -26,8 +27,11 @@ const (
 	Listen         string = ""          // default listening address
 	EnableListener bool   = true        // listen by default
 	RemoteClose    bool   = false       // default remote-closing pin
+	RemoteBackup   bool   = false       // default remote-backup pin
+	RemoteRepair   bool   = false       // default remote-repair pin
 	RPCAddress     string = "[::]:8878" // default RPC address
 	InMemoryDB     bool   = false       // default database placement pin
+	LSMDB          bool   = false       // default database engine pin
 
 	// PingInterval is default interval by which server send pings
 	// to connections that doesn't communicate. Actually, the
@@ -36,13 +40,18 @@ const (
 	ResponseTimeout time.Duration = 5 * time.Second // default
 	PublicServer    bool          = false           // default
 
+	// SnapshotInterval is default interval of snapshots
+	// of in-memory database, see MemorySnapshot
+	SnapshotInterval time.Duration = time.Minute
+
 	// default tree is
 	//   server: ~/.skycoin/cxo/bolt.db
 
 	skycoinDataDir = ".skycoin"
 	cxoSubDir      = "cxo"
 
-	dbFile = "bolt.db"
+	dbFile    = "bolt.db"
+	backupDir = "backup"
 )
 
 // log pins
@@ -94,6 +103,14 @@ type Config struct {
 	// RemoteClose allows closing the
 	// server using RPC
 	RemoteClose bool
+	// RemoteBackup allows Backup and Restore using RPC.
+	// Archives are files in the BackupDir, and RPC
+	// clients can choose only names of the files
+	RemoteBackup bool
+	// BackupDir is directory of archives of RemoteBackup
+	BackupDir string
+	// RemoteRepair allows Check in repair mode using RPC
+	RemoteRepair bool
 
 	// PingInterval used to ping clients
 	// Set to 0 to disable pings
@@ -105,8 +122,38 @@ type Config struct {
 
 	// InMemoryDB uses database in memory
 	InMemoryDB bool
+	// MemorySnapshot is path to snapshot file of in-memory
+	// database. If it's not empty, then the database loaded
+	// from the file and saved to it on close and every
+	// SnapshotInterval (zero interval means on close only).
+	// See data.NewMemorySnapshotDB for details
+	MemorySnapshot string
+	// SnapshotInterval is interval of snapshots
+	SnapshotInterval time.Duration
+	// LSMDB uses write-optimised LSM-tree database
+	// instead of boltdb. The DBPath is path to
+	// directory of the database in this case
+	LSMDB bool
 	// DBPath is path to database file
 	DBPath string
+	// DriveOptions of drive (boltdb) database, nil
+	// means data.SafeDriveOptions. If the ReadOnly
+	// is set, then the Container is read-only too
+	DriveOptions *data.DriveOptions
+	// BlobThreshold is size in bytes. Values of objects larger
+	// than the threshold are stored as files in DataDir (or in
+	// directory of DBPath if the DataDir is empty). Zero means
+	// that all values are stored in database. It's ignored for
+	// in-memory database. See data.NewBlobDB for details
+	BlobThreshold int
+	// DBKeyFile is path to file with passphrase to encrypt
+	// database. If it's empty then database is not encrypted.
+	// See data.NewCryptDB for details
+	DBKeyFile string
+	// CompressLevel is compress/flate level of values of
+	// objects. Zero means that values are not compressed.
+	// See data.NewCompressDB for details
+	CompressLevel int
 	// DataDir is directory with data files
 	DataDir string
 
@@ -163,6 +210,11 @@ type Config struct {
 	// The callback never called for rejected
 	// Roots (including "already exists")
 	OnRootReceived func(n *Node, c *gnet.Conn, root *skyobject.Root)
+	// OnRootRejected is callback that called when
+	// a remote peer sends invalid Root object: wrong
+	// hash, signature, seq or broken chain of roots.
+	// The connection will be closed after the callback
+	OnRootRejected func(n *Node, c *gnet.Conn, err *data.RootError)
 	// OnRootFilled is callback that called when
 	// Client finishes filling received Root object
 	OnRootFilled func(n *Node, c *gnet.Conn, root *skyobject.Root)
@@ -176,6 +228,22 @@ type Config struct {
 	// save received object, the Root it fills malformed
 	// and can't be filled, etc
 	OnFillingBreaks func(n *Node, c *gnet.Conn, root *skyobject.Root, err error)
+
+	// database
+
+	// The callbacks built on top of callbacks of skyobject.Config
+	// and called after a change of database commits, no matter
+	// how the change was made: by the Node, by RPC or using
+	// Container directly. Callbacks of the Skyobject config
+	// are called too
+
+	// OnFeedAdded called when new feed added to database
+	OnFeedAdded func(n *Node, feed cipher.PubKey)
+	// OnFeedDeleted called when a feed removed from database
+	OnFeedDeleted func(n *Node, feed cipher.PubKey)
+	// OnRootDeleted called when a Root object removed
+	// from database, for example by CleanUp
+	OnRootDeleted func(n *Node, feed cipher.PubKey, seq uint64)
 }
 
 // NewConfig returns Config
@@ -189,10 +257,16 @@ func NewConfig() (sc Config) {
 	sc.Listen = Listen
 	sc.EnableListener = EnableListener
 	sc.RemoteClose = RemoteClose
+	sc.RemoteBackup = RemoteBackup
+	sc.RemoteRepair = RemoteRepair
 	sc.PingInterval = PingInterval
 	sc.InMemoryDB = InMemoryDB
+	sc.SnapshotInterval = SnapshotInterval
+	sc.LSMDB = LSMDB
 	sc.DataDir = dataDir()
 	sc.DBPath = filepath.Join(sc.DataDir, dbFile)
+	sc.BackupDir = filepath.Join(sc.DataDir, backupDir)
+	sc.DriveOptions = data.SafeDriveOptions()
 	sc.ResponseTimeout = ResponseTimeout
 	sc.PublicServer = PublicServer
 	sc.Config.OnDial = OnDialFilter
@@ -230,6 +304,18 @@ func (s *Config) FromFlags() {
 		"remote-close",
 		s.RemoteClose,
 		"allow closing the server using RPC")
+	flag.BoolVar(&s.RemoteBackup,
+		"remote-backup",
+		s.RemoteBackup,
+		"allow backup and restore using RPC")
+	flag.StringVar(&s.BackupDir,
+		"backup-dir",
+		s.BackupDir,
+		"directory of archives of remote backup")
+	flag.BoolVar(&s.RemoteRepair,
+		"remote-repair",
+		s.RemoteRepair,
+		"allow checking database in repair mode using RPC")
 	flag.DurationVar(&s.PingInterval,
 		"ping",
 		s.PingInterval,
@@ -238,6 +324,18 @@ func (s *Config) FromFlags() {
 		"mem-db",
 		s.InMemoryDB,
 		"use in-memory database")
+	flag.StringVar(&s.MemorySnapshot,
+		"mem-db-snapshot",
+		s.MemorySnapshot,
+		"path to snapshot file of in-memory database (empty = no snapshots)")
+	flag.DurationVar(&s.SnapshotInterval,
+		"mem-db-snapshot-interval",
+		s.SnapshotInterval,
+		"interval of snapshots of in-memory database (0 = on close only)")
+	flag.BoolVar(&s.LSMDB,
+		"lsm-db",
+		s.LSMDB,
+		"use LSM-tree database (the db-path is directory)")
 	flag.StringVar(&s.DataDir,
 		"data-dir",
 		s.DataDir,
@@ -246,6 +344,44 @@ func (s *Config) FromFlags() {
 		"db-path",
 		s.DBPath,
 		"path to database")
+	if s.DriveOptions == nil {
+		s.DriveOptions = data.SafeDriveOptions()
+	}
+	flag.Var(durabilityFlag{s.DriveOptions},
+		"db-durability",
+		"durability of drive database (safe or fast)")
+	flag.IntVar(&s.DriveOptions.InitialMmapSize,
+		"db-mmap-size",
+		s.DriveOptions.InitialMmapSize,
+		"initial mmap size of drive database in bytes (0 = default)")
+	flag.IntVar(&s.DriveOptions.MaxBatchSize,
+		"db-batch-size",
+		s.DriveOptions.MaxBatchSize,
+		"max size of batch of drive database (0 = no batching)")
+	flag.DurationVar(&s.DriveOptions.MaxBatchDelay,
+		"db-batch-delay",
+		s.DriveOptions.MaxBatchDelay,
+		"max delay of batch of drive database")
+	flag.BoolVar(&s.DriveOptions.ReadOnly,
+		"db-read-only",
+		s.DriveOptions.ReadOnly,
+		"open drive database in read-only mode")
+	flag.DurationVar(&s.DriveOptions.Timeout,
+		"db-timeout",
+		s.DriveOptions.Timeout,
+		"timeout to lock drive database (0 = forever)")
+	flag.IntVar(&s.BlobThreshold,
+		"db-blob-threshold",
+		s.BlobThreshold,
+		"store values larger than the threshold in files (0 = never)")
+	flag.StringVar(&s.DBKeyFile,
+		"db-key-file",
+		s.DBKeyFile,
+		"path to file with passphrase to encrypt database")
+	flag.IntVar(&s.CompressLevel,
+		"db-compress",
+		s.CompressLevel,
+		"compression level of values of objects (0 = no compression)")
 	flag.DurationVar(&s.ResponseTimeout,
 		"response-tm",
 		s.ResponseTimeout,
@@ -255,6 +391,46 @@ func (s *Config) FromFlags() {
 		s.PublicServer,
 		"make the server public")
 
+	// quotas
+
+	if s.Skyobject == nil {
+		s.Skyobject = skyobject.NewConfig()
+	}
+	flag.IntVar((*int)(&s.Skyobject.Quota.MaxSpace),
+		"quota-space",
+		int(s.Skyobject.Quota.MaxSpace),
+		"max space of all feeds in bytes (0 = unlimited)")
+	flag.IntVar(&s.Skyobject.Quota.MaxRoots,
+		"quota-roots",
+		s.Skyobject.Quota.MaxRoots,
+		"max number of root objects of all feeds (0 = unlimited)")
+	flag.DurationVar(&s.Skyobject.Quota.MaxAge,
+		"quota-age",
+		s.Skyobject.Quota.MaxAge,
+		"max age of root objects of all feeds (0 = unlimited)")
+	flag.IntVar((*int)(&s.Skyobject.FeedQuota.MaxSpace),
+		"feed-quota-space",
+		int(s.Skyobject.FeedQuota.MaxSpace),
+		"max space of a feed in bytes (0 = unlimited)")
+	flag.IntVar(&s.Skyobject.FeedQuota.MaxRoots,
+		"feed-quota-roots",
+		s.Skyobject.FeedQuota.MaxRoots,
+		"max number of root objects of a feed (0 = unlimited)")
+	flag.DurationVar(&s.Skyobject.FeedQuota.MaxAge,
+		"feed-quota-age",
+		s.Skyobject.FeedQuota.MaxAge,
+		"max age of root objects of a feed (0 = unlimited)")
+	flag.Var(&s.Skyobject.QuotaPolicy,
+		"quota-policy",
+		"reject new root objects or evict old ones (reject or evict)")
+
+	// cache
+
+	flag.IntVar(&s.Skyobject.CacheSize,
+		"cache-size",
+		s.Skyobject.CacheSize,
+		"max size of cache of objects in bytes (0 = disabled)")
+
 	// TODO: skyobejct.Configs from flags
 
 	return
@@ -284,3 +460,26 @@ func OnDialFilter(c *gnet.Conn, err error) (reject error) {
 	}
 	return // nil (unknowm case)
 }
+
+// durabilityFlag is flag.Value that sets
+// durability profile of data.DriveOptions
+type durabilityFlag struct {
+	opts *data.DriveOptions
+}
+
+// String implements flag.Value interface
+func (d durabilityFlag) String() string {
+	if d.opts == nil {
+		return data.SafeDurability.String()
+	}
+	return d.opts.Durability().String()
+}
+
+// Set implements flag.Value interface
+func (d durabilityFlag) Set(s string) (err error) {
+	var dur data.Durability
+	if err = dur.Set(s); err == nil {
+		d.opts.SetDurability(dur)
+	}
+	return
+}
@@ -454,10 +454,20 @@ func (e ErrInvalidMsgType) Error() string {
 	return fmt.Sprint("invalid message type: ", e.msgType.String())
 }
 
-// Encode given message to []byte prefixed by MsgType
+// Version of the protocol. Every encoded message prefixed
+// by the Version. Nodes with different versions can't
+// communicate. Changes:
+//
+//   - 2: RootPack of RootMsg doesn't contain IsFull and Space
+//
+const Version byte = 2
+
+// Encode given message to []byte prefixed
+// by protocol Version and MsgType
 func Encode(msg Msg) (p []byte) {
 	p = append(
 		[]byte{
+			Version,
 			byte(msg.MsgType()),
 		},
 		encoder.Serialize(msg)...)
@@ -465,23 +475,31 @@ func Encode(msg Msg) (p []byte) {
 }
 
 var (
-	// ErrEmptyMessage occurs when you
-	// try to Decode an empty slice
+	// ErrEmptyMessage occurs when you try to Decode
+	// an empty slice or a slice without MsgType
 	ErrEmptyMessage = errors.New("empty message")
+	// ErrVersionMismatch occurs when you try to Decode
+	// a message of another version of the protocol
+	ErrVersionMismatch = errors.New("protocol version mismatch")
 	// ErrIncomplieDecoding occurs when incoming message
 	// decoded correctly but the decoding doesn't use
 	// entire encoded message
 	ErrIncomplieDecoding = errors.New("incomplite decoding")
 )
 
-// Decode encoded MsgType-prefixed data to message.
-// It can returns encoding errors or ErrInvalidMsgType
+// Decode encoded Version and MsgType prefixed data to message.
+// It can returns encoding errors, ErrVersionMismatch or
+// ErrInvalidMsgType
 func Decode(p []byte) (msg Msg, err error) {
-	if len(p) < 1 {
+	if len(p) < 2 {
 		err = ErrEmptyMessage
 		return
 	}
-	mt := MsgType(p[0])
+	if p[0] != Version {
+		err = ErrVersionMismatch
+		return
+	}
+	mt := MsgType(p[1])
 	if mt <= 0 || int(mt) >= len(forwardRegistry) {
 		err = ErrInvalidMsgType{mt}
 		return
@@ -489,10 +507,10 @@ func Decode(p []byte) (msg Msg, err error) {
 	typ := forwardRegistry[mt]
 	val := reflect.New(typ)
 	var n int
-	if n, err = encoder.DeserializeRawToValue(p[1:], val); err != nil {
+	if n, err = encoder.DeserializeRawToValue(p[2:], val); err != nil {
 		return
 	}
-	if n+1 != len(p) {
+	if n+2 != len(p) {
 		err = ErrIncomplieDecoding
 		return
 	}
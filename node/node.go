@@ -3,6 +3,7 @@ package node
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -73,6 +74,104 @@ type Node struct {
 	await sync.WaitGroup
 }
 
+// encryptDB wraps given DB using passphrase from given
+// file. It closes the DB on failure
+func encryptDB(db data.DB, keyFile string) (cdb data.DB, err error) {
+	var passphrase []byte
+	if passphrase, err = data.ReadKeyFile(keyFile); err == nil {
+		cdb, err = data.NewCryptDB(db, passphrase)
+	}
+	if err != nil {
+		db.Close()
+	}
+	return
+}
+
+// blobDB wraps given DB to keep large values in files
+// of data directory. It closes the DB on failure
+func blobDB(db data.DB, sc Config) (bdb data.DB, err error) {
+	dir := sc.DataDir
+	if dir == "" {
+		dir = filepath.Dir(sc.DBPath)
+	}
+	if bdb, err = data.NewBlobDB(db, dir, sc.BlobThreshold); err != nil {
+		db.Close()
+	}
+	return
+}
+
+// driveOptions returns DriveOptions of given Config
+// that use skyobject.RootTime to migrate database
+func driveOptions(sc Config) (opts *data.DriveOptions) {
+	opts = data.SafeDriveOptions()
+	if sc.DriveOptions != nil {
+		*opts = *sc.DriveOptions
+	}
+	if opts.RootTime == nil {
+		opts.RootTime = skyobject.RootTime
+	}
+	return
+}
+
+// compressDB wraps given DB to compress values
+// of objects. It closes the DB on failure
+func compressDB(db data.DB, level int) (cdb data.DB, err error) {
+	if cdb, err = data.NewCompressDB(db, level); err != nil {
+		db.Close()
+	}
+	return
+}
+
+// NewDB creates database of a Node using given configurations
+// the same way the NewNode does. It can be used to open database
+// of a Node that is not running. The Skyobject and network
+// configurations are not used
+func NewDB(sc Config) (db data.DB, err error) {
+	if sc.InMemoryDB {
+		if sc.MemorySnapshot == "" {
+			db = data.NewMemoryDB()
+		} else if db, err = data.NewMemorySnapshotDB(sc.MemorySnapshot,
+			sc.SnapshotInterval); err != nil {
+
+			return
+		}
+	} else {
+		if sc.DataDir != "" {
+			if err = initDataDir(sc.DataDir); err != nil {
+				return
+			}
+		}
+		if sc.LSMDB {
+			db, err = data.NewLSMDB(sc.DBPath)
+		} else {
+			db, err = data.NewDriveDBOptions(sc.DBPath, driveOptions(sc))
+		}
+		if err != nil {
+			return
+		}
+		if sc.BlobThreshold > 0 {
+			if db, err = blobDB(db, sc); err != nil {
+				return
+			}
+		}
+	}
+
+	if sc.DBKeyFile != "" {
+		if db, err = encryptDB(db, sc.DBKeyFile); err != nil {
+			return
+		}
+	}
+
+	// compress before encrypting
+	if sc.CompressLevel != 0 {
+		if db, err = compressDB(db, sc.CompressLevel); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
 // NewNode creates new Node instnace using given
 // configurations. The functions creates database and
 // Container of skyobject instances internally. Use
@@ -91,32 +190,24 @@ func NewNode(sc Config) (s *Node,
 	// database
 
 	var db data.DB
-	if sc.InMemoryDB {
-		db = data.NewMemoryDB()
-	} else {
-		if sc.DataDir != "" {
-			if err = initDataDir(sc.DataDir); err != nil {
-				return
-			}
-		}
-		if db, err = data.NewDriveDB(sc.DBPath); err != nil {
-			return
-		}
+	if db, err = NewDB(sc); err != nil {
+		return
 	}
 
-	// container
-
-	var so *skyobject.Container
-	so = skyobject.NewContainer(db, sc.Skyobject)
-
 	// node instance
 
 	s = new(Node)
 
+	// container
+
+	var so *skyobject.Container
+	so = skyobject.NewContainer(data.NewWatchDB(db),
+		s.skyobjectConfig(sc))
+
 	s.Logger = log.NewLogger(sc.Log)
 	s.conf = sc
 
-	s.db = db
+	s.db = so.DB() // watched
 
 	s.so = so
 	s.feeds = make(map[cipher.PubKey]map[*gnet.Conn]struct{})
@@ -184,6 +275,53 @@ func NewNode(sc Config) (s *Node,
 	return
 }
 
+// skyobjectConfig returns copy of skyobject.Config
+// of given Config with callbacks of the Node
+func (s *Node) skyobjectConfig(sc Config) (conf *skyobject.Config) {
+	conf = skyobject.NewConfig()
+	if sc.Skyobject != nil {
+		*conf = *sc.Skyobject // copy
+	}
+
+	// read-only database
+	if sc.DriveOptions != nil && sc.DriveOptions.ReadOnly &&
+		!sc.InMemoryDB && !sc.LSMDB {
+
+		conf.ReadOnly = true
+	}
+
+	if sc.OnFeedAdded != nil {
+		ofa := conf.OnFeedAdded
+		conf.OnFeedAdded = func(c *skyobject.Container, feed cipher.PubKey) {
+			if ofa != nil {
+				ofa(c, feed)
+			}
+			sc.OnFeedAdded(s, feed)
+		}
+	}
+	if sc.OnFeedDeleted != nil {
+		ofd := conf.OnFeedDeleted
+		conf.OnFeedDeleted = func(c *skyobject.Container, feed cipher.PubKey) {
+			if ofd != nil {
+				ofd(c, feed)
+			}
+			sc.OnFeedDeleted(s, feed)
+		}
+	}
+	if sc.OnRootDeleted != nil {
+		ord := conf.OnRootDeleted
+		conf.OnRootDeleted = func(c *skyobject.Container, feed cipher.PubKey,
+			seq uint64) {
+
+			if ord != nil {
+				ord(c, feed, seq)
+			}
+			sc.OnRootDeleted(s, feed, seq)
+		}
+	}
+	return
+}
+
 func (s *Node) start() (err error) {
 	s.Debugf(log.All, `starting node:
     data dir:             %s
@@ -216,7 +354,9 @@ func (s *Node) start() (err error) {
     remote close:         %t
 
     in-memory DB:         %v
+    LSM-tree DB:          %v
     DB path:              %s
+    encrypted DB:         %v
 
     debug:                %#v
 `,
@@ -249,7 +389,9 @@ func (s *Node) start() (err error) {
 		s.conf.RemoteClose,
 
 		s.conf.InMemoryDB,
+		s.conf.LSMDB,
 		s.conf.DBPath,
+		s.conf.DBKeyFile != "",
 
 		s.conf.Log.Debug,
 	)
@@ -427,6 +569,16 @@ func (s *Node) dropRoot(c *gnet.Conn, dre *skyobject.Root, err error) {
 	}
 }
 
+// rejectRoot drops invalid root and closes connection that sent it,
+// because the connection sends forged or broken data
+func (s *Node) rejectRoot(c *gnet.Conn, err *data.RootError) {
+	s.Printf("[ERR] %s sent invalid root: %v", c.Address(), err)
+	if orr := s.conf.OnRootRejected; orr != nil {
+		orr(s, c, err)
+	}
+	c.Close()
+}
+
 func (s *Node) rootFilled(r *skyobject.Root, c *gnet.Conn) {
 	if orf := s.conf.OnRootFilled; orf != nil {
 		orf(s, c, r)
@@ -538,11 +690,29 @@ func (s *Node) sendLastFullRoot(c *gnet.Conn, feed cipher.PubKey) (sent bool) {
 	return
 }
 
-func (s *Node) handleSubscribeMsg(c *gnet.Conn, msg *SubscribeMsg) {
+// refill Root objects of given feed that lost some
+// objects, using given connection (see
+// (*skyobject.Container).Check)
+func (s *Node) refill(fill *filler, feed cipher.PubKey) {
+	rs, err := s.so.Refill(feed)
+	if err != nil {
+		s.Debugf(RootPin, "can't get roots of %s to refill: %v",
+			feed.Hex()[:7], err)
+		return
+	}
+	for _, r := range rs {
+		fill.fill(r)
+	}
+}
+
+func (s *Node) handleSubscribeMsg(c *gnet.Conn, fill *filler,
+	msg *SubscribeMsg) {
+
 	s.Debugln(SubscrPin, "handleSubscribeMsg", c.Address(), msg.Feed.Hex()[:7])
 
 	// (1) subscribe if the Node shares feed and send AcceptSubscriptionMsg back
-	//     and send latest full root of the feed if has
+	//     and send latest full root of the feed if has; and refill roots
+	//     that lost some objects
 	// (2) send AcceptSubscriptionMsg back if the connection already
 	//     subscibed to the feed
 	// (3) send  RejectSubscriptionMsg if the Node doesn't share feed
@@ -554,6 +724,7 @@ func (s *Node) handleSubscribeMsg(c *gnet.Conn, msg *SubscribeMsg) {
 		// (1)
 		if s.sendAcceptSubscriptionMsg(c, msg.ID(), msg.Feed) {
 			s.sendLastFullRoot(c, msg.Feed)
+			s.refill(fill, msg.Feed)
 		}
 		return
 	}
@@ -633,7 +804,7 @@ func (s *Node) addToResubscriptions(c *gnet.Conn, feed cipher.PubKey) {
 	}
 }
 
-func (s *Node) handleAcceptSubscriptionMsg(c *gnet.Conn,
+func (s *Node) handleAcceptSubscriptionMsg(c *gnet.Conn, fill *filler,
 	msg *AcceptSubscriptionMsg) {
 
 	s.Debugln(SubscrPin, "handleAcceptSubscriptionMsg", c.Address(),
@@ -659,6 +830,7 @@ func (s *Node) handleAcceptSubscriptionMsg(c *gnet.Conn,
 		// thus if the ok is true then we can ignore already,
 		// because it is false
 		s.sendLastFullRoot(c, msg.Feed)
+		s.refill(fill, msg.Feed)
 
 		// add the subscription to list of resubscribtions
 		// if connection fails
@@ -787,6 +959,14 @@ func (s *Node) handleRootMsg(c *gnet.Conn, fill *filler, msg *RootMsg) {
 			fill.fill(rbs) // fill it (already exist)
 			return
 		}
+		if re, ok := err.(*data.RootError); ok {
+			s.rejectRoot(c, re) // invalid root
+			return
+		}
+		if qe, ok := err.(*skyobject.QuotaError); ok {
+			s.Debug(RootPin, "reject root: ", qe) // valid, but not wanted
+			return
+		}
 		s.Debugf(RootPin, "error adding root {%s:%d}: %v",
 			msg.Feed.Hex()[:7], // } short
 			msg.RootPack.Seq,   // }
@@ -840,13 +1020,13 @@ func (s *Node) handleMsg(c *gnet.Conn, f *filler, msg Msg) {
 
 	// subscribe/unsubscribe
 	case *SubscribeMsg:
-		s.handleSubscribeMsg(c, x)
+		s.handleSubscribeMsg(c, f, x)
 	case *UnsubscribeMsg:
 		s.handleUnsubscribeMsg(c, x)
 
 	// relies for subscribing
 	case *AcceptSubscriptionMsg:
-		s.handleAcceptSubscriptionMsg(c, x)
+		s.handleAcceptSubscriptionMsg(c, f, x)
 	case *RejectSubscriptionMsg:
 		s.handleRejectSubscriptionMsg(c, x)
 
@@ -1287,7 +1467,7 @@ func (s *Node) Publish(r *skyobject.Root) {
 
 // Stat of underlying DB and Container
 func (s *Node) Stat() (st Stat) {
-	st.Data = s.DB().Stat()
+	st.Data = s.Container().DBStat()
 	st.CXO = s.Container().Stat()
 	return
 }
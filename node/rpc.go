@@ -2,8 +2,12 @@ package node
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"net/rpc"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/skycoin/skycoin/src/cipher"
@@ -78,7 +82,12 @@ func (r *rpcServer) Close() (err error) {
 // - Associate
 // - ListeningAddress
 // - Roots
+// - RootsByTime
 // - Tree
+// - Diff
+// - Backup
+// - Restore
+// - Check
 // - Terminate
 
 // A ConnFeed represetns connection->feed pair. The struct used
@@ -207,20 +216,68 @@ func (r *RPC) Roots(feed cipher.PubKey, roots *[]RootInfo) (_ error) {
 		if roots == nil {
 			return
 		}
-		return roots.Ascend(func(rp *data.RootPack) (err error) {
-			var ri RootInfo
-			var root *skyobject.Root
-			root, err = r.ns.Container().PackToRoot(feed, rp)
-			if err != nil {
-				return
-			}
-			ri.Hash = rp.Hash
-			ri.Time = time.Unix(0, root.Time)
-			ri.Seq = rp.Seq
-			ri.IsFull = rp.IsFull
-			rs = append(rs, ri)
+		return roots.Ascend(r.appendRootInfo(roots, &rs))
+	})
+	*roots = rs
+	return
+}
+
+// appendRootInfo returns function that appends
+// RootInfo of given RootPack to given list
+func (r *RPC) appendRootInfo(roots data.ViewRoots,
+	rs *[]RootInfo) func(rp *data.RootPack) error {
+
+	return func(rp *data.RootPack) (err error) {
+		var ri RootInfo
+		if ri, err = NewRootInfo(r.ns.Container(), roots, rp); err != nil {
+			return
+		}
+		*rs = append(*rs, ri)
+		return
+	}
+}
+
+// NewRootInfo returns RootInfo of given RootPack
+// of given roots (of a feed) using given Container
+func NewRootInfo(c *skyobject.Container, roots data.ViewRoots,
+	rp *data.RootPack) (ri RootInfo, err error) {
+
+	var root *skyobject.Root
+	if root, err = c.PackToRoot(roots.Feed(), rp); err != nil {
+		return
+	}
+	ri.Hash = rp.Hash
+	ri.Time = time.Unix(0, root.Time)
+	ri.Seq = rp.Seq
+	ri.IsFull = roots.IsFull(rp.Seq)
+	return
+}
+
+// A TimeRange used by RPC to select root objects of
+// a feed created in [From, To) range of time. Zero To
+// means no upper bound
+type TimeRange struct {
+	Feed cipher.PubKey
+	From time.Time
+	To   time.Time
+}
+
+// RootsByTime returns basic information about root objects of a
+// feed created in given range of time. It returns (by RPC) list
+// sorted by time from old roots to new
+func (r *RPC) RootsByTime(tr TimeRange, roots *[]RootInfo) (_ error) {
+	var to int64
+	if !tr.To.IsZero() {
+		to = tr.To.UnixNano()
+	}
+	rs := make([]RootInfo, 0)
+	r.ns.DB().View(func(tx data.Tv) (_ error) {
+		roots := tx.Feeds().Roots(tr.Feed)
+		if roots == nil {
 			return
-		})
+		}
+		return roots.RangeTime(tr.From.UnixNano(), to,
+			r.appendRootInfo(roots, &rs))
 	})
 	*roots = rs
 	return
@@ -229,10 +286,13 @@ func (r *RPC) Roots(feed cipher.PubKey, roots *[]RootInfo) (_ error) {
 type SelectRoot struct {
 	Pub      cipher.PubKey
 	Seq      uint64
-	LastFull bool // ignore the seq and print last full of the feed
+	LastFull bool   // ignore the seq and print last full of the feed
+	Path     string // print only values found by the path (optional)
 }
 
-// Tree prints objects tree of chosen root object (chosen by pk+seq)
+// Tree prints objects tree of chosen root object (chosen by pk+seq).
+// If the Path is not empty, then only subtrees of values found by the
+// Path are printed. See skyobject.Query for path syntax
 func (r *RPC) Tree(sel SelectRoot, tree *string) (err error) {
 	var root *skyobject.Root
 	if sel.LastFull {
@@ -245,11 +305,175 @@ func (r *RPC) Tree(sel SelectRoot, tree *string) (err error) {
 	}
 	if root == nil {
 		*tree = "<not found>"
+		return
+	}
+	if sel.Path != "" {
+		*tree, err = r.ns.so.InspectQuery(root, sel.Path)
+		return
 	}
 	*tree = r.ns.so.Inspect(root)
 	return
 }
 
+// A SelectDiff used by RPC to choose two root objects
+// of a feed to compare
+type SelectDiff struct {
+	Pub  cipher.PubKey
+	SeqA uint64 // old
+	SeqB uint64 // new
+}
+
+// Diff prints changes between two root objects of a feed
+func (r *RPC) Diff(sel SelectDiff, diff *string) (err error) {
+	*diff, err = Diff(r.ns.so, sel.Pub, sel.SeqA, sel.SeqB)
+	return
+}
+
+// Diff returns changes between root objects of given feed
+// with given seq numbers using given Container, a change
+// per line
+func Diff(c *skyobject.Container, pk cipher.PubKey, seqA,
+	seqB uint64) (diff string, err error) {
+
+	var a, b *skyobject.Root
+	if a, err = c.Root(pk, seqA); err != nil {
+		return
+	}
+	if b, err = c.Root(pk, seqB); err != nil {
+		return
+	}
+	var changes []skyobject.Change
+	if changes, err = c.Diff(a, b); err != nil {
+		return
+	}
+	if len(changes) == 0 {
+		return "<no changes>", nil
+	}
+	lines := make([]string, 0, len(changes))
+	for _, ch := range changes {
+		lines = append(lines, ch.String())
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// A BackupFile used by RPC to choose file and mode of backup
+type BackupFile struct {
+	Name        string // name of archive in BackupDir of the node
+	Incremental bool   // dump only roots newer then the Since
+	Since       uint64 // seq number for incremental mode
+}
+
+// backupPath returns path to archive with given name
+// if RemoteBackup is allowed. The name must be name
+// of a file, not a path
+func (r *RPC) backupPath(name string) (path string, err error) {
+	if !r.ns.conf.RemoteBackup {
+		err = errors.New("not allowed")
+		return
+	}
+	if r.ns.conf.BackupDir == "" {
+		err = errors.New("empty BackupDir")
+		return
+	}
+	if name == "" || name == "." || name == ".." || filepath.IsAbs(name) ||
+		filepath.Base(name) != name {
+
+		err = fmt.Errorf("invalid name of archive %q", name)
+		return
+	}
+	path = filepath.Join(r.ns.conf.BackupDir, name)
+	return
+}
+
+// Backup writes database of the Node to an archive file in
+// BackupDir of the Node if RemoteBackup is allowed. See
+// data.Dump and (*skyobject.Container).DumpIncremental
+// for details
+func (r *RPC) Backup(b BackupFile, _ *struct{}) (err error) {
+	var path string
+	if path, err = r.backupPath(b.Name); err != nil {
+		return
+	}
+	if err = os.MkdirAll(r.ns.conf.BackupDir, 0700); err != nil {
+		return
+	}
+	var fl *os.File
+	if fl, err = os.Create(path); err != nil {
+		return
+	}
+	if b.Incremental {
+		err = r.ns.Container().DumpIncremental(fl, b.Since)
+	} else {
+		err = data.Dump(r.ns.DB(), fl)
+	}
+	if err != nil {
+		fl.Close()
+		os.Remove(path)
+		return
+	}
+	return fl.Close()
+}
+
+// Restore reads archive file with given name from BackupDir
+// of the Node to database of the Node if RemoteBackup is
+// allowed. The node subscribes to restored feeds. See
+// (*skyobject.Container).Restore for details
+func (r *RPC) Restore(name string, _ *struct{}) (err error) {
+	var path string
+	if path, err = r.backupPath(name); err != nil {
+		return
+	}
+	var fl *os.File
+	if fl, err = os.Open(path); err != nil {
+		return
+	}
+	defer fl.Close()
+	if err = r.ns.Container().Restore(fl); err != nil {
+		return
+	}
+	var feeds []cipher.PubKey
+	r.ns.DB().View(func(tx data.Tv) (_ error) {
+		feeds = tx.Feeds().List()
+		return
+	})
+	for _, pk := range feeds {
+		r.ns.Subscribe(nil, pk)
+	}
+	return
+}
+
+// A CheckReply represents result of
+// (*skyobject.Container).Check
+type CheckReply struct {
+	Problems []string // found problems
+	Orphaned []string // orphaned registries
+	Unmarked int      // roots marked as non-full (repair mode)
+	Removed  int      // removed objects (repair mode)
+}
+
+// Check verifies database of the Node. If repair is true,
+// then broken objects removed and broken full roots marked
+// as non-full. The repair mode is allowed only if RemoteRepair
+// is set. See (*skyobject.Container).Check for details
+func (r *RPC) Check(repair bool, reply *CheckReply) (err error) {
+	if repair && !r.ns.conf.RemoteRepair {
+		err = errors.New("not allowed")
+		return
+	}
+	var rep *skyobject.CheckReport
+	if rep, err = r.ns.Container().Check(repair); err != nil {
+		return
+	}
+	for _, p := range rep.Problems {
+		reply.Problems = append(reply.Problems, p.Error())
+	}
+	for _, rr := range rep.Orphaned {
+		reply.Orphaned = append(reply.Orphaned, rr.String())
+	}
+	reply.Unmarked, reply.Removed = rep.Unmarked, rep.Removed
+	return
+}
+
 // Terminate remote Node if allowed by it s configurations
 func (r *RPC) Terminate(_ struct{}, _ *struct{}) (err error) {
 	if !r.ns.conf.RemoteClose {
@@ -2,6 +2,7 @@ package node
 
 import (
 	"net/rpc"
+	"time"
 
 	"github.com/skycoin/skycoin/src/cipher"
 )
@@ -102,12 +103,64 @@ func (r *RPCClient) Roots(feed cipher.PubKey) (ris []RootInfo, err error) {
 	return
 }
 
+// RootsByTime returns brief information about root objects of a
+// feed created in [from, to) range of time. Zero to means no upper
+// bound
+func (r *RPCClient) RootsByTime(feed cipher.PubKey,
+	from, to time.Time) (ris []RootInfo, err error) {
+
+	err = r.c.Call("cxo.RootsByTime", TimeRange{feed, from, to}, &ris)
+	return
+}
+
 // Tree returns strigified objects tree of a root object. The
 // method useful for inspecting
 func (r *RPCClient) Tree(pk cipher.PubKey, seq uint64,
 	lastFull bool) (tree string, err error) {
 
-	err = r.c.Call("cxo.Tree", SelectRoot{pk, seq, lastFull}, &tree)
+	err = r.c.Call("cxo.Tree", SelectRoot{pk, seq, lastFull, ""}, &tree)
+	return
+}
+
+// TreePath returns strigified subtrees of values of a root object
+// found by given path. See skyobject.Query for path syntax
+func (r *RPCClient) TreePath(pk cipher.PubKey, seq uint64, lastFull bool,
+	path string) (tree string, err error) {
+
+	err = r.c.Call("cxo.Tree", SelectRoot{pk, seq, lastFull, path}, &tree)
+	return
+}
+
+// Diff returns changes between root objects of a feed
+// with given seq numbers, a change per line
+func (r *RPCClient) Diff(pk cipher.PubKey, seqA, seqB uint64) (diff string,
+	err error) {
+
+	err = r.c.Call("cxo.Diff", SelectDiff{pk, seqA, seqB}, &diff)
+	return
+}
+
+// Backup database of the node to file with given name in
+// BackupDir of the node. If incremental is true, then only
+// roots with seq greater then given since are written
+func (r *RPCClient) Backup(name string, incremental bool,
+	since uint64) (err error) {
+
+	err = r.c.Call("cxo.Backup", BackupFile{name, incremental, since}, &struct{}{})
+	return
+}
+
+// Restore database of the node from archive file with given
+// name in BackupDir of the node
+func (r *RPCClient) Restore(name string) (err error) {
+	err = r.c.Call("cxo.Restore", name, &struct{}{})
+	return
+}
+
+// Check database of the node. If repair is true, then broken
+// objects are removed and broken full roots are marked as non-full
+func (r *RPCClient) Check(repair bool) (reply CheckReply, err error) {
+	err = r.c.Call("cxo.Check", repair, &reply)
 	return
 }
 
@@ -0,0 +1,109 @@
+package skyobject
+
+import (
+	"io"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+// DumpIncremental writes roots of the Container with seq number
+// greater than given and objects of the roots that are not
+// objects of older roots. See data.DumpIncremental for details
+func (c *Container) DumpIncremental(w io.Writer, since uint64) error {
+	return data.DumpIncremental(c.DB(), w, since, c.objectsOf)
+}
+
+// objectsOf is data.ObjectsOfFunc
+func (c *Container) objectsOf(objs data.ViewObjects, pk cipher.PubKey,
+	rp *data.RootPack, fn func(cipher.SHA256) (bool, error)) (err error) {
+
+	var r *Root
+	if r, err = c.unpackRoot(pk, rp); err != nil {
+		return
+	}
+	return c.knowsAbout(r, objs, fn)
+}
+
+// Restore loads archive created by data.Dump or DumpIncremental
+// to database of the Container. Unlike the data.Restore, the
+// method can be used with working Container. It locks the
+// Container the same way the CleanUp does, verifies restored
+// roots (hash, signature, seq and prev. reference) removing
+// invalid, and recounts references counters of objects
+func (c *Container) Restore(r io.Reader) (err error) {
+	c.Debug(VerbosePin, "Restore")
+
+	c.cleanmx.Lock()
+	defer c.cleanmx.Unlock()
+
+	// roots before the restoring (they are verified already)
+	known := make(map[cipher.PubKey]map[uint64]struct{})
+	err = c.DB().View(func(tx data.Tv) error {
+		return c.ascendRoots(tx, func(pk cipher.PubKey, rp *data.RootPack) {
+			if known[pk] == nil {
+				known[pk] = make(map[uint64]struct{})
+			}
+			known[pk][rp.Seq] = struct{}{}
+		})
+	})
+	if err != nil {
+		return
+	}
+
+	if err = data.Restore(c.DB(), r); err != nil {
+		return
+	}
+
+	// verify restored roots
+
+	bad := make(map[cipher.PubKey][]uint64)
+	err = c.DB().View(func(tx data.Tv) error {
+		return c.ascendRoots(tx, func(pk cipher.PubKey, rp *data.RootPack) {
+			if _, ok := known[pk][rp.Seq]; ok {
+				return
+			}
+			if _, verr := c.verifyRootPack(pk, rp); verr != nil {
+				c.Printf("[ERR] remove restored root: %v", verr)
+				bad[pk] = append(bad[pk], rp.Seq)
+			}
+		})
+	})
+	if err != nil {
+		return
+	}
+	if len(bad) > 0 {
+		err = c.DB().Update(func(tx data.Tu) (err error) {
+			feeds := tx.Feeds()
+			for pk, seqs := range bad {
+				roots := feeds.Roots(pk)
+				for _, seq := range seqs {
+					if err = roots.Del(seq); err != nil {
+						return
+					}
+				}
+			}
+			return
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = c.recount()
+	return
+}
+
+// ascendRoots calls given function for every Root in DB
+func (c *Container) ascendRoots(tx data.Tv,
+	fn func(pk cipher.PubKey, rp *data.RootPack)) error {
+
+	feeds := tx.Feeds()
+	return feeds.Ascend(func(pk cipher.PubKey) error {
+		return feeds.Roots(pk).Ascend(func(rp *data.RootPack) (_ error) {
+			fn(pk, rp)
+			return
+		})
+	})
+}
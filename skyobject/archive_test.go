@@ -0,0 +1,137 @@
+package skyobject
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+func TestContainer_DumpIncremental(t *testing.T) {
+	// DumpIncremental(w io.Writer, since uint64) error
+
+	c := getCont()
+	defer c.db.Close()
+	defer c.Close()
+
+	pk, sk := cipher.GenerateKeyPair()
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := &User{Name: "Alice"}
+	testSaveUsers(t, c, pk, sk, alice)
+	r := testSaveUsers(t, c, pk, sk, alice, &User{Name: "Eva"})
+
+	var buf bytes.Buffer
+	if err := c.DumpIncremental(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	db := data.NewMemoryDB()
+	defer db.Close()
+	if err := data.Restore(db, &buf); err != nil {
+		t.Fatal(err)
+	}
+	err := db.View(func(tx data.Tv) (_ error) {
+		objs := tx.Objects()
+		if objs.IsExist(r.Refs[0].Object) {
+			t.Error("object of old root archived")
+		}
+		if !objs.IsExist(r.Refs[1].Object) {
+			t.Error("missing new object")
+		}
+		if roots := tx.Feeds().Roots(pk); roots == nil {
+			t.Error("missing feed")
+		} else if roots.Get(r.Seq-1) != nil || roots.Get(r.Seq) == nil {
+			t.Error("wrong roots")
+		}
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContainer_Restore(t *testing.T) {
+	// Restore(r io.Reader) (err error)
+
+	src := getCont()
+	defer src.db.Close()
+	defer src.Close()
+
+	pk, sk := cipher.GenerateKeyPair()
+	if err := src.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := &User{Name: "Alice"}
+	r := testSaveUsers(t, src, pk, sk, alice)
+	testSaveUsers(t, src, pk, sk, alice)
+
+	var full, inc bytes.Buffer
+	if err := data.Dump(src.DB(), &full); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.DumpIncremental(&inc, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := getCont()
+	defer dst.db.Close()
+	defer dst.Close()
+
+	archive := full.Bytes()
+	if err := dst.Restore(bytes.NewReader(archive)); err != nil {
+		t.Fatal(err)
+	}
+	testRefs(t, dst, r.Refs[0].Object, 2)
+
+	// restore again, and the incremental archive
+	if err := dst.Restore(bytes.NewReader(archive)); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Restore(&inc); err != nil {
+		t.Fatal(err)
+	}
+	testRefs(t, dst, r.Refs[0].Object, 2)
+
+	t.Run("invalid root", func(t *testing.T) {
+		db := data.NewMemoryDB()
+		defer db.Close()
+
+		pk, _ := cipher.GenerateKeyPair()
+		err := db.Update(func(tx data.Tu) (err error) {
+			if err = tx.Feeds().Add(pk); err != nil {
+				return
+			}
+			rp := &data.RootPack{Root: []byte("forged")}
+			rp.Hash = cipher.SumSHA256(rp.Root)
+			return tx.Feeds().Roots(pk).Add(rp)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err = data.Dump(db, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if err = dst.Restore(&buf); err != nil {
+			t.Fatal(err)
+		}
+		err = dst.DB().View(func(tx data.Tv) (_ error) {
+			if roots := tx.Feeds().Roots(pk); roots == nil {
+				t.Error("missing feed")
+			} else if roots.Last() != nil {
+				t.Error("invalid root restored")
+			}
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+}
@@ -0,0 +1,112 @@
+package skyobject
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// cache is size-bounded LRU cache of encoded objects
+// used by (*Container).Get, (*Pack).get and Filler.
+// Size of the cache is sum of lengths of values.
+// Values of the cache must not be modified
+type cache struct {
+	mx sync.Mutex
+
+	max  int // max size in bytes, zero means disabled
+	size int // current size in bytes
+
+	ll    *list.List // front is most recently used
+	items map[cipher.SHA256]*list.Element
+
+	// gen is generation of the cache, that increased
+	// every time objects removed from the cache; a value
+	// read from database before a removal can't be put
+	// to the cache after the removal
+	gen uint64
+
+	hits, misses uint64
+}
+
+// cache entry
+type cacheItem struct {
+	key cipher.SHA256
+	val []byte
+}
+
+func (c *cache) init(max int) {
+	c.max = max
+	c.ll = list.New()
+	c.items = make(map[cipher.SHA256]*list.Element)
+}
+
+// get value from the cache; the gen is current
+// generation of the cache that should be passed
+// to put after reading the value from database
+func (c *cache) get(key cipher.SHA256) (val []byte, gen uint64, ok bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.max == 0 {
+		return
+	}
+
+	var el *list.Element
+	if el, ok = c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		c.hits++
+		return el.Value.(*cacheItem).val, c.gen, true
+	}
+	c.misses++
+	return nil, c.gen, false
+}
+
+// put value to the cache if generation of the cache
+// is still the same; the value must not be modified
+// after; values bigger then the cache are ignored
+func (c *cache) put(key cipher.SHA256, val []byte, gen uint64) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if gen != c.gen || len(val) > c.max {
+		return // also, if the cache is disabled
+	}
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el) // the same value
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheItem{key, val})
+	c.size += len(val)
+	for c.size > c.max {
+		c.remove(c.ll.Back())
+	}
+}
+
+// del removes given objects from the cache
+func (c *cache) del(keys []cipher.SHA256) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.gen++
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+// remove element, the mx must be locked
+func (c *cache) remove(el *list.Element) {
+	ci := c.ll.Remove(el).(*cacheItem)
+	delete(c.items, ci.key)
+	c.size -= len(ci.val)
+}
+
+// stat returns hits and misses
+func (c *cache) stat() (hits, misses uint64) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return c.hits, c.misses
+}
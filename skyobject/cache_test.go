@@ -0,0 +1,118 @@
+package skyobject
+
+import (
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func Test_cache(t *testing.T) {
+
+	var c cache
+	c.init(10)
+
+	a, b, d := cipher.SumSHA256([]byte("a")), cipher.SumSHA256([]byte("b")),
+		cipher.SumSHA256([]byte("d"))
+
+	_, gen, ok := c.get(a)
+	if ok {
+		t.Fatal("got from empty cache")
+	}
+	c.put(a, []byte("aaaa"), gen)
+	c.put(b, []byte("bbbb"), gen)
+
+	if val, _, ok := c.get(a); !ok || string(val) != "aaaa" {
+		t.Error("missing or wrong value")
+	}
+
+	// the b is least recently used
+	c.put(d, []byte("dddd"), gen)
+	if _, _, ok := c.get(b); ok {
+		t.Error("not evicted")
+	}
+	if _, _, ok := c.get(a); !ok {
+		t.Error("evicted recently used")
+	}
+	if c.size != 8 {
+		t.Error("wrong size:", c.size)
+	}
+
+	// too big
+	c.put(b, make([]byte, 11), gen)
+	if _, _, ok := c.get(b); ok {
+		t.Error("cached value bigger then the cache")
+	}
+
+	// del and generations
+	_, gen, _ = c.get(b)
+	c.del([]cipher.SHA256{a, b})
+	if _, _, ok := c.get(a); ok {
+		t.Error("not deleted")
+	}
+	c.put(b, []byte("bbbb"), gen) // read before the del
+	if _, _, ok := c.get(b); ok {
+		t.Error("cached value of previous generation")
+	}
+
+	if hits, misses := c.stat(); hits != 2 || misses != 6 {
+		t.Errorf("wrong hits and misses: %d, %d", hits, misses)
+	}
+
+	// disabled
+	var z cache
+	z.init(0)
+	z.put(a, []byte("a"), 0)
+	if _, _, ok := z.get(a); ok {
+		t.Error("disabled cache used")
+	}
+	if hits, misses := z.stat(); hits != 0 || misses != 0 {
+		t.Error("stat of disabled cache")
+	}
+
+}
+
+func TestContainer_cache(t *testing.T) {
+
+	c := getCont()
+	defer c.db.Close()
+	defer c.Close()
+
+	val := []byte("value")
+	hash := cipher.SumSHA256(val)
+	if err := c.Set(hash, val); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if string(c.Get(hash)) != string(val) {
+			t.Fatal("missing or wrong value")
+		}
+	}
+
+	if s := c.Stat(); s.CacheHits != 1 || s.CacheMisses != 1 {
+		t.Errorf("wrong stat: %d hits, %d misses", s.CacheHits,
+			s.CacheMisses)
+	} else if s.CacheRatio() != 0.5 {
+		t.Error("wrong ratio:", s.CacheRatio())
+	}
+
+	// object of root removed by CleanUp
+	pk, sk := cipher.GenerateKeyPair()
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+	r := testSaveUsers(t, c, pk, sk, &User{Name: "Alice"})
+	testSaveUsers(t, c, pk, sk, &User{Name: "Eve"})
+
+	obj := r.Refs[0].Object
+	if c.Get(obj) == nil {
+		t.Fatal("missing object")
+	}
+	if err := c.CleanUp(false); err != nil {
+		t.Fatal(err)
+	}
+	if c.Get(obj) != nil {
+		t.Error("removed object got from cache")
+	}
+
+}
@@ -0,0 +1,245 @@
+package skyobject
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+
+	"github.com/skycoin/cxo/data"
+)
+
+// A CheckReport represents result of (*Container).Check
+type CheckReport struct {
+	// Problems found. They are *data.ObjectError
+	// and *data.RootError
+	Problems []error
+	// Orphaned registries of the Container and registries
+	// stored in database that are not used by any Root
+	// object (except core registry)
+	Orphaned []RegistryRef
+
+	// repair mode
+
+	Unmarked int // number of full roots marked as non-full
+	Removed  int // number of removed broken objects
+}
+
+// a broken full Root
+type brokenRoot struct {
+	feed cipher.PubKey
+	seq  uint64
+}
+
+// Check is fsck-like tool that verifies database of the Container.
+// It performs data.Check and walks all full Root objects through
+// their registries to confirm that all references resolve. In
+// repair mode, the Check removes broken objects and marks full
+// Root objects with missing or broken objects as non-full, to be
+// filled again (see data.RootMeta.Refill and Refill method). After
+// that it recounts references counters and statistic counters of
+// the database (see data.RecountStat). Root
+// objects that can't be verified (wrong hash, signature, seq or
+// broken chain) are never changed. The err is error of database
+func (c *Container) Check(repair bool) (rep *CheckReport, err error) {
+	c.Debug(VerbosePin, "Check")
+
+	rep = new(CheckReport)
+
+	if rep.Problems, err = data.Check(c.DB()); err != nil {
+		return
+	}
+
+	bad := make(map[cipher.SHA256]struct{})  // broken objects
+	invalid := make(map[brokenRoot]struct{}) // invalid roots
+	regs := make(map[RegistryRef]struct{})   // used registries
+	var broken []brokenRoot                  // broken full roots
+
+	for _, p := range rep.Problems {
+		switch pe := p.(type) {
+		case *data.ObjectError:
+			bad[pe.Key()] = struct{}{}
+		case *data.RootError:
+			invalid[brokenRoot{pe.Feed(), pe.Seq()}] = struct{}{}
+		}
+	}
+
+	stored := make(map[RegistryRef]struct{}) // stored registries
+
+	err = c.DB().View(func(tx data.Tv) (err error) {
+		objs := tx.Objects()
+		err = objs.Ascend(func(key cipher.SHA256, val []byte) (_ error) {
+			if isRegistry(val) {
+				stored[RegistryRef(key)] = struct{}{}
+			}
+			return
+		})
+		if err != nil {
+			return
+		}
+		feeds := tx.Feeds()
+		return feeds.Ascend(func(pk cipher.PubKey) error {
+			roots := feeds.Roots(pk)
+			return roots.Ascend(func(rp *data.RootPack) (_ error) {
+				if _, ok := invalid[brokenRoot{pk, rp.Seq}]; ok {
+					return // already reported
+				}
+				r, err := c.unpackRoot(pk, rp)
+				if err != nil {
+					rep.Problems = append(rep.Problems,
+						data.NewRootError(pk, rp, err.Error()))
+					return
+				}
+				regs[r.Reg] = struct{}{}
+				if !roots.IsFull(rp.Seq) {
+					return // nothing to check
+				}
+				if descr := c.checkRoot(r, objs, bad); descr != "" {
+					rep.Problems = append(rep.Problems,
+						data.NewRootError(pk, rp, descr))
+					broken = append(broken, brokenRoot{pk, rp.Seq})
+				}
+				return
+			})
+		})
+	})
+	if err != nil {
+		return
+	}
+
+	var core RegistryRef
+	if cr := c.CoreRegistry(); cr != nil {
+		core = cr.Reference()
+	}
+
+	c.rmx.RLock()
+	for rr := range c.regs {
+		stored[rr] = struct{}{}
+	}
+	c.rmx.RUnlock()
+
+	for rr := range stored {
+		if _, ok := regs[rr]; !ok && rr != core {
+			rep.Orphaned = append(rep.Orphaned, rr)
+		}
+	}
+
+	sort.Slice(rep.Orphaned, func(i, j int) bool {
+		return bytes.Compare(rep.Orphaned[i][:], rep.Orphaned[j][:]) < 0
+	})
+
+	if !repair || (len(bad) == 0 && len(broken) == 0) {
+		return
+	}
+
+	if err = c.repair(bad, broken, rep); err != nil {
+		return
+	}
+	return rep, data.RecountStat(c.DB())
+}
+
+// isRegistry returns true if given value is encoded Registry
+func isRegistry(val []byte) bool {
+	var res registryEntities
+	if err := encoder.DeserializeRaw(val, &res); err != nil {
+		return false
+	}
+	if len(res) == 0 || !bytes.Equal(encoder.Serialize(res), val) {
+		return false
+	}
+	for _, re := range res {
+		if _, err := decodeSchema(re.Schema); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// checkRoot walks given full Root and returns description
+// of first problem found or empty string
+func (c *Container) checkRoot(r *Root, objs data.ViewObjects,
+	bad map[cipher.SHA256]struct{}) (descr string) {
+
+	if c.registryOf(r.Reg, objs) == nil {
+		return "missing registry " + r.Reg.Short()
+	}
+
+	seen := make(map[cipher.SHA256]struct{})
+	err := c.knowsAbout(r, objs, func(hash cipher.SHA256) (bool, error) {
+		if _, ok := bad[hash]; ok {
+			descr = "broken object " + hash.Hex()[:7]
+			return false, ErrStopIteration
+		}
+		if !objs.IsExist(hash) {
+			descr = "missing object " + hash.Hex()[:7]
+			return false, ErrStopIteration
+		}
+		if _, ok := seen[hash]; ok {
+			return false, nil // already checked
+		}
+		seen[hash] = struct{}{}
+		return true, nil
+	})
+	if err != nil && err != ErrStopIteration {
+		descr = err.Error()
+	}
+	return
+}
+
+// repair removes given broken objects, marks given broken
+// roots as non-full to be filled again and recounts
+// references counters of objects
+func (c *Container) repair(bad map[cipher.SHA256]struct{},
+	broken []brokenRoot, rep *CheckReport) error {
+
+	c.cleanmx.Lock()
+	defer c.cleanmx.Unlock()
+
+	var (
+		unmarked, removed int
+		freed             []cipher.SHA256
+	)
+
+	err := c.DB().Update(func(tx data.Tu) (err error) {
+		objs := tx.Objects()
+		for key := range bad {
+			if err = objs.Del(key); err != nil {
+				return
+			}
+			freed = append(freed, key)
+			removed++
+		}
+		feeds := tx.Feeds()
+		for _, br := range broken {
+			roots := feeds.Roots(br.feed)
+			var rm data.RootMeta
+			if rm, err = roots.Meta(br.seq); err != nil {
+				if err == data.ErrNotFound {
+					err = nil
+					continue
+				}
+				return
+			}
+			if !rm.IsFull {
+				continue
+			}
+			rm.IsFull, rm.Refill = false, true
+			if err = roots.SetMeta(br.seq, rm); err != nil {
+				return
+			}
+			unmarked++
+		}
+		return
+	})
+
+	if err != nil {
+		return err
+	}
+
+	rep.Unmarked, rep.Removed = unmarked, removed
+	c.forgetObjects(freed)
+
+	_, err = c.recount() // non-full roots are not counted
+	return err
+}
@@ -0,0 +1,133 @@
+package skyobject
+
+import (
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+func TestContainer_Check(t *testing.T) {
+	// Check(repair bool) (rep *CheckReport, err error)
+
+	c := getCont()
+	defer c.db.Close()
+	defer c.Close()
+
+	pk, sk := cipher.GenerateKeyPair()
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	r := testSaveUsers(t, c, pk, sk, &User{Name: "Alice"})
+	if err := c.MarkFull(r); err != nil {
+		t.Fatal(err)
+	}
+
+	orphan := NewRegistry(func(r *Reg) {
+		r.Register("cxo.Developer", Developer{})
+	})
+	if err := c.AddRegistry(orphan); err != nil {
+		t.Fatal(err)
+	}
+
+	// stored, but unknown for the Container
+	stored := NewRegistry(func(r *Reg) {
+		r.Register("cxo.User", User{})
+	})
+	err := c.DB().Update(func(tx data.Tu) error {
+		return tx.Objects().Set(cipher.SHA256(stored.Reference()),
+			stored.Encode())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := c.Check(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Problems) != 0 {
+		t.Error("unexpected problems:", rep.Problems)
+	}
+	if len(rep.Orphaned) != 2 {
+		t.Error("wrong orphaned registries:", rep.Orphaned)
+	}
+	for _, rr := range []RegistryRef{orphan.Reference(), stored.Reference()} {
+		var found bool
+		for _, or := range rep.Orphaned {
+			found = found || or == rr
+		}
+		if !found {
+			t.Error("missing orphaned registry", rr.Short())
+		}
+	}
+
+	// remove Alice
+	alice := c.Get(r.Refs[0].Object)
+	err = c.DB().Update(func(tx data.Tu) error {
+		return tx.Objects().Del(r.Refs[0].Object)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rep, err = c.Check(false); err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Problems) != 1 {
+		t.Fatal("wrong problems:", rep.Problems)
+	}
+	if re, ok := rep.Problems[0].(*data.RootError); !ok || re.Seq() != 0 {
+		t.Error("wrong problem:", rep.Problems[0])
+	}
+	if rep.Unmarked != 0 {
+		t.Error("changed without repair mode")
+	}
+
+	// repair
+	if rep, err = c.Check(true); err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Problems) != 1 || rep.Unmarked != 1 || rep.Removed != 0 {
+		t.Errorf("wrong report: %v, %d, %d", rep.Problems, rep.Unmarked,
+			rep.Removed)
+	}
+	if _, full, err := c.RootBySeq(pk, 0); err != nil {
+		t.Fatal(err)
+	} else if full {
+		t.Error("broken root is not unmarked")
+	}
+
+	// should be kept to be filled again
+	if err = c.removeNonFullRoots(); err != nil {
+		t.Fatal(err)
+	}
+	if rs, err := c.Refill(pk); err != nil {
+		t.Fatal(err)
+	} else if len(rs) != 1 || rs[0].Seq != 0 {
+		t.Error("wrong roots to refill:", rs)
+	}
+
+	// non-full roots are not checked
+	if rep, err = c.Check(false); err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Problems) != 0 {
+		t.Error("unexpected problems:", rep.Problems)
+	}
+
+	// filled again
+	if err = c.Set(r.Refs[0].Object, alice); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.MarkFull(r); err != nil {
+		t.Fatal(err)
+	}
+	if rs, err := c.Refill(pk); err != nil {
+		t.Fatal(err)
+	} else if len(rs) != 0 {
+		t.Error("unexpected roots to refill:", rs)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
 	"github.com/skycoin/cxo/node/log"
 )
 
@@ -25,6 +28,12 @@ const (
 	VerbosePin // to many logs to show
 )
 
+// EventsQueue is default size of buffer of events
+const EventsQueue int = 128
+
+// CacheSize is default size of cache of objects in bytes
+const CacheSize int = 1 << 24 // 16M
+
 // A Config represents oconfigurations
 // and options of Container
 type Config struct {
@@ -58,6 +67,66 @@ type Config struct {
 	// false) all non-full root objects will be removed from database
 	// before shutdown
 	KeepNonFull bool
+
+	// EventsQueue is size of buffer of events of database
+	// used by callbacks below. If the buffer is full, then
+	// events are lost and OnEventsLost is called
+	EventsQueue int
+
+	// ReadOnly Container never changes database by itself. It
+	// doesn't recount references, doesn't index roots by time
+	// and doesn't clean up (the CleanUp and KeepNonFull are
+	// ignored). Use it with read-only database
+	ReadOnly bool
+
+	// CacheSize is max size of LRU cache of objects in bytes.
+	// The cache used to get objects without database
+	// transactions. Set to 0 to disable the cache
+	CacheSize int
+
+	//
+	// quotas
+	//
+
+	// Quotas are enforced when a received Root object added
+	// (AddRoot) and when it's filled (MarkFull). Root objects
+	// created by (*Pack).Save are not limited. Zero quotas
+	// means no limits. See DBStat of Container to get state of
+	// quotas
+
+	// FeedQuota is default quota of every feed
+	FeedQuota data.Quota
+	// FeedQuotas is quotas of particular feeds,
+	// that overrides the FeedQuota
+	FeedQuotas map[cipher.PubKey]data.Quota
+	// Quota is global quota of all feeds
+	Quota data.Quota
+	// QuotaPolicy is QuotaReject (default) or QuotaEvict
+	QuotaPolicy QuotaPolicy
+
+	//
+	// callbacks
+	//
+
+	// The callbacks called one by one from separate goroutine
+	// after a change of database commits. The callbacks called
+	// for all changes made through the Container or through
+	// its DB. See data.WatchDB for details
+
+	// OnRootAdded called when new Root object saved
+	OnRootAdded func(c *Container, feed cipher.PubKey, seq uint64)
+	// OnRootFull called when a Root object marked as full
+	OnRootFull func(c *Container, feed cipher.PubKey, seq uint64)
+	// OnRootDeleted called when a Root object removed
+	OnRootDeleted func(c *Container, feed cipher.PubKey, seq uint64)
+	// OnFeedAdded called when new feed added
+	OnFeedAdded func(c *Container, feed cipher.PubKey)
+	// OnFeedDeleted called when a feed removed with all its roots
+	OnFeedDeleted func(c *Container, feed cipher.PubKey)
+	// OnEventsLost called when the buffer of events was full
+	// and some events have been lost. Rescan database to get
+	// actual state
+	OnEventsLost func(c *Container, lost int)
 }
 
 // NewConfig returns pointer to Config with default values
@@ -83,6 +152,14 @@ func NewConfig() (conf *Config) {
 	conf.CleanUp = CleanUp
 	conf.KeepRoots = KeepRoots
 	conf.KeepNonFull = KeepNonFull
+
+	// events
+
+	conf.EventsQueue = EventsQueue
+
+	// cache
+
+	conf.CacheSize = CacheSize
 	return
 }
 
@@ -92,5 +169,43 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("skyobject.Config.MerkleDegree too small: %d",
 			c.MerkleDegree)
 	}
+	if c.EventsQueue < 0 {
+		return fmt.Errorf("skyobject.Config.EventsQueue is negative: %d",
+			c.EventsQueue)
+	}
+	if c.CacheSize < 0 {
+		return fmt.Errorf("skyobject.Config.CacheSize is negative: %d",
+			c.CacheSize)
+	}
+	if err := validateQuota("Quota", c.Quota); err != nil {
+		return err
+	}
+	if err := validateQuota("FeedQuota", c.FeedQuota); err != nil {
+		return err
+	}
+	for pk, q := range c.FeedQuotas {
+		if err := validateQuota("FeedQuotas["+pk.Hex()[:7]+"]",
+			q); err != nil {
+
+			return err
+		}
+	}
+	switch c.QuotaPolicy {
+	case QuotaReject, QuotaEvict:
+	default:
+		return fmt.Errorf("skyobject.Config.QuotaPolicy is invalid: %d",
+			c.QuotaPolicy)
+	}
 	return nil
 }
+
+// hasCallbacks returns true if any
+// callback of events is set
+func (c *Config) hasCallbacks() bool {
+	return c.OnRootAdded != nil ||
+		c.OnRootFull != nil ||
+		c.OnRootDeleted != nil ||
+		c.OnFeedAdded != nil ||
+		c.OnFeedDeleted != nil ||
+		c.OnEventsLost != nil
+}
@@ -16,4 +16,23 @@ func TestConfig_Validate(t *testing.T) {
 	if err := c.Validate(); err != nil {
 		t.Error("unexpected error:", err)
 	}
+	c.EventsQueue = -1
+	if c.Validate() == nil {
+		t.Error("missing error")
+	}
+	c.EventsQueue = 0
+	c.CacheSize = -1
+	if c.Validate() == nil {
+		t.Error("missing error")
+	}
+	c.CacheSize = 0
+	c.FeedQuota.MaxRoots = -1
+	if c.Validate() == nil {
+		t.Error("missing error")
+	}
+	c.FeedQuota.MaxRoots = 0
+	c.QuotaPolicy = 10
+	if c.Validate() == nil {
+		t.Error("missing error")
+	}
 }
@@ -27,9 +27,11 @@ var (
 type Container struct {
 	log.Logger
 	conf Config
-	db   data.DB
+	db   data.WatchDB
 	stat
 
+	cache cache // LRU cache of objects
+
 	// registries
 	coreRegistry *Registry
 
@@ -42,11 +44,22 @@ type Container struct {
 	closeq chan struct{}  //
 	closeo sync.Once      // clean up by interval
 	await  sync.WaitGroup //
+
+	// quotas
+	qmx  sync.Mutex
+	used map[cipher.PubKey]uint64 // last usage of feeds (LRU)
+	tick uint64
+
+	// callbacks
+	watcher *data.Watcher
+	eventsd chan struct{} // closed when events handled
 }
 
 // NewContainer by given database (required) and Registry
 // (optional). Given Registry will be CoreRegsitry of the
-// Container
+// Container. If given DB is not a data.WatchDB, then it
+// will be wrapped to watch changes. Thus, use DB method of
+// the Container to access the database
 func NewContainer(db data.DB, conf *Config) (c *Container) {
 	if db == nil {
 		panic("missing data.DB")
@@ -58,13 +71,19 @@ func NewContainer(db data.DB, conf *Config) (c *Container) {
 		panic(err)
 	}
 	c = new(Container)
-	c.db = db
+	if wdb, ok := db.(data.WatchDB); ok {
+		c.db = wdb
+	} else {
+		c.db = data.NewWatchDB(db)
+	}
 	c.closeq = make(chan struct{})
 	c.Logger = log.NewLogger(conf.Log)
 	c.regs = make(map[RegistryRef]*Registry)
+	c.used = make(map[cipher.PubKey]uint64)
 	// copy configs
 	c.conf = *conf
 	c.stat.init(c.conf.StatSamples)
+	c.cache.init(c.conf.CacheSize)
 
 	if conf.Registry != nil {
 		c.coreRegistry = conf.Registry
@@ -73,11 +92,30 @@ func NewContainer(db data.DB, conf *Config) (c *Container) {
 		}
 	}
 
-	if c.conf.CleanUp > 0 {
+	if c.conf.ReadOnly {
+		if c.needRecount() {
+			c.Print("[WRN] references of objects need recount")
+		}
+	} else {
+		if c.needRecount() {
+			c.Print("recount references of objects")
+			if _, err := c.Recount(); err != nil {
+				panic(err) // fatality
+			}
+		}
+	}
+
+	if c.conf.CleanUp > 0 && !c.conf.ReadOnly {
 		c.await.Add(1)
 		go c.cleanUpByInterval()
 	}
 
+	if c.conf.hasCallbacks() {
+		c.watcher = c.db.Watch(c.conf.EventsQueue)
+		c.eventsd = make(chan struct{})
+		go c.handleEvents()
+	}
+
 	return
 }
 
@@ -127,19 +165,35 @@ func (c *Container) DB() data.DB {
 	return c.db
 }
 
-// Set saves single object into database
+// Watch creates data.Watcher to watch changes of
+// database of the Container. See data.WatchDB
+// for details
+func (c *Container) Watch(size int) *data.Watcher {
+	return c.db.Watch(size)
+}
+
+// Set saves single object into database. Concurrent
+// Set calls can be combined into one transaction
+// (see data.Batch)
 func (c *Container) Set(hash cipher.SHA256, val []byte) (err error) {
 	c.Debugln(VerbosePin, "Set", hash.Hex()[:7])
 
-	return c.DB().Update(func(tx data.Tu) error {
+	return data.Batch(c.DB(), func(tx data.Tu) error {
 		return tx.Objects().Set(hash, val)
 	})
 }
 
-// Get returns data by hash. Result is nil if data not found
+// Get returns data by hash. Result is nil if data not found.
+// The Get uses cache of objects (see CacheSize of Config),
+// thus the result must not be modified
 func (c *Container) Get(hash cipher.SHA256) (value []byte) {
 	c.Debugln(VerbosePin, "Get", hash.Hex()[:7])
 
+	value, gen, ok := c.cache.get(hash)
+	if ok {
+		return
+	}
+
 	err := c.db.View(func(tx data.Tv) (_ error) {
 		value = tx.Objects().GetCopy(hash)
 		return
@@ -147,6 +201,9 @@ func (c *Container) Get(hash cipher.SHA256) (value []byte) {
 	if err != nil {
 		panic("database error: " + err.Error())
 	}
+	if value != nil {
+		c.cache.put(hash, value, gen)
+	}
 	return
 }
 
@@ -326,10 +383,28 @@ func (c *Container) NewRootReg(pk cipher.PubKey, sk cipher.SecKey,
 	return c.Unpack(r, flags, types, sk)
 }
 
-// CelanUp removes unused objects from database
+// CelanUp removes old roots (roots before last full root of
+// every feed) if keepRoots is false. Objects of removed roots
+// are freed using references counters. After that, the CleanUp
+// scans all objects and removes objects that have no references
+// and are not used by non-full roots, except the core Registry.
+// Such objects can be left by roots removed directly from the
+// database (see Check and data.Restore). Periodic CleanUp (see
+// Config.CleanUp) and Close don't scan objects
 func (c *Container) CleanUp(keepRoots bool) (err error) {
+	return c.cleanUp(keepRoots, true)
+}
+
+// cleanUp removes old roots if keepRoots is false, and objects
+// that have no references if sweep is true
+func (c *Container) cleanUp(keepRoots, sweep bool) (err error) {
 
-	c.Debugln(VerbosePin, "CleanUp, keep roots:", keepRoots)
+	c.Debugln(VerbosePin, "CleanUp, keep roots:", keepRoots, "sweep:",
+		sweep)
+
+	if keepRoots && !sweep {
+		return // nothing to clean up
+	}
 
 	// avoid simultaneous CleanUps,
 	// otherwise timing would be wrong
@@ -337,54 +412,26 @@ func (c *Container) CleanUp(keepRoots bool) (err error) {
 	defer c.cleanmx.Unlock()
 
 	tp := time.Now()
-	var elapsed, verboseElapsed time.Duration
 
-	// hash -> needed (> 0)
-	coll := make(map[cipher.SHA256]int)
-
-	// remove roots before (seq of last full)
-	collRoots := make(map[cipher.PubKey]uint64)
-
-	// we have to use single transaction
+	var freed []cipher.SHA256 // removed objects
 
 	err = c.DB().Update(func(tx data.Tu) (err error) {
-
-		//
-		// collect
-		//
-
-		err = c.cleanUpCollect(tx, coll, collRoots, keepRoots)
-
-		if err != nil {
-			c.Debugf(CleanUpPin,
-				"CleanUp failed after collecting, took: %v, error: %v",
-				time.Now().Sub(tp),
-				err)
-			return
-		}
-
-		if c.Logger.Pins()&CleanUpVerbosePin != 0 {
-			verboseElapsed = time.Now().Sub(tp)
-			c.Debug(CleanUpVerbosePin, "CleanUp collecting took: ",
-				verboseElapsed)
+		if !keepRoots {
+			if freed, err = c.cleanUpRoots(tx, freed); err != nil {
+				return
+			}
 		}
-
-		//
-		// delete
-		//
-
-		if cr := c.CoreRegistry(); cr != nil {
-			coll[cipher.SHA256(cr.Reference())] = 1
+		if sweep {
+			freed, err = c.sweepObjects(tx, freed)
 		}
-
-		if len(coll) != 0 && len(collRoots) != 0 {
-			err = c.cleanUpRemove(tx, coll, collRoots, keepRoots)
-		}
-
 		return
 	})
 
-	elapsed = time.Now().Sub(tp)
+	if err == nil {
+		c.forgetObjects(freed)
+	}
+
+	elapsed := time.Now().Sub(tp)
 	c.stat.addCleanUp(elapsed)
 
 	if err != nil {
@@ -392,124 +439,68 @@ func (c *Container) CleanUp(keepRoots bool) (err error) {
 		return
 	}
 
-	if c.Logger.Pins()&CleanUpVerbosePin != 0 {
-		verboseElapsed = time.Now().Sub(tp) - verboseElapsed
-		c.Debug(CleanUpVerbosePin, "CleanUp removing took: ",
-			verboseElapsed)
-	}
-
-	c.Debugln(CleanUpPin, "CleanUp", elapsed)
+	c.Debugln(CleanUpPin, "CleanUp", elapsed, "freed", len(freed))
 	return
 }
 
-func (c *Container) cleanUpCollect(tx data.Tu, coll map[cipher.SHA256]int,
-	collRoots map[cipher.PubKey]uint64, keepRoots bool) error {
+// sweepObjects removes objects that have no references,
+// see freeObjects
+func (c *Container) sweepObjects(tx data.Tu,
+	freed []cipher.SHA256) (_ []cipher.SHA256, err error) {
 
-	feeds := tx.Feeds()
 	objs := tx.Objects()
+	unused := make(map[cipher.SHA256]struct{})
 
-	// range over roots
-
-	return feeds.Ascend(func(pk cipher.PubKey) (err error) {
-
-		roots := feeds.Roots(pk)
+	err = objs.Ascend(func(key cipher.SHA256, _ []byte) (_ error) {
+		if objs.Refs(key) == 0 {
+			unused[key] = struct{}{}
+		}
+		return
+	})
+	if err != nil {
+		return freed, err
+	}
 
-		var lastFull uint64
-		var hasLastFull bool
+	return c.freeObjects(tx, unused, freed)
+}
 
-		err = roots.Descend(func(rp *data.RootPack) (err error) {
+// cleanUpRoots removes roots before last full root of every feed
+func (c *Container) cleanUpRoots(tx data.Tu,
+	freed []cipher.SHA256) (_ []cipher.SHA256, err error) {
 
-			var r *Root
-			if r, err = c.unpackRoot(pk, rp); err != nil {
-				return
-			}
+	feeds := tx.Feeds()
 
-			kerr := c.knowsAbout(r, objs, func(hash cipher.SHA256) (deeper bool,
-				_ error) {
+	for _, pk := range feeds.List() {
 
-				if _, ok := coll[hash]; !ok {
-					coll[hash] = 1
-					return true, nil // go deeper
-				}
-				return // already known the object
-			})
-			if kerr != nil {
-				c.Printf("[ERR] knowsAbout of %s error: %v",
-					r.Short(),
-					kerr)
-			}
+		roots := feeds.Roots(pk)
 
-			// ignore all possible errors of the knowsAbout
-			// becasue we need to walk through all Roots
-			//
-			// TOTH (kostyarin): ignore? or be strict?
+		var lastFull uint64
+		var hasLastFull bool
 
-			if rp.IsFull && !keepRoots {
+		err = roots.Descend(func(rp *data.RootPack) (_ error) {
+			if roots.IsFull(rp.Seq) {
 				lastFull, hasLastFull = rp.Seq, true
-				// we will delete roots below last full
 				return data.ErrStopIteration
 			}
-
 			return
 		})
-
-		if hasLastFull && !keepRoots {
-			collRoots[pk] = lastFull
+		if err != nil {
+			return freed, err
 		}
 
-		return
-
-	})
-
-}
-
-func (c *Container) cleanUpRemove(tx data.Tu, coll map[cipher.SHA256]int,
-	collRoots map[cipher.PubKey]uint64, keepRoots bool) (err error) {
-
-	// remove unused registries
-
-	c.cleanUpRemoveRegistries(coll)
-
-	// remove roots
-
-	if len(collRoots) > 0 {
-		feeds := tx.Feeds()
-		for pk, before := range collRoots {
-			if roots := feeds.Roots(pk); roots != nil {
-				if err = roots.DelBefore(before); err != nil {
-					return
-				}
-			}
+		if !hasLastFull || lastFull == 0 {
+			continue // nothing to remove
 		}
-	}
 
-	// remove objects
+		if freed, err = c.delRootsBefore(tx, roots, lastFull,
+			freed); err != nil {
 
-	if len(coll) > 0 {
-		objs := tx.Objects()
-		return objs.AscendDel(func(key cipher.SHA256, _ []byte) (del bool,
-			_ error) {
+			return freed, err
+		}
 
-			if _, ok := coll[key]; !ok {
-				del = true
-			}
-			return
-		})
 	}
 
-	return
-}
-
-func (c *Container) cleanUpRemoveRegistries(coll map[cipher.SHA256]int) {
-	c.rmx.Lock()
-	defer c.rmx.Unlock()
-
-	for k := range c.regs {
-		if _, ok := coll[cipher.SHA256(k)]; !ok {
-			delete(c.regs, k)
-			c.stat.addRegistry(-1)
-		}
-	}
+	return freed, nil
 }
 
 func (c *Container) cleanUpByInterval() {
@@ -528,7 +519,7 @@ func (c *Container) cleanUpByInterval() {
 	for {
 		select {
 		case <-tick:
-			if err = c.CleanUp(c.conf.KeepRoots); err != nil {
+			if err = c.cleanUp(c.conf.KeepRoots, false); err != nil {
 				c.Print("[ERR] CleanUp error: ", err)
 			}
 		case <-c.closeq:
@@ -537,6 +528,43 @@ func (c *Container) cleanUpByInterval() {
 	}
 }
 
+// handleEvents calls callbacks for events of the watcher
+func (c *Container) handleEvents() {
+	defer close(c.eventsd)
+
+	for e := range c.watcher.Events() {
+		c.Debugln(VerbosePin, "event", e)
+
+		switch e.Type {
+		case data.EventRootAdded:
+			if callback := c.conf.OnRootAdded; callback != nil {
+				callback(c, e.Feed, e.Seq)
+			}
+		case data.EventRootFull:
+			if callback := c.conf.OnRootFull; callback != nil {
+				callback(c, e.Feed, e.Seq)
+			}
+		case data.EventRootDeleted:
+			if callback := c.conf.OnRootDeleted; callback != nil {
+				callback(c, e.Feed, e.Seq)
+			}
+		case data.EventFeedAdded:
+			if callback := c.conf.OnFeedAdded; callback != nil {
+				callback(c, e.Feed)
+			}
+		case data.EventFeedDeleted:
+			if callback := c.conf.OnFeedDeleted; callback != nil {
+				callback(c, e.Feed)
+			}
+		case data.EventOverflow:
+			c.Print("[ERR] events lost: ", e.Lost)
+			if callback := c.conf.OnEventsLost; callback != nil {
+				callback(c, e.Lost)
+			}
+		}
+	}
+}
+
 func (c *Container) unpackRoot(pk cipher.PubKey, rp *data.RootPack) (r *Root,
 	err error) {
 
@@ -558,25 +586,58 @@ func (c *Container) unpackRoot(pk cipher.PubKey, rp *data.RootPack) (r *Root,
 	return
 }
 
-// removeNonFullRoots removes all non-full
-// Root objects from database
-func (c *Container) removeNonFullRoots() error {
+// needRecount returns true if references
+// counters of database are not actual
+func (c *Container) needRecount() (need bool) {
+	err := c.DB().View(func(tx data.Tv) (_ error) {
+		need = tx.Objects().NeedRecount()
+		return
+	})
+	if err != nil {
+		panic("database error: " + err.Error())
+	}
+	return
+}
+
+// removeNonFullRoots removes all non-full Root objects
+// from database with their objects, except roots that
+// should be filled again (see Check)
+func (c *Container) removeNonFullRoots() (err error) {
 	c.Debug(VerbosePin, "removeNonFullRoots")
 
 	// don't perform simultaneously with CleanUp
 	c.cleanmx.Lock()
 	defer c.cleanmx.Unlock()
 
-	return c.DB().Update(func(tx data.Tu) error {
+	var freed []cipher.SHA256
+
+	err = c.DB().Update(func(tx data.Tu) (err error) {
 		feeds := tx.Feeds()
-		return feeds.Ascend(func(pk cipher.PubKey) error {
+		dropped := make(map[cipher.PubKey][]*data.RootPack)
+		err = feeds.Ascend(func(pk cipher.PubKey) error {
 			roots := feeds.Roots(pk)
 			return roots.AscendDel(func(rp *data.RootPack) (del bool, _ error) {
-				del = !rp.IsFull
+				rm, err := roots.Meta(rp.Seq)
+				if err != nil {
+					return false, err
+				}
+				if del = !rm.IsFull && !rm.Refill; del {
+					dropped[pk] = append(dropped[pk], rp)
+				}
 				return
 			})
 		})
+		if err != nil || len(dropped) == 0 {
+			return
+		}
+		freed, err = c.dropRoots(tx, dropped, freed)
+		return
 	})
+
+	if err == nil {
+		c.forgetObjects(freed)
+	}
+	return
 }
 
 // Close the Container. The
@@ -591,14 +652,25 @@ func (c *Container) Close() error {
 	})
 	c.await.Wait()
 
-	if !c.conf.KeepNonFull {
-		if err := c.removeNonFullRoots(); err != nil {
-			c.Print("[ERR] error removing non-full roots:", err)
+	var err error
+
+	if !c.conf.ReadOnly {
+		if !c.conf.KeepNonFull {
+			if err := c.removeNonFullRoots(); err != nil {
+				c.Print("[ERR] error removing non-full roots:", err)
+			}
 		}
+
+		// and remove all possible
+		err = c.cleanUp(c.conf.KeepRoots, false)
 	}
 
-	// and remove all possible
-	return c.CleanUp(c.conf.KeepRoots)
+	// handle all events and stop
+	if c.watcher != nil {
+		c.watcher.Close()
+		<-c.eventsd
+	}
+	return err
 }
 
 //
@@ -614,12 +686,39 @@ func (c *Container) AddFeed(pk cipher.PubKey) error {
 	})
 }
 
-// DelFeed. The method never returns "not found" errors
+// DelFeed deletes feed with all its roots, freeing objects
+// of the roots. The method never returns "not found" errors
 func (c *Container) DelFeed(pk cipher.PubKey) (err error) {
 	c.Debugln(VerbosePin, "DelFeed", pk.Hex()[:7])
 
-	err = c.DB().Update(func(tx data.Tu) error {
-		return tx.Feeds().Del(pk)
+	c.cleanmx.Lock()
+	defer c.cleanmx.Unlock()
+
+	var freed []cipher.SHA256
+
+	err = c.DB().Update(func(tx data.Tu) (err error) {
+		feeds := tx.Feeds()
+		roots := feeds.Roots(pk)
+		if roots == nil {
+			return // not found
+		}
+		var rps []*data.RootPack
+		err = roots.Ascend(func(rp *data.RootPack) (_ error) {
+			rps = append(rps, rp)
+			return
+		})
+		if err != nil {
+			return
+		}
+		if freed, err = c.delRoots(tx, roots, rps, freed); err != nil {
+			return
+		}
+		return feeds.Del(pk)
 	})
+
+	if err == nil {
+		c.forgetObjects(freed)
+		c.forget(pk)
+	}
 	return
 }
@@ -94,7 +94,7 @@ func TestContainer_Get(t *testing.T) {
 	t.Run("fatal get", func(t *testing.T) {
 		c.db.Close()
 		defer shouldPanic(t)
-		c.Get(hash)
+		c.Get(cipher.SumSHA256([]byte("not cached")))
 	})
 
 }
@@ -313,3 +313,119 @@ func TestContainer_DelFeed(t *testing.T) {
 		t.Error("undeleted feed")
 	}
 }
+
+func TestContainer_Watch(t *testing.T) {
+	c := getCont()
+	defer c.db.Close()
+	defer c.Close()
+
+	w := c.Watch(8)
+	defer w.Close()
+
+	pk, _ := cipher.GenerateKeyPair()
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-w.Events(); e.Type != data.EventFeedAdded || e.Feed != pk {
+		t.Error("wrong event:", e)
+	}
+}
+
+func TestContainer_callbacks(t *testing.T) {
+	type event struct {
+		typ  data.EventType
+		feed cipher.PubKey
+		seq  uint64
+	}
+
+	events := make(chan event, 10)
+
+	conf := getConf()
+	conf.OnFeedAdded = func(_ *Container, feed cipher.PubKey) {
+		events <- event{data.EventFeedAdded, feed, 0}
+	}
+	conf.OnRootAdded = func(_ *Container, feed cipher.PubKey, seq uint64) {
+		events <- event{data.EventRootAdded, feed, seq}
+	}
+	conf.OnFeedDeleted = func(_ *Container, feed cipher.PubKey) {
+		events <- event{data.EventFeedDeleted, feed, 0}
+	}
+
+	c := NewContainer(data.NewMemoryDB(), conf)
+	defer c.db.Close()
+
+	pk, sk := cipher.GenerateKeyPair()
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+	pack, err := c.NewRoot(pk, sk, 0, c.CoreRegistry().Types())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pack.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DelFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	// handles all events before closing
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	close(events)
+
+	var got []event
+	for e := range events {
+		got = append(got, e)
+	}
+	want := []event{
+		{data.EventFeedAdded, pk, 0},
+		{data.EventRootAdded, pk, 0},
+		{data.EventFeedDeleted, pk, 0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("wrong events: want %v, got %v", want, got)
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("wrong events: want %v, got %v", want, got)
+		}
+	}
+}
+
+func TestContainer_readOnly(t *testing.T) {
+
+	db := data.NewMemoryDB()
+	defer db.Close()
+
+	pk, sk := cipher.GenerateKeyPair()
+
+	c := NewContainer(db, getConf())
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AddRoot(pk, getSignedRootPack(pk, sk, 0,
+		cipher.SHA256{})); err != nil {
+
+		t.Fatal(err)
+	}
+	val := []byte("value")
+	if err := c.Set(cipher.SumSHA256(val), val); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := getConf()
+	conf.ReadOnly = true
+	ro := NewContainer(db, conf)
+	if err := ro.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := c.Root(pk, 0); err != nil {
+		t.Error("non-full root removed by read-only Container:", err)
+	}
+	if c.Get(cipher.SumSHA256(val)) == nil {
+		t.Error("object removed by read-only Container")
+	}
+}
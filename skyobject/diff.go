@@ -0,0 +1,666 @@
+package skyobject
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+)
+
+// A ChangeKind represents kind of a Change
+type ChangeKind int
+
+// kinds of changes
+const (
+	// ChangeAdded is an element of the Refs of a Root, an element
+	// of a Refs, an element of a slice or a field that added
+	ChangeAdded ChangeKind = iota
+	// ChangeDeleted is an element or a field that deleted
+	ChangeDeleted
+	// ChangeReference is a Ref, a Dynamic or an element of a
+	// Refs that points to another object
+	ChangeReference
+	// ChangeValue is a value that differs
+	ChangeValue
+)
+
+// String implements fmt.Stringer interface
+func (c ChangeKind) String() string {
+	switch c {
+	case ChangeAdded:
+		return "added"
+	case ChangeDeleted:
+		return "deleted"
+	case ChangeReference:
+		return "reference"
+	case ChangeValue:
+		return "value"
+	}
+	return fmt.Sprintf("ChangeKind<%d>", c)
+}
+
+// A Change represents difference between two Root objects.
+// The Path is path to changed object or value, for example
+// "Refs[0].Members[2].Name". For references the OldHash and
+// the NewHash are hashes of referenced objects and the Old and
+// the New are the objects (nil for nil references). For values
+// the Old and the New are the values. The Old is nil for added
+// and the New is nil for deleted
+type Change struct {
+	Kind ChangeKind
+	Path string
+
+	OldHash cipher.SHA256
+	NewHash cipher.SHA256
+
+	Old *Value
+	New *Value
+}
+
+func shortHash(hash cipher.SHA256) string {
+	if hash == (cipher.SHA256{}) {
+		return "nil"
+	}
+	return hash.Hex()[:7]
+}
+
+// valueString returns JSON of given Value,
+// without values of references
+func valueString(v *Value) string {
+	if v == nil {
+		return "nil"
+	}
+	e := exporter{ExportConfig{MaxDepth: 1}}
+	x, err := e.value(v, 2)
+	if err != nil {
+		return v.String()
+	}
+	b, err := json.Marshal(x)
+	if err != nil {
+		return v.String()
+	}
+	return string(b)
+}
+
+// String implements fmt.Stringer interface
+func (c *Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		if c.NewHash != (cipher.SHA256{}) {
+			return "+ " + c.Path + " " + shortHash(c.NewHash)
+		}
+		return "+ " + c.Path + " " + valueString(c.New)
+	case ChangeDeleted:
+		if c.OldHash != (cipher.SHA256{}) {
+			return "- " + c.Path + " " + shortHash(c.OldHash)
+		}
+		return "- " + c.Path + " " + valueString(c.Old)
+	case ChangeReference:
+		return "~ " + c.Path + " " + shortHash(c.OldHash) + " -> " +
+			shortHash(c.NewHash)
+	}
+	return "~ " + c.Path + " " + valueString(c.Old) + " -> " +
+		valueString(c.New)
+}
+
+type differ struct {
+	changes []Change
+}
+
+// Diff returns changes between given Root objects, from a to b.
+// It walks both trees in parallel skipping references and values
+// with the same hashes. Branches of Refs with the same hashes are
+// skipped too. Elements of the Refs of the Roots and elements of
+// Refs are matched by hashes (using Myers' algorithm), unmatched
+// elements between matched ones are changed pairwise, and the rest
+// of them are deleted or added. Paths of deleted elements contain
+// indices in a, and paths of other changes contain indices in b.
+// Objects referenced by changed references are compared if they
+// have the same schema. Registries of the Roots are loaded from
+// database if they are not loaded yet. Go types of the objects are
+// not required
+func (c *Container) Diff(a, b *Root) (changes []Change, err error) {
+
+	var av, bv []*Value
+	if av, err = c.RootValues(a); err != nil {
+		return
+	}
+	if bv, err = c.RootValues(b); err != nil {
+		return
+	}
+
+	var d differ
+	if err = d.list("Refs", valueItems(av), valueItems(bv)); err != nil {
+		return
+	}
+	return d.changes, nil
+}
+
+func (d *differ) add(ch Change) {
+	d.changes = append(d.changes, ch)
+}
+
+// A diffItem is an element of a list or a branch of a Refs that
+// has the same hash and the same position in both compared Refs
+type diffItem struct {
+	val *Value // element, nil for branch
+
+	refs  *Value        // the Refs of the branch
+	hash  cipher.SHA256 // hash of the branch
+	depth int           // depth of nested hashes of the branch
+	index int           // index of (first) element
+}
+
+// valueItems returns items of given elements
+func valueItems(vals []*Value) (items []*diffItem) {
+	items = make([]*diffItem, 0, len(vals))
+	for i, val := range vals {
+		items = append(items, &diffItem{val: val, index: i})
+	}
+	return
+}
+
+func (d *diffItem) equal(x *diffItem) bool {
+	if d.val != nil || x.val != nil {
+		return d.val != nil && x.val != nil && bytes.Equal(d.val.val, x.val.val)
+	}
+	return d.hash == x.hash && d.depth == x.depth
+}
+
+// expand replaces branches with elements of the branches
+func expand(items []*diffItem) (elems []*diffItem, err error) {
+	for _, it := range items {
+		if it.val != nil {
+			elems = append(elems, it)
+			continue
+		}
+		var ern encodedRefsNode
+		if err = it.refs.node(it.hash, &ern); err != nil {
+			return
+		}
+		index := it.index
+		err = it.refs.refsLeafs(it.depth, ern.Nested, func(h cipher.SHA256) {
+			elems = append(elems, &diffItem{
+				val:   it.refs.refsElem(h),
+				index: index,
+			})
+			index++
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// list of references compared by hashes
+func (d *differ) list(path string, a, b []*diffItem) (err error) {
+
+	// gaps between matched elements
+	var pa, pb int
+	ma, mb := matchLists(a, b)
+	for k := 0; k <= len(ma); k++ {
+		na, nb := len(a), len(b) // end
+		if k < len(ma) {
+			na, nb = ma[k], mb[k]
+		}
+		if err = d.gap(path, a[pa:na], b[pb:nb]); err != nil {
+			return
+		}
+		pa, pb = na+1, nb+1
+	}
+	return
+}
+
+// gap compares unmatched items
+func (d *differ) gap(path string, a, b []*diffItem) (err error) {
+
+	if len(a) == 0 && len(b) == 0 {
+		return
+	}
+
+	if a, err = expand(a); err != nil {
+		return
+	}
+	if b, err = expand(b); err != nil {
+		return
+	}
+
+	// changed
+	for ; len(a) > 0 && len(b) > 0; a, b = a[1:], b[1:] {
+		err = d.reference(path+"["+strconv.Itoa(b[0].index)+"]", a[0].val,
+			b[0].val)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, it := range a {
+		ch := Change{Kind: ChangeDeleted}
+		ch.Path = path + "[" + strconv.Itoa(it.index) + "]"
+		if ch.OldHash, err = it.val.Hash(); err != nil {
+			return
+		}
+		if ch.Old, err = it.val.Dereference(); err != nil {
+			return
+		}
+		d.add(ch)
+	}
+
+	for _, it := range b {
+		ch := Change{Kind: ChangeAdded}
+		ch.Path = path + "[" + strconv.Itoa(it.index) + "]"
+		if ch.NewHash, err = it.val.Hash(); err != nil {
+			return
+		}
+		if ch.New, err = it.val.Dereference(); err != nil {
+			return
+		}
+		d.add(ch)
+	}
+	return
+}
+
+// matchLists returns indices of elements of longest
+// common subsequence of given lists
+func matchLists(a, b []*diffItem) (ma, mb []int) {
+	m := matcher{a: a, b: b}
+	m.lcs(0, len(a), 0, len(b))
+	return m.ma, m.mb
+}
+
+// A matcher finds longest common subsequence using
+// linear space variation of Myers' algorithm
+type matcher struct {
+	a, b   []*diffItem
+	ma, mb []int // matched
+}
+
+func (m *matcher) match(i, j int) {
+	m.ma, m.mb = append(m.ma, i), append(m.mb, j)
+}
+
+// lcs finds longest common subsequence of a[a0:a1] and b[b0:b1]
+func (m *matcher) lcs(a0, a1, b0, b1 int) {
+
+	for a0 < a1 && b0 < b1 && m.a[a0].equal(m.b[b0]) {
+		m.match(a0, b0)
+		a0, b0 = a0+1, b0+1
+	}
+
+	var tail int
+	for a0 < a1 && b0 < b1 && m.a[a1-1].equal(m.b[b1-1]) {
+		a1, b1, tail = a1-1, b1-1, tail+1
+	}
+
+	if a0 < a1 && b0 < b1 {
+		x, y, u, v := m.middleSnake(a0, a1, b0, b1)
+		m.lcs(a0, x, b0, y)
+		for ; x < u; x, y = x+1, y+1 {
+			m.match(x, y)
+		}
+		m.lcs(u, a1, v, b1)
+	}
+
+	for k := 0; k < tail; k++ {
+		m.match(a1+k, b1+k)
+	}
+}
+
+// middleSnake returns middle snake of shortest edit script
+// of a[a0:a1] and b[b0:b1], from (x, y) to (u, v)
+func (m *matcher) middleSnake(a0, a1, b0, b1 int) (x, y, u, v int) {
+
+	n, l := a1-a0, b1-b0
+	delta := n - l
+	odd := delta&1 != 0
+	max := (n + l + 1) / 2
+
+	// furthest x on diagonal k (forward) and
+	// furthest x from the end (backward)
+	off := max + 1
+	vf := make([]int, 2*max+3)
+	vb := make([]int, 2*max+3)
+
+	for d := 0; d <= max; d++ {
+
+		for k := -d; k <= d; k += 2 {
+			var fx int
+			if k == -d || k != d && vf[off+k-1] < vf[off+k+1] {
+				fx = vf[off+k+1]
+			} else {
+				fx = vf[off+k-1] + 1
+			}
+			fy := fx - k
+			sx, sy := fx, fy
+			for fx < n && fy < l && m.a[a0+fx].equal(m.b[b0+fy]) {
+				fx, fy = fx+1, fy+1
+			}
+			vf[off+k] = fx
+			if kr := delta - k; odd && kr >= -(d-1) && kr <= d-1 &&
+				fx+vb[off+kr] >= n {
+
+				return a0 + sx, b0 + sy, a0 + fx, b0 + fy
+			}
+		}
+
+		for kr := -d; kr <= d; kr += 2 {
+			var rx int
+			if kr == -d || kr != d && vb[off+kr-1] < vb[off+kr+1] {
+				rx = vb[off+kr+1]
+			} else {
+				rx = vb[off+kr-1] + 1
+			}
+			ry := rx - kr
+			sx, sy := rx, ry
+			for rx < n && ry < l && m.a[a1-1-rx].equal(m.b[b1-1-ry]) {
+				rx, ry = rx+1, ry+1
+			}
+			vb[off+kr] = rx
+			if k := delta - kr; !odd && k >= -d && k <= d &&
+				vf[off+k]+rx >= n {
+
+				return a1 - rx, b1 - ry, a1 - sx, b1 - sy
+			}
+		}
+
+	}
+
+	panic("no middle snake") // never happens
+}
+
+// are schemas of given values the same
+func sameSchema(a, b Schema) bool {
+	return a.Kind() == b.Kind() && a.Name() == b.Name() &&
+		a.IsReference() == b.IsReference() &&
+		a.ReferenceType() == b.ReferenceType()
+}
+
+// Ref, Refs or Dynamic
+func (d *differ) reference(path string, a, b *Value) (err error) {
+
+	if bytes.Equal(a.val, b.val) {
+		return // the same
+	}
+
+	if a.sch.ReferenceType() == ReferenceTypeSlice {
+		return d.refs(path, a, b)
+	}
+
+	ch := Change{Kind: ChangeReference, Path: path}
+	if ch.OldHash, err = a.Hash(); err != nil {
+		return
+	}
+	if ch.NewHash, err = b.Hash(); err != nil {
+		return
+	}
+	if ch.Old, err = a.Dereference(); err != nil {
+		return
+	}
+	if ch.New, err = b.Dereference(); err != nil {
+		return
+	}
+	d.add(ch)
+
+	if ch.Old == nil || ch.New == nil || !sameSchema(ch.Old.sch, ch.New.sch) {
+		return
+	}
+	return d.value(path, ch.Old, ch.New)
+}
+
+// elements of Refs
+func (d *differ) refs(path string, a, b *Value) (err error) {
+	var ai, bi []*diffItem
+	if ai, bi, err = refsItems(a, b); err != nil {
+		return
+	}
+	return d.list(path, ai, bi)
+}
+
+// refsItems returns elements of given Refs. If the Refs have the
+// same depth and degree, then branches with the same hashes and
+// positions are not loaded and returned as items
+func refsItems(a, b *Value) (ai, bi []*diffItem, err error) {
+
+	var ea, eb *encodedRefs
+	if ea, err = a.refs(); err != nil {
+		return
+	}
+	if eb, err = b.refs(); err != nil {
+		return
+	}
+
+	var ia, ib int // indices
+
+	var addA = func(h cipher.SHA256) {
+		ai = append(ai, &diffItem{val: a.refsElem(h), index: ia})
+		ia++
+	}
+	var addB = func(h cipher.SHA256) {
+		bi = append(bi, &diffItem{val: b.refsElem(h), index: ib})
+		ib++
+	}
+
+	if ea == nil || eb == nil || ea.Depth != eb.Depth ||
+		ea.Degree != eb.Degree {
+
+		if ea != nil {
+			if err = a.refsLeafs(int(ea.Depth), ea.Nested, addA); err != nil {
+				return
+			}
+		}
+		if eb != nil {
+			err = b.refsLeafs(int(eb.Depth), eb.Nested, addB)
+		}
+		return
+	}
+
+	var branches func(depth int, na, nb []cipher.SHA256) error
+	branches = func(depth int, na, nb []cipher.SHA256) (err error) {
+		for i := 0; i < len(na) || i < len(nb); i++ {
+			var ha, hb cipher.SHA256
+			if i < len(na) {
+				ha = na[i]
+			}
+			if i < len(nb) {
+				hb = nb[i]
+			}
+			if depth == 0 {
+				if ha != (cipher.SHA256{}) {
+					addA(ha)
+				}
+				if hb != (cipher.SHA256{}) {
+					addB(hb)
+				}
+				continue
+			}
+			var ra, rb encodedRefsNode
+			if ha != (cipher.SHA256{}) {
+				if err = a.node(ha, &ra); err != nil {
+					return
+				}
+			}
+			if ha == hb {
+				if ha == (cipher.SHA256{}) {
+					continue
+				}
+				ln := int(ra.Length)
+				ai = append(ai, &diffItem{refs: a, hash: ha, depth: depth - 1,
+					index: ia})
+				bi = append(bi, &diffItem{refs: b, hash: hb, depth: depth - 1,
+					index: ib})
+				ia, ib = ia+ln, ib+ln
+				continue
+			}
+			if hb != (cipher.SHA256{}) {
+				if err = b.node(hb, &rb); err != nil {
+					return
+				}
+			}
+			if err = branches(depth-1, ra.Nested, rb.Nested); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	err = branches(int(ea.Depth), ea.Nested, eb.Nested)
+	return
+}
+
+// non-reference values, or references
+func (d *differ) value(path string, a, b *Value) (err error) {
+
+	if bytes.Equal(a.val, b.val) {
+		return // the same
+	}
+
+	if a.sch.IsReference() {
+		return d.reference(path, a, b)
+	}
+
+	switch a.Kind() {
+	case reflect.Array, reflect.Slice:
+		if a.isBytes() {
+			break
+		}
+		return d.elems(path, a, b)
+	case reflect.Struct:
+		return d.fields(path, a, b)
+	}
+
+	d.add(Change{Kind: ChangeValue, Path: path, Old: a, New: b})
+	return
+}
+
+// elements of arrays or slices
+func (d *differ) elems(path string, a, b *Value) (err error) {
+
+	var al, bl int
+	if al, err = a.Len(); err != nil {
+		return
+	}
+	if bl, err = b.Len(); err != nil {
+		return
+	}
+
+	var i int
+	for ; i < al || i < bl; i++ {
+		ep := path + "[" + strconv.Itoa(i) + "]"
+		var ae, be *Value
+		if i < al {
+			if ae, err = a.Index(i); err != nil {
+				return
+			}
+		}
+		if i < bl {
+			if be, err = b.Index(i); err != nil {
+				return
+			}
+		}
+		switch {
+		case be == nil:
+			d.add(Change{Kind: ChangeDeleted, Path: ep, Old: ae})
+		case ae == nil:
+			d.add(Change{Kind: ChangeAdded, Path: ep, New: be})
+		default:
+			if err = d.value(ep, ae, be); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// fields of structures
+func (d *differ) fields(path string, a, b *Value) (err error) {
+
+	for _, name := range a.Fields() {
+		fp := path + "." + name
+		var af, bf *Value
+		if af, err = a.FieldByName(name); err != nil {
+			return
+		}
+		if bf, err = b.FieldByName(name); err == ErrNoSuchField {
+			d.add(Change{Kind: ChangeDeleted, Path: fp, Old: af})
+			err = nil
+			continue
+		} else if err != nil {
+			return
+		}
+		if !sameSchema(af.sch, bf.sch) {
+			d.add(Change{Kind: ChangeValue, Path: fp, Old: af, New: bf})
+			continue
+		}
+		if err = d.value(fp, af, bf); err != nil {
+			return
+		}
+	}
+
+	for _, name := range b.Fields() {
+		if _, err = a.FieldByName(name); err == nil {
+			continue
+		} else if err != ErrNoSuchField {
+			return
+		}
+		var bf *Value
+		if bf, err = b.FieldByName(name); err != nil {
+			return
+		}
+		d.add(Change{Kind: ChangeAdded, Path: path + "." + name, New: bf})
+	}
+	return nil
+}
+
+// refsElems returns elements of a Refs as
+// Values of Ref, the list is nil if the Refs
+// is blank
+func (v *Value) refsElems() (elems []*Value, err error) {
+	var er *encodedRefs
+	if er, err = v.refs(); err != nil || er == nil {
+		return
+	}
+	elems = make([]*Value, 0, er.Length)
+	err = v.refsLeafs(int(er.Depth), er.Nested, func(hash cipher.SHA256) {
+		elems = append(elems, v.refsElem(hash))
+	})
+	return
+}
+
+// refsElem returns element of the Refs as Value of Ref
+func (v *Value) refsElem(hash cipher.SHA256) *Value {
+	sch := &referenceSchema{
+		schema: schema{kind: typeOfRef.Kind()},
+		typ:    ReferenceTypeSingle,
+		elem:   v.sch.Elem(),
+	}
+	return v.sub(sch, encoder.Serialize(Ref{Hash: hash}))
+}
+
+// refsLeafs calls given function for every non-zero
+// leaf of a Refs node
+func (v *Value) refsLeafs(depth int, nested []cipher.SHA256,
+	fn func(hash cipher.SHA256)) (err error) {
+
+	for _, h := range nested {
+		if h == (cipher.SHA256{}) {
+			continue // zero
+		}
+		if depth == 0 {
+			fn(h)
+			continue
+		}
+		var ern encodedRefsNode
+		if err = v.node(h, &ern); err != nil {
+			return
+		}
+		if err = v.refsLeafs(depth-1, ern.Nested, fn); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,291 @@
+package skyobject
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func testDiffRoot(t *testing.T, c *Container, pack *Pack, seq uint64) *Root {
+	r, err := c.Root(pack.Root().Pub, seq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func testDiffGroup(t *testing.T, pack *Pack) *Group {
+	obj, err := pack.RefByIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj.(*Group)
+}
+
+func compareChanges(t *testing.T, want []string, got []Change) {
+	if len(want) != len(got) {
+		t.Errorf("wrong amount of changes: want %d, got %d", len(want),
+			len(got))
+	}
+	for i := 0; i < len(want) && i < len(got); i++ {
+		if s := got[i].String(); s != want[i] {
+			t.Errorf("wrong change %d: want %q, got %q", i, want[i], s)
+		}
+	}
+}
+
+func TestContainer_Diff(t *testing.T) {
+	// Diff(a, b *Root) (changes []Change, err error)
+
+	c, pack, closeFunc := testValueContainer(t)
+	defer closeFunc()
+
+	// seq 1
+	group := testDiffGroup(t, pack)
+	group.Name = "team"
+	if err := group.Leader.SetValue(&User{"Bob", 32, nil}); err != nil {
+		t.Fatal(err)
+	}
+	if err := group.Members.Append(&User{"Dan", 25, nil}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pack.SetRefByIndex(0, group); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := pack.RefByIndex(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tuple := obj.(*Tuple)
+	tuple.Ints[1] = 5
+	tuple.Names = append(tuple.Names, "four")
+	if err = pack.SetRefByIndex(1, tuple); err != nil {
+		t.Fatal(err)
+	}
+	pack.Append(&User{"New", 1, nil})
+	if _, err = pack.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// seq 2
+	group = testDiffGroup(t, pack)
+	bob, err := group.Members.RefByIndex(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = bob.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if err = pack.SetRefByIndex(0, group); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pack.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	r0 := testDiffRoot(t, c, pack, 0)
+	r1 := testDiffRoot(t, c, pack, 1)
+	r2 := testDiffRoot(t, c, pack, 2)
+
+	t.Run("changes", func(t *testing.T) {
+		changes, err := c.Diff(r0, r1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 10 {
+			t.Fatal("wrong amount of changes:", len(changes))
+		}
+		compareChanges(t, []string{
+			"~ Refs[0] " + shortHash(r0.Refs[0].Object) + " -> " +
+				shortHash(r1.Refs[0].Object),
+			`~ Refs[0].Name "group" -> "team"`,
+			"~ Refs[0].Leader " + changes[2].OldHash.Hex()[:7] + " -> " +
+				changes[2].NewHash.Hex()[:7],
+			`~ Refs[0].Leader.Name "Alice" -> "Bob"`,
+			`~ Refs[0].Leader.Age 21 -> 32`,
+			"+ Refs[0].Members[3] " + changes[5].NewHash.Hex()[:7],
+			"~ Refs[1] " + shortHash(r0.Refs[1].Object) + " -> " +
+				shortHash(r1.Refs[1].Object),
+			`~ Refs[1].Ints[1] 0 -> 5`,
+			`+ Refs[1].Names[3] "four"`,
+			"+ Refs[3] " + shortHash(r1.Refs[3].Object),
+		}, changes)
+		if changes[0].Kind != ChangeReference {
+			t.Error("wrong kind:", changes[0].Kind)
+		}
+		if changes[5].Kind != ChangeAdded || changes[5].Old != nil {
+			t.Error("wrong added change")
+		}
+		name, err := testValueField(t, changes[5].New, "Name").Str()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != "Dan" {
+			t.Error("wrong name of added:", name)
+		}
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		changes, err := c.Diff(r1, r2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 2 {
+			t.Fatalf("wrong amount of changes: %d", len(changes))
+		}
+		if ch := changes[1]; ch.Kind != ChangeDeleted ||
+			ch.Path != "Refs[0].Members[1]" {
+
+			t.Error("wrong change:", ch.String())
+		}
+		// and back
+		if changes, err = c.Diff(r2, r1); err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 2 || changes[1].Kind != ChangeAdded {
+			t.Error("wrong changes:", changes)
+		}
+	})
+
+	t.Run("same", func(t *testing.T) {
+		changes, err := c.Diff(r1, r1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 0 {
+			t.Error("unexpected changes:", changes)
+		}
+	})
+
+	t.Run("branches", func(t *testing.T) {
+		// seq 3
+		group := testDiffGroup(t, pack)
+		for i := 0; i < 6; i++ {
+			err := group.Members.Append(&User{"Member", uint32(i), nil})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := pack.SetRefByIndex(0, group); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pack.Save(); err != nil {
+			t.Fatal(err)
+		}
+		// seq 4
+		group = testDiffGroup(t, pack)
+		member, err := group.Members.RefByIndex(7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = member.SetValue(&User{"Changed", 4, nil}); err != nil {
+			t.Fatal(err)
+		}
+		if err = pack.SetRefByIndex(0, group); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = pack.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		r3 := testDiffRoot(t, c, pack, 3)
+		r4 := testDiffRoot(t, c, pack, 4)
+
+		changes, err := c.Diff(r3, r4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 3 {
+			t.Fatal("wrong amount of changes:", changes)
+		}
+		compareChanges(t, []string{
+			"~ Refs[0] " + shortHash(r3.Refs[0].Object) + " -> " +
+				shortHash(r4.Refs[0].Object),
+			"~ Refs[0].Members[7] " + changes[1].OldHash.Hex()[:7] + " -> " +
+				changes[1].NewHash.Hex()[:7],
+			`~ Refs[0].Members[7].Name "Member" -> "Changed"`,
+		}, changes)
+
+		// equal branches are not loaded
+		members := func(r *Root) *Value {
+			vals, err := c.RootValues(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			group, err := vals[0].Dereference()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return testValueField(t, group, "Members")
+		}
+		ai, bi, err := refsItems(members(r3), members(r4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var branches int
+		for _, it := range ai {
+			if it.val == nil {
+				branches++
+			}
+		}
+		if branches == 0 || len(ai) != len(bi) {
+			t.Errorf("wrong items: %d branches, %d and %d items", branches,
+				len(ai), len(bi))
+		}
+	})
+
+	t.Run("empty registry", func(t *testing.T) {
+		if _, err := c.Diff(r0, &Root{}); err != ErrEmptyRegsitryRef {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+}
+
+func Test_matchLists(t *testing.T) {
+
+	items := func(n int) (items []*diffItem) {
+		for i := 0; i < n; i++ {
+			val := &Value{val: []byte{byte(rand.Intn(4))}}
+			items = append(items, &diffItem{val: val, index: i})
+		}
+		return
+	}
+
+	// length of longest common subsequence
+	lcs := func(a, b []*diffItem) int {
+		l := make([][]int, len(a)+1)
+		for i := range l {
+			l[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				switch {
+				case a[i].equal(b[j]):
+					l[i][j] = l[i+1][j+1] + 1
+				case l[i+1][j] >= l[i][j+1]:
+					l[i][j] = l[i+1][j]
+				default:
+					l[i][j] = l[i][j+1]
+				}
+			}
+		}
+		return l[0][0]
+	}
+
+	for k := 0; k < 500; k++ {
+		a, b := items(rand.Intn(20)), items(rand.Intn(20))
+		ma, mb := matchLists(a, b)
+		if want := lcs(a, b); len(ma) != want || len(mb) != want {
+			t.Fatalf("wrong length: want %d, got %d", want, len(ma))
+		}
+		for i := range ma {
+			if !a[ma[i]].equal(b[mb[i]]) {
+				t.Fatal("matched different elements")
+			}
+			if i > 0 && (ma[i] <= ma[i-1] || mb[i] <= mb[i-1]) {
+				t.Fatal("matched elements are not ordered")
+			}
+		}
+	}
+
+}
@@ -0,0 +1,659 @@
+package skyobject
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+)
+
+// An ExportConfig represents limits of ExportJSON.
+// Zero values mean "no limits"
+type ExportConfig struct {
+	// MaxDepth is maximum depth of references to follow.
+	// References of a Root have depth 1. Deeper references
+	// are exported without values, with hashes only
+	MaxDepth int
+	// RefsOffset is amount of elements of every Refs
+	// to skip
+	RefsOffset int
+	// RefsLimit is maximum amount of elements of every
+	// Refs to export
+	RefsLimit int
+}
+
+// A JSONRoot is JSON representation of a Root and
+// objects reachable from it. Structures represented as
+// JSON objects, arrays and slices as JSON arrays, bytes
+// as hex-encoded strings, and references as JSONReference
+type JSONRoot struct {
+	Pub      string           `json:"pub"`
+	Seq      uint64           `json:"seq"`
+	Hash     string           `json:"hash,omitempty"`
+	Registry string           `json:"registry"`
+	Refs     []*JSONReference `json:"refs"`
+}
+
+// A JSONReference is JSON representation of a Ref,
+// a Refs or a Dynamic. For a nil reference the Hash is
+// empty. The Value is omitted if the MaxDepth reached.
+// The Length, the Offset and the Items are used by Refs
+// only. A Refs is incomplete if the RefsOffset or the
+// RefsLimit cut its elements off
+type JSONReference struct {
+	Schema string           `json:"schema,omitempty"`
+	Hash   string           `json:"hash,omitempty"`
+	Value  interface{}      `json:"value,omitempty"`
+	Length int              `json:"length,omitempty"`
+	Offset int              `json:"offset,omitempty"`
+	Items  []*JSONReference `json:"items,omitempty"`
+}
+
+// Export related errors
+var (
+	ErrMissingValue = errors.New("missing value")
+)
+
+type exporter struct {
+	conf ExportConfig
+}
+
+// ExportJSON renders given Root and all objects reachable from
+// it as indented JSON. Given ExportConfig can be nil. The Registry
+// of the Root is loaded from database if it's not loaded yet.
+// Go types of the objects are not required. Use ImportJSON to
+// create new Pack from the JSON
+func (c *Container) ExportJSON(r *Root, conf *ExportConfig) (b []byte,
+	err error) {
+
+	var vals []*Value
+	if vals, err = c.RootValues(r); err != nil {
+		return
+	}
+
+	var e exporter
+	if conf != nil {
+		e.conf = *conf
+	}
+
+	jr := &JSONRoot{
+		Pub:      r.Pub.Hex(),
+		Seq:      r.Seq,
+		Registry: r.Reg.String(),
+		Refs:     make([]*JSONReference, 0, len(vals)),
+	}
+	if r.Hash != (cipher.SHA256{}) {
+		jr.Hash = r.Hash.Hex()
+	}
+
+	for _, val := range vals {
+		var ref *JSONReference
+		if ref, err = e.reference(val, 1); err != nil {
+			return
+		}
+		jr.Refs = append(jr.Refs, ref)
+	}
+
+	return json.MarshalIndent(jr, "", "  ")
+}
+
+// is the depth deeper then allowed
+func (e *exporter) deep(depth int) bool {
+	return e.conf.MaxDepth > 0 && depth > e.conf.MaxDepth
+}
+
+// value of a non-reference
+func (e *exporter) value(v *Value, depth int) (x interface{}, err error) {
+
+	if v.sch.IsReference() {
+		return e.reference(v, depth)
+	}
+
+	if v.isBytes() {
+		var b []byte
+		if b, err = v.Bytes(); err != nil {
+			return
+		}
+		return hex.EncodeToString(b), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.Str()
+	case reflect.Array, reflect.Slice:
+		var ln int
+		if ln, err = v.Len(); err != nil {
+			return
+		}
+		list := make([]interface{}, 0, ln)
+		for i := 0; i < ln; i++ {
+			var el *Value
+			if el, err = v.Index(i); err != nil {
+				return
+			}
+			var y interface{}
+			if y, err = e.value(el, depth); err != nil {
+				return
+			}
+			list = append(list, y)
+		}
+		return list, nil
+	case reflect.Struct:
+		obj := make(map[string]interface{})
+		for _, name := range v.Fields() {
+			var fv *Value
+			if fv, err = v.FieldByName(name); err != nil {
+				return
+			}
+			if obj[name], err = e.value(fv, depth); err != nil {
+				return
+			}
+		}
+		return obj, nil
+	}
+
+	return nil, fmt.Errorf("can't export %s", v.sch.String())
+}
+
+// object referenced by a reference
+func (e *exporter) object(ref *JSONReference, obj *Value,
+	depth int) (err error) {
+
+	if obj == nil {
+		return
+	}
+	ref.Schema = obj.sch.Name()
+	if e.deep(depth) {
+		return
+	}
+	ref.Value, err = e.value(obj, depth+1)
+	return
+}
+
+// Ref, Refs or Dynamic
+func (e *exporter) reference(v *Value, depth int) (ref *JSONReference,
+	err error) {
+
+	ref = new(JSONReference)
+
+	var hash cipher.SHA256
+	if hash, err = v.Hash(); err != nil {
+		return
+	}
+
+	if el := v.sch.Elem(); el != nil {
+		ref.Schema = el.Name()
+	}
+
+	if hash == (cipher.SHA256{}) {
+		return // nil
+	}
+
+	ref.Hash = hash.Hex()
+
+	switch v.sch.ReferenceType() {
+	case ReferenceTypeSingle:
+		if e.deep(depth) {
+			return
+		}
+		var obj *Value
+		if obj, err = v.Dereference(); err != nil {
+			return
+		}
+		err = e.object(ref, obj, depth)
+	case ReferenceTypeDynamic:
+		var dr Dynamic
+		if err = v.decode(&dr); err != nil {
+			return
+		}
+		if v.reg == nil {
+			return nil, ErrEmptyRegsitryRef
+		}
+		var sch Schema
+		if sch, err = v.reg.SchemaByReference(dr.SchemaRef); err != nil {
+			return
+		}
+		ref.Schema = sch.Name()
+		if e.deep(depth) {
+			return
+		}
+		var obj *Value
+		if obj, err = v.object(sch, dr.Object); err != nil {
+			return
+		}
+		err = e.object(ref, obj, depth)
+	case ReferenceTypeSlice:
+		err = e.refs(ref, v, depth)
+	}
+	return
+}
+
+// elements of a Refs
+func (e *exporter) refs(ref *JSONReference, v *Value, depth int) (err error) {
+
+	if ref.Length, err = v.Len(); err != nil {
+		return
+	}
+	if e.deep(depth) {
+		return
+	}
+
+	from, to := e.conf.RefsOffset, ref.Length
+	if from > to {
+		from = to
+	}
+	if e.conf.RefsLimit > 0 && to-from > e.conf.RefsLimit {
+		to = from + e.conf.RefsLimit
+	}
+	ref.Offset = from
+	ref.Items = make([]*JSONReference, 0, to-from)
+
+	for i := from; i < to; i++ {
+		var hash cipher.SHA256
+		if hash, err = v.refsIndex(i); err != nil {
+			return
+		}
+		var obj *Value
+		if obj, err = v.object(v.sch.Elem(), hash); err != nil {
+			return
+		}
+		item := &JSONReference{Hash: hash.Hex()}
+		if err = e.object(item, obj, depth); err != nil {
+			return
+		}
+		ref.Items = append(ref.Items, item)
+	}
+	return
+}
+
+type importer struct {
+	p *Pack
+}
+
+// ImportJSON creates new Pack of given feed using JSON created by
+// ExportJSON. Registry of the JSON must be known by the Container.
+// Objects are encoded using schemas of the Registry and stored in the
+// Pack. Missing fields of structures are set to zero values. References
+// without values and incomplete Refs are imported by hashes, thus the
+// objects must be in database. Use Save method of the Pack to save the
+// Root. Public key and seq number of the JSON are ignored
+func (c *Container) ImportJSON(pk cipher.PubKey, sk cipher.SecKey,
+	b []byte, flags Flag, types *Types) (pack *Pack, err error) {
+
+	var jr JSONRoot
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err = dec.Decode(&jr); err != nil {
+		return
+	}
+
+	var rr RegistryRef
+	if rr, err = RegistryRefFromHex(jr.Registry); err != nil {
+		return
+	}
+	if c.Registry(rr) == nil {
+		return nil, fmt.Errorf("missing Registry [%s]", rr.Short())
+	}
+
+	if pack, err = c.NewRootReg(pk, sk, rr, flags, types); err != nil {
+		return
+	}
+
+	im := importer{pack}
+	for _, ref := range jr.Refs {
+		var val []byte
+		if val, err = im.reference(rootRefSchema, ref); err != nil {
+			return nil, err
+		}
+		var dr Dynamic
+		if err = encoder.DeserializeRaw(val, &dr); err != nil {
+			return nil, err
+		}
+		pack.initializeDynamic(&dr)
+		pack.r.Refs = append(pack.r.Refs, dr)
+	}
+	return
+}
+
+func importError(sch Schema, x interface{}) error {
+	return fmt.Errorf("can't import %T as %s", x, sch.String())
+}
+
+// encode value of given schema
+func (im *importer) value(sch Schema, x interface{}) (val []byte, err error) {
+
+	if sch.IsReference() {
+		var ref *JSONReference
+		if ref, err = jsonReference(x); err != nil {
+			return
+		}
+		return im.reference(sch, ref)
+	}
+
+	if x == nil {
+		return im.zero(sch)
+	}
+
+	switch sch.Kind() {
+	case reflect.Bool:
+		b, ok := x.(bool)
+		if !ok {
+			return nil, importError(sch, x)
+		}
+		return encoder.Serialize(b), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return im.int(sch, x)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return im.uint(sch, x)
+	case reflect.Float32, reflect.Float64:
+		return im.float(sch, x)
+	case reflect.String:
+		s, ok := x.(string)
+		if !ok {
+			return nil, importError(sch, x)
+		}
+		return encoder.Serialize(s), nil
+	case reflect.Array, reflect.Slice:
+		return im.list(sch, x)
+	case reflect.Struct:
+		obj, ok := x.(map[string]interface{})
+		if !ok {
+			return nil, importError(sch, x)
+		}
+		for name := range obj {
+			if !hasField(sch, name) {
+				return nil, fmt.Errorf("unknown field %q of %s", name,
+					sch.String())
+			}
+		}
+		for _, f := range sch.Fields() {
+			var fv []byte
+			if fv, err = im.value(f.Schema(), obj[f.Name()]); err != nil {
+				return
+			}
+			val = append(val, fv...)
+		}
+		return
+	}
+
+	return nil, fmt.Errorf("can't import %s", sch.String())
+}
+
+func hasField(sch Schema, name string) bool {
+	for _, f := range sch.Fields() {
+		if f.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// zero value of given schema
+func (im *importer) zero(sch Schema) (val []byte, err error) {
+	switch sch.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Float32, reflect.Float64:
+
+		return make([]byte, fixedSize(sch.Kind())), nil
+	case reflect.String, reflect.Slice:
+		return make([]byte, 4), nil // zero length
+	case reflect.Array:
+		return im.list(sch, []interface{}{})
+	case reflect.Struct:
+		return im.value(sch, map[string]interface{}{})
+	}
+	return nil, fmt.Errorf("can't import %s", sch.String())
+}
+
+func jsonNumber(sch Schema, x interface{}) (n json.Number, err error) {
+	switch y := x.(type) {
+	case json.Number:
+		return y, nil
+	case float64:
+		return json.Number(strconv.FormatFloat(y, 'f', -1, 64)), nil
+	}
+	return "", importError(sch, x)
+}
+
+func (im *importer) int(sch Schema, x interface{}) (val []byte, err error) {
+	var n json.Number
+	if n, err = jsonNumber(sch, x); err != nil {
+		return
+	}
+	var i int64
+	if i, err = strconv.ParseInt(n.String(), 10, fixedSize(sch.Kind())*8); err != nil {
+		return
+	}
+	switch sch.Kind() {
+	case reflect.Int8:
+		return encoder.Serialize(int8(i)), nil
+	case reflect.Int16:
+		return encoder.Serialize(int16(i)), nil
+	case reflect.Int32:
+		return encoder.Serialize(int32(i)), nil
+	}
+	return encoder.Serialize(i), nil
+}
+
+func (im *importer) uint(sch Schema, x interface{}) (val []byte, err error) {
+	var n json.Number
+	if n, err = jsonNumber(sch, x); err != nil {
+		return
+	}
+	var u uint64
+	if u, err = strconv.ParseUint(n.String(), 10, fixedSize(sch.Kind())*8); err != nil {
+		return
+	}
+	switch sch.Kind() {
+	case reflect.Uint8:
+		return encoder.Serialize(uint8(u)), nil
+	case reflect.Uint16:
+		return encoder.Serialize(uint16(u)), nil
+	case reflect.Uint32:
+		return encoder.Serialize(uint32(u)), nil
+	}
+	return encoder.Serialize(u), nil
+}
+
+func (im *importer) float(sch Schema, x interface{}) (val []byte, err error) {
+	var n json.Number
+	if n, err = jsonNumber(sch, x); err != nil {
+		return
+	}
+	var f float64
+	if f, err = n.Float64(); err != nil {
+		return
+	}
+	if sch.Kind() == reflect.Float32 {
+		if math.Abs(f) > math.MaxFloat32 {
+			return nil, fmt.Errorf("%s overflows float32", n)
+		}
+		return encoder.Serialize(float32(f)), nil
+	}
+	return encoder.Serialize(f), nil
+}
+
+// array or slice
+func (im *importer) list(sch Schema, x interface{}) (val []byte, err error) {
+
+	el := sch.Elem()
+	if el == nil {
+		return nil, ErrInvalidSchema
+	}
+
+	var items []interface{}
+
+	switch y := x.(type) {
+	case string:
+		// bytes
+		if el.IsReference() || el.Kind() != reflect.Uint8 {
+			return nil, importError(sch, x)
+		}
+		var b []byte
+		if b, err = hex.DecodeString(y); err != nil {
+			return
+		}
+		if sch.Kind() == reflect.Array {
+			if len(b) != sch.Len() {
+				return nil, fmt.Errorf("wrong length of %s: %d", sch.String(),
+					len(b))
+			}
+			return b, nil
+		}
+		return encoder.Serialize(b), nil
+	case []interface{}:
+		items = y
+	default:
+		return nil, importError(sch, x)
+	}
+
+	if sch.Kind() == reflect.Array {
+		if len(items) > sch.Len() {
+			return nil, fmt.Errorf("wrong length of %s: %d", sch.String(),
+				len(items))
+		}
+		// missing elements are zero
+		for len(items) < sch.Len() {
+			items = append(items, nil)
+		}
+	} else {
+		val = encoder.Serialize(uint32(len(items)))
+	}
+
+	for _, item := range items {
+		var ev []byte
+		if ev, err = im.value(el, item); err != nil {
+			return
+		}
+		val = append(val, ev...)
+	}
+	return
+}
+
+func jsonReference(x interface{}) (ref *JSONReference, err error) {
+	switch y := x.(type) {
+	case nil:
+		return new(JSONReference), nil
+	case *JSONReference:
+		return y, nil
+	case map[string]interface{}:
+		// decoded to interface{} by importer, encode and decode
+		// it again to get JSONReference keeping numbers
+		var b []byte
+		if b, err = json.Marshal(y); err != nil {
+			return
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		ref = new(JSONReference)
+		err = dec.Decode(ref)
+		return
+	}
+	return nil, fmt.Errorf("can't import %T as reference", x)
+}
+
+// hash of object of the reference; the object is
+// stored in the Pack if the reference has value
+func (im *importer) object(sch Schema, ref *JSONReference) (hash cipher.SHA256,
+	err error) {
+
+	if ref.Value == nil {
+		if ref.Hash == "" {
+			return // nil
+		}
+		return cipher.SHA256FromHex(ref.Hash)
+	}
+	var val []byte
+	if val, err = im.value(sch, ref.Value); err != nil {
+		return
+	}
+	return im.p.add(val), nil
+}
+
+// encode Ref, Refs or Dynamic
+func (im *importer) reference(sch Schema, ref *JSONReference) (val []byte,
+	err error) {
+
+	switch rt := sch.ReferenceType(); rt {
+	case ReferenceTypeSingle:
+		var r Ref
+		if r.Hash, err = im.object(sch.Elem(), ref); err != nil {
+			return
+		}
+		return encoder.Serialize(r), nil
+	case ReferenceTypeDynamic:
+		var dr Dynamic
+		if ref.Schema != "" {
+			var es Schema
+			if es, err = im.p.reg.SchemaByName(ref.Schema); err != nil {
+				return
+			}
+			dr.SchemaRef = es.Reference()
+			if dr.Object, err = im.object(es, ref); err != nil {
+				return
+			}
+		} else if ref.Hash != "" || ref.Value != nil {
+			return nil, fmt.Errorf("missing schema of Dynamic %q", ref.Hash)
+		}
+		return encoder.Serialize(dr), nil
+	case ReferenceTypeSlice:
+		return im.refs(sch, ref)
+	default:
+		return nil, fmt.Errorf("invalid ReferenceType %d", rt)
+	}
+}
+
+func (im *importer) refs(sch Schema, ref *JSONReference) (val []byte,
+	err error) {
+
+	var refs Refs
+
+	if ref.Offset != 0 || ref.Length != len(ref.Items) {
+		// incomplete Refs
+		if ref.Hash == "" {
+			if ref.Length == 0 && len(ref.Items) == 0 {
+				return encoder.Serialize(refs), nil // nil
+			}
+			return nil, ErrMissingValue
+		}
+		if refs.Hash, err = cipher.SHA256FromHex(ref.Hash); err != nil {
+			return
+		}
+		return encoder.Serialize(refs), nil
+	}
+
+	hashes := make([]cipher.SHA256, 0, len(ref.Items))
+	for _, item := range ref.Items {
+		var hash cipher.SHA256
+		if hash, err = im.object(sch.Elem(), item); err != nil {
+			return
+		}
+		if hash == (cipher.SHA256{}) {
+			return nil, ErrMissingValue // nil elements are not allowed
+		}
+		hashes = append(hashes, hash)
+	}
+
+	refs = im.p.Refs()
+	if err = refs.AppendHashes(hashes...); err != nil {
+		return
+	}
+	return encoder.Serialize(refs), nil
+}
@@ -0,0 +1,246 @@
+package skyobject
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// import given JSON to new feed and save
+func testImportJSON(t *testing.T, c *Container, b []byte) *Pack {
+	pk, sk := cipher.GenerateKeyPair()
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+	pack, err := c.ImportJSON(pk, sk, b, 0, c.CoreRegistry().Types())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pack.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return pack
+}
+
+func testDecodeJSONRoot(t *testing.T, b []byte) (jr *JSONRoot) {
+	jr = new(JSONRoot)
+	if err := json.Unmarshal(b, jr); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func compareRootRefs(t *testing.T, want, got *Root) {
+	if len(want.Refs) != len(got.Refs) {
+		t.Fatalf("wrong amount of Refs: want %d, got %d", len(want.Refs),
+			len(got.Refs))
+	}
+	for i, dr := range want.Refs {
+		if !dr.Eq(&got.Refs[i]) {
+			t.Errorf("wrong reference %d: want %s, got %s", i, dr.Short(),
+				got.Refs[i].Short())
+		}
+	}
+}
+
+func TestContainer_ExportJSON(t *testing.T) {
+	// ExportJSON(r *Root, conf *ExportConfig) (b []byte, err error)
+
+	c, pack, closeFunc := testValueContainer(t)
+	defer closeFunc()
+
+	t.Run("round trip", func(t *testing.T) {
+		b, err := c.ExportJSON(pack.Root(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jr := testDecodeJSONRoot(t, b)
+		if jr.Pub != pack.Root().Pub.Hex() {
+			t.Error("wrong pub")
+		}
+		if jr.Hash != pack.Root().Hash.Hex() {
+			t.Error("wrong hash")
+		}
+		if len(jr.Refs) != 3 {
+			t.Fatal("wrong amount of refs:", len(jr.Refs))
+		}
+		if jr.Refs[0].Schema != "cxo.Group" {
+			t.Error("wrong schema:", jr.Refs[0].Schema)
+		}
+		if jr.Refs[2].Hash != "" || jr.Refs[2].Value != nil {
+			t.Error("blank reference is not blank")
+		}
+		group, ok := jr.Refs[0].Value.(map[string]interface{})
+		if !ok {
+			t.Fatalf("wrong value of group: %T", jr.Refs[0].Value)
+		}
+		if group["Name"] != "group" {
+			t.Error("wrong name:", group["Name"])
+		}
+		tuple := jr.Refs[1].Value.(map[string]interface{})
+		if tuple["Bytes"] != "6279746573" {
+			t.Error("wrong bytes:", tuple["Bytes"])
+		}
+
+		ip := testImportJSON(t, c, b)
+		compareRootRefs(t, pack.Root(), ip.Root())
+
+		ib, err := c.ExportJSON(ip.Root(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ijr := testDecodeJSONRoot(t, ib)
+		ijr.Pub, ijr.Hash = jr.Pub, jr.Hash
+		if ib, err = json.Marshal(ijr); err != nil {
+			t.Fatal(err)
+		}
+		if b, err = json.Marshal(jr); err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != string(ib) {
+			t.Errorf("wrong JSON after import:\n%s\n%s", b, ib)
+		}
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		b, err := c.ExportJSON(pack.Root(), &ExportConfig{MaxDepth: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		jr := testDecodeJSONRoot(t, b)
+		group := jr.Refs[0].Value.(map[string]interface{})
+		leader := group["Leader"].(map[string]interface{})
+		if leader["schema"] != "cxo.User" || leader["hash"] == nil {
+			t.Error("wrong leader:", leader)
+		}
+		if _, ok := leader["value"]; ok {
+			t.Error("value of too deep reference")
+		}
+		members := group["Members"].(map[string]interface{})
+		if members["length"] != 3.0 {
+			t.Error("wrong length:", members["length"])
+		}
+		if _, ok := members["items"]; ok {
+			t.Error("items of too deep Refs")
+		}
+		// imported by hashes
+		ip := testImportJSON(t, c, b)
+		compareRootRefs(t, pack.Root(), ip.Root())
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		b, err := c.ExportJSON(pack.Root(), &ExportConfig{
+			RefsOffset: 1,
+			RefsLimit:  1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		jr := testDecodeJSONRoot(t, b)
+		group := jr.Refs[0].Value.(map[string]interface{})
+		members := group["Members"].(map[string]interface{})
+		items := members["items"].([]interface{})
+		if len(items) != 1 {
+			t.Fatal("wrong amount of items:", len(items))
+		}
+		bob := items[0].(map[string]interface{})["value"]
+		if name := bob.(map[string]interface{})["Name"]; name != "Bob" {
+			t.Error("wrong name:", name)
+		}
+		if members["offset"] != 1.0 || members["length"] != 3.0 {
+			t.Error("wrong offset or length:", members)
+		}
+		// incomplete Refs imported by hash
+		ip := testImportJSON(t, c, b)
+		compareRootRefs(t, pack.Root(), ip.Root())
+	})
+
+}
+
+func TestContainer_ImportJSON(t *testing.T) {
+	// ImportJSON(pk cipher.PubKey, sk cipher.SecKey, b []byte, flags Flag,
+	//     types *Types) (pack *Pack, err error)
+
+	c, _, closeFunc := testValueContainer(t)
+	defer closeFunc()
+
+	rr := c.CoreRegistry().Reference().String()
+
+	t.Run("seed", func(t *testing.T) {
+		seed := `{"registry": "` + rr + `", "refs": [
+			{"schema": "cxo.User", "value": {"Name": "Seed", "Age": 5}},
+			{"schema": "cxo.Group", "value": {
+				"Name": "seeds",
+				"Members": {"items": [
+					{"value": {"Name": "One"}},
+					{"value": {"Name": "Two"}}
+				], "length": 2}
+			}},
+			{}
+		]}`
+		ip := testImportJSON(t, c, []byte(seed))
+		vals, err := c.RootValues(ip.Root())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(vals) != 3 {
+			t.Fatal("wrong amount of values:", len(vals))
+		}
+		user, err := vals[0].Dereference()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if age, err := testValueField(t, user, "Age").Uint(); err != nil {
+			t.Error(err)
+		} else if age != 5 {
+			t.Error("wrong age:", age)
+		}
+		group, err := vals[1].Dereference()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if yep, err := testValueField(t, group, "Leader").IsNil(); err != nil {
+			t.Error(err)
+		} else if !yep {
+			t.Error("missing field is not zero")
+		}
+		member, err := testValueField(t, group, "Members").Index(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name, err := testValueField(t, member, "Name").Str(); err != nil {
+			t.Error(err)
+		} else if name != "Two" {
+			t.Error("wrong name:", name)
+		}
+		if yep, err := vals[2].IsNil(); err != nil {
+			t.Error(err)
+		} else if !yep {
+			t.Error("blank reference is not blank")
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		pk, sk := cipher.GenerateKeyPair()
+		types := c.CoreRegistry().Types()
+		for _, bad := range []string{
+			`{"registry": "` + strings.Repeat("0", 64) + `", "refs": []}`,
+			`{"registry": "` + rr + `", "refs": [
+				{"schema": "cxo.User", "value": {"Unknown": 1}}]}`,
+			`{"registry": "` + rr + `", "refs": [
+				{"schema": "cxo.User", "value": {"Age": -1}}]}`,
+			`{"registry": "` + rr + `", "refs": [
+				{"schema": "cxo.None", "value": {}}]}`,
+			`{"registry": "` + rr + `", "refs": [{"hash": "` +
+				strings.Repeat("1", 64) + `"}]}`,
+			`not a JSON`,
+		} {
+			if _, err := c.ImportJSON(pk, sk, []byte(bad), 0, types); err == nil {
+				t.Errorf("missing error for %s", bad)
+			}
+		}
+	})
+
+}
@@ -11,6 +11,9 @@ import (
 	"github.com/skycoin/skycoin/src/cipher/encoder"
 )
 
+// Inspect returns human readable tree of given Root. Unlike
+// the Walk, that stops on first error, the Inspect renders
+// missing and malformed objects in the tree and continues
 func (c *Container) Inspect(r *Root) string {
 	ins := inspector{
 		c: c,
@@ -39,7 +42,8 @@ func (i *inspector) Inspect() (s string) {
 		return
 	}
 
-	if i.reg = i.c.Registry(i.r.Reg); i.reg == nil {
+	// the Registry can be saved in database only
+	if i.reg = i.c.registryOf(i.r.Reg, i.c); i.reg == nil {
 		i.rootError(fmt.Sprintf("missing Registry [s%s] in container",
 			i.r.Reg.Short()))
 		return
@@ -1,11 +1,7 @@
 package skyobject
 
 import (
-	"fmt"
-	"reflect"
-
 	"github.com/skycoin/skycoin/src/cipher"
-	"github.com/skycoin/skycoin/src/cipher/encoder"
 )
 
 // knowsAboutFunc used to determine objects of a Root. If it returns
@@ -37,264 +33,54 @@ func (c *Container) knowsAbout(r *Root, g getter,
 		return
 	}
 	var reg *Registry
-	if reg = c.Registry(r.Reg); reg == nil {
+	if reg = c.registryOf(r.Reg, g); reg == nil {
 		return // return nil (no "missing Registry" errors)
 	}
 	// 2) refs ([]Dynamic)
-	var kn knowsAbout
-	kn.fn = fn
-	kn.g = g
-	kn.reg = reg
-	for _, dr := range r.Refs {
-		if err = kn.Dynamic(dr); err != nil {
-			break
-		}
-	}
-	return
-}
-
-type knowsAbout struct {
-	fn  knowsAboutFunc
-	g   getter
-	reg *Registry
-}
-
-func (k *knowsAbout) Dynamic(dr Dynamic) (err error) {
-	if !dr.IsValid() {
-		return fmt.Errorf("invalid dynamic %s", dr.Short())
-	}
-	if dr.Object == (cipher.SHA256{}) {
-		return // represents nil
-	}
-	var deeper bool
-	if deeper, err = k.fn(dr.Object); err != nil {
-		return // some error
-	}
-	if deeper == false {
-		return // don't inspect deeper
-	}
-	var sch Schema
-	if sch, err = k.reg.SchemaByReference(dr.SchemaRef); err != nil {
-		return
-	}
-	return k.Hash(sch, dr.Object)
-}
-
-func (k *knowsAbout) Hash(sch Schema, hash cipher.SHA256) (err error) {
-	if !sch.HasReferences() {
-		return // skip (no references)
-	}
-	var val []byte
-	if val = k.g.Get(hash); val == nil {
-		return // skip (not found)
-	}
-	return k.Data(sch, val)
-}
-
-func (k *knowsAbout) Data(sch Schema, val []byte) (err error) {
-	if !sch.HasReferences() {
-		return // skip (no references)
-	}
-	if sch.IsReference() {
-		return k.DataRefsSwitch(sch, val)
-	}
-	switch sch.Kind() {
-	case reflect.Array:
-		return k.DataArray(sch, val)
-	case reflect.Slice:
-		return k.DataSlice(sch, val)
-	case reflect.Struct:
-		return k.DataStruct(sch, val)
-	}
-	return fmt.Errorf("schema is not reference, array, slice or struct but"+
-		"HasReferenes() retruns true: %s", sch)
-}
-
-func (k *knowsAbout) DataArray(sch Schema, val []byte) (err error) {
-	ln := sch.Len()  // length of the array
-	el := sch.Elem() // schema of element
-	if el == nil {
-		err = fmt.Errorf("nil schema of element of array: %s", sch)
-		return
-	}
-	return k.rangeArraySlice(el, ln, val)
-}
-
-func (k *knowsAbout) DataSlice(sch Schema, val []byte) (err error) {
-	var ln int
-	if ln, err = getLength(val); err != nil {
-		return
-	}
-	el := sch.Elem() // schema of element
-	if el == nil {
-		err = fmt.Errorf("nil schema of element of slice: %s", sch)
-		return
-	}
-	return k.rangeArraySlice(el, ln, val[4:])
-}
-
-func (k *knowsAbout) DataStruct(sch Schema, val []byte) (err error) {
-	var shift, s int
-	for i, fl := range sch.Fields() {
-		if shift > len(val) {
-			err = fmt.Errorf("unexpected end of encoded struct <%s>, "+
-				"field number: %d, field name: %q, schema of field: %s",
-				i,
-				fl.Name(),
-				fl.Schema())
-			return
-		}
-		if s, err = fl.Schema().Size(val[shift:]); err != nil {
-			return
+	pre := func(n *WalkNode) (err error) {
+		if n.Hash == (cipher.SHA256{}) {
+			return ErrSkipNode // represents nil
 		}
-		if err = k.Data(fl.Schema(), val[shift:shift+s]); err != nil {
-			return
-		}
-		shift += s
-	}
-	return
-}
-
-func (k *knowsAbout) rangeArraySlice(el Schema, ln int,
-	val []byte) (err error) {
-
-	var shift, m int
-	for i := 0; i < ln; i++ {
-		if shift > len(val) {
-			err = fmt.Errorf("unexpected end of encoded  array or slice "+
-				"of <%s>, length: %d, index: %d", el, ln, i)
-			return
-		}
-		if m, err = el.Size(val[shift:]); err != nil {
-			return
-		}
-		if err = k.Data(el, val[shift:shift+m]); err != nil {
-			return
+		var deeper bool
+		if deeper, err = fn(n.Hash); err == nil && !deeper {
+			err = ErrSkipNode // don't inspect deeper
 		}
-		shift += m
-	}
-	return
-}
-
-func (k *knowsAbout) DataRefsSwitch(sch Schema, val []byte) error {
-	switch rt := sch.ReferenceType(); rt {
-	case ReferenceTypeSingle:
-		return k.DataRef(sch, val)
-	case ReferenceTypeSlice:
-		return k.DataRefs(sch, val)
-	case ReferenceTypeDynamic:
-		return k.DataDynamic(val)
-	default:
-		return fmt.Errorf("[ERR] reference with invalid ReferenceType: %d", rt)
-	}
-}
-
-func (k *knowsAbout) DataRef(sch Schema, val []byte) (err error) {
-	var ref Ref
-	if err = encoder.DeserializeRaw(val, &ref); err != nil {
-		return
-	}
-	el := sch.Elem()
-	if el == nil {
-		err = fmt.Errorf("[ERR] schema of Ref [%s] without element: %s",
-			ref.Short(),
-			sch)
-		return
-	}
-	if ref.IsBlank() {
-		return
-	}
-	var deeper bool
-	if deeper, err = k.fn(ref.Hash); err != nil {
-		return
-	}
-	if deeper == false {
 		return
 	}
-	return k.Hash(el, ref.Hash)
+	w := &walker{
+		c:   c,
+		g:   g,
+		reg: reg,
+		vis: &Visitor{Pre: map[NodeKind]WalkFunc{
+			NodeDynamic:    pre,
+			NodeRef:        pre,
+			NodeRefs:       pre,
+			NodeRefsBranch: pre,
+		}},
+		missing: func(*WalkNode) ([]byte, error) {
+			return nil, nil // skip (not found)
+		},
+		prune: true,
+	}
+	return w.root(r)
 }
 
-func (k *knowsAbout) DataRefs(sch Schema, val []byte) (err error) {
-
-	var refs Refs
-	if err = encoder.DeserializeRaw(val, &refs); err != nil {
-		return
-	}
-	if refs.IsBlank() {
-		return
-	}
-
-	var deepper bool
-	if deepper, err = k.fn(refs.Hash); err != nil {
+// registryOf returns Registry by reference loading it from given
+// getter if the Registry is not loaded yet. It returns nil if the
+// Registry not found or can't be decoded
+func (c *Container) registryOf(rr RegistryRef, g getter) (reg *Registry) {
+	if reg = c.Registry(rr); reg != nil {
 		return
 	}
-	if deepper == false {
-		return
-	}
-
-	el := sch.Elem()
-	if el == nil {
-		err = fmt.Errorf("[ERR] schema of Refs [%s] without element: %s",
-			refs.Short(),
-			sch)
-		return
+	val := g.Get(cipher.SHA256(rr))
+	if val == nil {
+		return // not found
 	}
-
-	if val = k.g.Get(refs.Hash); val == nil {
-		return // skip
+	var err error
+	if reg, err = DecodeRegistry(val); err != nil {
+		c.Printf("[ERR] can't decode registry %s: %v", rr.Short(), err)
+		return nil
 	}
-
-	var ers encodedRefs
-	if err = encoder.DeserializeRaw(val, &ers); err != nil {
-		return
-	}
-	return k.RefsNode(ers.Depth, ers.Nested, el)
-}
-
-func (k *knowsAbout) RefsNode(depth uint32, hashes []cipher.SHA256,
-	el Schema) (err error) {
-
-	for _, hash := range hashes {
-		if hash == (cipher.SHA256{}) {
-			continue
-		}
-
-		var deeper bool
-		if deeper, err = k.fn(hash); err != nil {
-			return
-		}
-		if deeper == false {
-			continue
-		}
-		if depth == 0 { // the leaf
-			if err = k.Hash(el, hash); err != nil {
-				return
-			}
-			continue
-		}
-
-		var val []byte
-		if val = k.g.Get(hash); val == nil {
-			return // skip (not found)
-		}
-
-		var ers encodedRefs
-		if err = encoder.DeserializeRaw(val, &ers); err != nil {
-			return
-		}
-		if err = k.RefsNode(depth-1, ers.Nested, el); err != nil {
-			return
-		}
-	}
-
+	c.addRegistry(reg) // already saved
 	return
 }
-
-func (k *knowsAbout) DataDynamic(val []byte) (err error) {
-	var dr Dynamic
-	if err = encoder.DeserializeRaw(val, &dr); err != nil {
-		return
-	}
-	return k.Dynamic(dr)
-
-}
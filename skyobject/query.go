@@ -0,0 +1,600 @@
+package skyobject
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/disiqueira/gotree"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// A Query represents parsed path to values of a Root.
+// A path starts with "Refs" (the Refs of the Root) and
+// contains steps
+//
+//     .Name         - field of a structure
+//     .*            - all fields of a structure
+//     [2]           - element of a Refs, an array or a slice,
+//     [-1]            negative index is index from the end
+//     [*]           - all elements
+//     [#a1b2c3]     - elements that reference objects with
+//                     hashes that starts with given hex
+//     [Age>=18]     - elements with field matching given
+//     [Name="Bob"]    predicate, possible operators are =,
+//                     !=, <, <=, > and >=, possible literals
+//                     are quoted strings, numbers, true and
+//                     false
+//
+// References are followed automatically. For example
+//
+//     Refs[0].Threads[*].Posts[-1].Body
+//
+// "Refs" alone or "Refs" followed by a field means all
+// elements of the Refs of the Root. A predicate after an
+// object that is not a list filters the object itself,
+// e.g. "Refs[Age>18][Age<30]". Elements that don't
+// have a field of a step are skipped silently. The same
+// for indices out of range
+type Query struct {
+	path  string
+	steps []queryStep
+}
+
+type queryStepKind int
+
+const (
+	queryField  queryStepKind = iota // .Name
+	queryFields                      // .*
+	queryIndex                       // [i]
+	queryAll                         // [*]
+	queryHash                        // [#hex]
+	queryFilter                      // [Field op literal]
+)
+
+type queryStep struct {
+	kind  queryStepKind
+	name  string // field name or hash prefix
+	index int
+
+	// filter
+	op  string
+	lit interface{} // string, bool or number (queryNumber)
+}
+
+// a number literal
+type queryNumber string
+
+// A Match represents value found by a Query. The Path is path to
+// the value with indices instead of selectors. The Hash is hash of
+// the object that is the Value or contains the Value
+type Match struct {
+	Path  string
+	Hash  cipher.SHA256
+	Value *Value
+}
+
+// query related errors
+var (
+	ErrEmptyQuery = errors.New("empty query")
+)
+
+func queryError(path string, pos int, msg string) error {
+	return fmt.Errorf("invalid query %q at %d: %s", path, pos, msg)
+}
+
+// ParseQuery parses given path
+func ParseQuery(path string) (q *Query, err error) {
+
+	if path == "" {
+		return nil, ErrEmptyQuery
+	}
+	if !strings.HasPrefix(path, "Refs") {
+		return nil, queryError(path, 0, `must start with "Refs"`)
+	}
+
+	q = &Query{path: path}
+
+	for i := len("Refs"); i < len(path); {
+		var st queryStep
+		switch path[i] {
+		case '.':
+			j := i + 1
+			for j < len(path) && path[j] != '.' && path[j] != '[' {
+				j++
+			}
+			switch name := path[i+1 : j]; {
+			case name == "":
+				return nil, queryError(path, i, "missing field name")
+			case name == "*":
+				st.kind = queryFields
+			case !isQueryName(name):
+				return nil, queryError(path, i, "invalid field name")
+			default:
+				st.kind, st.name = queryField, name
+			}
+			i = j
+		case '[':
+			j := closingBracket(path, i+1)
+			if j < 0 {
+				return nil, queryError(path, i, "missing ]")
+			}
+			if err = parseSelector(path[i+1:j], &st); err != nil {
+				return nil, queryError(path, i, err.Error())
+			}
+			i = j + 1
+		default:
+			return nil, queryError(path, i, "unexpected symbol")
+		}
+		q.steps = append(q.steps, st)
+	}
+
+	// "Refs" or "Refs.Field" means all elements of the Refs
+	if len(q.steps) == 0 || q.steps[0].kind == queryField ||
+		q.steps[0].kind == queryFields {
+
+		q.steps = append([]queryStep{{kind: queryAll}}, q.steps...)
+	}
+	return
+}
+
+// String returns the path
+func (q *Query) String() string {
+	return q.path
+}
+
+func isQueryName(name string) bool {
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+// index of closing bracket skipping quoted strings
+func closingBracket(path string, i int) int {
+	for quoted := false; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case ']':
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseSelector(sel string, st *queryStep) (err error) {
+
+	switch {
+	case sel == "":
+		return errors.New("empty selector")
+	case sel == "*":
+		st.kind = queryAll
+		return
+	case sel[0] == '#':
+		if len(sel) == 1 || len(sel) > 65 {
+			return errors.New("invalid length of hash")
+		}
+		for _, r := range sel[1:] {
+			if !strings.ContainsRune("0123456789abcdef", r) {
+				return errors.New("invalid hash")
+			}
+		}
+		st.kind, st.name = queryHash, sel[1:]
+		return
+	}
+
+	if st.index, err = strconv.Atoi(sel); err == nil {
+		st.kind = queryIndex
+		return
+	}
+
+	// filter
+	err = nil
+	i := strings.IndexAny(sel, "=!<>")
+	if i < 0 {
+		return errors.New("invalid selector")
+	}
+	st.kind, st.name = queryFilter, strings.TrimSpace(sel[:i])
+	if !isQueryName(st.name) {
+		return errors.New("invalid field name")
+	}
+	rest := sel[i:]
+	for _, op := range []string{"!=", "<=", ">=", "=", "<", ">"} {
+		if strings.HasPrefix(rest, op) {
+			st.op, rest = op, strings.TrimSpace(rest[len(op):])
+			break
+		}
+	}
+	if st.op == "" {
+		return errors.New("invalid operator")
+	}
+
+	switch {
+	case rest == "true":
+		st.lit = true
+	case rest == "false":
+		st.lit = false
+	case strings.HasPrefix(rest, `"`):
+		if st.lit, err = strconv.Unquote(rest); err != nil {
+			return errors.New("invalid string")
+		}
+	default:
+		if _, err = strconv.ParseFloat(rest, 64); err != nil {
+			return errors.New("invalid literal")
+		}
+		st.lit = queryNumber(rest)
+	}
+
+	if _, ok := st.lit.(bool); ok && st.op != "=" && st.op != "!=" {
+		return errors.New("invalid operator for boolean")
+	}
+	return
+}
+
+// Query returns values of given Root found by given path. See
+// Query type for path syntax. Go types of the objects are not
+// required
+func (c *Container) Query(r *Root, path string) (ms []Match, err error) {
+	if r == nil {
+		return nil, ErrInvalidArgument
+	}
+	var q *Query
+	if q, err = ParseQuery(path); err != nil {
+		return
+	}
+	var vals []*Value
+	if vals, err = c.RootValues(r); err != nil {
+		return
+	}
+	return q.run(vals)
+}
+
+// Query returns values of the Root of the Pack found by
+// given path. Unlike (*Container).Query the method looks
+// for objects in unsaved objects of the Pack too
+func (p *Pack) Query(path string) (ms []Match, err error) {
+	var q *Query
+	if q, err = ParseQuery(path); err != nil {
+		return
+	}
+	var vals []*Value
+	if vals, err = p.c.RootValues(p.r); err != nil {
+		return
+	}
+	for _, val := range vals {
+		val.p = p
+	}
+	return q.run(vals)
+}
+
+func (q *Query) run(vals []*Value) (ms []Match, err error) {
+
+	// the Refs of the Root is the first "list"
+	cur := []Match{{Path: "Refs"}}
+
+	for _, st := range q.steps {
+		var next []Match
+		for _, m := range cur {
+			var es []*Value
+			if m.Value == nil {
+				es = vals // the Refs of the Root
+			} else if es, err = queryElems(m.Value, st.kind); err != nil {
+				return
+			}
+			if next, err = st.apply(next, m, es); err != nil {
+				return
+			}
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+// elements of Refs, array or slice; the
+// list is nil if the Value is not a list
+func queryElems(v *Value, kind queryStepKind) (es []*Value, err error) {
+
+	if kind == queryField || kind == queryFields {
+		return // not required
+	}
+
+	if v.sch.IsReference() {
+		if v.sch.ReferenceType() == ReferenceTypeSlice {
+			return v.refsElems()
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice:
+		var ln int
+		if ln, err = v.Len(); err != nil {
+			return
+		}
+		for i := 0; i < ln; i++ {
+			var el *Value
+			if el, err = v.Index(i); err != nil {
+				return
+			}
+			es = append(es, el)
+		}
+	}
+	return
+}
+
+// follow a Ref or a Dynamic, the result is nil if the
+// reference is nil
+func follow(m Match) (fm *Match, err error) {
+	v := m.Value
+	if !v.sch.IsReference() || v.sch.ReferenceType() == ReferenceTypeSlice {
+		return &m, nil
+	}
+	if m.Hash, err = v.Hash(); err != nil {
+		return
+	}
+	if m.Value, err = v.Dereference(); err != nil || m.Value == nil {
+		return
+	}
+	return &m, nil
+}
+
+// apply the step to given Match with given elements
+// and append results to given list
+func (st *queryStep) apply(next []Match, m Match,
+	es []*Value) (_ []Match, err error) {
+
+	add := func(path string, v *Value) (err error) {
+		var fm *Match
+		if fm, err = follow(Match{path, m.Hash, v}); err != nil {
+			return
+		}
+		if fm != nil {
+			next = append(next, *fm)
+		}
+		return
+	}
+
+	elem := func(i int) string {
+		return m.Path + "[" + strconv.Itoa(i) + "]"
+	}
+
+	switch st.kind {
+	case queryField, queryFields:
+		v := m.Value
+		if v == nil || v.sch.IsReference() || v.Kind() != reflect.Struct {
+			return next, nil
+		}
+		for _, name := range v.Fields() {
+			if st.kind == queryField && name != st.name {
+				continue
+			}
+			var fv *Value
+			if fv, err = v.FieldByName(name); err != nil {
+				return
+			}
+			if err = add(m.Path+"."+name, fv); err != nil {
+				return
+			}
+		}
+	case queryIndex:
+		i := st.index
+		if i < 0 {
+			i += len(es)
+		}
+		if i >= 0 && i < len(es) {
+			err = add(elem(i), es[i])
+		}
+	case queryAll:
+		for i, e := range es {
+			if err = add(elem(i), e); err != nil {
+				return
+			}
+		}
+	case queryHash:
+		for i, e := range es {
+			if !e.sch.IsReference() {
+				break // not references
+			}
+			var hash cipher.SHA256
+			if hash, err = e.Hash(); err != nil {
+				return
+			}
+			if strings.HasPrefix(hash.Hex(), st.name) {
+				if err = add(elem(i), e); err != nil {
+					return
+				}
+			}
+		}
+	case queryFilter:
+		if es == nil && m.Value != nil {
+			// filter the structure itself
+			var ok bool
+			if ok, err = st.match(m.Value); err != nil || !ok {
+				return next, err
+			}
+			return append(next, m), nil
+		}
+		for i, e := range es {
+			var fm *Match
+			if fm, err = follow(Match{elem(i), m.Hash, e}); err != nil {
+				return
+			}
+			if fm == nil {
+				continue
+			}
+			var ok bool
+			if ok, err = st.match(fm.Value); err != nil {
+				return
+			}
+			if ok {
+				next = append(next, *fm)
+			}
+		}
+	}
+	return next, err
+}
+
+// match the filter against given object
+func (st *queryStep) match(v *Value) (ok bool, err error) {
+
+	if v.sch.IsReference() || v.Kind() != reflect.Struct {
+		return
+	}
+
+	var fv *Value
+	if fv, err = v.FieldByName(st.name); err == ErrNoSuchField {
+		return false, nil
+	} else if err != nil {
+		return
+	}
+	if fv.sch.IsReference() {
+		return
+	}
+
+	var cmp int // -1, 0, 1
+
+	switch lit := st.lit.(type) {
+	case bool:
+		if fv.Kind() != reflect.Bool {
+			return
+		}
+		var x bool
+		if x, err = fv.Bool(); err != nil {
+			return
+		}
+		if x != lit {
+			cmp = 1
+		}
+	case string:
+		if fv.Kind() != reflect.String {
+			return
+		}
+		var x string
+		if x, err = fv.Str(); err != nil {
+			return
+		}
+		cmp = strings.Compare(x, lit)
+	case queryNumber:
+		var known bool
+		if cmp, known, err = compareNumber(fv, string(lit)); err != nil ||
+			!known {
+
+			return
+		}
+	}
+
+	switch st.op {
+	case "=":
+		ok = cmp == 0
+	case "!=":
+		ok = cmp != 0
+	case "<":
+		ok = cmp < 0
+	case "<=":
+		ok = cmp <= 0
+	case ">":
+		ok = cmp > 0
+	case ">=":
+		ok = cmp >= 0
+	}
+	return
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compare value of a number with given literal, the
+// known is false if the value is not a number
+func compareNumber(v *Value, lit string) (cmp int, known bool, err error) {
+
+	f, _ := strconv.ParseFloat(lit, 64)
+
+	switch v.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var x int64
+		if x, err = v.Int(); err != nil {
+			return
+		}
+		if y, perr := strconv.ParseInt(lit, 10, 64); perr == nil {
+			switch {
+			case x < y:
+				cmp = -1
+			case x > y:
+				cmp = 1
+			}
+			return cmp, true, nil
+		}
+		return compareFloats(float64(x), f), true, nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var x uint64
+		if x, err = v.Uint(); err != nil {
+			return
+		}
+		if y, perr := strconv.ParseUint(lit, 10, 64); perr == nil {
+			switch {
+			case x < y:
+				cmp = -1
+			case x > y:
+				cmp = 1
+			}
+			return cmp, true, nil
+		}
+		return compareFloats(float64(x), f), true, nil
+	case reflect.Float32, reflect.Float64:
+		var x float64
+		if x, err = v.Float(); err != nil {
+			return
+		}
+		return compareFloats(x, f), true, nil
+	}
+	return
+}
+
+// InspectQuery returns printed tree of values of given Root
+// found by given path. See Query for path syntax
+func (c *Container) InspectQuery(r *Root, path string) (s string,
+	err error) {
+
+	var ms []Match
+	if ms, err = c.Query(r, path); err != nil {
+		return
+	}
+
+	ins := inspector{c: c, r: r}
+	ins.gt.Name = "(root) " + r.Short() + " " + path
+
+	if len(ms) == 0 {
+		ins.rootError("(not found)")
+	}
+
+	for _, m := range ms {
+		ins.reg = m.Value.reg
+		it := ins.Data(m.Value.sch, m.Value.val)
+		it.Name = m.Path + " {" + shortHash(m.Hash) + "}: " + it.Name
+		ins.gt.Items = append(ins.gt.Items, it)
+	}
+
+	return gotree.StringTree(ins.gt), nil
+}
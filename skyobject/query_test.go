@@ -0,0 +1,212 @@
+package skyobject
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestParseQuery(t *testing.T) {
+	// ParseQuery(path string) (q *Query, err error)
+
+	for _, path := range []string{
+		"Refs",
+		"Refs[0]",
+		"Refs.Name",
+		"Refs[-1].Threads[*].Posts[-1].Body",
+		"Refs[#a1b2].*",
+		`Refs[Name="Bob"]`,
+		`Refs[Name="with ] and \" inside"]`,
+		"Refs[Age>=18][Age<30]",
+		"Refs[Flag!=true]",
+		"Refs[Float>-1.5]",
+	} {
+		if q, err := ParseQuery(path); err != nil {
+			t.Errorf("%q: %v", path, err)
+		} else if q.String() != path {
+			t.Errorf("wrong String: %q", q.String())
+		}
+	}
+
+	for _, path := range []string{
+		"",
+		"Users",
+		"Refs[",
+		"Refs[]",
+		"Refs..Name",
+		"Refs.1Name",
+		"Refs[Age~1]",
+		"Refs[Age>]",
+		"Refs[Age>x]",
+		"Refs[Flag>true]",
+		`Refs[Name="unclosed]`,
+		"Refs[#xyz]",
+		"Refs?",
+	} {
+		if _, err := ParseQuery(path); err == nil {
+			t.Errorf("missing error for %q", path)
+		}
+	}
+}
+
+func testQuery(t *testing.T, c *Container, r *Root, path string) []Match {
+	ms, err := c.Query(r, path)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return ms
+}
+
+func compareMatches(t *testing.T, path string, want []string, ms []Match) {
+	if len(want) != len(ms) {
+		t.Errorf("%s: wrong amount of matches: want %d, got %d", path,
+			len(want), len(ms))
+		return
+	}
+	for i, m := range ms {
+		if got := valueString(m.Value); got != want[i] {
+			t.Errorf("%s: wrong value %d: want %s, got %s", path, i,
+				want[i], got)
+		}
+	}
+}
+
+func TestContainer_Query(t *testing.T) {
+	// Query(r *Root, path string) (ms []Match, err error)
+
+	c, pack, closeFunc := testValueContainer(t)
+	defer closeFunc()
+
+	r := pack.Root()
+	tupleHash := r.Refs[1].Object.Hex()
+
+	t.Run("nil root", func(t *testing.T) {
+		if _, err := c.Query(nil, "Refs"); err != ErrInvalidArgument {
+			t.Error("wrong error:", err)
+		}
+		if _, err := c.InspectQuery(nil, "Refs"); err != ErrInvalidArgument {
+			t.Error("wrong error:", err)
+		}
+	})
+
+	t.Run("paths", func(t *testing.T) {
+		for _, q := range []struct {
+			path  string
+			paths []string
+		}{
+			{"Refs", []string{"Refs[0]", "Refs[1]"}},
+			{"Refs.Name", []string{"Refs[0].Name"}},
+			{"Refs[0].*", []string{"Refs[0].Name", "Refs[0].Leader",
+				"Refs[0].Members", "Refs[0].Curator"}},
+			{"Refs[0].Members[-1].Name", []string{"Refs[0].Members[2].Name"}},
+			{"Refs[5]", nil},
+			{"Refs[2]", nil}, // nil reference
+		} {
+			ms := testQuery(t, c, r, q.path)
+			if len(ms) != len(q.paths) {
+				t.Errorf("%s: wrong amount of matches %d", q.path, len(ms))
+				continue
+			}
+			for i, m := range ms {
+				if m.Path != q.paths[i] {
+					t.Errorf("%s: wrong path %q", q.path, m.Path)
+				}
+			}
+		}
+	})
+
+	t.Run("values", func(t *testing.T) {
+		for _, q := range []struct {
+			path string
+			want []string
+		}{
+			{"Refs[0].Name", []string{`"group"`}},
+			{"Refs[0].Leader.Age", []string{"21"}},
+			{"Refs[0].Curator.Name", []string{`"Curator"`}},
+			{"Refs[0].Members[*].Name",
+				[]string{`"Alice"`, `"Bob"`, `"Eva"`}},
+			{"Refs[0].Members[Age>20].Name", []string{`"Alice"`, `"Bob"`}},
+			{"Refs[0].Members[Age>20][Age<=21].Name", []string{`"Alice"`}},
+			{`Refs[0].Members[Name!="Bob"].Age`, []string{"21", "19"}},
+			{`Refs[0].Members[Name>="C"].Name`, []string{`"Eva"`}},
+			{"Refs[0].Members[Unknown=1]", nil},
+			{"Refs[#" + tupleHash[:6] + "].Ints[1]", []string{"0"}},
+			{"Refs[#" + tupleHash + "].Names[-1]", []string{`"three"`}},
+			{"Refs[Flag=true].Signed", []string{"-100"}},
+			{"Refs[Signed<-99.5].Float", []string{"1.5"}},
+			{"Refs[Float=1.5].Bytes", []string{`"6279746573"`}},
+			{"Refs[1].Names[Name=1]", nil}, // not structures
+		} {
+			compareMatches(t, q.path, q.want, testQuery(t, c, r, q.path))
+		}
+	})
+
+	t.Run("hashes", func(t *testing.T) {
+		ms := testQuery(t, c, r, `Refs[0].Members[Name="Bob"]`)
+		if len(ms) != 1 {
+			t.Fatal("wrong amount of matches:", len(ms))
+		}
+		bob := ms[0]
+		if bob.Value.Schema().Name() != "cxo.User" {
+			t.Error("wrong schema:", bob.Value.Schema())
+		}
+		if bob.Hash != cipher.SumSHA256(bob.Value.Encoded()) {
+			t.Error("wrong hash of object")
+		}
+		ms = testQuery(t, c, r, "Refs[0].Members[1].Name")
+		if len(ms) != 1 || ms[0].Hash != bob.Hash {
+			t.Error("wrong hash of field")
+		}
+	})
+
+	t.Run("inspect", func(t *testing.T) {
+		s, err := c.InspectQuery(r, `Refs[0].Members[Name="Bob"]`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"Refs[0].Members[1] {", "cxo.User",
+			`"Bob"`} {
+
+			if !strings.Contains(s, want) {
+				t.Errorf("missing %q in %s", want, s)
+			}
+		}
+		if strings.Contains(s, "Alice") {
+			t.Error("not matched value printed")
+		}
+		if s, err = c.InspectQuery(r, "Refs[9]"); err != nil {
+			t.Fatal(err)
+		} else if !strings.Contains(s, "(not found)") {
+			t.Error("missing (not found):", s)
+		}
+		if _, err = c.InspectQuery(r, "Users"); err == nil {
+			t.Error("missing error")
+		}
+	})
+
+}
+
+func TestPack_Query(t *testing.T) {
+	// Query(path string) (ms []Match, err error)
+
+	c, _, closeFunc := testValueContainer(t)
+	defer closeFunc()
+
+	pk, sk := cipher.GenerateKeyPair()
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+	pack, err := c.NewRoot(pk, sk, 0, c.CoreRegistry().Types())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pack.Append(User{"Unsaved", 1, nil})
+
+	// not saved yet
+	ms, err := pack.Query("Refs[0].Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareMatches(t, "Refs[0].Name", []string{`"Unsaved"`}, ms)
+}
@@ -0,0 +1,486 @@
+package skyobject
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+
+	"github.com/skycoin/cxo/data"
+)
+
+// A QuotaPolicy represents behaviour of Container
+// when a quota is exceeded
+type QuotaPolicy int
+
+// quota policies
+const (
+	// QuotaReject rejects Root objects that exceed a quota
+	// with *QuotaError
+	QuotaReject QuotaPolicy = iota
+	// QuotaEvict removes oldest Root objects of a feed to fit
+	// quota of the feed, and oldest Root objects of least
+	// recently used feeds to fit global quota
+	QuotaEvict
+)
+
+// String implements fmt.Stringer interface
+func (q QuotaPolicy) String() string {
+	switch q {
+	case QuotaReject:
+		return "reject"
+	case QuotaEvict:
+		return "evict"
+	}
+	return fmt.Sprintf("QuotaPolicy<%d>", q)
+}
+
+// Set implements flag.Value interface
+func (q *QuotaPolicy) Set(s string) error {
+	switch s {
+	case "reject":
+		*q = QuotaReject
+	case "evict":
+		*q = QuotaEvict
+	default:
+		return fmt.Errorf("unknown quota policy %q", s)
+	}
+	return nil
+}
+
+// limits of quotas
+const (
+	LimitSpace string = "space" // max space
+	LimitRoots string = "roots" // max roots
+	LimitAge   string = "age"   // max age
+)
+
+// A QuotaError represents a quota exceeded by a Root object.
+// The Root can't be saved or marked as full
+type QuotaError struct {
+	Feed   cipher.PubKey // feed of the Root
+	Seq    uint64        // seq of the Root
+	Global bool          // global quota (or quota of the feed)
+	Limit  string        // LimitSpace, LimitRoots or LimitAge
+}
+
+// Error implements error interface
+func (q *QuotaError) Error() string {
+	quota := "quota"
+	if q.Global {
+		quota = "global quota"
+	}
+	return fmt.Sprintf("[%s:%d] %s exceeded: max %s",
+		q.Feed.Hex()[:7],
+		q.Seq,
+		quota,
+		q.Limit)
+}
+
+// hasQuotas returns true if any quota is set
+func (c *Config) hasQuotas() bool {
+	return !c.Quota.IsZero() || !c.FeedQuota.IsZero() ||
+		len(c.FeedQuotas) > 0
+}
+
+// feedQuota returns quota of given feed
+func (c *Config) feedQuota(pk cipher.PubKey) data.Quota {
+	if q, ok := c.FeedQuotas[pk]; ok {
+		return q
+	}
+	return c.FeedQuota
+}
+
+// validateQuota returns error if given quota has negative limits
+func validateQuota(name string, q data.Quota) error {
+	switch {
+	case q.MaxSpace < 0:
+		return fmt.Errorf("skyobject.Config.%s.MaxSpace is negative: %d",
+			name, q.MaxSpace)
+	case q.MaxRoots < 0:
+		return fmt.Errorf("skyobject.Config.%s.MaxRoots is negative: %d",
+			name, q.MaxRoots)
+	case q.MaxAge < 0:
+		return fmt.Errorf("skyobject.Config.%s.MaxAge is negative: %v",
+			name, q.MaxAge)
+	}
+	return nil
+}
+
+// touch marks given feed as recently used
+func (c *Container) touch(pk cipher.PubKey) {
+	c.qmx.Lock()
+	defer c.qmx.Unlock()
+
+	c.tick++
+	c.used[pk] = c.tick
+}
+
+// forget removes given feed from list of used feeds
+func (c *Container) forget(pk cipher.PubKey) {
+	c.qmx.Lock()
+	defer c.qmx.Unlock()
+
+	delete(c.used, pk)
+}
+
+// lru returns given feeds ordered by last usage,
+// feeds that never used since start go first
+func (c *Container) lru(feeds []cipher.PubKey) []cipher.PubKey {
+	c.qmx.Lock()
+	defer c.qmx.Unlock()
+
+	sort.SliceStable(feeds, func(i, j int) bool {
+		return c.used[feeds[i]] < c.used[feeds[j]]
+	})
+	return feeds
+}
+
+// DBStat returns statistic of DB of the Container
+// with quotas. Space of objects of a feed is calculated
+// only if the space is limited by a quota. Unlike other
+// fields, that are counters of the DB, the space is
+// calculated walking all roots of the feed
+func (c *Container) DBStat() (s data.Stat) {
+	s = c.DB().Stat()
+	s.Quota = c.conf.Quota
+	for pk, fs := range s.Feeds {
+		if fs.Quota = c.conf.feedQuota(pk); fs.Quota.MaxSpace > 0 {
+			var err error
+			if fs.ObjectsSpace, _, err = c.feedSpace(pk); err != nil {
+				c.Printf("[ERR] can't calculate space of feed %s: %v",
+					pk.Hex()[:7],
+					err)
+			}
+		}
+		s.Feeds[pk] = fs
+	}
+	return
+}
+
+// feedSpace returns space taken by roots of given feed
+// and all their objects. The bySeq is space freed by
+// eviction of a root (by seq) if roots are evicted from
+// oldest. The feedSpace walks roots from newest, and an
+// object is attributed to newest root that refers to it
+func (c *Container) feedSpace(pk cipher.PubKey) (space data.Space,
+	bySeq map[uint64]data.Space, err error) {
+
+	bySeq = make(map[uint64]data.Space)
+	err = c.DB().View(func(tx data.Tv) error {
+		roots := tx.Feeds().Roots(pk)
+		if roots == nil {
+			return nil
+		}
+		objs := tx.Objects()
+		seen := make(map[cipher.SHA256]struct{})
+		return roots.Descend(func(rp *data.RootPack) (err error) {
+			rs := data.Space(len(rp.Root))
+			defer func() {
+				bySeq[rp.Seq] = rs
+				space += rs
+			}()
+			var r *Root
+			if r, err = c.unpackRoot(pk, rp); err != nil {
+				return nil // skip malformed root
+			}
+			return c.knowsAbout(r, objs, func(hash cipher.SHA256) (bool,
+				error) {
+
+				if _, ok := seen[hash]; ok {
+					return false, nil
+				}
+				seen[hash] = struct{}{}
+				val := objs.Get(hash)
+				if val == nil {
+					return false, nil // missing
+				}
+				rs += data.Space(len(val))
+				return true, nil
+			})
+		})
+	})
+	return
+}
+
+// globalSpace returns space taken by all objects and roots
+func (c *Container) globalSpace() (space data.Space) {
+	s := c.DB().Stat()
+	space = s.Space
+	for _, fs := range s.Feeds {
+		space += fs.Space
+	}
+	return
+}
+
+// rootsCount returns number of roots of given feed,
+// or number of all roots if global is true
+func (c *Container) rootsCount(pk cipher.PubKey, global bool) (n int) {
+	s := c.DB().Stat()
+	if !global {
+		return s.Feeds[pk].Roots
+	}
+	for _, fs := range s.Feeds {
+		n += fs.Roots
+	}
+	return
+}
+
+// enforceQuotas checks quotas of feed of given Root and global
+// quota. The extra is space of the Root if it's not saved yet.
+// In QuotaEvict mode the enforceQuotas removes oldest Root objects
+// to fit the quotas. The Root and Root objects of its feed newer
+// then the Root are never evicted. The cleanmx must be locked
+func (c *Container) enforceQuotas(r *Root, extra int) (err error) {
+	fq, gq := c.conf.feedQuota(r.Pub), c.conf.Quota
+
+	if err = c.enforceAge(r, fq, false); err != nil {
+		return
+	}
+	if err = c.enforceAge(r, gq, true); err != nil {
+		return
+	}
+
+	if err = c.enforceRoots(r, fq, false, extra > 0); err != nil {
+		return
+	}
+	if err = c.enforceRoots(r, gq, true, extra > 0); err != nil {
+		return
+	}
+
+	if err = c.enforceSpace(r, fq, false, extra); err != nil {
+		return
+	}
+	return c.enforceSpace(r, gq, true, extra)
+}
+
+// quotaError returns *QuotaError for given Root
+func quotaError(r *Root, global bool, limit string) error {
+	return &QuotaError{
+		Feed:   r.Pub,
+		Seq:    r.Seq,
+		Global: global,
+		Limit:  limit,
+	}
+}
+
+func (c *Container) enforceAge(r *Root, q data.Quota, global bool) error {
+	if q.MaxAge == 0 {
+		return nil
+	}
+	before := time.Now().Add(-q.MaxAge).UnixNano()
+	if r.Time < before {
+		return quotaError(r, global, LimitAge)
+	}
+	if c.conf.QuotaPolicy != QuotaEvict {
+		return nil
+	}
+	return c.evictOlder(r, before, global)
+}
+
+func (c *Container) enforceRoots(r *Root, q data.Quota, global,
+	adding bool) (err error) {
+
+	if q.MaxRoots == 0 {
+		return
+	}
+	n := c.rootsCount(r.Pub, global)
+	if adding {
+		n++
+	}
+	for ; n > q.MaxRoots; n-- {
+		if _, err = c.evictOne(r, global, LimitRoots); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (c *Container) enforceSpace(r *Root, q data.Quota, global bool,
+	extra int) (err error) {
+
+	if q.MaxSpace == 0 {
+		return
+	}
+
+	// the space is calculated once and evicted
+	// roots are subtracted
+
+	var (
+		space data.Space
+		bySeq map[uint64]data.Space
+	)
+	if global {
+		space = c.globalSpace()
+	} else if space, bySeq, err = c.feedSpace(r.Pub); err != nil {
+		return
+	}
+	for space+data.Space(extra) > q.MaxSpace {
+		var ev evicted
+		if ev, err = c.evictOne(r, global, LimitSpace); err != nil {
+			return
+		}
+		if global {
+			space -= ev.space
+		} else {
+			space -= bySeq[ev.seq]
+		}
+	}
+	return
+}
+
+// evictable returns true if given root of given
+// feed can be evicted to save given Root
+func evictable(r *Root, pk cipher.PubKey, seq uint64) bool {
+	return pk != r.Pub || seq < r.Seq
+}
+
+// An evicted represents a Root object removed by the evictOne
+type evicted struct {
+	pk    cipher.PubKey // feed
+	seq   uint64        // seq of the Root
+	space data.Space    // space of the Root and removed objects
+}
+
+// objectsSpace returns length of objects of given Root that can be
+// removed with the Root. Objects referenced more then once are never
+// removed with a Root, and the objectsSpace doesn't go deeper them
+func (c *Container) objectsSpace(objs data.ViewObjects,
+	r *Root) (lens map[cipher.SHA256]data.Space) {
+
+	lens = make(map[cipher.SHA256]data.Space)
+	err := c.knowsAbout(r, objs, func(hash cipher.SHA256) (bool, error) {
+		if _, ok := lens[hash]; ok || objs.Refs(hash) > 1 {
+			return false, nil
+		}
+		val := objs.Get(hash)
+		if val == nil {
+			return false, nil // missing
+		}
+		lens[hash] = data.Space(len(val))
+		return true, nil
+	})
+	if err != nil {
+		c.Printf("[ERR] can't calculate space of %s: %v", r.Short(), err)
+	}
+	return
+}
+
+// evictOne removes oldest Root object of feed of given Root, or
+// oldest Root object of least recently used feed if global is
+// true. It returns *QuotaError if there is nothing to evict or
+// the policy is QuotaReject
+func (c *Container) evictOne(r *Root, global bool,
+	limit string) (ev evicted, err error) {
+
+	if c.conf.QuotaPolicy != QuotaEvict {
+		err = quotaError(r, global, limit)
+		return
+	}
+
+	var freed []cipher.SHA256
+	var ok bool // evicted
+
+	err = c.DB().Update(func(tx data.Tu) (err error) {
+		feeds := tx.Feeds()
+		candidates := []cipher.PubKey{r.Pub}
+		if global {
+			candidates = c.lru(feeds.List())
+		}
+		for _, pk := range candidates {
+			roots := feeds.Roots(pk)
+			if roots == nil {
+				continue
+			}
+			var oldest *data.RootPack
+			err = roots.Ascend(func(rp *data.RootPack) (_ error) {
+				if evictable(r, pk, rp.Seq) {
+					oldest = rp
+				}
+				return data.ErrStopIteration
+			})
+			if err != nil {
+				return
+			}
+			if oldest == nil {
+				continue
+			}
+			c.Debugf(VerbosePin, "evict root {%s:%d} (max %s)",
+				pk.Hex()[:7], oldest.Seq, limit)
+			ev.pk, ev.seq = pk, oldest.Seq
+			ev.space = data.Space(len(encoder.Serialize(oldest)))
+			var lens map[cipher.SHA256]data.Space
+			if x, err := c.unpackRoot(pk, oldest); err == nil {
+				lens = c.objectsSpace(tx.Objects(), x)
+			}
+			freed, err = c.delRoots(tx, roots,
+				[]*data.RootPack{oldest}, freed)
+			if ok = err == nil; ok {
+				for _, hash := range freed {
+					ev.space += lens[hash]
+				}
+			}
+			return
+		}
+		return
+	})
+
+	if err != nil {
+		return
+	}
+	c.forgetObjects(freed)
+	if !ok {
+		err = quotaError(r, global, limit)
+	}
+	return
+}
+
+// evictOlder removes Root objects created before given time of
+// feed of given Root or of all feeds if global is true
+func (c *Container) evictOlder(r *Root, before int64,
+	global bool) (err error) {
+
+	var freed []cipher.SHA256
+
+	err = c.DB().Update(func(tx data.Tu) (err error) {
+		feeds := tx.Feeds()
+		candidates := []cipher.PubKey{r.Pub}
+		if global {
+			candidates = feeds.List()
+		}
+		for _, pk := range candidates {
+			roots := feeds.Roots(pk)
+			if roots == nil {
+				continue
+			}
+			var old []*data.RootPack
+			err = roots.Ascend(func(rp *data.RootPack) (_ error) {
+				if !evictable(r, pk, rp.Seq) {
+					return data.ErrStopIteration
+				}
+				x, err := c.unpackRoot(pk, rp)
+				if err != nil || x.Time >= before {
+					return data.ErrStopIteration
+				}
+				old = append(old, rp)
+				return
+			})
+			if err != nil {
+				return
+			}
+			if freed, err = c.delRoots(tx, roots, old,
+				freed); err != nil {
+
+				return
+			}
+		}
+		return
+	})
+
+	if err == nil {
+		c.forgetObjects(freed)
+	}
+	return
+}
@@ -0,0 +1,209 @@
+package skyobject
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+func shouldBeQuotaError(t *testing.T, err error, global bool, limit string) {
+	if err == nil {
+		t.Error("missing error")
+	} else if qe, ok := err.(*QuotaError); !ok {
+		t.Errorf("unexpected error type %T: %v", err, err)
+	} else if qe.Global != global || qe.Limit != limit {
+		t.Error("wrong quota error:", qe)
+	}
+}
+
+// getQuotaCont returns Container with given quotas
+// and given feeds
+func getQuotaCont(feed, global data.Quota, policy QuotaPolicy,
+	pks ...cipher.PubKey) (c *Container) {
+
+	conf := getConf()
+	conf.FeedQuota = feed
+	conf.Quota = global
+	conf.QuotaPolicy = policy
+	c = NewContainer(data.NewMemoryDB(), conf)
+	for _, pk := range pks {
+		if err := c.AddFeed(pk); err != nil {
+			panic(err)
+		}
+	}
+	return
+}
+
+// addRoots adds given number of roots to given feed
+// starting from given seq
+func addRoots(c *Container, pk cipher.PubKey, sk cipher.SecKey, seq uint64,
+	n int) (err error) {
+
+	var prev cipher.SHA256
+	if seq > 0 {
+		if r, _, err := c.RootBySeq(pk, seq-1); err == nil {
+			prev = r.Hash
+		} else {
+			prev = cipher.SumSHA256([]byte("evicted"))
+		}
+	}
+	for i := 0; i < n; i++ {
+		rp := getSignedRootPack(pk, sk, seq+uint64(i), prev)
+		if _, err = c.AddRoot(pk, rp); err != nil {
+			return
+		}
+		prev = rp.Hash
+	}
+	return
+}
+
+func rootsOf(c *Container, pk cipher.PubKey) int {
+	return c.DB().Stat().Feeds[pk].Roots
+}
+
+func TestContainer_quotas(t *testing.T) {
+
+	pk, sk := cipher.GenerateKeyPair()
+
+	t.Run("roots reject", func(t *testing.T) {
+		c := getQuotaCont(data.Quota{MaxRoots: 2}, data.Quota{},
+			QuotaReject, pk)
+		defer c.Close()
+
+		if err := addRoots(c, pk, sk, 0, 2); err != nil {
+			t.Fatal(err)
+		}
+		err := addRoots(c, pk, sk, 2, 1)
+		shouldBeQuotaError(t, err, false, LimitRoots)
+		if n := rootsOf(c, pk); n != 2 {
+			t.Error("wrong number of roots:", n)
+		}
+	})
+
+	t.Run("roots evict", func(t *testing.T) {
+		c := getQuotaCont(data.Quota{MaxRoots: 2}, data.Quota{},
+			QuotaEvict, pk)
+		defer c.Close()
+
+		if err := addRoots(c, pk, sk, 0, 3); err != nil {
+			t.Fatal(err)
+		}
+		if n := rootsOf(c, pk); n != 2 {
+			t.Error("wrong number of roots:", n)
+		}
+		if _, _, err := c.RootBySeq(pk, 0); err == nil {
+			t.Error("oldest root is not evicted")
+		}
+	})
+
+	t.Run("global evict", func(t *testing.T) {
+		pk2, sk2 := cipher.GenerateKeyPair()
+
+		c := getQuotaCont(data.Quota{}, data.Quota{MaxRoots: 2},
+			QuotaEvict, pk, pk2)
+		defer c.Close()
+
+		if err := addRoots(c, pk, sk, 0, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := addRoots(c, pk2, sk2, 0, 1); err != nil {
+			t.Fatal(err)
+		}
+		// the pk is least recently used
+		if err := addRoots(c, pk2, sk2, 1, 1); err != nil {
+			t.Fatal(err)
+		}
+		if n := rootsOf(c, pk); n != 0 {
+			t.Error("wrong number of roots:", n)
+		}
+		if n := rootsOf(c, pk2); n != 2 {
+			t.Error("wrong number of roots:", n)
+		}
+	})
+
+	t.Run("age", func(t *testing.T) {
+		c := getQuotaCont(data.Quota{MaxAge: time.Hour}, data.Quota{},
+			QuotaEvict, pk)
+		defer c.Close()
+
+		r := &Root{Pub: pk, Time: time.Now().Add(-2 * time.Hour).UnixNano()}
+		rp := r.Pack()
+		rp.Hash = cipher.SumSHA256(rp.Root)
+		rp.Sig = cipher.SignHash(rp.Hash, sk)
+
+		_, err := c.AddRoot(pk, rp)
+		shouldBeQuotaError(t, err, false, LimitAge)
+	})
+
+	t.Run("space", func(t *testing.T) {
+		rp := getSignedRootPack(pk, sk, 0, cipher.SHA256{})
+		quota := data.Quota{MaxSpace: data.Space(len(rp.Root) * 3 / 2)}
+
+		c := getQuotaCont(quota, data.Quota{}, QuotaReject, pk)
+		defer c.Close()
+
+		if err := addRoots(c, pk, sk, 0, 1); err != nil {
+			t.Fatal(err)
+		}
+		err := addRoots(c, pk, sk, 1, 1)
+		shouldBeQuotaError(t, err, false, LimitSpace)
+	})
+
+	t.Run("space evict", func(t *testing.T) {
+		rp := getSignedRootPack(pk, sk, 0, cipher.SHA256{})
+		quota := data.Quota{MaxSpace: data.Space(len(rp.Root) * 5 / 2)}
+
+		c := getQuotaCont(quota, data.Quota{}, QuotaEvict, pk)
+		defer c.Close()
+
+		if err := addRoots(c, pk, sk, 0, 4); err != nil {
+			t.Fatal(err)
+		}
+		if n := rootsOf(c, pk); n != 2 {
+			t.Error("wrong number of roots:", n)
+		}
+	})
+
+	t.Run("global space evict", func(t *testing.T) {
+		c := getQuotaCont(data.Quota{}, data.Quota{}, QuotaEvict, pk)
+		defer c.Close()
+
+		if err := addRoots(c, pk, sk, 0, 2); err != nil {
+			t.Fatal(err)
+		}
+		// no room for another root
+		c.conf.Quota.MaxSpace = c.globalSpace() + 1
+		if err := addRoots(c, pk, sk, 2, 2); err != nil {
+			t.Fatal(err)
+		}
+		if n := rootsOf(c, pk); n != 2 {
+			t.Error("wrong number of roots:", n)
+		}
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		quota := data.Quota{MaxSpace: 1 << 20, MaxRoots: 10}
+
+		c := getQuotaCont(quota, quota, QuotaReject, pk)
+		defer c.Close()
+
+		if err := addRoots(c, pk, sk, 0, 1); err != nil {
+			t.Fatal(err)
+		}
+		s := c.DBStat()
+		if s.Quota != quota {
+			t.Error("wrong global quota:", s.Quota)
+		}
+		fs := s.Feeds[pk]
+		if fs.Quota != quota {
+			t.Error("wrong quota of feed:", fs.Quota)
+		}
+		if fs.ObjectsSpace == 0 {
+			t.Error("missing objects space")
+		}
+	})
+
+}
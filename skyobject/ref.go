@@ -30,6 +30,15 @@ func (r RegistryRef) Short() string {
 	return r.String()[:7]
 }
 
+// RegistryRefFromHex parses hex-encoded RegistryRef
+func RegistryRefFromHex(h string) (rr RegistryRef, err error) {
+	var hash cipher.SHA256
+	if hash, err = cipher.SHA256FromHex(h); err != nil {
+		return
+	}
+	return RegistryRef(hash), nil
+}
+
 //
 // SchemaRef
 //
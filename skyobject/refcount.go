@@ -0,0 +1,356 @@
+package skyobject
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+// incRoot increments references counters of all objects of given
+// full Root. Only objects referenced first time are inspected
+// deeper. The incRoot returns error if an object of the Root is
+// missing, the Root can't be full then and changes should be
+// rolled back
+func (c *Container) incRoot(objs data.UpdateObjects, r *Root) error {
+	c.Debugln(VerbosePin, "incRoot", r.Short())
+
+	err := c.knowsAbout(r, objs, func(hash cipher.SHA256) (deeper bool,
+		err error) {
+
+		var rc uint32
+		if rc, err = objs.Inc(hash); err != nil {
+			if err == data.ErrNotFound {
+				err = fmt.Errorf("missing object %s", hash.Hex()[:7])
+			}
+			return
+		}
+		deeper = rc == 1 // the object and its childs are not counted yet
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("can't count references of %s: %v", r.Short(), err)
+	}
+	return nil
+}
+
+// decRoot decrements references counters of all objects of given
+// full Root and adds objects that are not referenced anymore to
+// given zero set. The decRoot ignores (and logs) errors of walking
+func (c *Container) decRoot(objs data.UpdateObjects, pk cipher.PubKey,
+	rp *data.RootPack, zero map[cipher.SHA256]struct{}) error {
+
+	c.Debugln(VerbosePin, "decRoot", pk.Hex()[:7], rp.Seq)
+
+	r, err := c.unpackRoot(pk, rp)
+	if err != nil {
+		return err
+	}
+
+	err = c.knowsAbout(r, objs, func(hash cipher.SHA256) (deeper bool,
+		err error) {
+
+		if objs.Refs(hash) == 0 {
+			return // not counted or already freed
+		}
+		var rc uint32
+		if rc, err = objs.Dec(hash); err != nil {
+			return
+		}
+		if rc == 0 {
+			zero[hash] = struct{}{}
+			deeper = true // decrement childs
+		}
+		return
+	})
+	if err != nil {
+		c.Printf("[ERR] can't decrement references of %s: %v",
+			r.Short(),
+			err)
+	}
+	return nil
+}
+
+// delRootsBefore deletes roots of given bucket before given seq
+// (exclusive) freeing objects of the roots, see delRoots
+func (c *Container) delRootsBefore(tx data.Tu,
+	roots data.UpdateRoots, seq uint64,
+	freed []cipher.SHA256) ([]cipher.SHA256, error) {
+
+	var rps []*data.RootPack
+
+	// collect first, because some databases can't
+	// modify objects inside an iteration
+	err := roots.Ascend(func(rp *data.RootPack) (_ error) {
+		if rp.Seq >= seq {
+			return data.ErrStopIteration
+		}
+		rps = append(rps, rp)
+		return
+	})
+	if err != nil {
+		return freed, err
+	}
+
+	return c.delRoots(tx, roots, rps, freed)
+}
+
+// delRoots deletes given roots from given bucket decrementing
+// references counters of full roots and removing objects that
+// are not referenced anymore (see freeObjects)
+func (c *Container) delRoots(tx data.Tu, roots data.UpdateRoots,
+	rps []*data.RootPack, freed []cipher.SHA256) (_ []cipher.SHA256,
+	err error) {
+
+	objs := tx.Objects()
+
+	unused := make(map[cipher.SHA256]struct{})
+	var dropped []*data.RootPack // non-full
+
+	for _, rp := range rps {
+		if roots.IsFull(rp.Seq) {
+			if err = c.decRoot(objs, roots.Feed(), rp, unused); err != nil {
+				return freed, err
+			}
+		} else {
+			dropped = append(dropped, rp)
+		}
+		if err = roots.Del(rp.Seq); err != nil {
+			return freed, err
+		}
+	}
+
+	c.unusedObjects(objs, roots.Feed(), dropped, unused)
+
+	return c.freeObjects(tx, unused, freed)
+}
+
+// dropRoots removes objects of given deleted non-full roots.
+// Objects of non-full roots are not counted. Thus, the dropRoots
+// removes objects that have no references (see freeObjects)
+func (c *Container) dropRoots(tx data.Tu,
+	dropped map[cipher.PubKey][]*data.RootPack,
+	freed []cipher.SHA256) (_ []cipher.SHA256, err error) {
+
+	unused := make(map[cipher.SHA256]struct{})
+
+	for pk, rps := range dropped {
+		c.unusedObjects(tx.Objects(), pk, rps, unused)
+	}
+
+	return c.freeObjects(tx, unused, freed)
+}
+
+// unusedObjects adds objects of given deleted non-full roots
+// that have no references to given unused set. Errors of
+// walking are logged
+func (c *Container) unusedObjects(objs data.UpdateObjects,
+	pk cipher.PubKey, rps []*data.RootPack,
+	unused map[cipher.SHA256]struct{}) {
+
+	for _, rp := range rps {
+		r, err := c.unpackRoot(pk, rp)
+		if err != nil {
+			c.Printf("[ERR] can't unpack dropped root {%s:%d}: %v",
+				pk.Hex()[:7], rp.Seq, err)
+			continue
+		}
+		// objects of counted objects are counted too, thus
+		// the walking never goes deeper than a counted object
+		kerr := c.knowsAbout(r, objs, func(hash cipher.SHA256) (bool,
+			error) {
+
+			if _, ok := unused[hash]; ok || !isFree(objs, hash) {
+				return false, nil
+			}
+			unused[hash] = struct{}{}
+			return true, nil
+		})
+		if kerr != nil {
+			c.Printf("[ERR] can't walk dropped root %s: %v",
+				r.Short(),
+				kerr)
+		}
+	}
+}
+
+// isFree returns true if given object
+// exists and has no references
+func isFree(objs data.ViewObjects, hash cipher.SHA256) bool {
+	return objs.IsExist(hash) && objs.Refs(hash) == 0
+}
+
+// freeObjects removes given unused objects, except the core
+// Registry and objects used by stored non-full roots, because
+// they can be filled later. Removed objects are appended to
+// given freed slice
+func (c *Container) freeObjects(tx data.Tu,
+	unused map[cipher.SHA256]struct{},
+	freed []cipher.SHA256) (_ []cipher.SHA256, err error) {
+
+	if len(unused) == 0 {
+		return freed, nil
+	}
+
+	if cr := c.CoreRegistry(); cr != nil {
+		delete(unused, cipher.SHA256(cr.Reference()))
+	}
+
+	// keep objects used by remaining non-full roots
+
+	objs := tx.Objects()
+	seen := make(map[cipher.SHA256]struct{})
+	feeds := tx.Feeds()
+
+	err = feeds.Ascend(func(pk cipher.PubKey) error {
+		roots := feeds.Roots(pk)
+		return roots.Ascend(func(rp *data.RootPack) (_ error) {
+			if roots.IsFull(rp.Seq) {
+				return
+			}
+			r, err := c.unpackRoot(pk, rp)
+			if err != nil {
+				return // can't be filled
+			}
+			kerr := c.knowsAbout(r, objs, func(hash cipher.SHA256) (bool,
+				error) {
+
+				if _, ok := seen[hash]; ok || !isFree(objs, hash) {
+					return false, nil
+				}
+				seen[hash] = struct{}{}
+				delete(unused, hash)
+				return true, nil
+			})
+			if kerr != nil {
+				c.Printf("[ERR] knowsAbout of %s error: %v",
+					r.Short(),
+					kerr)
+			}
+			return
+		})
+	})
+	if err != nil {
+		return freed, err
+	}
+
+	for hash := range unused {
+		if err = objs.Del(hash); err != nil {
+			return freed, err
+		}
+		freed = append(freed, hash)
+	}
+
+	return freed, nil
+}
+
+// forgetObjects removes given objects from the
+// cache and registries of the objects from the
+// Container if any
+func (c *Container) forgetObjects(freed []cipher.SHA256) {
+	if len(freed) == 0 {
+		return
+	}
+
+	c.cache.del(freed)
+
+	c.rmx.Lock()
+	defer c.rmx.Unlock()
+
+	for _, hash := range freed {
+		if _, ok := c.regs[RegistryRef(hash)]; ok {
+			delete(c.regs, RegistryRef(hash))
+			c.stat.addRegistry(-1)
+		}
+	}
+}
+
+// Recount recounts references counters of all objects walking
+// through all full roots. The Recount is fsck-like tool that
+// used to fix counters or to build them after migration of an
+// old database. It returns number of fixed counters. The
+// NewContainer calls the Recount if it's necessary
+func (c *Container) Recount() (fixed int, err error) {
+	c.Debug(VerbosePin, "Recount")
+
+	c.cleanmx.Lock()
+	defer c.cleanmx.Unlock()
+
+	return c.recount()
+}
+
+// recount is Recount without locking,
+// the cleanmx must be locked
+func (c *Container) recount() (fixed int, err error) {
+	err = c.DB().Update(func(tx data.Tu) (err error) {
+
+		objs := tx.Objects()
+		feeds := tx.Feeds()
+
+		counts := make(map[cipher.SHA256]uint32)
+
+		err = feeds.Ascend(func(pk cipher.PubKey) error {
+			roots := feeds.Roots(pk)
+			return roots.Ascend(func(rp *data.RootPack) (err error) {
+				if !roots.IsFull(rp.Seq) {
+					return // not counted
+				}
+				var r *Root
+				if r, err = c.unpackRoot(pk, rp); err != nil {
+					return
+				}
+				kerr := c.knowsAbout(r, objs, func(hash cipher.SHA256) (bool,
+					error) {
+
+					if !objs.IsExist(hash) {
+						return false, nil // skip missing object
+					}
+					counts[hash]++
+					return counts[hash] == 1, nil
+				})
+				if kerr != nil {
+					c.Printf("[ERR] can't count references of %s: %v",
+						r.Short(),
+						kerr)
+				}
+				return
+			})
+		})
+		if err != nil {
+			return
+		}
+
+		// collect first, because some databases can't
+		// modify objects inside an iteration
+
+		var wrong []cipher.SHA256
+
+		err = objs.Ascend(func(key cipher.SHA256, _ []byte) (_ error) {
+			if objs.Refs(key) != counts[key] {
+				wrong = append(wrong, key)
+			}
+			return
+		})
+		if err != nil {
+			return
+		}
+
+		for _, key := range wrong {
+			if err = objs.SetRefs(key, counts[key]); err != nil {
+				return
+			}
+		}
+
+		fixed = len(wrong)
+		return objs.Recounted()
+	})
+
+	if err != nil {
+		fixed = 0
+		return
+	}
+
+	c.Debugln(VerbosePin, "Recount fixed", fixed)
+	return
+}
@@ -0,0 +1,312 @@
+package skyobject
+
+import (
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+func testRefs(t *testing.T, c *Container, hash cipher.SHA256, want uint32) {
+	err := c.DB().View(func(tx data.Tv) (_ error) {
+		if rc := tx.Objects().Refs(hash); rc != want {
+			t.Errorf("wrong references counter of %s: %d, want %d",
+				hash.Hex()[:7], rc, want)
+		}
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// saves new Root with given users
+func testSaveUsers(t *testing.T, c *Container, pk cipher.PubKey,
+	sk cipher.SecKey, users ...interface{}) *Root {
+
+	pack, err := c.NewRoot(pk, sk, 0, c.CoreRegistry().Types())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pack.Append(users...)
+	if _, err := pack.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return pack.Root()
+}
+
+func TestContainer_DelRootsBefore(t *testing.T) {
+	// DelRootsBefore(pk cipher.PubKey, seq uint64) (err error)
+
+	c := getCont()
+	defer c.db.Close()
+	defer c.Close()
+
+	pk, sk := cipher.GenerateKeyPair()
+
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	alice, bob := &User{Name: "Alice"}, &User{Name: "Bob"}
+
+	r0 := testSaveUsers(t, c, pk, sk, alice)
+	r1 := testSaveUsers(t, c, pk, sk, alice, bob)
+
+	aliceHash := r0.Refs[0].Object
+	bobHash := r1.Refs[1].Object
+	reg := cipher.SHA256(c.CoreRegistry().Reference())
+
+	testRefs(t, c, aliceHash, 2)
+	testRefs(t, c, bobHash, 1)
+	testRefs(t, c, reg, 2)
+
+	if err := c.DelRootsBefore(pk, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	testRefs(t, c, aliceHash, 1)
+	testRefs(t, c, bobHash, 1)
+	testRefs(t, c, reg, 1)
+
+	if err := c.DelFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Get(aliceHash) != nil || c.Get(bobHash) != nil {
+		t.Error("objects of deleted feed are not removed")
+	}
+	if c.Get(reg) == nil {
+		t.Error("core registry removed")
+	}
+
+}
+
+func TestContainer_CleanUp(t *testing.T) {
+	// CleanUp(keepRoots bool) (err error)
+
+	c := getCont()
+	defer c.db.Close()
+	defer c.Close()
+
+	pk, sk := cipher.GenerateKeyPair()
+
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	r := testSaveUsers(t, c, pk, sk, &User{Name: "Alice"})
+	testSaveUsers(t, c, pk, sk, &User{Name: "Eve"})
+
+	if err := c.CleanUp(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Get(r.Refs[0].Object) == nil {
+		t.Error("object of kept root removed")
+	}
+
+	if err := c.CleanUp(false); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Get(r.Refs[0].Object) != nil {
+		t.Error("object of removed root is not removed")
+	}
+
+	// orphaned object, for example of a root removed by Check
+	orphan := cipher.SumSHA256([]byte("orphan"))
+	if err := c.Set(orphan, []byte("orphan")); err != nil {
+		t.Fatal(err)
+	}
+
+	// non-full root
+	f := testSaveUsers(t, c, pk, sk, &User{Name: "Filling"})
+	testUnmark(t, c, f)
+
+	if err := c.CleanUp(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Get(orphan) != nil {
+		t.Error("orphaned object is not removed")
+	}
+	if c.Get(f.Refs[0].Object) == nil {
+		t.Error("object of non-full root removed")
+	}
+	if c.CoreRegistry() == nil || c.Get(cipher.SHA256(
+		c.CoreRegistry().Reference())) == nil {
+
+		t.Error("core registry removed")
+	}
+
+}
+
+// unmark given root and recount references
+func testUnmark(t *testing.T, c *Container, r *Root) {
+	err := c.DB().Update(func(tx data.Tu) error {
+		return tx.Feeds().Roots(r.Pub).SetMeta(r.Seq, data.RootMeta{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Recount(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContainer_freeObjects(t *testing.T) {
+
+	c := getCont()
+	defer c.db.Close()
+	defer c.Close()
+
+	pk, sk := cipher.GenerateKeyPair()
+
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	alice, bob := &User{Name: "Alice"}, &User{Name: "Bob"}
+
+	r0 := testSaveUsers(t, c, pk, sk, alice)
+	r1 := testSaveUsers(t, c, pk, sk, alice, bob)
+	testUnmark(t, c, r1) // filling
+
+	aliceHash := r0.Refs[0].Object
+	bobHash := r1.Refs[1].Object
+
+	testRefs(t, c, aliceHash, 1)
+	testRefs(t, c, bobHash, 0)
+
+	if err := c.DelRootsBefore(pk, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Get(aliceHash) == nil || c.Get(bobHash) == nil {
+		t.Error("objects of non-full root removed")
+	}
+
+	if err := c.DelFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Get(aliceHash) != nil || c.Get(bobHash) != nil {
+		t.Error("objects of deleted feed are not removed")
+	}
+
+}
+
+func TestContainer_removeNonFullRoots(t *testing.T) {
+
+	src := getCont()
+	defer src.db.Close()
+	defer src.Close()
+
+	pk, sk := cipher.GenerateKeyPair()
+	if err := src.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := &User{Name: "Alice"}
+	full := testSaveUsers(t, src, pk, sk, alice)
+	r := testSaveUsers(t, src, pk, sk, alice, &User{Name: "Eve"})
+
+	c := getCont()
+	defer c.db.Close()
+	defer c.Close()
+
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first root is full, the second one
+	// is not full and shares object of Alice
+
+	var rps []*data.RootPack
+	err := src.DB().View(func(tx data.Tv) (err error) {
+		err = tx.Objects().Ascend(func(key cipher.SHA256,
+			val []byte) error {
+
+			return c.Set(key, val)
+		})
+		if err != nil {
+			return
+		}
+		return tx.Feeds().Roots(pk).Ascend(func(rp *data.RootPack) (_ error) {
+			rps = append(rps, rp)
+			return
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rps) != 2 {
+		t.Fatal("wrong number of roots:", len(rps))
+	}
+
+	for i, rp := range rps {
+		x, err := c.AddRoot(pk, rp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			if err = c.MarkFull(x); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err = c.removeNonFullRoots(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Get(full.Refs[0].Object) == nil {
+		t.Error("object of full root removed")
+	}
+	if c.Get(r.Refs[1].Object) != nil {
+		t.Error("object of dropped root is not removed")
+	}
+	if c.Get(cipher.SHA256(c.CoreRegistry().Reference())) == nil {
+		t.Error("core registry removed")
+	}
+
+}
+
+func TestContainer_Recount(t *testing.T) {
+	// Recount() (fixed int, err error)
+
+	c := getCont()
+	defer c.db.Close()
+	defer c.Close()
+
+	pk, sk := cipher.GenerateKeyPair()
+
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	r := testSaveUsers(t, c, pk, sk, &User{Name: "Alice"})
+	testSaveUsers(t, c, pk, sk, &User{Name: "Alice"})
+
+	alice := r.Refs[0].Object
+
+	err := c.DB().Update(func(tx data.Tu) error {
+		return tx.Objects().SetRefs(alice, 10)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixed, err := c.Recount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed != 1 {
+		t.Error("wrong number of fixed counters:", fixed)
+	}
+
+	testRefs(t, c, alice, 2)
+
+}
@@ -3,6 +3,7 @@ package skyobject
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/cipher/encoder"
@@ -35,7 +36,7 @@ func (r *Root) Encode() []byte {
 	return encoder.Serialize(r)
 }
 
-// Pack of the Root (IsFull field always false)
+// Pack of the Root
 func (r *Root) Pack() (rp *data.RootPack) {
 	rp = new(data.RootPack)
 	rp.Root = r.Encode()
@@ -65,7 +66,7 @@ func (c *Container) LastFullPack(pk cipher.PubKey) (rp *data.RootPack,
 			return fmt.Errorf("no such feed %s", pk.Hex()[:7])
 		}
 		return roots.Descend(func(rpd *data.RootPack) (_ error) {
-			if rpd.IsFull {
+			if roots.IsFull(rpd.Seq) {
 				rp = rpd
 				return data.ErrStopIteration // data.ErrStopIteration
 			}
@@ -87,6 +88,37 @@ func (c *Container) LastFull(pk cipher.PubKey) (r *Root, err error) {
 	return c.unpackRoot(pk, rp)
 }
 
+// Refill returns Root objects of given feed that was full,
+// but lost some objects and should be filled again. See
+// Check and data.RootMeta
+func (c *Container) Refill(pk cipher.PubKey) (rs []*Root, err error) {
+	var rps []*data.RootPack
+	err = c.DB().View(func(tx data.Tv) (_ error) {
+		roots := tx.Feeds().Roots(pk)
+		if roots == nil {
+			return fmt.Errorf("no such feed %s", pk.Hex()[:7])
+		}
+		return roots.Ascend(func(rp *data.RootPack) (err error) {
+			var rm data.RootMeta
+			if rm, err = roots.Meta(rp.Seq); err == nil && rm.Refill {
+				rps = append(rps, rp)
+			}
+			return
+		})
+	})
+	if err != nil {
+		return
+	}
+	var r *Root
+	for _, rp := range rps {
+		if r, err = c.unpackRoot(pk, rp); err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return
+}
+
 // LastPack returns data.RootPack to send throug network
 func (c *Container) LastPack(pk cipher.PubKey) (rp *data.RootPack,
 	err error) {
@@ -120,6 +152,16 @@ func DecodeRoot(val []byte) (r *Root, err error) {
 	return
 }
 
+// RootTime returns timestamp of Root of given RootPack. It's
+// data.RootTimeFunc used to migrate database
+func RootTime(rp *data.RootPack) (tm int64, err error) {
+	var r *Root
+	if r, err = DecodeRoot(rp.Root); err != nil {
+		return
+	}
+	return r.Time, nil
+}
+
 // Short retusn string like "[1a2ef33:2]" ({pub_key:seq})
 func (r *Root) Short() string {
 	return fmt.Sprintf("{%s:%d}",
@@ -135,39 +177,170 @@ func (r *Root) String() string {
 		r.Hash.Hex()[:7])
 }
 
-// AddRoot to container. The method sets rp.IsFull to false.
-// The rp must not be nil.
+// AddRoot to container. The Root saved as non-full. The
+// rp must not be nil. The method verifies given RootPack
+// before saving: hash of the Root field, signature of the hash,
+// seq number and previous hash encoded inside the Root, and
+// chain of roots (if previous and next roots exist in DB). If
+// the RootPack is not valid, then the method returns
+// *data.RootError. If the Root exceeds a quota, then the
+// method returns *QuotaError (see QuotaPolicy)
 func (c *Container) AddRoot(pk cipher.PubKey, rp *data.RootPack) (r *Root,
 	err error) {
 
-	rp.IsFull = false
-
-	if r, err = c.unpackRoot(pk, rp); err != nil {
+	if r, err = c.verifyRootPack(pk, rp); err != nil {
 		return
 	}
 
 	c.cleanmx.Lock()
 	defer c.cleanmx.Unlock()
 
-	err = c.DB().Update(func(tx data.Tu) error {
+	if c.conf.hasQuotas() && !c.hasRoot(pk, rp.Seq) {
+		if err = c.enforceQuotas(r, len(rp.Root)); err != nil {
+			return
+		}
+	}
+
+	err = c.DB().Update(func(tx data.Tu) (err error) {
 		roots := tx.Feeds().Roots(pk)
 		if roots == nil {
 			return ErrNoSuchFeed
 		}
-		return roots.Add(rp)
+		if roots.Get(rp.Seq) != nil {
+			return data.ErrRootAlreadyExists
+		}
+		if err = verifyRootChain(pk, roots, rp); err != nil {
+			return
+		}
+		if err = roots.Add(rp); err != nil {
+			return
+		}
+		return roots.SetMeta(rp.Seq, data.RootMeta{Time: r.Time})
+	})
+	if err == nil {
+		c.touch(pk)
+	}
+	return
+}
+
+// hasRoot returns true if Root with given
+// seq of given feed exists
+func (c *Container) hasRoot(pk cipher.PubKey, seq uint64) (yep bool) {
+	c.DB().View(func(tx data.Tv) (_ error) {
+		if roots := tx.Feeds().Roots(pk); roots != nil {
+			yep = roots.Get(seq) != nil
+		}
+		return
 	})
 	return
 }
 
-// MarkFull marks given Root as full in DB
+// verifyRootPack checks hash and signature of given RootPack
+// and compares fields of the RootPack with encoded Root
+func (c *Container) verifyRootPack(pk cipher.PubKey,
+	rp *data.RootPack) (r *Root, err error) {
+
+	if rp.Seq == 0 {
+		if rp.Prev != (cipher.SHA256{}) {
+			err = data.NewRootError(pk, rp, "unexpected prev. reference")
+			return
+		}
+	} else if rp.Prev == (cipher.SHA256{}) {
+		err = data.NewRootError(pk, rp, "missing prev. reference")
+		return
+	}
+
+	if cipher.SumSHA256(rp.Root) != rp.Hash {
+		err = data.NewRootError(pk, rp, "wrong hash of the root")
+		return
+	}
+
+	if err = cipher.VerifySignature(pk, rp.Sig, rp.Hash); err != nil {
+		err = data.NewRootError(pk, rp, "invalid signature: "+err.Error())
+		return
+	}
+
+	if r, err = c.unpackRoot(pk, rp); err != nil {
+		err = data.NewRootError(pk, rp, err.Error())
+		return
+	}
+
+	switch {
+	case r.Pub != pk:
+		err = data.NewRootError(pk, rp, "the root belongs to another feed")
+	case r.Seq != rp.Seq:
+		err = data.NewRootError(pk, rp, fmt.Sprintf(
+			"seq of the RootPack (%d) is not seq of the Root (%d)",
+			rp.Seq,
+			r.Seq))
+	case r.Prev != rp.Prev:
+		err = data.NewRootError(pk, rp,
+			"prev. of the RootPack is not prev. of the Root")
+	}
+
+	if err != nil {
+		r = nil
+	}
+	return
+}
+
+// verifyRootChain checks that given RootPack fits previous
+// and next roots of the feed, if they are in DB
+func verifyRootChain(pk cipher.PubKey, roots data.ViewRoots,
+	rp *data.RootPack) (err error) {
+
+	if rp.Seq > 0 {
+		if prev := roots.Get(rp.Seq - 1); prev != nil {
+			if prev.Hash != rp.Prev {
+				return data.NewRootError(pk, rp,
+					"prev. reference doesn't match hash of previous root")
+			}
+		}
+	}
+
+	if next := roots.Get(rp.Seq + 1); next != nil {
+		if next.Prev != rp.Hash {
+			return data.NewRootError(pk, rp,
+				"next root doesn't point to the root")
+		}
+	}
+
+	return
+}
+
+// MarkFull marks given Root as full in DB and
+// increments references counters of its objects.
+// If the Root exceeds a quota, then the method
+// returns *QuotaError (see QuotaPolicy). If an
+// object of the Root is missing, then the method
+// returns error and the Root stays non-full
 func (c *Container) MarkFull(r *Root) (err error) {
-	err = c.DB().Update(func(tx data.Tu) error {
+	if c.conf.hasQuotas() {
+		c.cleanmx.Lock()
+		defer c.cleanmx.Unlock()
+
+		if _, full, _ := c.RootBySeq(r.Pub, r.Seq); !full {
+			if err = c.enforceQuotas(r, 0); err != nil {
+				return
+			}
+		}
+	}
+	err = c.DB().Update(func(tx data.Tu) (err error) {
 		roots := tx.Feeds().Roots(r.Pub)
 		if roots == nil {
 			return ErrNoSuchFeed
 		}
-		return roots.MarkFull(r.Seq)
+		if roots.IsFull(r.Seq) {
+			return // already full (and counted)
+		}
+		if err = roots.MarkFull(r.Seq); err != nil {
+			return
+		}
+		return c.incRoot(tx.Objects(), r) // or keep non-full
 	})
+	if err == nil {
+		c.touch(r.Pub)
+	}
 	return
 }
 
@@ -179,7 +352,7 @@ func (c *Container) RootBySeq(pk cipher.PubKey, seq uint64) (r *Root,
 	var rp *data.RootPack
 	err = c.DB().View(func(tx data.Tv) (_ error) {
 		if roots := tx.Feeds().Roots(pk); roots != nil {
-			rp = roots.Get(seq)
+			rp, full = roots.Get(seq), roots.IsFull(seq)
 		}
 		return
 	})
@@ -190,20 +363,61 @@ func (c *Container) RootBySeq(pk cipher.PubKey, seq uint64) (r *Root,
 		err = fmt.Errorf("root %d of %s not found", seq, pk.Hex()[:7])
 		return
 	}
-	full = rp.IsFull
+	r, err = c.unpackRoot(pk, rp)
+	return
+}
+
+// RootAtTime returns latest Root of given feed created at or before
+// given time. The method also returns "full" reply, that describes
+// fullness of the Root
+func (c *Container) RootAtTime(pk cipher.PubKey, t time.Time) (r *Root,
+	full bool, err error) {
+
+	var rp *data.RootPack
+	err = c.DB().View(func(tx data.Tv) (_ error) {
+		roots := tx.Feeds().Roots(pk)
+		if roots == nil {
+			return ErrNoSuchFeed
+		}
+		if rp = roots.AtTime(t.UnixNano()); rp != nil {
+			full = roots.IsFull(rp.Seq)
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	if rp == nil {
+		err = fmt.Errorf("root of %s at %s not found", pk.Hex()[:7],
+			t.Format(time.RFC3339))
+		return
+	}
 	r, err = c.unpackRoot(pk, rp)
 	return
 }
 
 // DelRootsBefore deletes root obejcts of given feed before given seq number
-// (exclusive). It never returns "no such feed" error. The error can only
+// (exclusive). Objects of the roots that are not referenced anymore will
+// be removed too. It never returns "no such feed" error. The error can only
 // be error of database
-func (c *Container) DelRootsBefore(pk cipher.PubKey, seq uint64) error {
-	return c.DB().Update(func(tx data.Tu) (_ error) {
+func (c *Container) DelRootsBefore(pk cipher.PubKey, seq uint64) (err error) {
+
+	c.cleanmx.Lock()
+	defer c.cleanmx.Unlock()
+
+	var freed []cipher.SHA256
+
+	err = c.DB().Update(func(tx data.Tu) (err error) {
 		roots := tx.Feeds().Roots(pk)
 		if roots == nil {
 			return // nothing to delete
 		}
-		return roots.DelBefore(seq)
+		freed, err = c.delRootsBefore(tx, roots, seq, freed)
+		return
 	})
+
+	if err == nil {
+		c.forgetObjects(freed)
+	}
+	return
 }
@@ -6,6 +6,8 @@ import (
 
 	"github.com/skycoin/skycoin/src/cipher"
 	//"github.com/skycoin/skycoin/src/cipher/encoder"
+
+	"github.com/skycoin/cxo/data"
 )
 
 func TestRoot_Encode(t *testing.T) {
@@ -93,6 +95,21 @@ func TestDecodeRoot(t *testing.T) {
 
 }
 
+func TestRootTime(t *testing.T) {
+	// RootTime(rp *data.RootPack) (tm int64, err error)
+
+	r := Root{Seq: 1, Time: time.Now().UnixNano()}
+	if tm, err := RootTime(r.Pack()); err != nil {
+		t.Error(err)
+	} else if tm != r.Time {
+		t.Error("wrong time:", tm)
+	}
+	if _, err := RootTime(&data.RootPack{Root: []byte("x")}); err == nil {
+		t.Error("missing error")
+	}
+
+}
+
 func TestRoot_Short(t *testing.T) {
 	// Short() string
 
@@ -106,17 +123,237 @@ func TestRoot_String(t *testing.T) {
 
 }
 
+// getSignedRootPack returns signed RootPack with given seq and prev
+func getSignedRootPack(pk cipher.PubKey, sk cipher.SecKey, seq uint64,
+	prev cipher.SHA256) *data.RootPack {
+
+	r := &Root{
+		Pub:  pk,
+		Seq:  seq,
+		Time: time.Now().UnixNano(),
+		Prev: prev,
+	}
+	rp := r.Pack()
+	rp.Hash = cipher.SumSHA256(rp.Root)
+	rp.Sig = cipher.SignHash(rp.Hash, sk)
+	return rp
+}
+
 func TestContainer_AddRoot(t *testing.T) {
 	// AddRoot(pk cipher.PubKey, rp *data.RootPack) (r *Root, err error)
 
-	// TODO (kostyarin): low priority
+	pk, sk := cipher.GenerateKeyPair()
+
+	shouldBeRootError := func(t *testing.T, err error) {
+		if err == nil {
+			t.Error("missing error")
+		} else if _, ok := err.(*data.RootError); !ok {
+			t.Errorf("unexpected error type %T: %v", err, err)
+		}
+	}
+
+	t.Run("no such feed", func(t *testing.T) {
+		c := getCont()
+		defer c.Close()
+
+		rp := getSignedRootPack(pk, sk, 0, cipher.SHA256{})
+		if _, err := c.AddRoot(pk, rp); err != ErrNoSuchFeed {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		c := getCont()
+		defer c.Close()
+
+		if err := c.AddFeed(pk); err != nil {
+			t.Fatal(err)
+		}
+
+		rp := getSignedRootPack(pk, sk, 0, cipher.SHA256{})
+		r, err := c.AddRoot(pk, rp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Hash != rp.Hash || r.Sig != rp.Sig || r.Seq != 0 {
+			t.Error("wrong root returned")
+		}
+
+		next := getSignedRootPack(pk, sk, 1, rp.Hash)
+		if _, err = c.AddRoot(pk, next); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = c.AddRoot(pk, rp); err != data.ErrRootAlreadyExists {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+	t.Run("wrong hash", func(t *testing.T) {
+		c := getCont()
+		defer c.Close()
+
+		if err := c.AddFeed(pk); err != nil {
+			t.Fatal(err)
+		}
+
+		rp := getSignedRootPack(pk, sk, 0, cipher.SHA256{})
+		rp.Hash = cipher.SumSHA256([]byte("wrong"))
+		rp.Sig = cipher.SignHash(rp.Hash, sk)
+
+		_, err := c.AddRoot(pk, rp)
+		shouldBeRootError(t, err)
+	})
+
+	t.Run("wrong signature", func(t *testing.T) {
+		c := getCont()
+		defer c.Close()
+
+		if err := c.AddFeed(pk); err != nil {
+			t.Fatal(err)
+		}
+
+		_, ask := cipher.GenerateKeyPair()
+
+		rp := getSignedRootPack(pk, ask, 0, cipher.SHA256{})
+
+		_, err := c.AddRoot(pk, rp)
+		shouldBeRootError(t, err)
+	})
+
+	t.Run("another feed", func(t *testing.T) {
+		c := getCont()
+		defer c.Close()
+
+		apk, ask := cipher.GenerateKeyPair()
+
+		if err := c.AddFeed(pk); err != nil {
+			t.Fatal(err)
+		}
+
+		// signed by pk but encoded Root belongs to apk
+		rp := getSignedRootPack(apk, ask, 0, cipher.SHA256{})
+		rp.Sig = cipher.SignHash(rp.Hash, sk)
+
+		_, err := c.AddRoot(pk, rp)
+		shouldBeRootError(t, err)
+	})
+
+	t.Run("seq mismatch", func(t *testing.T) {
+		c := getCont()
+		defer c.Close()
+
+		if err := c.AddFeed(pk); err != nil {
+			t.Fatal(err)
+		}
+
+		rp := getSignedRootPack(pk, sk, 1,
+			cipher.SumSHA256([]byte("prev")))
+		rp.Seq = 2
+
+		_, err := c.AddRoot(pk, rp)
+		shouldBeRootError(t, err)
+	})
+
+	t.Run("prev mismatch", func(t *testing.T) {
+		c := getCont()
+		defer c.Close()
+
+		if err := c.AddFeed(pk); err != nil {
+			t.Fatal(err)
+		}
+
+		rp := getSignedRootPack(pk, sk, 0, cipher.SHA256{})
+		rp.Seq, rp.Prev = 1, cipher.SumSHA256([]byte("prev"))
+
+		_, err := c.AddRoot(pk, rp)
+		shouldBeRootError(t, err)
+
+		rp = getSignedRootPack(pk, sk, 1, cipher.SHA256{})
+
+		_, err = c.AddRoot(pk, rp)
+		shouldBeRootError(t, err)
+	})
+
+	t.Run("broken chain", func(t *testing.T) {
+		c := getCont()
+		defer c.Close()
+
+		if err := c.AddFeed(pk); err != nil {
+			t.Fatal(err)
+		}
+
+		rp := getSignedRootPack(pk, sk, 0, cipher.SHA256{})
+		if _, err := c.AddRoot(pk, rp); err != nil {
+			t.Fatal(err)
+		}
+
+		// points to another root
+		next := getSignedRootPack(pk, sk, 1,
+			cipher.SumSHA256([]byte("another")))
+
+		_, err := c.AddRoot(pk, next)
+		shouldBeRootError(t, err)
+
+		// previous root doesn't fit next one
+		c2 := getCont()
+		defer c2.Close()
+
+		if err := c2.AddFeed(pk); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c2.AddRoot(pk, next); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c2.AddRoot(pk, rp)
+		shouldBeRootError(t, err)
+	})
 
 }
 
 func TestContainer_MarkFull(t *testing.T) {
 	// MarkFull(r *Root) (err error)
 
-	// TODO (kostyarin): low priority
+	c := getCont()
+	defer c.db.Close()
+	defer c.Close()
+
+	pk, sk := cipher.GenerateKeyPair()
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	r := testSaveUsers(t, c, pk, sk, &User{Name: "Alice"})
+	testUnmark(t, c, r)
+
+	alice := r.Refs[0].Object
+	val := c.Get(alice)
+
+	err := c.DB().Update(func(tx data.Tu) error {
+		return tx.Objects().Del(alice)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.MarkFull(r); err == nil {
+		t.Error("missing error")
+	}
+	if _, full, err := c.RootBySeq(pk, r.Seq); err != nil {
+		t.Fatal(err)
+	} else if full {
+		t.Error("marked as full with missing object")
+	}
+
+	if err = c.Set(alice, val); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.MarkFull(r); err != nil {
+		t.Fatal(err)
+	}
+	testRefs(t, c, alice, 1)
 
 }
 
@@ -126,3 +363,61 @@ func TestContainer_RootBySeq(t *testing.T) {
 	// TODO (kostyarin): low priority
 
 }
+
+func TestContainer_RootAtTime(t *testing.T) {
+	// RootAtTime(pk cipher.PubKey, t time.Time) (r *Root, full bool,
+	//     err error)
+
+	pk, sk := cipher.GenerateKeyPair()
+
+	conf := getConf()
+	conf.KeepNonFull = true
+	c := NewContainer(data.NewMemoryDB(), conf)
+	defer c.Close()
+
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Now()
+
+	var prev cipher.SHA256
+	for i := 0; i < 3; i++ {
+		r := &Root{
+			Pub:  pk,
+			Seq:  uint64(i),
+			Time: base.Add(time.Duration(i) * time.Hour).UnixNano(),
+			Prev: prev,
+		}
+		rp := r.Pack()
+		rp.Hash = cipher.SumSHA256(rp.Root)
+		rp.Sig = cipher.SignHash(rp.Hash, sk)
+		if _, err := c.AddRoot(pk, rp); err != nil {
+			t.Fatal(err)
+		}
+		prev = rp.Hash
+	}
+
+	var err error
+
+	if _, _, err = c.RootAtTime(pk, base.Add(-time.Second)); err == nil {
+		t.Error("missing error")
+	}
+	r, full, err := c.RootAtTime(pk, base.Add(90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Seq != 1 || full {
+		t.Errorf("wrong root %d (full: %t)", r.Seq, full)
+	}
+	if r, _, err = c.RootAtTime(pk, base.Add(time.Hour*24)); err != nil {
+		t.Fatal(err)
+	} else if r.Seq != 2 {
+		t.Error("wrong root", r.Seq)
+	}
+
+	unknown, _ := cipher.GenerateKeyPair()
+	if _, _, err = c.RootAtTime(unknown, base); err != ErrNoSuchFeed {
+		t.Error("unexpected error:", err)
+	}
+}
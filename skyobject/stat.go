@@ -10,6 +10,18 @@ type Stat struct {
 	Registries int           // amount of unpacked registries
 	Save       time.Duration // avg time of pack.Save() call
 	CleanUp    time.Duration // avg time of c.CleanUp() call
+
+	CacheHits   uint64 // number of objects found in the cache
+	CacheMisses uint64 // number of objects not found in the cache
+}
+
+// CacheRatio returns hit ratio of the cache of objects
+// in range [0, 1]. It returns 0 if the cache is not used
+func (s *Stat) CacheRatio() float64 {
+	if total := s.CacheHits + s.CacheMisses; total > 0 {
+		return float64(s.CacheHits) / float64(total)
+	}
+	return 0
 }
 
 // rolling average of duration
@@ -96,6 +108,8 @@ func (s *stat) Stat() (cp Stat) {
 }
 
 // Stat of Container
-func (c *Container) Stat() Stat {
-	return c.stat.Stat()
+func (c *Container) Stat() (s Stat) {
+	s = c.stat.Stat()
+	s.CacheHits, s.CacheMisses = c.cache.stat()
+	return
 }
@@ -83,16 +83,9 @@ func (p *Pack) get(key cipher.SHA256) (val []byte, err error) {
 	if val, ok = p.unsaved[key]; ok {
 		return
 	}
-	// ignore DB error
-	err = p.c.DB().View(func(tx data.Tv) (_ error) {
-		val = tx.Objects().Get(key)
-		return
-	})
-
-	if err == nil && val == nil {
+	if val = p.c.Get(key); val == nil {
 		err = fmt.Errorf("object [%s] not found", key.Hex()[:7])
 	}
-
 	return
 }
 
@@ -169,7 +162,6 @@ func (p *Pack) Save() (rp data.RootPack, err error) {
 		p.r.Sig = cipher.SignHash(p.r.Hash, p.sk)
 
 		rp.Hash = p.r.Hash
-		rp.IsFull = true
 		rp.Prev = p.r.Prev
 		rp.Root = val
 		rp.Seq = p.r.Seq
@@ -178,8 +170,16 @@ func (p *Pack) Save() (rp data.RootPack, err error) {
 		if err = roots.Add(&rp); err != nil {
 			return
 		}
+		err = roots.SetMeta(rp.Seq, data.RootMeta{IsFull: true, Time: p.r.Time})
+		if err != nil {
+			return
+		}
 		// save objects
-		return tx.Objects().SetMap(p.unsaved)
+		objs := tx.Objects()
+		if err = objs.SetMap(p.unsaved); err != nil {
+			return
+		}
+		return p.c.incRoot(objs, p.r) // the Root is full
 	})
 
 	if err == nil {
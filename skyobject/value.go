@@ -0,0 +1,479 @@
+package skyobject
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+)
+
+// A Value represents encoded object (or a part of an object)
+// and its Schema. The Value decodes the object using the Schema
+// and the Registry of a Root only. Thus, Go types of objects
+// are not required to read them. It's useful for explorers and
+// bridges to other languages. A Value is read-only. Use
+// Container.RootValues to get Values of a Root
+type Value struct {
+	c   *Container
+	p   *Pack     // unsaved objects (can be nil)
+	reg *Registry // registry of the Root
+	sch Schema    // schema of the value
+	val []byte    // encoded value
+}
+
+// schema of Dynamic references of a Root
+var rootRefSchema Schema = &referenceSchema{
+	schema: schema{kind: typeOfDynamic.Kind()},
+	typ:    ReferenceTypeDynamic,
+}
+
+// NewValue creates Value of given Schema and encoded data.
+// Given Registry is used to follow Dynamic references. The
+// encoded data must not be modified after
+func (c *Container) NewValue(reg *Registry, sch Schema, val []byte) *Value {
+	return &Value{c: c, reg: reg, sch: sch, val: val}
+}
+
+// RootValues returns Values of Dynamic references of given
+// Root. Use Dereference to get referenced objects. The Root
+// must have Registry. The Registry loaded from database if
+// it's not loaded yet
+func (c *Container) RootValues(r *Root) (vals []*Value, err error) {
+	if r == nil {
+		return nil, ErrInvalidArgument
+	}
+	if r.Reg == (RegistryRef{}) {
+		return nil, ErrEmptyRegsitryRef
+	}
+	reg := c.registryOf(r.Reg, c)
+	if reg == nil {
+		return nil, fmt.Errorf("missing Registry [%s]", r.Reg.Short())
+	}
+	vals = make([]*Value, 0, len(r.Refs))
+	for _, dr := range r.Refs {
+		vals = append(vals, c.NewValue(reg, rootRefSchema,
+			encoder.Serialize(dr)))
+	}
+	return
+}
+
+// value of given schema that uses
+// the same Container, Pack and Registry
+func (v *Value) sub(sch Schema, val []byte) *Value {
+	return &Value{c: v.c, p: v.p, reg: v.reg, sch: sch, val: val}
+}
+
+// get object by hash from the Pack,
+// or from the Container if the Pack is nil
+func (v *Value) get(hash cipher.SHA256) (val []byte) {
+	if v.p != nil {
+		val, _ = v.p.get(hash)
+		return
+	}
+	return v.c.Get(hash)
+}
+
+// Schema of the Value
+func (v *Value) Schema() Schema {
+	return v.sch
+}
+
+// Kind of the Value
+func (v *Value) Kind() reflect.Kind {
+	return v.sch.Kind()
+}
+
+// Encoded returns encoded value, that must not be modified
+func (v *Value) Encoded() []byte {
+	return v.val
+}
+
+// String implements fmt.Stringer interface
+// and returns string of the Schema of the Value.
+// Use Str to get value of a string
+func (v *Value) String() string {
+	return "Value of " + v.sch.String()
+}
+
+func (v *Value) kindError(want string) error {
+	return fmt.Errorf("can't get %s of %s", want, v.sch.String())
+}
+
+func (v *Value) decode(x interface{}) (err error) {
+	if err = encoder.DeserializeRaw(v.val, x); err != nil {
+		err = fmt.Errorf("can't decode %s: %v", v.sch.String(), err)
+	}
+	return
+}
+
+// Bool returns value of boolean
+func (v *Value) Bool() (x bool, err error) {
+	if v.sch.IsReference() || v.Kind() != reflect.Bool {
+		return false, v.kindError("bool")
+	}
+	err = v.decode(&x)
+	return
+}
+
+// Int returns value of an int8, int16, int32 or int64
+func (v *Value) Int() (x int64, err error) {
+	if v.sch.IsReference() {
+		return 0, v.kindError("int")
+	}
+	switch v.Kind() {
+	case reflect.Int8:
+		var y int8
+		err = v.decode(&y)
+		x = int64(y)
+	case reflect.Int16:
+		var y int16
+		err = v.decode(&y)
+		x = int64(y)
+	case reflect.Int32:
+		var y int32
+		err = v.decode(&y)
+		x = int64(y)
+	case reflect.Int64:
+		err = v.decode(&x)
+	default:
+		err = v.kindError("int")
+	}
+	return
+}
+
+// Uint returns value of an uint8, uint16, uint32 or uint64
+func (v *Value) Uint() (x uint64, err error) {
+	if v.sch.IsReference() {
+		return 0, v.kindError("uint")
+	}
+	switch v.Kind() {
+	case reflect.Uint8:
+		var y uint8
+		err = v.decode(&y)
+		x = uint64(y)
+	case reflect.Uint16:
+		var y uint16
+		err = v.decode(&y)
+		x = uint64(y)
+	case reflect.Uint32:
+		var y uint32
+		err = v.decode(&y)
+		x = uint64(y)
+	case reflect.Uint64:
+		err = v.decode(&x)
+	default:
+		err = v.kindError("uint")
+	}
+	return
+}
+
+// Float returns value of a float32 or float64
+func (v *Value) Float() (x float64, err error) {
+	if v.sch.IsReference() {
+		return 0, v.kindError("float")
+	}
+	switch v.Kind() {
+	case reflect.Float32:
+		var y float32
+		err = v.decode(&y)
+		x = float64(y)
+	case reflect.Float64:
+		err = v.decode(&x)
+	default:
+		err = v.kindError("float")
+	}
+	return
+}
+
+// Str returns value of a string
+func (v *Value) Str() (x string, err error) {
+	if v.sch.IsReference() || v.Kind() != reflect.String {
+		return "", v.kindError("string")
+	}
+	err = v.decode(&x)
+	return
+}
+
+// isBytes returns true if the Value is
+// array or slice of bytes
+func (v *Value) isBytes() bool {
+	if v.sch.IsReference() {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice:
+		el := v.sch.Elem()
+		return el != nil && !el.IsReference() && el.Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// Bytes returns copy of value of a slice
+// or an array of bytes
+func (v *Value) Bytes() (x []byte, err error) {
+	if !v.isBytes() {
+		return nil, v.kindError("bytes")
+	}
+	if v.Kind() == reflect.Array {
+		if len(v.val) < v.sch.Len() {
+			return nil, ErrInvalidSchemaOrData
+		}
+		return append([]byte{}, v.val[:v.sch.Len()]...), nil
+	}
+	err = v.decode(&x)
+	return
+}
+
+// Len returns length of an array, a slice
+// or a Refs. Length of a Refs is amount of
+// its non-blank elements
+func (v *Value) Len() (ln int, err error) {
+	if v.sch.IsReference() {
+		if v.sch.ReferenceType() != ReferenceTypeSlice {
+			return 0, v.kindError("length")
+		}
+		var er *encodedRefs
+		if er, err = v.refs(); err != nil || er == nil {
+			return
+		}
+		return int(er.Length), nil
+	}
+	switch v.Kind() {
+	case reflect.Array:
+		ln = v.sch.Len()
+	case reflect.Slice:
+		ln, err = getLength(v.val)
+	default:
+		err = v.kindError("length")
+	}
+	return
+}
+
+// Index returns element of an array or a slice by
+// index. For a Refs it returns referenced object
+func (v *Value) Index(i int) (el *Value, err error) {
+	var ln int
+	if ln, err = v.Len(); err != nil {
+		return
+	}
+	if err = validateIndex(i, ln); err != nil {
+		return
+	}
+	if v.sch.IsReference() {
+		var hash cipher.SHA256
+		if hash, err = v.refsIndex(i); err != nil {
+			return
+		}
+		return v.object(v.sch.Elem(), hash)
+	}
+	es := v.sch.Elem()
+	if es == nil {
+		return nil, ErrInvalidSchema
+	}
+	shift := 0
+	if v.Kind() == reflect.Slice {
+		shift = 4 // encoded length
+	}
+	var n int
+	if s := fixedSize(es.Kind()); s > 0 && !es.IsReference() {
+		shift += i * s
+	} else {
+		for j := 0; j < i; j++ {
+			if shift > len(v.val) {
+				return nil, ErrInvalidSchemaOrData
+			}
+			if n, err = es.Size(v.val[shift:]); err != nil {
+				return
+			}
+			shift += n
+		}
+	}
+	if shift > len(v.val) {
+		return nil, ErrInvalidSchemaOrData
+	}
+	if n, err = es.Size(v.val[shift:]); err != nil {
+		return
+	}
+	return v.sub(es, v.val[shift:shift+n]), nil
+}
+
+// Fields returns names of fields of a struct
+func (v *Value) Fields() (names []string) {
+	if v.sch.IsReference() || v.Kind() != reflect.Struct {
+		return
+	}
+	for _, f := range v.sch.Fields() {
+		names = append(names, f.Name())
+	}
+	return
+}
+
+// FieldByName returns field of a struct by name
+func (v *Value) FieldByName(name string) (fv *Value, err error) {
+	if v.sch.IsReference() || v.Kind() != reflect.Struct {
+		return nil, v.kindError("field")
+	}
+	var shift, n int
+	for _, f := range v.sch.Fields() {
+		if shift > len(v.val) {
+			return nil, ErrInvalidSchemaOrData
+		}
+		if n, err = f.Schema().Size(v.val[shift:]); err != nil {
+			return
+		}
+		if f.Name() == name {
+			return v.sub(f.Schema(), v.val[shift:shift+n]), nil
+		}
+		shift += n
+	}
+	return nil, ErrNoSuchField
+}
+
+// IsNil returns true if the Value is nil reference
+func (v *Value) IsNil() (yep bool, err error) {
+	var hash cipher.SHA256
+	if hash, err = v.Hash(); err != nil {
+		return
+	}
+	return hash == (cipher.SHA256{}), nil
+}
+
+// Hash returns hash of object referenced
+// by a Ref, a Refs or a Dynamic
+func (v *Value) Hash() (hash cipher.SHA256, err error) {
+	if !v.sch.IsReference() {
+		return hash, v.kindError("hash")
+	}
+	switch rt := v.sch.ReferenceType(); rt {
+	case ReferenceTypeSingle:
+		var ref Ref
+		err = v.decode(&ref)
+		hash = ref.Hash
+	case ReferenceTypeSlice:
+		var refs Refs
+		err = v.decode(&refs)
+		hash = refs.Hash
+	case ReferenceTypeDynamic:
+		var dr Dynamic
+		err = v.decode(&dr)
+		hash = dr.Object
+	default:
+		err = fmt.Errorf("invalid ReferenceType %d", rt)
+	}
+	return
+}
+
+// Dereference returns object referenced by a Ref or
+// a Dynamic. It returns (nil, nil) if the reference is
+// nil. Use Index to get elements of a Refs
+func (v *Value) Dereference() (obj *Value, err error) {
+	if !v.sch.IsReference() {
+		return nil, v.kindError("referenced object")
+	}
+	switch v.sch.ReferenceType() {
+	case ReferenceTypeSingle:
+		var ref Ref
+		if err = v.decode(&ref); err != nil {
+			return
+		}
+		return v.object(v.sch.Elem(), ref.Hash)
+	case ReferenceTypeDynamic:
+		var dr Dynamic
+		if err = v.decode(&dr); err != nil {
+			return
+		}
+		if dr.IsBlank() {
+			return
+		}
+		if !dr.IsValid() {
+			return nil, ErrInvalidDynamicReference
+		}
+		if v.reg == nil {
+			return nil, ErrEmptyRegsitryRef
+		}
+		var sch Schema
+		if sch, err = v.reg.SchemaByReference(dr.SchemaRef); err != nil {
+			return
+		}
+		return v.object(sch, dr.Object)
+	}
+	return nil, v.kindError("referenced object")
+}
+
+// object returns Value of object by hash, or
+// nil if the hash is blank
+func (v *Value) object(sch Schema, hash cipher.SHA256) (*Value, error) {
+	if hash == (cipher.SHA256{}) {
+		return nil, nil
+	}
+	if sch == nil {
+		return nil, ErrInvalidSchema
+	}
+	val := v.get(hash)
+	if val == nil {
+		return nil, fmt.Errorf("missing object %s", hash.Hex()[:7])
+	}
+	return v.sub(sch, val), nil
+}
+
+// refs returns decoded Refs, the er is
+// nil if the Refs is blank
+func (v *Value) refs() (er *encodedRefs, err error) {
+	var refs Refs
+	if err = v.decode(&refs); err != nil || refs.IsBlank() {
+		return
+	}
+	er = new(encodedRefs)
+	err = v.node(refs.Hash, er)
+	return
+}
+
+// node decodes encoded Refs or
+// encoded node of Refs by hash
+func (v *Value) node(hash cipher.SHA256, x interface{}) (err error) {
+	val := v.get(hash)
+	if val == nil {
+		return fmt.Errorf("missing object %s", hash.Hex()[:7])
+	}
+	return encoder.DeserializeRaw(val, x)
+}
+
+// refsIndex returns hash of element of the Refs by index,
+// the index must be valid
+func (v *Value) refsIndex(i int) (hash cipher.SHA256, err error) {
+	var er *encodedRefs
+	if er, err = v.refs(); err != nil {
+		return
+	}
+	depth, nested := er.Depth, er.Nested
+	for ; depth > 0; depth-- {
+		var found bool
+		for _, h := range nested {
+			if h == (cipher.SHA256{}) {
+				continue // not missing, not nil: zero
+			}
+			var ern encodedRefsNode
+			if err = v.node(h, &ern); err != nil {
+				return
+			}
+			if ln := int(ern.Length); i >= ln {
+				i -= ln
+				continue
+			}
+			nested, found = ern.Nested, true
+			break
+		}
+		if !found {
+			err = fmt.Errorf("malformed Refs %s: index not found in branches",
+				v.sch.String())
+			return
+		}
+	}
+	if i >= len(nested) {
+		err = fmt.Errorf("malformed Refs %s: not enough leafs",
+			v.sch.String())
+		return
+	}
+	return nested[i], nil
+}
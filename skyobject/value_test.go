@@ -0,0 +1,267 @@
+package skyobject
+
+import (
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+type Tuple struct {
+	Bytes  []byte
+	Hash   cipher.SHA256
+	Ints   []int16
+	Names  []string
+	Float  float32
+	Flag   bool
+	Signed int64
+}
+
+func testValueField(t *testing.T, v *Value, name string) *Value {
+	fv, err := v.FieldByName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fv
+}
+
+// testValueContainer returns Container with saved Root that contains
+// Group with Refs of three users, Tuple and blank reference. The Refs
+// has branches. Use closeFunc to close the Container and its DB
+func testValueContainer(t *testing.T) (c *Container, pack *Pack,
+	closeFunc func()) {
+
+	conf := getConf()
+	conf.Registry = NewRegistry(func(r *Reg) {
+		r.Register("cxo.User", User{})
+		r.Register("cxo.Group", Group{})
+		r.Register("cxo.Tuple", Tuple{})
+	})
+	conf.MerkleDegree = 2 // Refs with branches
+
+	db := data.NewMemoryDB()
+	c = NewContainer(db, conf)
+	closeFunc = func() {
+		c.Close()
+		db.Close()
+	}
+
+	pk, sk := cipher.GenerateKeyPair()
+	if err := c.AddFeed(pk); err != nil {
+		t.Fatal(err)
+	}
+	pack, err := c.NewRoot(pk, sk, 0, c.CoreRegistry().Types())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pack.Append(
+		Group{
+			Name:   "group",
+			Leader: pack.Ref(User{"Alice", 21, nil}),
+			Members: pack.Refs(
+				User{"Alice", 21, nil},
+				User{"Bob", 32, nil},
+				User{"Eva", 19, nil},
+			),
+			Curator: pack.Dynamic(User{"Curator", 40, nil}),
+		},
+		Tuple{
+			Bytes:  []byte("bytes"),
+			Hash:   cipher.SumSHA256([]byte("hash")),
+			Ints:   []int16{-1, 0, 1},
+			Names:  []string{"one", "two", "three"},
+			Float:  1.5,
+			Flag:   true,
+			Signed: -100,
+		},
+		nil,
+	)
+	if _, err = pack.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestContainer_RootValues(t *testing.T) {
+	// RootValues(r *Root) (vals []*Value, err error)
+
+	c, pack, closeFunc := testValueContainer(t)
+	defer closeFunc()
+
+	// the Values don't need Go types
+	vals, err := c.RootValues(pack.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vals) != 3 {
+		t.Fatal("wrong amount of values:", len(vals))
+	}
+
+	t.Run("nil root", func(t *testing.T) {
+		if _, err := c.RootValues(nil); err != ErrInvalidArgument {
+			t.Error("wrong error:", err)
+		}
+	})
+
+	t.Run("blank", func(t *testing.T) {
+		if yep, err := vals[2].IsNil(); err != nil {
+			t.Fatal(err)
+		} else if !yep {
+			t.Error("not nil")
+		}
+		if obj, err := vals[2].Dereference(); err != nil {
+			t.Error(err)
+		} else if obj != nil {
+			t.Error("got object of blank reference")
+		}
+	})
+
+	group, err := vals[0].Dereference()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("struct", func(t *testing.T) {
+		if group.Schema().Name() != "cxo.Group" {
+			t.Error("wrong schema:", group.Schema())
+		}
+		want := []string{"Name", "Leader", "Members", "Curator"}
+		if got := group.Fields(); len(got) != len(want) {
+			t.Errorf("wrong fields: %q", got)
+		}
+		if name, err := testValueField(t, group, "Name").Str(); err != nil {
+			t.Error(err)
+		} else if name != "group" {
+			t.Error("wrong name:", name)
+		}
+		if _, err := group.FieldByName("Unknown"); err != ErrNoSuchField {
+			t.Error("unexpected error:", err)
+		}
+		if _, err := group.Int(); err == nil {
+			t.Error("missing error")
+		}
+	})
+
+	t.Run("Ref", func(t *testing.T) {
+		leader, err := testValueField(t, group, "Leader").Dereference()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name, err := testValueField(t, leader, "Name").Str(); err != nil {
+			t.Error(err)
+		} else if name != "Alice" {
+			t.Error("wrong name:", name)
+		}
+		if age, err := testValueField(t, leader, "Age").Uint(); err != nil {
+			t.Error(err)
+		} else if age != 21 {
+			t.Error("wrong age:", age)
+		}
+	})
+
+	t.Run("Refs", func(t *testing.T) {
+		members := testValueField(t, group, "Members")
+		ln, err := members.Len()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ln != 3 {
+			t.Fatal("wrong length:", ln)
+		}
+		for i, want := range []string{"Alice", "Bob", "Eva"} {
+			member, err := members.Index(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name, err := testValueField(t, member, "Name").Str()
+			if err != nil {
+				t.Error(err)
+			} else if name != want {
+				t.Error("wrong name:", name)
+			}
+		}
+		if _, err = members.Index(ln); err == nil {
+			t.Error("missing error")
+		}
+		if _, err = members.Dereference(); err == nil {
+			t.Error("missing error")
+		}
+	})
+
+	t.Run("Dynamic", func(t *testing.T) {
+		curator, err := testValueField(t, group, "Curator").Dereference()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name, err := testValueField(t, curator, "Name").Str(); err != nil {
+			t.Error(err)
+		} else if name != "Curator" {
+			t.Error("wrong name:", name)
+		}
+	})
+
+	t.Run("scalars", func(t *testing.T) {
+		tv, err := vals[1].Dereference()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, err := testValueField(t, tv, "Bytes").Bytes(); err != nil {
+			t.Error(err)
+		} else if string(b) != "bytes" {
+			t.Errorf("wrong bytes %q", b)
+		}
+		hash := cipher.SumSHA256([]byte("hash"))
+		if b, err := testValueField(t, tv, "Hash").Bytes(); err != nil {
+			t.Error(err)
+		} else if string(b) != string(hash[:]) {
+			t.Error("wrong hash")
+		}
+		ints := testValueField(t, tv, "Ints")
+		for i, want := range []int16{-1, 0, 1} {
+			el, err := ints.Index(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := el.Int(); err != nil {
+				t.Error(err)
+			} else if got != int64(want) {
+				t.Errorf("wrong int %d: %d", i, got)
+			}
+		}
+		names := testValueField(t, tv, "Names")
+		if el, err := names.Index(2); err != nil {
+			t.Error(err)
+		} else if got, err := el.Str(); err != nil {
+			t.Error(err)
+		} else if got != "three" {
+			t.Error("wrong name:", got)
+		}
+		if f, err := testValueField(t, tv, "Float").Float(); err != nil {
+			t.Error(err)
+		} else if f != 1.5 {
+			t.Error("wrong float:", f)
+		}
+		if flag, err := testValueField(t, tv, "Flag").Bool(); err != nil {
+			t.Error(err)
+		} else if !flag {
+			t.Error("wrong flag")
+		}
+		if s, err := testValueField(t, tv, "Signed").Int(); err != nil {
+			t.Error(err)
+		} else if s != -100 {
+			t.Error("wrong int:", s)
+		}
+		if _, err := testValueField(t, tv, "Signed").Uint(); err == nil {
+			t.Error("missing error")
+		}
+	})
+
+	t.Run("empty registry", func(t *testing.T) {
+		if _, err := c.RootValues(&Root{}); err != ErrEmptyRegsitryRef {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+}
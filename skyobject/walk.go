@@ -0,0 +1,612 @@
+package skyobject
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+)
+
+// ErrSkipNode can be returned by a Pre hook of a Visitor
+// to skip subtree of current node. The Post hook of the
+// node is not called in this case
+var ErrSkipNode = errors.New("skip node")
+
+// A NodeKind represents kind of a WalkNode
+type NodeKind int
+
+// kinds of nodes
+const (
+	// NodeAny is not a kind of a node. It's used as key of
+	// hooks of a Visitor to catch nodes of all other kinds
+	NodeAny NodeKind = iota
+	// NodeDynamic is a Dynamic reference
+	NodeDynamic
+	// NodeRef is a Ref or an element of a Refs
+	NodeRef
+	// NodeRefs is a Refs
+	NodeRefs
+	// NodeRefsBranch is a branch of a Refs
+	NodeRefsBranch
+	// NodeStruct is a struct
+	NodeStruct
+	// NodeArray is an array, except array of bytes
+	NodeArray
+	// NodeSlice is a slice, except slice of bytes
+	NodeSlice
+	// NodeData is a bool, a number, a string or bytes
+	NodeData
+)
+
+// String implements fmt.Stringer interface
+func (k NodeKind) String() string {
+	switch k {
+	case NodeAny:
+		return "any"
+	case NodeDynamic:
+		return "Dynamic"
+	case NodeRef:
+		return "Ref"
+	case NodeRefs:
+		return "Refs"
+	case NodeRefsBranch:
+		return "branch"
+	case NodeStruct:
+		return "struct"
+	case NodeArray:
+		return "array"
+	case NodeSlice:
+		return "slice"
+	case NodeData:
+		return "data"
+	}
+	return fmt.Sprintf("NodeKind<%d>", k)
+}
+
+// A WalkNode represents a node of a tree of a Root. Nodes of
+// references (NodeDynamic, NodeRef and NodeRefs) have Hash of
+// referenced object and the object is the only child of such
+// node. Elements of a Refs are children of the Refs or of its
+// branches. For a branch the Hash is hash of the branch and
+// the Value is the Refs. First value of an object has Hash of the
+// object. Other nodes have blank Hash.
+//
+// The Depth is number of objects above the node. The Index is
+// index of an element of the Refs of the Root, of a Refs, of an
+// array or a slice, or -1. For a branch the Index is index of
+// its first element. A branch is loaded after its Pre hook, and
+// its Index is -1 in the Pre hook and in the Missing function.
+// The Field is name of a field of a struct. A WalkNode must not
+// be modified
+type WalkNode struct {
+	Kind   NodeKind
+	Value  *Value
+	Hash   cipher.SHA256
+	Depth  int
+	Index  int
+	Field  string
+	Parent *WalkNode // nil for the Refs of the Root
+
+	el    Schema        // schema of elements of a Refs (branch)
+	depth int           // depth of a branch
+	sib   *walkBranches // the branch and its siblings
+	pos   int           // position of the branch in the sib
+}
+
+// A walkBranches represents sibling branches of a Refs. Lengths
+// of the branches are used to compute indices of elements and are
+// loaded lazily
+type walkBranches struct {
+	mx     sync.Mutex
+	index  int             // index of first element of the branches
+	hashes []cipher.SHA256 // the branches
+	lens   map[int]int     // known lengths by position
+}
+
+// Path of the node in the same form the Query uses,
+// for example "Refs[0].Members[2].Name"
+func (n *WalkNode) Path() string {
+	if n.Parent == nil {
+		return "Refs[" + strconv.Itoa(n.Index) + "]"
+	}
+	path := n.Parent.Path()
+	switch {
+	case n.Field != "":
+		return path + "." + n.Field
+	case n.Kind == NodeRefsBranch, n.Index < 0:
+		return path
+	}
+	return path + "[" + strconv.Itoa(n.Index) + "]"
+}
+
+// A WalkFunc is a hook of a Visitor. It can return ErrSkipNode
+// (from a Pre hook) to skip subtree of given node, or
+// ErrStopIteration to stop walking
+type WalkFunc func(n *WalkNode) (err error)
+
+// A MissingFunc is called by Walk for a reference or a branch
+// of a Refs if referenced object not found in database. The
+// function can return the object (requesting it from a remote
+// peer, for example), nil to skip the subtree or an error to
+// stop walking
+type MissingFunc func(n *WalkNode) (val []byte, err error)
+
+// A Visitor represents hooks of Walk. Pre hooks called before
+// children of a node, and Post hooks called after. Hooks are
+// chosen by kind of a node. The NodeAny hook used for kinds
+// without hooks.
+//
+// If Missing is nil, then Walk fails on first missing object.
+// If the Workers greater than 1, then up to Workers goroutines
+// walk elements of lists (of the Root, of Refs, of slices and
+// arrays) and hooks must be safe for concurrent use. Post hook
+// of a node called after all its children in any case.
+//
+// If there are no hooks for data nodes (struct, array, slice
+// and data), then Walk doesn't create such nodes. And if the
+// Missing is nil too, then Walk doesn't load objects without
+// references
+type Visitor struct {
+	Pre     map[NodeKind]WalkFunc
+	Post    map[NodeKind]WalkFunc
+	Missing MissingFunc
+	Workers int
+}
+
+func (v *Visitor) hasDataHooks() bool {
+	for _, hooks := range []map[NodeKind]WalkFunc{v.Pre, v.Post} {
+		for kind := range hooks {
+			if kind == NodeAny || kind >= NodeStruct {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Walk walks through objects of given Root, depth-first and in
+// order. It returns first error of hooks except ErrStopIteration
+// and ErrSkipNode. The Root must have Registry. The Registry
+// loaded from database if it's not loaded yet
+func (c *Container) Walk(r *Root, vis *Visitor) (err error) {
+	if r.Reg == (RegistryRef{}) {
+		return ErrEmptyRegsitryRef
+	}
+	reg := c.registryOf(r.Reg, c)
+	if reg == nil {
+		return fmt.Errorf("missing Registry [%s]", r.Reg.Short())
+	}
+	w := &walker{
+		c:       c,
+		g:       c,
+		reg:     reg,
+		vis:     vis,
+		missing: vis.Missing,
+		prune:   !vis.hasDataHooks(),
+		fetch:   vis.Missing != nil,
+	}
+	if w.missing == nil {
+		w.missing = func(n *WalkNode) ([]byte, error) {
+			return nil, fmt.Errorf("missing object %s", n.Hash.Hex()[:7])
+		}
+	}
+	if vis.Workers > 1 {
+		w.sem = make(chan struct{}, vis.Workers-1)
+	}
+	if err = w.root(r); err == ErrStopIteration {
+		err = nil
+	}
+	return
+}
+
+type walker struct {
+	c       *Container
+	g       getter
+	reg     *Registry
+	vis     *Visitor
+	missing MissingFunc
+	prune   bool // skip values without references
+	fetch   bool // load objects without references
+
+	sem chan struct{} // workers (nil if no workers)
+	mx  sync.Mutex
+	err error // first error of a worker
+}
+
+func (w *walker) root(r *Root) (err error) {
+	ns := make([]*WalkNode, 0, len(r.Refs))
+	for i, dr := range r.Refs {
+		if !dr.IsValid() {
+			return fmt.Errorf("invalid dynamic %s", dr.Short())
+		}
+		ns = append(ns, &WalkNode{
+			Kind:  NodeDynamic,
+			Value: w.c.NewValue(w.reg, rootRefSchema, encoder.Serialize(dr)),
+			Hash:  dr.Object,
+			Index: i,
+		})
+	}
+	return w.children(ns)
+}
+
+func (w *walker) fail(err error) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *walker) failed() error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	return w.err
+}
+
+// children walks given nodes using
+// free workers if any
+func (w *walker) children(ns []*WalkNode) (err error) {
+	var wg sync.WaitGroup
+	for _, n := range ns {
+		if w.sem != nil {
+			select {
+			case w.sem <- struct{}{}:
+				wg.Add(1)
+				go func(n *WalkNode) {
+					defer wg.Done()
+					if err := w.node(n); err != nil {
+						w.fail(err)
+					}
+					<-w.sem
+				}(n)
+				continue
+			default:
+			}
+		}
+		if err = w.node(n); err != nil {
+			break
+		}
+	}
+	if w.sem == nil {
+		return
+	}
+	if err != nil {
+		w.fail(err) // stop workers
+	}
+	wg.Wait()
+	if err == nil {
+		err = w.failed()
+	}
+	return
+}
+
+func (w *walker) call(hooks map[NodeKind]WalkFunc, n *WalkNode) error {
+	fn, ok := hooks[n.Kind]
+	if !ok {
+		fn = hooks[NodeAny]
+	}
+	if fn == nil {
+		return nil
+	}
+	return fn(n)
+}
+
+func (w *walker) node(n *WalkNode) (err error) {
+	if w.sem != nil {
+		if err = w.failed(); err != nil {
+			return
+		}
+	}
+	if err = w.call(w.vis.Pre, n); err != nil {
+		if err == ErrSkipNode {
+			err = nil
+		}
+		return
+	}
+	var ns []*WalkNode
+	if ns, err = w.expand(n); err != nil {
+		return
+	}
+	if err = w.children(ns); err != nil {
+		return
+	}
+	if err = w.call(w.vis.Post, n); err == ErrSkipNode {
+		err = nil
+	}
+	return
+}
+
+// get object of given node calling
+// the missing function if not found
+func (w *walker) get(n *WalkNode) (val []byte, err error) {
+	if val = w.g.Get(n.Hash); val == nil {
+		val, err = w.missing(n)
+	}
+	return
+}
+
+func (w *walker) expand(n *WalkNode) ([]*WalkNode, error) {
+	switch n.Kind {
+	case NodeDynamic, NodeRef:
+		return w.object(n)
+	case NodeRefs:
+		return w.refs(n)
+	case NodeRefsBranch:
+		return w.branch(n)
+	case NodeStruct:
+		return w.fields(n)
+	case NodeArray, NodeSlice:
+		return w.elems(n)
+	}
+	return nil, nil // data
+}
+
+// value creates node of given value, the node
+// is nil if the value should be skipped
+func (w *walker) value(parent *WalkNode, sch Schema, val []byte,
+	field string, index int) (n *WalkNode, err error) {
+
+	if w.prune && !sch.HasReferences() {
+		return
+	}
+	n = &WalkNode{
+		Value:  parent.Value.sub(sch, val),
+		Depth:  parent.Depth,
+		Index:  index,
+		Field:  field,
+		Parent: parent,
+	}
+	if sch.IsReference() {
+		switch rt := sch.ReferenceType(); rt {
+		case ReferenceTypeSingle:
+			var ref Ref
+			err = encoder.DeserializeRaw(val, &ref)
+			n.Kind, n.Hash = NodeRef, ref.Hash
+		case ReferenceTypeSlice:
+			var refs Refs
+			err = encoder.DeserializeRaw(val, &refs)
+			n.Kind, n.Hash = NodeRefs, refs.Hash
+		case ReferenceTypeDynamic:
+			var dr Dynamic
+			if err = encoder.DeserializeRaw(val, &dr); err == nil &&
+				!dr.IsValid() {
+
+				err = fmt.Errorf("invalid dynamic %s", dr.Short())
+			}
+			n.Kind, n.Hash = NodeDynamic, dr.Object
+		default:
+			err = fmt.Errorf("reference with invalid ReferenceType: %d", rt)
+		}
+		if err != nil {
+			n = nil
+		}
+		return
+	}
+	switch n.Kind = NodeData; sch.Kind() {
+	case reflect.Struct:
+		n.Kind = NodeStruct
+	case reflect.Array, reflect.Slice:
+		if n.Value.isBytes() {
+			break
+		}
+		if n.Kind = NodeArray; sch.Kind() == reflect.Slice {
+			n.Kind = NodeSlice
+		}
+	}
+	return
+}
+
+// object of a Ref or a Dynamic
+func (w *walker) object(n *WalkNode) (ns []*WalkNode, err error) {
+	if n.Hash == (cipher.SHA256{}) {
+		return // nil
+	}
+	var sch Schema
+	if n.Kind == NodeDynamic {
+		var dr Dynamic
+		if err = n.Value.decode(&dr); err != nil {
+			return
+		}
+		if sch, err = w.reg.SchemaByReference(dr.SchemaRef); err != nil {
+			return
+		}
+	} else if sch = n.Value.Schema().Elem(); sch == nil {
+		err = fmt.Errorf("schema of Ref [%s] without element: %s",
+			n.Hash.Hex()[:7], n.Value.Schema())
+		return
+	}
+	if w.prune && !w.fetch && !sch.HasReferences() {
+		return
+	}
+	var val []byte
+	if val, err = w.get(n); err != nil || val == nil {
+		return
+	}
+	var obj *WalkNode
+	if obj, err = w.value(n, sch, val, "", -1); err != nil || obj == nil {
+		return
+	}
+	obj.Hash, obj.Depth = n.Hash, n.Depth+1
+	return []*WalkNode{obj}, nil
+}
+
+func (w *walker) refs(n *WalkNode) (ns []*WalkNode, err error) {
+	if n.Hash == (cipher.SHA256{}) {
+		return // blank
+	}
+	el := n.Value.Schema().Elem()
+	if el == nil {
+		err = fmt.Errorf("schema of Refs [%s] without element: %s",
+			n.Hash.Hex()[:7], n.Value.Schema())
+		return
+	}
+	var val []byte
+	if val, err = w.get(n); err != nil || val == nil {
+		return
+	}
+	var er encodedRefs
+	if err = encoder.DeserializeRaw(val, &er); err != nil {
+		return
+	}
+	return w.nested(n, el, int(er.Depth), er.Nested, 0)
+}
+
+func (w *walker) branch(n *WalkNode) (ns []*WalkNode, err error) {
+	var val []byte
+	if val, err = w.get(n); err != nil || val == nil {
+		return
+	}
+	var ern encodedRefsNode
+	if err = encoder.DeserializeRaw(val, &ern); err != nil {
+		return
+	}
+	if n.Index, err = w.branchIndex(n, int(ern.Length)); err != nil {
+		return
+	}
+	return w.nested(n, n.el, n.depth, ern.Nested, n.Index)
+}
+
+// branchIndex saves length of given branch and returns index of its
+// first element. Lengths of previous branches skipped by the walking
+// are loaded from database, missing branches have no elements
+func (w *walker) branchIndex(n *WalkNode, ln int) (index int, err error) {
+	sib := n.sib
+
+	sib.mx.Lock()
+	defer sib.mx.Unlock()
+
+	sib.lens[n.pos] = ln
+	index = sib.index
+	for i, hash := range sib.hashes[:n.pos] {
+		ln, ok := sib.lens[i]
+		if !ok {
+			if val := w.g.Get(hash); val != nil {
+				var ern encodedRefsNode
+				if err = encoder.DeserializeRaw(val, &ern); err != nil {
+					return
+				}
+				ln = int(ern.Length)
+			}
+			sib.lens[i] = ln
+		}
+		index += ln
+	}
+	return
+}
+
+// nested creates nodes of branches or elements of a Refs,
+// the branches are not loaded
+func (w *walker) nested(parent *WalkNode, el Schema, depth int,
+	hashes []cipher.SHA256, index int) (ns []*WalkNode, err error) {
+
+	var sib *walkBranches
+	if depth > 0 {
+		sib = &walkBranches{index: index, lens: make(map[int]int)}
+	}
+
+	var sch Schema
+	if depth == 0 {
+		sch = &referenceSchema{
+			schema: schema{kind: typeOfRef.Kind()},
+			typ:    ReferenceTypeSingle,
+			elem:   el,
+		}
+	}
+	for _, hash := range hashes {
+		if hash == (cipher.SHA256{}) {
+			continue // zero
+		}
+		if depth == 0 {
+			ns = append(ns, &WalkNode{
+				Kind: NodeRef,
+				Value: parent.Value.sub(sch,
+					encoder.Serialize(Ref{Hash: hash})),
+				Hash:   hash,
+				Depth:  parent.Depth,
+				Index:  index,
+				Parent: parent,
+			})
+			index++
+			continue
+		}
+		ns = append(ns, &WalkNode{
+			Kind:   NodeRefsBranch,
+			Value:  parent.Value,
+			Hash:   hash,
+			Depth:  parent.Depth,
+			Index:  -1, // unknown
+			Parent: parent,
+			el:     el,
+			depth:  depth - 1,
+			sib:    sib,
+			pos:    len(sib.hashes),
+		})
+		sib.hashes = append(sib.hashes, hash)
+	}
+	return
+}
+
+func (w *walker) fields(n *WalkNode) (ns []*WalkNode, err error) {
+	val := n.Value.Encoded()
+	var shift, s int
+	for _, fl := range n.Value.Schema().Fields() {
+		if shift > len(val) {
+			err = fmt.Errorf("unexpected end of encoded struct <%s>, "+
+				"field %q", n.Value.Schema(), fl.Name())
+			return
+		}
+		if s, err = fl.Schema().Size(val[shift:]); err != nil {
+			return
+		}
+		var fv *WalkNode
+		fv, err = w.value(n, fl.Schema(), val[shift:shift+s], fl.Name(), -1)
+		if err != nil {
+			return
+		}
+		if fv != nil {
+			ns = append(ns, fv)
+		}
+		shift += s
+	}
+	return
+}
+
+func (w *walker) elems(n *WalkNode) (ns []*WalkNode, err error) {
+	sch, val := n.Value.Schema(), n.Value.Encoded()
+	el := sch.Elem()
+	if el == nil {
+		err = fmt.Errorf("nil schema of element of %s", sch)
+		return
+	}
+	ln := sch.Len()
+	if n.Kind == NodeSlice {
+		if ln, err = getLength(val); err != nil {
+			return
+		}
+		val = val[4:]
+	}
+	var shift, m int
+	for i := 0; i < ln; i++ {
+		if shift > len(val) {
+			err = fmt.Errorf("unexpected end of encoded %s, index: %d",
+				sch, i)
+			return
+		}
+		if m, err = el.Size(val[shift:]); err != nil {
+			return
+		}
+		var en *WalkNode
+		if en, err = w.value(n, el, val[shift:shift+m], "", i); err != nil {
+			return
+		}
+		if en != nil {
+			ns = append(ns, en)
+		}
+		shift += m
+	}
+	return
+}
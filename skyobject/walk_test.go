@@ -0,0 +1,290 @@
+package skyobject
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/skycoin/cxo/data"
+)
+
+func TestContainer_Walk(t *testing.T) {
+	// Walk(r *Root, vis *Visitor) (err error)
+
+	c, pack, closeFunc := testValueContainer(t)
+	defer closeFunc()
+
+	r := pack.Root()
+
+	t.Run("order", func(t *testing.T) {
+		var pre, post []string
+		err := c.Walk(r, &Visitor{
+			Pre: map[NodeKind]WalkFunc{
+				NodeAny: func(n *WalkNode) error {
+					pre = append(pre, n.Kind.String()+" "+n.Path())
+					return nil
+				},
+			},
+			Post: map[NodeKind]WalkFunc{
+				NodeRef: func(n *WalkNode) error {
+					post = append(post, n.Path())
+					return nil
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			"Dynamic Refs[0]",
+			"struct Refs[0]",
+			"data Refs[0].Name",
+			"Ref Refs[0].Leader",
+			"struct Refs[0].Leader",
+			"data Refs[0].Leader.Name",
+			"data Refs[0].Leader.Age",
+			"Refs Refs[0].Members",
+			"branch Refs[0].Members",
+			"branch Refs[0].Members",
+			"Ref Refs[0].Members[0]",
+		}
+		if len(pre) < len(want) {
+			t.Fatalf("too few nodes: %v", pre)
+		}
+		for i, w := range want {
+			if pre[i] != w {
+				t.Errorf("wrong node %d: want %q, got %q", i, w, pre[i])
+			}
+		}
+		want = []string{
+			"Refs[0].Leader",
+			"Refs[0].Members[0]",
+			"Refs[0].Members[1]",
+			"Refs[0].Members[2]",
+		}
+		if len(post) != len(want) {
+			t.Fatalf("wrong Post calls: %v", post)
+		}
+		for i, w := range want {
+			if post[i] != w {
+				t.Errorf("wrong Post %d: want %q, got %q", i, w, post[i])
+			}
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		var names []string
+		err := c.Walk(r, &Visitor{
+			Pre: map[NodeKind]WalkFunc{
+				NodeRefs: func(*WalkNode) error {
+					return ErrSkipNode
+				},
+				NodeStruct: func(n *WalkNode) error {
+					if n.Value.Schema().Name() != "cxo.User" {
+						return nil
+					}
+					name, err := testValueField(t, n.Value, "Name").Str()
+					names = append(names, name)
+					return err
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(names) != 2 || names[0] != "Alice" || names[1] != "Curator" {
+			t.Error("wrong names:", names)
+		}
+	})
+
+	t.Run("skip branch", func(t *testing.T) {
+		var skipped bool
+		var paths []string
+		err := c.Walk(r, &Visitor{
+			Pre: map[NodeKind]WalkFunc{
+				NodeRefsBranch: func(n *WalkNode) error {
+					if n.Index != -1 {
+						t.Error("index of branch is known before loading")
+					}
+					if !skipped && n.depth == 0 {
+						skipped = true
+						return ErrSkipNode // not loaded
+					}
+					return nil
+				},
+				NodeRef: func(n *WalkNode) error {
+					if n.Parent.Kind == NodeRefsBranch {
+						paths = append(paths, n.Path())
+					}
+					return nil
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(paths) != 1 || paths[0] != "Refs[0].Members[2]" {
+			t.Error("wrong elements:", paths)
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		var count int
+		stop := func(*WalkNode) error {
+			count++
+			return ErrStopIteration
+		}
+		err := c.Walk(r, &Visitor{Pre: map[NodeKind]WalkFunc{NodeAny: stop}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Error("walking is not stopped:", count)
+		}
+		errTest := errors.New("test error")
+		err = c.Walk(r, &Visitor{
+			Post: map[NodeKind]WalkFunc{
+				NodeData: func(*WalkNode) error { return errTest },
+			},
+		})
+		if err != errTest {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		group := r.Refs[0].Object
+		lost := *r
+		lost.Refs = append([]Dynamic{}, r.Refs...)
+		lost.Refs[0].Object = cipher.SumSHA256([]byte("lost"))
+
+		if err := c.Walk(&lost, &Visitor{}); err == nil {
+			t.Error("missing error")
+		}
+		var hashes []cipher.SHA256
+		var paths []string
+		err := c.Walk(&lost, &Visitor{
+			Pre: map[NodeKind]WalkFunc{
+				NodeStruct: func(n *WalkNode) error {
+					if n.Value.Schema().Name() == "cxo.Group" {
+						paths = append(paths, n.Path())
+					}
+					return nil
+				},
+			},
+			Missing: func(n *WalkNode) ([]byte, error) {
+				hashes = append(hashes, n.Hash)
+				return c.Get(group), nil // from "remote peer"
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hashes) != 1 || hashes[0] != lost.Refs[0].Object {
+			t.Error("wrong missing objects:", hashes)
+		}
+		if len(paths) != 1 || paths[0] != "Refs[0]" {
+			t.Error("missing object not walked:", paths)
+		}
+	})
+
+	t.Run("workers", func(t *testing.T) {
+		var mx sync.Mutex
+		seq := make(map[string]struct{})
+		par := make(map[string]struct{})
+		visit := func(m map[string]struct{}) WalkFunc {
+			return func(n *WalkNode) error {
+				mx.Lock()
+				defer mx.Unlock()
+				m[n.Kind.String()+" "+n.Path()] = struct{}{}
+				return nil
+			}
+		}
+		err := c.Walk(r, &Visitor{
+			Pre: map[NodeKind]WalkFunc{NodeAny: visit(seq)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.Walk(r, &Visitor{
+			Pre:     map[NodeKind]WalkFunc{NodeAny: visit(par)},
+			Workers: 4,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(seq) == 0 || len(seq) != len(par) {
+			t.Fatalf("wrong amount of nodes: %d, %d", len(seq), len(par))
+		}
+		for node := range seq {
+			if _, ok := par[node]; !ok {
+				t.Error("missing node", node)
+			}
+		}
+	})
+
+	t.Run("filler", func(t *testing.T) {
+		var rp *data.RootPack
+		c.DB().View(func(tx data.Tv) (_ error) {
+			rp = tx.Feeds().Roots(r.Pub).Get(r.Seq)
+			return
+		})
+		if rp == nil {
+			t.Fatal("missing root")
+		}
+
+		db := data.NewMemoryDB()
+		defer db.Close()
+		empty := NewContainer(db, getConf())
+		defer empty.Close()
+
+		if err := empty.AddFeed(r.Pub); err != nil {
+			t.Fatal(err)
+		}
+		er, err := empty.AddRoot(r.Pub, rp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wantq := make(chan WCXO)
+		fullq := make(chan *Root, 1)
+		dropq := make(chan DropRootError, 1)
+		var wg sync.WaitGroup
+		fl := empty.NewFiller(er, wantq, fullq, dropq, &wg)
+		defer fl.Close()
+
+		var requested int
+		for {
+			select {
+			case wcxo := <-wantq:
+				val := c.Get(wcxo.Hash)
+				if err := empty.Set(wcxo.Hash, val); err != nil {
+					t.Fatal(err)
+				}
+				wcxo.GotQ <- val
+				requested++
+				continue
+			case <-fullq:
+			case dr := <-dropq:
+				t.Fatal(dr.Err)
+			}
+			break
+		}
+		wg.Wait()
+		if requested == 0 {
+			t.Error("nothing requested")
+		}
+		if err := empty.Walk(er, &Visitor{}); err != nil {
+			t.Error("not filled:", err)
+		}
+	})
+
+	t.Run("empty registry", func(t *testing.T) {
+		if err := c.Walk(&Root{}, &Visitor{}); err != ErrEmptyRegsitryRef {
+			t.Error("unexpected error:", err)
+		}
+	})
+
+}